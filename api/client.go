@@ -13,6 +13,11 @@ import (
 
 const AuthCookieName = "token"
 
+// DefaultUserAgent is the User-Agent header sent by the client unless
+// another one is configured. Vault identifies the client libraries by
+// their User-Agent in the audit logs.
+const DefaultUserAgent = "vault-api-go"
+
 var (
 	errRedirect = errors.New("redirect")
 )
@@ -31,6 +36,10 @@ type Config struct {
 	// not work properly. If the jar is nil, a default empty cookie jar
 	// will be set.
 	HttpClient *http.Client
+
+	// UserAgent is the User-Agent header sent with every request, such as
+	// the name and version of the application using the client.
+	UserAgent string
 }
 
 // DefaultConfig returns a default configuration for the client. It is
@@ -42,6 +51,7 @@ func DefaultConfig() *Config {
 	config := &Config{
 		Address:    "https://127.0.0.1:8200",
 		HttpClient: &http.Client{},
+		UserAgent:  DefaultUserAgent,
 	}
 
 	if addr := os.Getenv("VAULT_ADDR"); addr != "" {
@@ -154,7 +164,8 @@ func (c *Client) NewRequest(method, path string) *Request {
 			Host:   c.addr.Host,
 			Path:   path,
 		},
-		Params: make(map[string][]string),
+		Params:    make(map[string][]string),
+		UserAgent: c.config.UserAgent,
 	}
 }
 
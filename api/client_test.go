@@ -169,3 +169,35 @@ func TestClientRedirect(t *testing.T) {
 		t.Fatalf("Bad: %s", buf.String())
 	}
 }
+
+func TestClientUserAgent(t *testing.T) {
+	var userAgent string
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		userAgent = req.Header.Get("User-Agent")
+	}
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := client.RawRequest(client.NewRequest("GET", "/")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if userAgent != DefaultUserAgent {
+		t.Fatalf("bad: %s", userAgent)
+	}
+
+	config.UserAgent = "foo/1.0"
+	client, err = NewClient(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := client.RawRequest(client.NewRequest("GET", "/")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if userAgent != "foo/1.0" {
+		t.Fatalf("bad: %s", userAgent)
+	}
+}
@@ -17,6 +17,9 @@ type Request struct {
 	Obj      interface{}
 	Body     io.Reader
 	BodySize int64
+
+	// UserAgent is sent as the User-Agent header, if set
+	UserAgent string
 }
 
 // SetJSONBody is used to set a request body that is a JSON-encoded value.
@@ -57,5 +60,9 @@ func (r *Request) ToHTTP() (*http.Request, error) {
 	req.URL.Host = r.URL.Host
 	req.Host = r.URL.Host
 
+	if r.UserAgent != "" {
+		req.Header.Set("User-Agent", r.UserAgent)
+	}
+
 	return req, nil
 }
@@ -40,7 +40,20 @@ func (c *Sys) EnableAudit(
 }
 
 func (c *Sys) DisableAudit(path string) error {
+	return c.disableAudit(path, false)
+}
+
+// ForceDisableAudit disables the audit backend even if it is the last
+// one remaining.
+func (c *Sys) ForceDisableAudit(path string) error {
+	return c.disableAudit(path, true)
+}
+
+func (c *Sys) disableAudit(path string, force bool) error {
 	r := c.c.NewRequest("DELETE", fmt.Sprintf("/v1/sys/audit/%s", path))
+	if force {
+		r.Params.Set("force", "true")
+	}
 	resp, err := c.c.RawRequest(r)
 	if err == nil {
 		defer resp.Body.Close()
@@ -48,6 +61,57 @@ func (c *Sys) DisableAudit(path string) error {
 	return err
 }
 
+func (c *Sys) AuditReplayStatus() (*AuditReplayStatus, error) {
+	r := c.c.NewRequest("GET", "/v1/sys/audit-replay")
+	resp, err := c.c.RawRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result AuditReplayStatus
+	err = resp.DecodeJSON(&result)
+	return &result, err
+}
+
+// RotateAuditSalt replaces the salt of the audit backend with a new random
+// salt and returns the new version of the salt.
+func (c *Sys) RotateAuditSalt(path string) (int, error) {
+	r := c.c.NewRequest("PUT", fmt.Sprintf("/v1/sys/audit-rotate/%s", path))
+	resp, err := c.c.RawRequest(r)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		SaltVersion int `json:"salt_version"`
+	}
+	err = resp.DecodeJSON(&result)
+	return result.SaltVersion, err
+}
+
+// TuneAudit switches the audit backend between the "shadow" and "enforced"
+// modes without recreating it.
+func (c *Sys) TuneAudit(path string, mode string) error {
+	body := map[string]interface{}{
+		"mode": mode,
+	}
+
+	r := c.c.NewRequest("PUT", fmt.Sprintf("/v1/sys/audit-tune/%s", path))
+	if err := r.SetJSONBody(body); err != nil {
+		return err
+	}
+
+	resp, err := c.c.RawRequest(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
 // Structures for the requests/resposne are all down here. They aren't
 // individually documentd because the map almost directly to the raw HTTP API
 // documentation. Please refer to that documentation for more details.
@@ -57,3 +121,9 @@ type Audit struct {
 	Description string
 	Options     map[string]string
 }
+
+type AuditReplayStatus struct {
+	InProgress bool `json:"in_progress"`
+	Pending    int  `json:"pending"`
+	Replayed   int  `json:"replayed"`
+}
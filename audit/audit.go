@@ -1,24 +1,234 @@
 package audit
 
-import "github.com/hashicorp/vault/logical"
+import (
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
 
 // Backend interface must be implemented for an audit
 // mechanism to be made available. Audit backends can be enabled to
 // sink information to different backends such as logs, file, databases,
 // or other external services.
+//
+// The context given to the log methods is canceled when the Vault begins
+// to seal. Backends that may block, such as those writing over the
+// network, should abort and return the error of the context when it is
+// done rather than leave a goroutine blocked in a write.
 type Backend interface {
 	// LogRequest is used to syncronously log a request. This is done after the
 	// request is authorized but before the request is executed. The arguments
 	// MUST not be modified in anyway. They should be deep copied if this is
 	// a possibility.
-	LogRequest(*logical.Auth, *logical.Request) error
+	LogRequest(context.Context, *logical.Auth, *logical.Request) error
 
 	// LogResponse is used to syncronously log a response. This is done after
 	// the request is processed but before the response is sent. The arguments
 	// MUST not be modified in anyway. They should be deep copied if this is
 	// a possibility.
-	LogResponse(*logical.Auth, *logical.Request, *logical.Response, error) error
+	//
+	// LogResponse is only called after LogRequest has returned for the same
+	// request. Backends that write entries asynchronously must preserve the
+	// order in which they are given the entries.
+	LogResponse(context.Context, *logical.Auth, *logical.Request,
+		*logical.Response, error) error
+}
+
+// Replayer is an optional interface implemented by audit backends that
+// durably queue entries which have not yet been flushed to their sink.
+// Pending entries are replayed after the backend is set up but before the
+// Vault begins to serve requests.
+type Replayer interface {
+	// Pending returns the number of entries waiting to be replayed.
+	Pending() (int, error)
+
+	// Replay flushes all the pending entries, invoking the callback
+	// after each entry is written.
+	Replay(func()) error
 }
 
 // Factory is the factory function to create an audit backend.
 type Factory func(map[string]string) (Backend, error)
+
+// Status describes a change in the health of an audit backend: it failed
+// to log an entry after having succeeded, or the other way around.
+type Status struct {
+	// Backend is the path the backend is enabled at.
+	Backend string
+
+	// Healthy is false if the backend failed to log an entry.
+	Healthy bool
+
+	// Error is the error of the failure, if not healthy.
+	Error string
+}
+
+// StatusLogger is an optional interface implemented by audit backends that
+// record changes in the health of the other backends, so that the audit
+// log itself documents the gaps in coverage.
+type StatusLogger interface {
+	LogStatus(context.Context, *Status) error
+}
+
+// Heartbeat is a periodic entry logged by an audit backend, so that a
+// quiet audit log can be told apart from a broken one. Heartbeats are
+// enabled per backend with the "heartbeat_interval" option.
+type Heartbeat struct {
+	// Backend is the path the backend is enabled at.
+	Backend string
+}
+
+// HeartbeatLogger is an optional interface implemented by audit backends
+// that can log heartbeats.
+type HeartbeatLogger interface {
+	LogHeartbeat(context.Context, *Heartbeat) error
+}
+
+// EntryReader is an optional interface implemented by audit backends that
+// keep their last entries in memory, so that they can be read back through
+// the sys/audit-entries endpoint.
+type EntryReader interface {
+	// Entries returns the entries kept, oldest first, decoded from JSON.
+	Entries() ([]map[string]interface{}, error)
+}
+
+// Summary counts the entries an audit backend dropped because of its rate
+// limit, when the "rate_limit_overflow" option is "summarize". It is
+// logged before the next entry the backend is allowed to log.
+type Summary struct {
+	// Backend is the path the backend is enabled at.
+	Backend string
+
+	// Dropped is the number of entries dropped between Since and Until,
+	// the times of the first and the last of them.
+	Dropped int
+	Since   time.Time
+	Until   time.Time
+}
+
+// SummaryLogger is an optional interface implemented by audit backends
+// that can log the summaries of the entries dropped by their rate limit.
+type SummaryLogger interface {
+	LogSummary(context.Context, *Summary) error
+}
+
+// Inventory is a snapshot of the configuration of the Vault, logged when
+// it is unsealed, so that the audit log itself records which audit
+// backends and mounts were enabled.
+type Inventory struct {
+	// Audits are the enabled audit backends, with their options as they
+	// are listed, so without the sensitive ones such as salts.
+	Audits []InventoryEntry
+
+	// Mounts are the mounted logical backends, and Auths the enabled
+	// credential backends, without their options.
+	Mounts []InventoryEntry
+	Auths  []InventoryEntry
+}
+
+// InventoryEntry is an audit backend or a mount of an Inventory.
+type InventoryEntry struct {
+	Path        string
+	Type        string
+	Description string
+	Options     map[string]string
+}
+
+// InventoryLogger is an optional interface implemented by audit backends
+// that can log inventories.
+type InventoryLogger interface {
+	LogInventory(context.Context, *Inventory) error
+}
+
+// AuditChange records an audit backend being enabled, disabled, or having
+// its salt rotated through the sys/audit endpoints. It is logged through
+// every audit backend before the change applies, so the backend being
+// disabled records its own removal.
+type AuditChange struct {
+	// Operation is "enable", "disable", "rotate", or "tune".
+	Operation string
+
+	// Path, Type, and Description are those of the audit backend changed.
+	Path        string
+	Type        string
+	Description string
+
+	// Options are all the options of the backend, including the ones
+	// stored outside the audit table, with the values of the sensitive
+	// ones, such as salts, redacted.
+	Options map[string]string
+
+	// Actor is the client that requested the change.
+	Actor AuditActor
+}
+
+// AuditActor identifies the client that sent a request.
+type AuditActor struct {
+	DisplayName string
+	RemoteAddr  string
+	ClientType  string
+}
+
+// AuditChangeLogger is an optional interface implemented by audit backends
+// that can log the changes of the audit backends.
+type AuditChangeLogger interface {
+	LogAuditChange(context.Context, *AuditChange) error
+}
+
+// SelfTest is a marker entry logged by an audit backend when it is
+// registered, so that a backend that can't log, such as one with a bad
+// file path or an unreachable collector, is caught before traffic flows.
+type SelfTest struct {
+	// Backend is the path the backend is enabled at.
+	Backend string
+}
+
+// SelfTester is an optional interface implemented by audit backends that
+// can check that they are able to log by logging a self-test entry.
+type SelfTester interface {
+	Test(context.Context, *SelfTest) error
+}
+
+// ClockSetter is an optional interface implemented by audit backends and
+// formatters that timestamp their entries, so that the audit broker can
+// have them use its clock. The clock is set before the backend logs any
+// entry.
+type ClockSetter interface {
+	SetClock(func() time.Time)
+}
+
+// SetClock sets the clock of the backend or formatter, if it implements
+// ClockSetter.
+func SetClock(v interface{}, clock func() time.Time) {
+	if cs, ok := v.(ClockSetter); ok {
+		cs.SetClock(clock)
+	}
+}
+
+// Closer is an optional interface implemented by audit backends that hold
+// resources, such as a process or a connection, that must be released
+// once the backend is no longer used: when it is disabled, replaced, or
+// the Vault is sealed. No entry is logged through a backend after it is
+// closed.
+type Closer interface {
+	Close() error
+}
+
+// Close closes the backend, if it implements Closer.
+func Close(b Backend) error {
+	if c, ok := b.(Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// DegradedReporter is an optional interface implemented by audit backends
+// that can degrade, such as the file backend logging entries without their
+// data when its disk is low on free space, so that the degradation can be
+// monitored before entries are lost.
+type DegradedReporter interface {
+	// Degraded returns why the backend is degraded, or an empty string
+	// if it isn't.
+	Degraded() string
+}
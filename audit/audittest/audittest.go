@@ -0,0 +1,197 @@
+// Package audittest provides helpers for testing audit backends and
+// formatters: a backend that records the entries it is given, a way to
+// replay entries through a backend as the core would, and golden file
+// assertions for formatted output.
+package audittest
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+// update rewrites the golden files with the actual output instead of
+// comparing them, for example: go test ./audit -audittest.update
+var update = flag.Bool("audittest.update", false, "update the golden files")
+
+// Entry is a request and its response, as they are given to an audit
+// backend. For requests, only Auth and Request are used.
+type Entry struct {
+	Auth     *logical.Auth
+	Request  *logical.Request
+	Response *logical.Response
+	Err      error
+
+	// Time is the time of the clock of the backend when the entry was
+	// recorded, see Backend.SetClock. It is ignored by Replay.
+	Time time.Time
+}
+
+// Clock returns a clock stopped at the given time, to set on formatters
+// and backends with audit.SetClock, so that the times of their entries
+// are known.
+func Clock(now time.Time) func() time.Time {
+	return func() time.Time {
+		return now
+	}
+}
+
+// Backend is an audit.Backend, and an audit.StatusLogger, that records
+// the entries and statuses it is given. It is an audit.ClockSetter, so
+// the entries are recorded with the time of the clock of the audit broker
+// they are logged through. It is safe for concurrent use.
+type Backend struct {
+	// ReqErr and RespErr are returned by LogRequest and LogResponse
+	// after the entry is recorded.
+	ReqErr  error
+	RespErr error
+
+	l         sync.Mutex
+	clock     func() time.Time
+	requests  []Entry
+	responses []Entry
+	statuses  []*audit.Status
+}
+
+func (b *Backend) LogRequest(
+	ctx context.Context, auth *logical.Auth, req *logical.Request) error {
+	b.l.Lock()
+	defer b.l.Unlock()
+	b.requests = append(b.requests, Entry{Auth: auth, Request: req, Time: b.now()})
+	return b.ReqErr
+}
+
+func (b *Backend) LogResponse(ctx context.Context, auth *logical.Auth,
+	req *logical.Request, resp *logical.Response, err error) error {
+	b.l.Lock()
+	defer b.l.Unlock()
+	b.responses = append(b.responses, Entry{
+		Auth:     auth,
+		Request:  req,
+		Response: resp,
+		Err:      err,
+		Time:     b.now(),
+	})
+	return b.RespErr
+}
+
+// SetClock sets the clock the entries are recorded with. It defaults to
+// time.Now.
+func (b *Backend) SetClock(clock func() time.Time) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	b.clock = clock
+}
+
+func (b *Backend) now() time.Time {
+	if b.clock == nil {
+		return time.Now()
+	}
+	return b.clock()
+}
+
+func (b *Backend) LogStatus(ctx context.Context, status *audit.Status) error {
+	b.l.Lock()
+	defer b.l.Unlock()
+	b.statuses = append(b.statuses, status)
+	return nil
+}
+
+// Requests returns the requests logged so far, in order.
+func (b *Backend) Requests() []Entry {
+	b.l.Lock()
+	defer b.l.Unlock()
+	return append([]Entry(nil), b.requests...)
+}
+
+// Responses returns the responses logged so far, in order.
+func (b *Backend) Responses() []Entry {
+	b.l.Lock()
+	defer b.l.Unlock()
+	return append([]Entry(nil), b.responses...)
+}
+
+// Statuses returns the statuses logged so far, in order.
+func (b *Backend) Statuses() []*audit.Status {
+	b.l.Lock()
+	defer b.l.Unlock()
+	return append([]*audit.Status(nil), b.statuses...)
+}
+
+// Replay logs each entry through the backend as the core does, the
+// request and then its response. The test fails if the backend fails to
+// log an entry.
+func Replay(t testing.TB, b audit.Backend, entries []Entry) {
+	ctx := context.Background()
+	for i, e := range entries {
+		if err := b.LogRequest(ctx, e.Auth, e.Request); err != nil {
+			t.Fatalf("entry %d: failed to log request: %s", i, err)
+		}
+		err := b.LogResponse(ctx, e.Auth, e.Request, e.Response, e.Err)
+		if err != nil {
+			t.Fatalf("entry %d: failed to log response: %s", i, err)
+		}
+	}
+}
+
+// Format returns the output of the formatter for the request and the
+// response of each entry. The test fails if the formatter fails.
+func Format(t testing.TB, f audit.Formatter, entries []Entry) []byte {
+	var buf bytes.Buffer
+	for i, e := range entries {
+		if err := f.FormatRequest(&buf, e.Auth, e.Request); err != nil {
+			t.Fatalf("entry %d: failed to format request: %s", i, err)
+		}
+		err := f.FormatResponse(&buf, e.Auth, e.Request, e.Response, e.Err)
+		if err != nil {
+			t.Fatalf("entry %d: failed to format response: %s", i, err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// timePatterns match the times written by the builtin formatters: RFC
+// 3339 times and the times of the Common Log Format.
+var timePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`),
+	regexp.MustCompile(`\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`),
+}
+
+// MaskTimes returns the output with the times replaced by "<time>", so
+// that it can be compared between runs.
+func MaskTimes(output []byte) []byte {
+	for _, re := range timePatterns {
+		output = re.ReplaceAll(output, []byte("<time>"))
+	}
+	return output
+}
+
+// AssertGolden fails the test if the output, with its times masked,
+// differs from the contents of the golden file at path. If the
+// -audittest.update flag is given, the golden file is written instead.
+func AssertGolden(t testing.TB, path string, output []byte) {
+	output = MaskTimes(output)
+	if *update {
+		if err := ioutil.WriteFile(path, output, 0644); err != nil {
+			t.Fatalf("failed to update %s: %s", path, err)
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %s", path, err)
+	}
+	if !bytes.Equal(output, expected) {
+		t.Fatalf("output differs from %s:\n\n%s\n\nexpected:\n\n%s",
+			path, output, expected)
+	}
+}
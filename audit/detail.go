@@ -0,0 +1,190 @@
+package audit
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// Detail is the amount of information an audit backend logs for each
+// entry, selected with the "detail" option. Lower levels reduce the size
+// of the audit log without disabling auditing.
+type Detail int
+
+const (
+	// DetailMinimal logs only the operation and path of the request,
+	// and the error of the response.
+	DetailMinimal Detail = iota
+
+	// DetailStandard adds the authentication information, such as the
+	// display name, policies and metadata, and the lease and redirect of
+	// the response.
+	DetailStandard
+
+	// DetailFull adds the data of the request and the response. This is
+	// the default.
+	DetailFull
+)
+
+// ParseDetail parses the value of the "detail" option. An empty value is
+// DetailFull.
+func ParseDetail(v string) (Detail, error) {
+	switch v {
+	case "minimal":
+		return DetailMinimal, nil
+	case "standard":
+		return DetailStandard, nil
+	case "", "full":
+		return DetailFull, nil
+	default:
+		return 0, fmt.Errorf("invalid detail: %s", v)
+	}
+}
+
+// detailFormatter is a Formatter that removes the information above its
+// level of detail from the entries before they are formatted.
+type detailFormatter struct {
+	formatter Formatter
+	detail    Detail
+
+	// listKeys, if set, hashes the "keys" of the responses to list
+	// operations, which are often sensitive, such as user names.
+	listKeys HashCallback
+}
+
+func (f *detailFormatter) FormatRequest(
+	w io.Writer, auth *logical.Auth, req *logical.Request) error {
+	return f.formatter.FormatRequest(w, f.auth(auth), f.request(req))
+}
+
+func (f *detailFormatter) FormatResponse(
+	w io.Writer,
+	auth *logical.Auth,
+	req *logical.Request,
+	resp *logical.Response,
+	err error) error {
+	resp, herr := f.response(req, resp)
+	if herr != nil {
+		return herr
+	}
+	return f.formatter.FormatResponse(w, f.auth(auth), f.request(req), resp, err)
+}
+
+// FormatStatus formats the status with the wrapped formatter, if it
+// supports it. Otherwise nothing is written.
+func (f *detailFormatter) FormatStatus(w io.Writer, status *Status) error {
+	if sf, ok := f.formatter.(StatusFormatter); ok {
+		return sf.FormatStatus(w, status)
+	}
+	return nil
+}
+
+// FormatHeartbeat formats the heartbeat with the wrapped formatter, if it
+// supports it. Otherwise nothing is written.
+func (f *detailFormatter) FormatHeartbeat(w io.Writer, hb *Heartbeat) error {
+	if hf, ok := f.formatter.(HeartbeatFormatter); ok {
+		return hf.FormatHeartbeat(w, hb)
+	}
+	return nil
+}
+
+// FormatSummary formats the summary with the wrapped formatter, if it
+// supports it. Otherwise nothing is written.
+func (f *detailFormatter) FormatSummary(w io.Writer, s *Summary) error {
+	if sf, ok := f.formatter.(SummaryFormatter); ok {
+		return sf.FormatSummary(w, s)
+	}
+	return nil
+}
+
+// FormatInventory formats the inventory with the wrapped formatter, if it
+// supports it. Otherwise nothing is written.
+func (f *detailFormatter) FormatInventory(w io.Writer, inv *Inventory) error {
+	if inf, ok := f.formatter.(InventoryFormatter); ok {
+		return inf.FormatInventory(w, inv)
+	}
+	return nil
+}
+
+// FormatAuditChange formats the change with the wrapped formatter, if it
+// supports it. Otherwise nothing is written.
+func (f *detailFormatter) FormatAuditChange(w io.Writer, change *AuditChange) error {
+	if cf, ok := f.formatter.(AuditChangeFormatter); ok {
+		return cf.FormatAuditChange(w, change)
+	}
+	return nil
+}
+
+// FormatSelfTest formats the self-test with the wrapped formatter, if it
+// supports it. Otherwise nothing is written.
+func (f *detailFormatter) FormatSelfTest(w io.Writer, st *SelfTest) error {
+	if sf, ok := f.formatter.(SelfTestFormatter); ok {
+		return sf.FormatSelfTest(w, st)
+	}
+	return nil
+}
+
+// SetClock sets the clock of the wrapped formatter, if it supports it.
+func (f *detailFormatter) SetClock(clock func() time.Time) {
+	SetClock(f.formatter, clock)
+}
+
+func (f *detailFormatter) auth(auth *logical.Auth) *logical.Auth {
+	if f.detail < DetailStandard {
+		return nil
+	}
+	return auth
+}
+
+func (f *detailFormatter) request(req *logical.Request) *logical.Request {
+	if req == nil || f.detail >= DetailFull {
+		return req
+	}
+	if f.detail < DetailStandard {
+		return &logical.Request{
+			Operation: req.Operation,
+			Path:      req.Path,
+		}
+	}
+
+	cp := *req
+	cp.Data = nil
+	return &cp
+}
+
+func (f *detailFormatter) response(
+	req *logical.Request, resp *logical.Response) (*logical.Response, error) {
+	if resp == nil || f.detail < DetailStandard {
+		return nil, nil
+	}
+
+	var data map[string]interface{}
+	if f.detail >= DetailFull {
+		data = resp.Data
+	}
+
+	// Hash the keys of list responses, leaving the rest of the data
+	if keys, ok := data["keys"]; ok && f.listKeys != nil &&
+		req != nil && req.Operation == logical.ListOperation {
+		hashed, err := HashStructure(keys, f.listKeys)
+		if err != nil {
+			return nil, err
+		}
+
+		cp := make(map[string]interface{}, len(data))
+		for k, v := range data {
+			cp[k] = v
+		}
+		cp["keys"] = hashed
+		data = cp
+	}
+
+	if f.detail >= DetailFull && f.listKeys == nil {
+		return resp, nil
+	}
+	cp := *resp
+	cp.Data = data
+	return &cp, nil
+}
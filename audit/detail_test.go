@@ -0,0 +1,151 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestParseDetail(t *testing.T) {
+	cases := map[string]Detail{
+		"":         DetailFull,
+		"full":     DetailFull,
+		"standard": DetailStandard,
+		"minimal":  DetailMinimal,
+	}
+	for v, expected := range cases {
+		d, err := ParseDetail(v)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if d != expected {
+			t.Fatalf("bad: %q: %d", v, d)
+		}
+	}
+
+	if _, err := ParseDetail("verbose"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestNewFormatter_detail(t *testing.T) {
+	auth := &logical.Auth{
+		DisplayName: "root",
+		Policies:    []string{"root"},
+	}
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/foo",
+		Data:      map[string]interface{}{"value": "bar"},
+	}
+	resp := &logical.Response{
+		Secret: &logical.Secret{LeaseID: "secret/foo/1234"},
+		Data:   map[string]interface{}{"value": "bar"},
+	}
+
+	cases := map[string]JSONResponseEntry{
+		"minimal": {
+			Request: JSONRequest{
+				Operation: logical.WriteOperation,
+				Path:      "secret/foo",
+			},
+		},
+		"standard": {
+			Auth: JSONAuth{DisplayName: "root", Policies: []string{"root"}},
+			Request: JSONRequest{
+				Operation: logical.WriteOperation,
+				Path:      "secret/foo",
+			},
+			Response: JSONResponse{
+				Secret: JSONSecret{LeaseID: "secret/foo/1234"},
+			},
+		},
+		"full": {
+			Auth: JSONAuth{DisplayName: "root", Policies: []string{"root"}},
+			Request: JSONRequest{
+				Operation: logical.WriteOperation,
+				Path:      "secret/foo",
+				Data:      map[string]interface{}{"value": "bar"},
+			},
+			Response: JSONResponse{
+				Secret: JSONSecret{LeaseID: "secret/foo/1234"},
+				Data:   map[string]interface{}{"value": "bar"},
+			},
+		},
+	}
+	for detail, expected := range cases {
+		f, err := NewFormatter(map[string]string{"detail": detail})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		var buf bytes.Buffer
+		if err := f.FormatResponse(&buf, auth, req, resp, nil); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		var entry JSONResponseEntry
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !reflect.DeepEqual(entry.Auth, expected.Auth) ||
+			!reflect.DeepEqual(entry.Request, expected.Request) ||
+			!reflect.DeepEqual(entry.Response, expected.Response) {
+			t.Fatalf("bad: %s: %#v", detail, entry)
+		}
+	}
+}
+
+func TestNewFormatter_hashListKeys(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "auth/userpass/users/",
+	}
+	resp := logical.ListResponse([]string{"alice", "bob"})
+	resp.Data["count"] = "2"
+
+	f, err := NewFormatter(map[string]string{
+		"log_raw":        "true",
+		"hash_list_keys": "true",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := f.FormatResponse(&buf, nil, req, resp, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var entry JSONResponseEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	alice, _ := HashSHA1("")("alice")
+	bob, _ := HashSHA1("")("bob")
+	expected := map[string]interface{}{
+		"keys":  []interface{}{alice, bob},
+		"count": "2",
+	}
+	if !reflect.DeepEqual(entry.Response.Data, expected) {
+		t.Fatalf("bad: %#v", entry.Response.Data)
+	}
+
+	// The response is not modified
+	if !reflect.DeepEqual(resp.Data["keys"], []string{"alice", "bob"}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Keys of other operations are left as is
+	buf.Reset()
+	req.Operation = logical.ReadOperation
+	if err := f.FormatResponse(&buf, nil, req, resp, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(buf.String(), `"keys":["alice","bob"]`) {
+		t.Fatalf("bad: %s", buf.String())
+	}
+}
@@ -0,0 +1,42 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// flattenJSON returns the JSON encoding of v as a single level object,
+// with the keys of nested objects joined by dots, such that
+// {"request":{"data":{"foo":"bar"}}} becomes {"request.data.foo":"bar"}.
+// Arrays are kept as values and are not flattened.
+func flattenJSON(v interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	// Numbers are kept as is rather than converted to floats
+	var nested map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&nested); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]interface{})
+	flattenInto(result, "", nested)
+	return result, nil
+}
+
+func flattenInto(result map[string]interface{}, prefix string, m map[string]interface{}) {
+	for k, v := range m {
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+		if nested, ok := v.(map[string]interface{}); ok && len(nested) > 0 {
+			flattenInto(result, k, nested)
+			continue
+		}
+		result[k] = v
+	}
+}
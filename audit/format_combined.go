@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// combinedTimeFormat is the time format of the Common Log Format.
+const combinedTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// FormatCombined is a Formatter implementation that writes a line in the
+// Combined Log Format of web servers for every response, so that access
+// log analyzers can read the audit log. Requests are not written, since
+// the response line describes the whole exchange.
+//
+// The user is the client token, which is hashed unless raw logging is
+// enabled. The status is the one the HTTP API responds with. The size,
+// referer, and user agent aren't known to the audit backends and are
+// always "-".
+type FormatCombined struct {
+	// Raw writes the path as it is, apart from the characters that would
+	// break the quoting of the request line. Otherwise control characters
+	// are escaped, see sanitizeString.
+	Raw bool
+
+	// Options set the location the times are written in. Like the other
+	// formats, the times are in UTC by default rather than in the local
+	// time zone of the server.
+	Options FormatOptions
+}
+
+func (f *FormatCombined) FormatRequest(
+	w io.Writer,
+	auth *logical.Auth, req *logical.Request) error {
+	return nil
+}
+
+func (f *FormatCombined) FormatResponse(
+	w io.Writer,
+	auth *logical.Auth,
+	req *logical.Request,
+	resp *logical.Response,
+	err error) error {
+	host := "-"
+	if req.Connection != nil && req.Connection.RemoteAddr != "" {
+		host = req.Connection.RemoteAddr
+	}
+
+	user := "-"
+	if auth != nil && auth.ClientToken != "" {
+		user = auth.ClientToken
+	}
+
+	path := "/v1/" + req.Path
+	if !f.Raw {
+		path = sanitizeString(path)
+	}
+
+	_, werr := fmt.Fprintf(w, "%s - %s [%s] \"%s %s HTTP/1.1\" %d - \"-\" \"-\"\n",
+		host,
+		user,
+		f.Options.Now().Format(combinedTimeFormat),
+		combinedMethod(req.Operation),
+		combinedEscape(path),
+		combinedStatus(req, resp, err))
+	return werr
+}
+
+// combinedMethod returns the HTTP method of the API for the operation.
+func combinedMethod(op logical.Operation) string {
+	switch op {
+	case logical.ReadOperation, logical.HelpOperation:
+		return "GET"
+	case logical.DeleteOperation:
+		return "DELETE"
+	default:
+		return "PUT"
+	}
+}
+
+// combinedStatus returns the status code the HTTP API responds with for
+// the result of a request, see respondCommon in the http package.
+func combinedStatus(
+	req *logical.Request, resp *logical.Response, err error) int {
+	switch {
+	case resp != nil && resp.IsError():
+		return http.StatusBadRequest
+	case err != nil:
+		return http.StatusInternalServerError
+	case resp == nil && req.Operation == logical.ReadOperation:
+		return http.StatusNotFound
+	case resp == nil:
+		return http.StatusNoContent
+	default:
+		return http.StatusOK
+	}
+}
+
+// combinedEscape escapes the characters that would break the quoting of
+// the request line.
+func combinedEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// SetClock sets the clock the entries are timestamped with, see
+// FormatOptions.Clock.
+func (f *FormatCombined) SetClock(clock func() time.Time) {
+	f.Options.Clock = clock
+}
@@ -0,0 +1,128 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestFormatCombined_formatRequest(t *testing.T) {
+	var buf bytes.Buffer
+	var format FormatCombined
+	err := format.FormatRequest(&buf, nil, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("bad: %s", buf.String())
+	}
+}
+
+func TestFormatCombined_formatResponse(t *testing.T) {
+	cases := map[string]struct {
+		Auth     *logical.Auth
+		Req      *logical.Request
+		Resp     *logical.Response
+		Err      error
+		Expected string
+	}{
+		"read": {
+			&logical.Auth{ClientToken: "sha1:1234"},
+			&logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      "secret/foo",
+				Connection: &logical.Connection{
+					RemoteAddr: "127.0.0.1",
+				},
+			},
+			&logical.Response{
+				Data: map[string]interface{}{"value": "bar"},
+			},
+			nil,
+			`127.0.0.1 - sha1:1234 [TIME] "GET /v1/secret/foo HTTP/1.1" 200 - "-" "-"`,
+		},
+		"read missing": {
+			nil,
+			&logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      "secret/foo",
+			},
+			nil,
+			nil,
+			`- - - [TIME] "GET /v1/secret/foo HTTP/1.1" 404 - "-" "-"`,
+		},
+		"write": {
+			nil,
+			&logical.Request{
+				Operation: logical.WriteOperation,
+				Path:      `secret/"foo"`,
+			},
+			nil,
+			nil,
+			`- - - [TIME] "PUT /v1/secret/\"foo\" HTTP/1.1" 204 - "-" "-"`,
+		},
+		"denied": {
+			nil,
+			&logical.Request{
+				Operation: logical.DeleteOperation,
+				Path:      "secret/foo",
+			},
+			logical.ErrorResponse("permission denied"),
+			logical.ErrPermissionDenied,
+			`- - - [TIME] "DELETE /v1/secret/foo HTTP/1.1" 400 - "-" "-"`,
+		},
+		"internal error": {
+			nil,
+			&logical.Request{
+				Operation: logical.WriteOperation,
+				Path:      "secret/foo",
+			},
+			nil,
+			errors.New("internal error"),
+			`- - - [TIME] "PUT /v1/secret/foo HTTP/1.1" 500 - "-" "-"`,
+		},
+	}
+
+	timeRe := regexp.MustCompile(`\[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\]`)
+	for name, tc := range cases {
+		var buf bytes.Buffer
+		var format FormatCombined
+		err := format.FormatResponse(&buf, tc.Auth, tc.Req, tc.Resp, tc.Err)
+		if err != nil {
+			t.Fatalf("%s: err: %s", name, err)
+		}
+
+		actual := timeRe.ReplaceAllString(buf.String(), "[TIME]")
+		if actual != tc.Expected+"\n" {
+			t.Fatalf("%s: bad: %s", name, buf.String())
+		}
+	}
+}
+
+func TestNewFormatter(t *testing.T) {
+	for _, name := range []string{"", "json", "combined", "csv"} {
+		if _, err := NewFormatter(map[string]string{"format": name}); err != nil {
+			t.Fatalf("%s: err: %s", name, err)
+		}
+	}
+	if _, err := NewFormatter(map[string]string{"format": "xml"}); err == nil {
+		t.Fatal("should error")
+	}
+
+	f, err := NewFormatter(map[string]string{"max_entry_size": "1024"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if f.(*FormatJSON).MaxEntrySize != 1024 {
+		t.Fatalf("bad: %#v", f)
+	}
+	if _, err := NewFormatter(map[string]string{"max_entry_size": "-1"}); err == nil {
+		t.Fatal("should error")
+	}
+}
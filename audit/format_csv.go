@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// CSVColumns are the columns of the records written by FormatCSV, in
+// order. Columns are only ever appended, so that spreadsheets and scripts
+// that refer to columns by position keep working.
+var CSVColumns = []string{
+	"time",
+	"type",
+	"operation",
+	"path",
+	"display_name",
+	"policies",
+	"lease_id",
+	"error",
+	"lease_duration",
+}
+
+// FormatCSV is a Formatter implementation that writes every request and
+// response as a CSV record with the columns in CSVColumns. Policies are
+// separated by commas within their column. Request and response data
+// isn't written.
+type FormatCSV struct {
+	// Raw writes the fields as they are. Otherwise control characters
+	// are escaped, see sanitizeString.
+	Raw bool
+
+	// Options set the location the times are written in, and the unit of
+	// the lease durations
+	Options FormatOptions
+}
+
+func (f *FormatCSV) FormatRequest(
+	w io.Writer,
+	auth *logical.Auth, req *logical.Request) error {
+	return f.write(w, "request", auth, req, nil, nil)
+}
+
+func (f *FormatCSV) FormatResponse(
+	w io.Writer,
+	auth *logical.Auth,
+	req *logical.Request,
+	resp *logical.Response,
+	err error) error {
+	return f.write(w, "response", auth, req, resp, err)
+}
+
+func (f *FormatCSV) write(
+	w io.Writer,
+	kind string,
+	auth *logical.Auth,
+	req *logical.Request,
+	resp *logical.Response,
+	err error) error {
+	if auth == nil {
+		auth = new(logical.Auth)
+	}
+
+	var leaseID, leaseDuration string
+	if resp != nil && resp.Secret != nil {
+		leaseID = resp.Secret.LeaseID
+		if resp.Secret.Lease > 0 {
+			leaseDuration = f.Options.Duration(resp.Secret.Lease)
+		}
+	}
+
+	var errStr string
+	if err != nil {
+		errStr = err.Error()
+	}
+
+	record := []string{
+		f.Options.Now().Format(time.RFC3339Nano),
+		kind,
+		string(req.Operation),
+		req.Path,
+		auth.DisplayName,
+		strings.Join(auth.Policies, ","),
+		leaseID,
+		errStr,
+		leaseDuration,
+	}
+	if !f.Raw {
+		for i, v := range record {
+			record[i] = sanitizeString(v)
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write(record)
+	cw.Flush()
+	return cw.Error()
+}
+
+// SetClock sets the clock the entries are timestamped with, see
+// FormatOptions.Clock.
+func (f *FormatCSV) SetClock(clock func() time.Time) {
+	f.Options.Clock = clock
+}
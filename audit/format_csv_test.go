@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestFormatCSV(t *testing.T) {
+	var buf bytes.Buffer
+	var format FormatCSV
+
+	auth := &logical.Auth{
+		DisplayName: "root",
+		Policies:    []string{"root", "ops"},
+	}
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo,bar",
+	}
+	resp := &logical.Response{
+		Secret: &logical.Secret{LeaseID: "secret/foo/1234"},
+	}
+	if err := format.FormatRequest(&buf, auth, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err := format.FormatResponse(&buf, auth, req, resp, errors.New(`bad "thing"`))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("bad: %#v", records)
+	}
+	for _, r := range records {
+		if len(r) != len(CSVColumns) {
+			t.Fatalf("bad: %#v", r)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, r[0]); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	expected := [][]string{
+		{"request", "read", "secret/foo,bar", "root", "root,ops", "", "", ""},
+		{"response", "read", "secret/foo,bar", "root", "root,ops",
+			"secret/foo/1234", `bad "thing"`, ""},
+	}
+	for i, r := range records {
+		if !reflect.DeepEqual(r[1:], expected[i]) {
+			t.Fatalf("bad: %#v", r)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package audit_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/audit/audittest"
+	"github.com/hashicorp/vault/logical"
+)
+
+// testFormatEntries are the entries formatted for the golden files.
+var testFormatEntries = []audittest.Entry{
+	{
+		Auth: &logical.Auth{
+			ClientToken: "sha1:0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33",
+			DisplayName: "github-armon",
+			Policies:    []string{"dev", "ops"},
+			Metadata:    map[string]string{"org": "hashicorp"},
+			Method:      "github/",
+		},
+		Request: &logical.Request{
+			Operation: logical.WriteOperation,
+			Path:      "secret/foo",
+			Data: map[string]interface{}{
+				"value": "sha1:62cdb7020ff920e5aa642c3d4066950dd1f01f4d",
+			},
+			Connection: &logical.Connection{RemoteAddr: "127.0.0.1"},
+		},
+	},
+	{
+		Auth: &logical.Auth{
+			DisplayName: "root",
+			Policies:    []string{"root"},
+		},
+		Request: &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "aws/creds/deploy",
+		},
+		Response: &logical.Response{
+			Secret: &logical.Secret{LeaseID: "aws/creds/deploy/1234"},
+		},
+		Err: errors.New("permission denied"),
+	},
+}
+
+func TestFormat_golden(t *testing.T) {
+	cases := map[string]map[string]string{
+		"testdata/format.json.golden":     {"format": "json"},
+		"testdata/format.csv.golden":      {"format": "csv"},
+		"testdata/format.combined.golden": {"format": "combined"},
+		"testdata/format.pretty.golden":   {"format": "pretty"},
+		"testdata/format.pretty_details.golden": {
+			"format":         "pretty",
+			"pretty_details": "true",
+		},
+	}
+	for golden, conf := range cases {
+		f, err := audit.NewFormatter(conf)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		audittest.AssertGolden(t, golden, audittest.Format(t, f, testFormatEntries))
+	}
+}
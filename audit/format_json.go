@@ -1,15 +1,119 @@
 package audit
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hashicorp/vault/logical"
 )
 
+// JSONSchemaVersion is the version of the structure of the JSON audit
+// entries, emitted as the "schema_version" field of every entry.
+//
+// The version is incremented whenever the structure changes, including
+// when new fields are added, so that parsers can detect which fields are
+// present. Fields are only ever added within a major release: existing
+// fields are not removed or renamed and their types do not change.
+// Parsers should ignore fields they do not know about.
+//
+// Version 2 added the "time" field. Version 3 added the "truncated" field.
+// Version 4 added the "audit_status" entries. Version 5 added the "method"
+// field of the authentication. Version 6 added the "heartbeat" entries.
+// Version 7 added the "salt_version" field. Version 8 added the
+// "item_count" field of the response. Version 9 added the
+// "rate_limit_summary" entries. Version 10 added the "client_type" field
+// of the request. Version 11 added the "mount_point" and "mount_type"
+// fields of the request. Version 12 added the "capabilities_granted"
+// field of the authentication. Version 13 added the "inventory" entries.
+// Version 14 added the "audit_change" entries. Version 15 added the
+// "client_token_previous" field of the authentication. Version 16 added
+// the "self_test" entries. Version 17 added the "lease_duration" field of
+// the secret. Version 18 added the "client_token" field to the
+// authentication of the request and response entries, unless Raw is set.
+// Version 19 added the
+// "start_time", "end_time", and "duration_ns" fields of the response.
+// Version 20 added the "remote_addr", "tls_version", and
+// "tls_cipher_suite" fields of the request.
+const JSONSchemaVersion = 20
+
 // FormatJSON is a Formatter implementation that structuteres data into
 // a JSON format.
-type FormatJSON struct{}
+type FormatJSON struct {
+	// MaxEntrySize is the maximum size of an entry in bytes, including
+	// the trailing newline. Entries that are larger are written without
+	// their request and response data, and with "truncated" set. If the
+	// entry is still too large, an error is returned. Zero means no limit.
+	MaxEntrySize int
+
+	// Flatten writes each entry as a single level object, with the keys
+	// of nested objects joined by dots, for log systems that cannot index
+	// nested objects.
+	Flatten bool
+
+	// Raw writes strings as they are. Otherwise the control characters
+	// that encoding/json doesn't escape are escaped, see sanitizeJSON.
+	// The client token of the request is then left out of the
+	// authentication, since it isn't hashed, see clientToken.
+	Raw bool
+
+	// SaltVersion is the version of the salt the values are hashed with,
+	// which is incremented every time the salt is rotated. It is written
+	// with every request and response entry, unless it is zero.
+	SaltVersion int
+
+	// Options set the location the times of the entries are written in,
+	// and the unit of the lease durations
+	Options FormatOptions
+}
+
+// jsonEncodeState holds everything needed to encode an entry. The states
+// are pooled so that formatting an entry doesn't allocate in the steady
+// state.
+type jsonEncodeState struct {
+	buf  bytes.Buffer
+	enc  *json.Encoder
+	req  JSONRequestEntry
+	resp JSONResponseEntry
+}
+
+var (
+	jsonEncodeStatePool = sync.Pool{
+		New: func() interface{} {
+			s := new(jsonEncodeState)
+			s.enc = json.NewEncoder(&s.buf)
+			return s
+		},
+	}
+
+	// jsonLastEntrySize is the size of the last entry encoded, used to
+	// size the buffers of new states.
+	jsonLastEntrySize int64
+)
+
+func getJSONEncodeState() *jsonEncodeState {
+	s := jsonEncodeStatePool.Get().(*jsonEncodeState)
+	s.buf.Grow(int(atomic.LoadInt64(&jsonLastEntrySize)))
+	return s
+}
+
+// putJSONEncodeState writes the encoded entry to w in a single write and
+// returns the state to the pool.
+func putJSONEncodeState(w io.Writer, s *jsonEncodeState) error {
+	atomic.StoreInt64(&jsonLastEntrySize, int64(s.buf.Len()))
+	_, err := w.Write(s.buf.Bytes())
+
+	s.buf.Reset()
+	s.req = JSONRequestEntry{}
+	s.resp = JSONResponseEntry{}
+	jsonEncodeStatePool.Put(s)
+	return err
+}
 
 func (f *FormatJSON) FormatRequest(
 	w io.Writer,
@@ -20,22 +124,51 @@ func (f *FormatJSON) FormatRequest(
 	}
 
 	// Encode!
-	enc := json.NewEncoder(w)
-	return enc.Encode(&JSONRequestEntry{
-		Type: "request",
+	state := getJSONEncodeState()
+	state.req = JSONRequestEntry{
+		Type:          "request",
+		SchemaVersion: JSONSchemaVersion,
+		Time:          f.Options.Now(),
+		SaltVersion:   f.SaltVersion,
 
 		Auth: JSONAuth{
-			DisplayName: auth.DisplayName,
-			Policies:    auth.Policies,
-			Metadata:    auth.Metadata,
+			ClientToken:         f.clientToken(auth),
+			DisplayName:         auth.DisplayName,
+			Policies:            auth.Policies,
+			Metadata:            auth.Metadata,
+			Method:              auth.Method,
+			CapabilitiesGranted: auth.Capabilities,
 		},
 
 		Request: JSONRequest{
-			Operation: req.Operation,
-			Path:      req.Path,
-			Data:      req.Data,
+			Operation:  req.Operation,
+			Path:       req.Path,
+			Data:       req.Data,
+			ClientType: req.ClientType,
+			MountPoint: req.MountPoint,
+			MountType:  req.MountType,
+
+			RemoteAddr:     remoteAddr(req.Connection),
+			TLSVersion:     tlsVersion(req.Connection),
+			TLSCipherSuite: tlsCipherSuite(req.Connection),
 		},
-	})
+	}
+	if err := f.encode(state, &state.req); err != nil {
+		// The state may hold a partial entry, so it isn't reused
+		return err
+	}
+	if f.tooLarge(state) {
+		state.buf.Reset()
+		state.req.Truncated = true
+		state.req.Request.Data = nil
+		if err := f.encode(state, &state.req); err != nil {
+			return err
+		}
+		if f.tooLarge(state) {
+			return f.tooLargeError(state)
+		}
+	}
+	return putJSONEncodeState(w, state)
 }
 
 func (f *FormatJSON) FormatResponse(
@@ -55,81 +188,492 @@ func (f *FormatJSON) FormatResponse(
 	var respAuth JSONAuth
 	if resp.Auth != nil {
 		respAuth = JSONAuth{
-			ClientToken: resp.Auth.ClientToken,
-			DisplayName: resp.Auth.DisplayName,
-			Policies:    resp.Auth.Policies,
-			Metadata:    resp.Auth.Metadata,
+			ClientToken:         resp.Auth.ClientToken,
+			ClientTokenPrevious: previousClientToken(resp.Auth),
+			DisplayName:         resp.Auth.DisplayName,
+			Policies:            resp.Auth.Policies,
+			Metadata:            resp.Auth.Metadata,
+			Method:              resp.Auth.Method,
 		}
 	}
 
+	var errString string
+	if err != nil {
+		errString = err.Error()
+	}
+
 	var respSecret JSONSecret
 	if resp.Secret != nil {
 		respSecret = JSONSecret{
 			LeaseID: resp.Secret.LeaseID,
 		}
+		if resp.Secret.Lease > 0 {
+			respSecret.LeaseDuration = f.Options.Duration(resp.Secret.Lease)
+		}
 	}
 
 	// Encode!
-	enc := json.NewEncoder(w)
-	return enc.Encode(&JSONResponseEntry{
-		Type: "response",
+	state := getJSONEncodeState()
+	state.resp = JSONResponseEntry{
+		Type:          "response",
+		SchemaVersion: JSONSchemaVersion,
+		Time:          f.Options.Now(),
+		SaltVersion:   f.SaltVersion,
+		Error:         errString,
 
 		Auth: JSONAuth{
-			Policies: auth.Policies,
-			Metadata: auth.Metadata,
+			ClientToken:         f.clientToken(auth),
+			DisplayName:         auth.DisplayName,
+			Policies:            auth.Policies,
+			Metadata:            auth.Metadata,
+			Method:              auth.Method,
+			CapabilitiesGranted: auth.Capabilities,
 		},
 
 		Request: JSONRequest{
-			Operation: req.Operation,
-			Path:      req.Path,
-			Data:      req.Data,
+			Operation:  req.Operation,
+			Path:       req.Path,
+			Data:       req.Data,
+			ClientType: req.ClientType,
+			MountPoint: req.MountPoint,
+			MountType:  req.MountType,
+
+			RemoteAddr:     remoteAddr(req.Connection),
+			TLSVersion:     tlsVersion(req.Connection),
+			TLSCipherSuite: tlsCipherSuite(req.Connection),
 		},
 
 		Response: JSONResponse{
-			Auth:     respAuth,
-			Secret:   respSecret,
-			Data:     resp.Data,
-			Redirect: resp.Redirect,
+			Auth:      respAuth,
+			Secret:    respSecret,
+			Data:      resp.Data,
+			Redirect:  resp.Redirect,
+			ItemCount: itemCount(req, resp),
 		},
+	}
+	if !req.StartTime.IsZero() && !req.EndTime.IsZero() {
+		start := f.Options.Time(req.StartTime)
+		end := f.Options.Time(req.EndTime)
+		state.resp.StartTime = &start
+		state.resp.EndTime = &end
+		state.resp.DurationNS = int64(req.EndTime.Sub(req.StartTime))
+	}
+	if err := f.encode(state, &state.resp); err != nil {
+		// The state may hold a partial entry, so it isn't reused
+		return err
+	}
+	if f.tooLarge(state) {
+		state.buf.Reset()
+		state.resp.Truncated = true
+		state.resp.Request.Data = nil
+		state.resp.Response.Data = nil
+		if err := f.encode(state, &state.resp); err != nil {
+			return err
+		}
+		if f.tooLarge(state) {
+			return f.tooLargeError(state)
+		}
+	}
+	return putJSONEncodeState(w, state)
+}
+
+// FormatStatus writes an "audit_status" entry for a change in the health
+// of an audit backend.
+func (f *FormatJSON) FormatStatus(w io.Writer, status *Status) error {
+	return f.writeEntry(w, &JSONStatusEntry{
+		Type:          "audit_status",
+		SchemaVersion: JSONSchemaVersion,
+		Time:          f.Options.Now(),
+
+		Status: JSONStatus{
+			Backend: status.Backend,
+			Healthy: status.Healthy,
+			Error:   status.Error,
+		},
+	})
+}
+
+// FormatHeartbeat writes a "heartbeat" entry for an audit backend.
+func (f *FormatJSON) FormatHeartbeat(w io.Writer, hb *Heartbeat) error {
+	return f.writeEntry(w, &JSONHeartbeatEntry{
+		Type:          "heartbeat",
+		SchemaVersion: JSONSchemaVersion,
+		Time:          f.Options.Now(),
+		Backend:       hb.Backend,
+	})
+}
+
+// FormatSummary writes a "rate_limit_summary" entry for the entries an
+// audit backend dropped because of its rate limit.
+func (f *FormatJSON) FormatSummary(w io.Writer, s *Summary) error {
+	return f.writeEntry(w, &JSONSummaryEntry{
+		Type:          "rate_limit_summary",
+		SchemaVersion: JSONSchemaVersion,
+		Time:          f.Options.Now(),
+		Backend:       s.Backend,
+		Dropped:       s.Dropped,
+		Since:         f.Options.Time(s.Since),
+		Until:         f.Options.Time(s.Until),
+	})
+}
+
+// FormatInventory writes an "inventory" entry with the audit backends and
+// mounts enabled.
+func (f *FormatJSON) FormatInventory(w io.Writer, inv *Inventory) error {
+	return f.writeEntry(w, &JSONInventoryEntry{
+		Type:          "inventory",
+		SchemaVersion: JSONSchemaVersion,
+		Time:          f.Options.Now(),
+		Audits:        jsonInventoryEntries(inv.Audits),
+		Mounts:        jsonInventoryEntries(inv.Mounts),
+		Auths:         jsonInventoryEntries(inv.Auths),
 	})
 }
 
+// FormatAuditChange writes an "audit_change" entry with the audit backend
+// changed, its redacted options, and the client that changed it.
+func (f *FormatJSON) FormatAuditChange(w io.Writer, change *AuditChange) error {
+	return f.writeEntry(w, &JSONAuditChangeEntry{
+		Type:          "audit_change",
+		SchemaVersion: JSONSchemaVersion,
+		Time:          f.Options.Now(),
+		Operation:     change.Operation,
+		Path:          change.Path,
+		BackendType:   change.Type,
+		Description:   change.Description,
+		Options:       change.Options,
+		Actor: JSONActor{
+			DisplayName:   change.Actor.DisplayName,
+			RemoteAddress: change.Actor.RemoteAddr,
+			ClientType:    change.Actor.ClientType,
+		},
+	})
+}
+
+// FormatSelfTest writes a "self_test" entry with the path of the backend
+// being registered.
+func (f *FormatJSON) FormatSelfTest(w io.Writer, st *SelfTest) error {
+	return f.writeEntry(w, &JSONSelfTestEntry{
+		Type:          "self_test",
+		SchemaVersion: JSONSchemaVersion,
+		Time:          f.Options.Now(),
+		Backend:       st.Backend,
+	})
+}
+
+func jsonInventoryEntries(entries []InventoryEntry) []JSONInventoryMount {
+	result := make([]JSONInventoryMount, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, JSONInventoryMount{
+			Path:        e.Path,
+			Type:        e.Type,
+			Description: e.Description,
+			Options:     e.Options,
+		})
+	}
+	return result
+}
+
+// writeEntry encodes an entry that isn't pooled, such as a status or a
+// heartbeat, and writes it.
+func (f *FormatJSON) writeEntry(w io.Writer, entry interface{}) error {
+	if f.Flatten {
+		var err error
+		if entry, err = flattenJSON(entry); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(entry); err != nil {
+		return err
+	}
+
+	b := buf.Bytes()
+	if !f.Raw {
+		b = sanitizeJSON(b)
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// encode encodes the entry into the buffer of the state, flattened if
+// configured.
+func (f *FormatJSON) encode(state *jsonEncodeState, entry interface{}) error {
+	if f.Flatten {
+		var err error
+		if entry, err = flattenJSON(entry); err != nil {
+			return err
+		}
+	}
+	if err := state.enc.Encode(entry); err != nil {
+		return err
+	}
+
+	if !f.Raw {
+		if b := sanitizeJSON(state.buf.Bytes()); len(b) != state.buf.Len() {
+			state.buf.Reset()
+			state.buf.Write(b)
+		}
+	}
+	return nil
+}
+
+// itemCount returns the number of keys a list operation responded with,
+// so that the scope of the operation is known even if the data is
+// truncated. It returns nil for other operations.
+func itemCount(req *logical.Request, resp *logical.Response) *int {
+	if req.Operation != logical.ListOperation {
+		return nil
+	}
+
+	var n int
+	switch keys := resp.Data["keys"].(type) {
+	case []string:
+		n = len(keys)
+	case []interface{}:
+		n = len(keys)
+	default:
+		return nil
+	}
+	return &n
+}
+
+// clientToken returns the client token of the request to write with the
+// authentication. It is left out if Raw is set, since the token is then
+// the actual token rather than its hash.
+func (f *FormatJSON) clientToken(auth *logical.Auth) string {
+	if f.Raw {
+		return ""
+	}
+	return auth.ClientToken
+}
+
+// tooLarge returns whether the encoded entry exceeds MaxEntrySize.
+func (f *FormatJSON) tooLarge(state *jsonEncodeState) bool {
+	return f.MaxEntrySize > 0 && state.buf.Len() > f.MaxEntrySize
+}
+
+func (f *FormatJSON) tooLargeError(state *jsonEncodeState) error {
+	return fmt.Errorf(
+		"audit entry of %d bytes without data exceeds the maximum size of %d bytes",
+		state.buf.Len(), f.MaxEntrySize)
+}
+
 // JSONRequest is the structure of a request audit log entry in JSON.
 type JSONRequestEntry struct {
-	Type    string      `json:"type"`
-	Auth    JSONAuth    `json:"auth"`
-	Request JSONRequest `json:"request"`
+	Type          string      `json:"type"`
+	SchemaVersion int         `json:"schema_version"`
+	Time          time.Time   `json:"time"`
+	Truncated     bool        `json:"truncated,omitempty"`
+	SaltVersion   int         `json:"salt_version,omitempty"`
+	Auth          JSONAuth    `json:"auth"`
+	Request       JSONRequest `json:"request"`
 }
 
 // JSONResponseEntry is the structure of a response audit log entry in JSON.
 type JSONResponseEntry struct {
-	Type     string       `json:"type"`
-	Error    string       `json:"error"`
-	Auth     JSONAuth     `json:"auth"`
-	Request  JSONRequest  `json:"request"`
-	Response JSONResponse `json:"response"`
+	Type          string       `json:"type"`
+	SchemaVersion int          `json:"schema_version"`
+	Time          time.Time    `json:"time"`
+	StartTime     *time.Time   `json:"start_time,omitempty"`
+	EndTime       *time.Time   `json:"end_time,omitempty"`
+	DurationNS    int64        `json:"duration_ns,omitempty"`
+	Truncated     bool         `json:"truncated,omitempty"`
+	SaltVersion   int          `json:"salt_version,omitempty"`
+	Error         string       `json:"error"`
+	Auth          JSONAuth     `json:"auth"`
+	Request       JSONRequest  `json:"request"`
+	Response      JSONResponse `json:"response"`
+}
+
+// JSONStatusEntry is the structure of an audit status entry in JSON.
+type JSONStatusEntry struct {
+	Type          string     `json:"type"`
+	SchemaVersion int        `json:"schema_version"`
+	Time          time.Time  `json:"time"`
+	Status        JSONStatus `json:"status"`
+}
+
+// JSONHeartbeatEntry is the structure of a heartbeat entry in JSON.
+type JSONHeartbeatEntry struct {
+	Type          string    `json:"type"`
+	SchemaVersion int       `json:"schema_version"`
+	Time          time.Time `json:"time"`
+	Backend       string    `json:"backend"`
+}
+
+// JSONSummaryEntry is the structure of a rate limit summary entry in JSON.
+type JSONSummaryEntry struct {
+	Type          string    `json:"type"`
+	SchemaVersion int       `json:"schema_version"`
+	Time          time.Time `json:"time"`
+	Backend       string    `json:"backend"`
+	Dropped       int       `json:"dropped"`
+	Since         time.Time `json:"since"`
+	Until         time.Time `json:"until"`
+}
+
+// JSONInventoryEntry is the structure of an inventory entry in JSON.
+type JSONInventoryEntry struct {
+	Type          string               `json:"type"`
+	SchemaVersion int                  `json:"schema_version"`
+	Time          time.Time            `json:"time"`
+	Audits        []JSONInventoryMount `json:"audits"`
+	Mounts        []JSONInventoryMount `json:"mounts"`
+	Auths         []JSONInventoryMount `json:"auths"`
+}
+
+// JSONAuditChangeEntry is the structure of an audit change entry in JSON.
+type JSONAuditChangeEntry struct {
+	Type          string            `json:"type"`
+	SchemaVersion int               `json:"schema_version"`
+	Time          time.Time         `json:"time"`
+	Operation     string            `json:"operation"`
+	Path          string            `json:"path"`
+	BackendType   string            `json:"backend_type"`
+	Description   string            `json:"description"`
+	Options       map[string]string `json:"options"`
+	Actor         JSONActor         `json:"actor"`
+}
+
+// JSONSelfTestEntry is the structure of a self-test entry in JSON.
+type JSONSelfTestEntry struct {
+	Type          string    `json:"type"`
+	SchemaVersion int       `json:"schema_version"`
+	Time          time.Time `json:"time"`
+	Backend       string    `json:"backend"`
 }
 
 type JSONRequest struct {
-	Operation logical.Operation      `json:"operation"`
-	Path      string                 `json:"path"`
-	Data      map[string]interface{} `json:"data"`
+	Operation  logical.Operation      `json:"operation"`
+	Path       string                 `json:"path"`
+	Data       map[string]interface{} `json:"data"`
+	ClientType string                 `json:"client_type,omitempty"`
+	MountPoint string                 `json:"mount_point,omitempty"`
+	MountType  string                 `json:"mount_type,omitempty"`
+
+	// The connection the request was received on, if it came through
+	// the HTTP API, and the TLS version and cipher suite it negotiated.
+	RemoteAddr     string `json:"remote_addr,omitempty"`
+	TLSVersion     string `json:"tls_version,omitempty"`
+	TLSCipherSuite string `json:"tls_cipher_suite,omitempty"`
 }
 
 type JSONResponse struct {
-	Auth     JSONAuth               `json:"auth,omitempty"`
-	Secret   JSONSecret             `json:"secret,emitempty"`
-	Data     map[string]interface{} `json:"data"`
-	Redirect string                 `json:"redirect"`
+	Auth      JSONAuth               `json:"auth"`
+	Secret    JSONSecret             `json:"secret"`
+	Data      map[string]interface{} `json:"data"`
+	Redirect  string                 `json:"redirect"`
+	ItemCount *int                   `json:"item_count,omitempty"`
 }
 
 type JSONAuth struct {
-	ClientToken string            `json:"client_token,omitempty"`
-	DisplayName string            `json:"display_name"`
-	Policies    []string          `json:"policies"`
-	Metadata    map[string]string `json:"metadata"`
+	ClientToken         string            `json:"client_token,omitempty"`
+	ClientTokenPrevious string            `json:"client_token_previous,omitempty"`
+	DisplayName         string            `json:"display_name"`
+	Policies            []string          `json:"policies"`
+	Metadata            map[string]string `json:"metadata"`
+	Method              string            `json:"method,omitempty"`
+	CapabilitiesGranted []string          `json:"capabilities_granted,omitempty"`
 }
 
 type JSONSecret struct {
-	LeaseID string `json:"lease_id"`
+	LeaseID       string `json:"lease_id"`
+	LeaseDuration string `json:"lease_duration,omitempty"`
+}
+
+// Auth returns the auth of an entry as logged, with the fields that
+// aren't written in JSON left empty.
+func (a JSONAuth) Auth() *logical.Auth {
+	auth := &logical.Auth{
+		ClientToken:  a.ClientToken,
+		DisplayName:  a.DisplayName,
+		Policies:     a.Policies,
+		Metadata:     a.Metadata,
+		Method:       a.Method,
+		Capabilities: a.CapabilitiesGranted,
+	}
+	if a.ClientTokenPrevious != "" {
+		setPreviousClientToken(auth, a.ClientTokenPrevious)
+	}
+	return auth
+}
+
+// Request returns the request of an entry as logged, with the fields that
+// aren't written in JSON left empty. The TLS state of the connection
+// can't be recovered from its version and cipher suite, so it is left
+// empty too.
+func (r JSONRequest) Request() *logical.Request {
+	req := &logical.Request{
+		Operation:  r.Operation,
+		Path:       r.Path,
+		Data:       r.Data,
+		ClientType: r.ClientType,
+		MountPoint: r.MountPoint,
+		MountType:  r.MountType,
+	}
+	if r.RemoteAddr != "" {
+		req.Connection = &logical.Connection{RemoteAddr: r.RemoteAddr}
+	}
+	return req
+}
+
+// Response returns the response of an entry, or nil if the entry has
+// none. Empty responses are logged the same way as missing ones, so they
+// can't be told apart.
+func (r JSONResponse) Response() *logical.Response {
+	resp := &logical.Response{
+		Data:     r.Data,
+		Redirect: r.Redirect,
+	}
+	if r.Auth.ClientToken != "" || r.Auth.DisplayName != "" || len(r.Auth.Policies) > 0 {
+		resp.Auth = r.Auth.Auth()
+	}
+	if r.Secret.LeaseID != "" {
+		resp.Secret = &logical.Secret{LeaseID: r.Secret.LeaseID}
+	}
+	if resp.Data == nil && resp.Redirect == "" && resp.Auth == nil && resp.Secret == nil {
+		return nil
+	}
+	return resp
+}
+
+// ParseJSONError returns the error of a response entry, or nil if it has
+// none. Permission denied errors are returned as
+// logical.ErrPermissionDenied, which some formats write differently.
+func ParseJSONError(s string) error {
+	switch s {
+	case "":
+		return nil
+	case logical.ErrPermissionDenied.Error():
+		return logical.ErrPermissionDenied
+	default:
+		return errors.New(s)
+	}
+}
+
+type JSONInventoryMount struct {
+	Path        string            `json:"path"`
+	Type        string            `json:"type"`
+	Description string            `json:"description"`
+	Options     map[string]string `json:"options,omitempty"`
+}
+
+type JSONActor struct {
+	DisplayName   string `json:"display_name"`
+	RemoteAddress string `json:"remote_address"`
+	ClientType    string `json:"client_type,omitempty"`
+}
+
+type JSONStatus struct {
+	Backend string `json:"backend"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// SetClock sets the clock the entries are timestamped with, see
+// FormatOptions.Clock.
+func (f *FormatJSON) SetClock(clock func() time.Time) {
+	f.Options.Clock = clock
 }
@@ -2,7 +2,15 @@ package audit
 
 import (
 	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/vault/logical"
 )
@@ -11,6 +19,7 @@ func TestFormatJSON_formatRequest(t *testing.T) {
 	cases := map[string]struct {
 		Auth   *logical.Auth
 		Req    *logical.Request
+		Raw    bool
 		Result string
 	}{
 		"auth, request": {
@@ -19,24 +28,635 @@ func TestFormatJSON_formatRequest(t *testing.T) {
 				Operation: logical.WriteOperation,
 				Path:      "/foo",
 			},
+			true,
 			testFormatJSONReqBasicStr,
 		},
+		"hashed client token": {
+			&logical.Auth{ClientToken: "sha1:foo", Policies: []string{"root"}},
+			&logical.Request{
+				Operation: logical.WriteOperation,
+				Path:      "/foo",
+			},
+			false,
+			testFormatJSONReqClientTokenStr,
+		},
+		"client type": {
+			nil,
+			&logical.Request{
+				Operation:  logical.ReadOperation,
+				Path:       "secret/foo",
+				ClientType: "cli",
+			},
+			false,
+			testFormatJSONReqClientTypeStr,
+		},
 	}
 
 	for name, tc := range cases {
 		var buf bytes.Buffer
-		var format FormatJSON
+		format := FormatJSON{Raw: tc.Raw}
 		if err := format.FormatRequest(&buf, tc.Auth, tc.Req); err != nil {
 			t.Fatalf("bad: %s\nerr: %s", name, err)
 		}
 
-		if buf.String() != tc.Result {
+		// The time is set when formatting, so take it from the result
+		var entry JSONRequestEntry
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("bad: %s\nerr: %s", name, err)
+		}
+		if time.Since(entry.Time) > time.Minute {
+			t.Fatalf("bad: %s\ntime: %s", name, entry.Time)
+		}
+		expected := fmt.Sprintf(
+			tc.Result, entry.Time.Format(time.RFC3339Nano))
+
+		if buf.String() != expected {
 			t.Fatalf(
 				"bad: %s\nResult:\n\n%s\n\nExpected:\n\n%s",
-				name, buf.String(), tc.Result)
+				name, buf.String(), expected)
 		}
 	}
 }
 
-const testFormatJSONReqBasicStr = `{"type":"request","auth":{"display_name":"","policies":["root"],"metadata":null},"request":{"operation":"write","path":"/foo","data":null}}
+const testFormatJSONReqBasicStr = `{"type":"request","schema_version":20,"time":"%s","auth":{"display_name":"","policies":["root"],"metadata":null},"request":{"operation":"write","path":"/foo","data":null}}
+`
+
+const testFormatJSONReqClientTokenStr = `{"type":"request","schema_version":20,"time":"%s","auth":{"client_token":"sha1:foo","display_name":"","policies":["root"],"metadata":null},"request":{"operation":"write","path":"/foo","data":null}}
 `
+
+const testFormatJSONReqClientTypeStr = `{"type":"request","schema_version":20,"time":"%s","auth":{"display_name":"","policies":null,"metadata":null},"request":{"operation":"read","path":"secret/foo","data":null,"client_type":"cli"}}
+`
+
+func TestFormatJSON_formatResponse(t *testing.T) {
+	var buf bytes.Buffer
+	var format FormatJSON
+	err := format.FormatResponse(
+		&buf,
+		&logical.Auth{Policies: []string{"root"}},
+		&logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "/foo",
+		},
+		nil,
+		nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var entry JSONResponseEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if entry.Type != "response" || entry.SchemaVersion != JSONSchemaVersion {
+		t.Fatalf("bad: %#v", entry)
+	}
+	if entry.Time.IsZero() {
+		t.Fatalf("bad: %#v", entry)
+	}
+}
+
+func BenchmarkFormatJSON_formatRequest(b *testing.B) {
+	auth := &logical.Auth{
+		DisplayName: "root",
+		Policies:    []string{"root"},
+	}
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/foo",
+		Data: map[string]interface{}{
+			"value": "sha1:0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33",
+		},
+	}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		var format FormatJSON
+		for pb.Next() {
+			if err := format.FormatRequest(ioutil.Discard, auth, req); err != nil {
+				b.Fatalf("err: %s", err)
+			}
+		}
+	})
+}
+
+func BenchmarkFormatJSON_formatResponse(b *testing.B) {
+	auth := &logical.Auth{
+		DisplayName: "root",
+		Policies:    []string{"root"},
+	}
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+	resp := &logical.Response{
+		Secret: &logical.Secret{
+			LeaseID: "secret/foo/1234",
+		},
+		Data: map[string]interface{}{
+			"value": "sha1:0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33",
+		},
+	}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		var format FormatJSON
+		for pb.Next() {
+			err := format.FormatResponse(ioutil.Discard, auth, req, resp, nil)
+			if err != nil {
+				b.Fatalf("err: %s", err)
+			}
+		}
+	})
+}
+
+func TestFormatJSON_maxEntrySize(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/foo",
+		Data: map[string]interface{}{
+			"value": strings.Repeat("a", 1024),
+		},
+	}
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"value": strings.Repeat("a", 1024),
+		},
+	}
+
+	format := FormatJSON{MaxEntrySize: 512}
+
+	var buf bytes.Buffer
+	if err := format.FormatRequest(&buf, nil, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if buf.Len() > 512 {
+		t.Fatalf("bad: %d", buf.Len())
+	}
+	var reqEntry JSONRequestEntry
+	if err := json.Unmarshal(buf.Bytes(), &reqEntry); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reqEntry.Truncated || reqEntry.Request.Data != nil {
+		t.Fatalf("bad: %#v", reqEntry)
+	}
+	if reqEntry.Request.Path != "secret/foo" {
+		t.Fatalf("bad: %#v", reqEntry)
+	}
+
+	buf.Reset()
+	if err := format.FormatResponse(&buf, nil, req, resp, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var respEntry JSONResponseEntry
+	if err := json.Unmarshal(buf.Bytes(), &respEntry); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !respEntry.Truncated || respEntry.Response.Data != nil {
+		t.Fatalf("bad: %#v", respEntry)
+	}
+
+	// Entries within the limit are untouched
+	buf.Reset()
+	format.MaxEntrySize = 4096
+	if err := format.FormatRequest(&buf, nil, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if strings.Contains(buf.String(), "truncated") {
+		t.Fatalf("bad: %s", buf.String())
+	}
+
+	// Entries that are too large even without data fail
+	buf.Reset()
+	format.MaxEntrySize = 16
+	if err := format.FormatRequest(&buf, nil, req); err == nil {
+		t.Fatal("should error")
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("bad: %s", buf.String())
+	}
+}
+
+func TestFormatJSON_formatStatus(t *testing.T) {
+	var buf bytes.Buffer
+	var format FormatJSON
+	err := format.FormatStatus(&buf, &Status{
+		Backend: "file/",
+		Error:   "disk full",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var entry JSONStatusEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := JSONStatus{Backend: "file/", Healthy: false, Error: "disk full"}
+	if entry.Type != "audit_status" || entry.Status != expected {
+		t.Fatalf("bad: %#v", entry)
+	}
+}
+
+func TestFormatJSON_formatHeartbeat(t *testing.T) {
+	var buf bytes.Buffer
+	var format FormatJSON
+	if err := format.FormatHeartbeat(&buf, &Heartbeat{Backend: "file/"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var entry JSONHeartbeatEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if entry.Type != "heartbeat" || entry.Backend != "file/" ||
+		entry.SchemaVersion != JSONSchemaVersion || entry.Time.IsZero() {
+		t.Fatalf("bad: %#v", entry)
+	}
+}
+
+func TestFormatJSON_formatSelfTest(t *testing.T) {
+	var buf bytes.Buffer
+	var format FormatJSON
+	if err := format.FormatSelfTest(&buf, &SelfTest{Backend: "file/"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var entry JSONSelfTestEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if entry.Type != "self_test" || entry.Backend != "file/" ||
+		entry.SchemaVersion != JSONSchemaVersion || entry.Time.IsZero() {
+		t.Fatalf("bad: %#v", entry)
+	}
+}
+
+func TestFormatJSON_formatSummary(t *testing.T) {
+	since := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	until := since.Add(time.Second)
+
+	var buf bytes.Buffer
+	var format FormatJSON
+	err := format.FormatSummary(&buf, &Summary{
+		Backend: "file/",
+		Dropped: 42,
+		Since:   since,
+		Until:   until,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var entry JSONSummaryEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if entry.Type != "rate_limit_summary" || entry.Backend != "file/" ||
+		entry.Dropped != 42 || !entry.Since.Equal(since) || !entry.Until.Equal(until) {
+		t.Fatalf("bad: %#v", entry)
+	}
+}
+
+func TestFormatJSON_formatInventory(t *testing.T) {
+	var buf bytes.Buffer
+	var format FormatJSON
+	err := format.FormatInventory(&buf, &Inventory{
+		Audits: []InventoryEntry{{
+			Path:    "file/",
+			Type:    "file",
+			Options: map[string]string{"path": "/var/log/vault_audit.log"},
+		}},
+		Mounts: []InventoryEntry{{
+			Path:        "secret/",
+			Type:        "generic",
+			Description: "generic secret storage",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := `"audits":[{"path":"file/","type":"file","description":"","options":{"path":"/var/log/vault_audit.log"}}],` +
+		`"mounts":[{"path":"secret/","type":"generic","description":"generic secret storage"}],"auths":[]}`
+	if !strings.HasPrefix(buf.String(), `{"type":"inventory",`) ||
+		!strings.HasSuffix(buf.String(), expected+"\n") {
+		t.Fatalf("bad: %s", buf.String())
+	}
+}
+
+func TestFormatJSON_formatAuditChange(t *testing.T) {
+	var buf bytes.Buffer
+	var format FormatJSON
+	err := format.FormatAuditChange(&buf, &AuditChange{
+		Operation: "disable",
+		Path:      "file/",
+		Type:      "file",
+		Options:   map[string]string{"path": "/var/log/vault_audit.log", "salt": "<redacted>"},
+		Actor:     AuditActor{DisplayName: "root", RemoteAddr: "127.0.0.1"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := `"operation":"disable","path":"file/","backend_type":"file","description":"",` +
+		`"options":{"path":"/var/log/vault_audit.log","salt":"\u003credacted\u003e"},` +
+		`"actor":{"display_name":"root","remote_address":"127.0.0.1"}}`
+	if !strings.HasPrefix(buf.String(), `{"type":"audit_change",`) ||
+		!strings.HasSuffix(buf.String(), expected+"\n") {
+		t.Fatalf("bad: %s", buf.String())
+	}
+}
+
+func TestFormatJSON_itemCount(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "secret/",
+	}
+	resp := logical.ListResponse([]string{"foo", "bar"})
+
+	var buf bytes.Buffer
+	format := FormatJSON{MaxEntrySize: 400}
+	if err := format.FormatResponse(&buf, nil, req, resp, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var entry JSONResponseEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if entry.Response.ItemCount == nil || *entry.Response.ItemCount != 2 {
+		t.Fatalf("bad: %s", buf.String())
+	}
+
+	// The count remains when the data is truncated
+	resp = logical.ListResponse([]string{strings.Repeat("a", 400)})
+	buf.Reset()
+	if err := format.FormatResponse(&buf, nil, req, resp, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	entry = JSONResponseEntry{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !entry.Truncated || entry.Response.ItemCount == nil ||
+		*entry.Response.ItemCount != 1 {
+		t.Fatalf("bad: %s", buf.String())
+	}
+
+	// Other operations have no count
+	req.Operation = logical.ReadOperation
+	buf.Reset()
+	if err := format.FormatResponse(&buf, nil, req, resp, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if strings.Contains(buf.String(), "item_count") {
+		t.Fatalf("bad: %s", buf.String())
+	}
+}
+
+func TestFormatJSON_timing(t *testing.T) {
+	start := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+		StartTime: start,
+		EndTime:   start.Add(1500 * time.Microsecond),
+	}
+
+	var buf bytes.Buffer
+	format := FormatJSON{}
+	if err := format.FormatResponse(&buf, nil, req, nil, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := `"start_time":"2016-01-02T03:04:05Z","end_time":"2016-01-02T03:04:05.0015Z","duration_ns":1500000`
+	if !strings.Contains(buf.String(), expected) {
+		t.Fatalf("bad: %s", buf.String())
+	}
+
+	// Responses the core didn't time have no timing
+	req.StartTime = time.Time{}
+	buf.Reset()
+	if err := format.FormatResponse(&buf, nil, req, nil, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if strings.Contains(buf.String(), "duration_ns") {
+		t.Fatalf("bad: %s", buf.String())
+	}
+}
+
+func TestFormatJSON_connection(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+		Connection: &logical.Connection{
+			RemoteAddr: "127.0.0.1",
+			ConnState: &tls.ConnectionState{
+				Version:     tls.VersionTLS12,
+				CipherSuite: tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	format := FormatJSON{}
+	if err := format.FormatRequest(&buf, nil, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := `"remote_addr":"127.0.0.1","tls_version":"tls12","tls_cipher_suite":"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"`
+	if !strings.Contains(buf.String(), expected) {
+		t.Fatalf("bad: %s", buf.String())
+	}
+
+	// Connections without TLS have only their address
+	req.Connection.ConnState = nil
+	buf.Reset()
+	if err := format.FormatResponse(&buf, nil, req, nil, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(buf.String(), `"remote_addr":"127.0.0.1"`) ||
+		strings.Contains(buf.String(), "tls_version") {
+		t.Fatalf("bad: %s", buf.String())
+	}
+}
+
+func TestFormatJSON_flatten(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/foo",
+		Data: map[string]interface{}{
+			"foo": map[string]interface{}{
+				"bar": "baz",
+			},
+			"list": []interface{}{"a", "b"},
+			"ttl":  3600,
+		},
+	}
+
+	var buf bytes.Buffer
+	format := FormatJSON{Flatten: true}
+	if err := format.FormatRequest(&buf, nil, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := map[string]interface{}{
+		"type":                 "request",
+		"request.operation":    "write",
+		"request.path":         "secret/foo",
+		"request.data.foo.bar": "baz",
+		"request.data.list":    []interface{}{"a", "b"},
+		"request.data.ttl":     float64(3600),
+		"auth.display_name":    "",
+		"auth.policies":        nil,
+		"auth.metadata":        nil,
+	}
+	for k, v := range expected {
+		if !reflect.DeepEqual(entry[k], v) {
+			t.Fatalf("bad: %s: %#v", k, entry)
+		}
+	}
+	for k, v := range entry {
+		if _, ok := v.(map[string]interface{}); ok {
+			t.Fatalf("bad: %s: %#v", k, entry)
+		}
+	}
+}
+
+func TestFormatJSON_sortedKeys(t *testing.T) {
+	// Identical entries must be written identically, which relies on
+	// encoding/json sorting map keys
+	data := make(map[string]interface{})
+	for i := 0; i < 32; i++ {
+		data[fmt.Sprintf("key%02d", i)] = map[string]interface{}{
+			"b": "x",
+			"a": "y",
+		}
+	}
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/foo",
+		Data:      data,
+	}
+
+	var format FormatJSON
+	var first string
+	for i := 0; i < 10; i++ {
+		var buf bytes.Buffer
+		if err := format.FormatRequest(&buf, nil, req); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		// Remove the time, which differs between entries
+		var entry map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		line := strings.Replace(buf.String(), entry["time"].(string), "", 1)
+
+		if i == 0 {
+			first = line
+			if !strings.Contains(line, `"key00":{"a":"y","b":"x"},"key01"`) {
+				t.Fatalf("bad: %s", line)
+			}
+		} else if line != first {
+			t.Fatalf("bad: %s", line)
+		}
+	}
+}
+
+// jsonOmitEmptyFields are the fields of the JSON entries that are omitted
+// when empty, by structure and field name. Fields added to the entries
+// are written even when empty, unless they are added here, so that the
+// schema doesn't change silently.
+var jsonOmitEmptyFields = map[string]bool{
+	"JSONRequestEntry.Truncated":    true,
+	"JSONRequestEntry.SaltVersion":  true,
+	"JSONResponseEntry.Truncated":   true,
+	"JSONResponseEntry.SaltVersion": true,
+	"JSONResponseEntry.StartTime":   true,
+	"JSONResponseEntry.EndTime":     true,
+	"JSONResponseEntry.DurationNS":  true,
+	"JSONRequest.ClientType":        true,
+	"JSONRequest.MountPoint":        true,
+	"JSONRequest.MountType":         true,
+	"JSONRequest.RemoteAddr":        true,
+	"JSONRequest.TLSVersion":        true,
+	"JSONRequest.TLSCipherSuite":    true,
+	"JSONResponse.ItemCount":        true,
+	"JSONSecret.LeaseDuration":      true,
+	"JSONAuth.ClientToken":          true,
+	"JSONAuth.ClientTokenPrevious":  true,
+	"JSONAuth.Method":               true,
+	"JSONAuth.CapabilitiesGranted":  true,
+	"JSONStatus.Error":              true,
+	"JSONInventoryMount.Options":    true,
+	"JSONActor.ClientType":          true,
+}
+
+// jsonTagName matches the names of the fields of the JSON entries.
+var jsonTagName = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+
+func TestFormatJSON_tags(t *testing.T) {
+	omitted := make(map[string]bool)
+	for _, entryType := range JSONEntryTypes() {
+		checkJSONTags(t, reflect.TypeOf(jsonEntryTypes[entryType]), omitted)
+	}
+	for field := range jsonOmitEmptyFields {
+		if !omitted[field] {
+			t.Fatalf("%s is not omitted when empty", field)
+		}
+	}
+}
+
+// checkJSONTags checks the tags of the fields of a structure and of the
+// structures it contains, recording the fields omitted when empty.
+func checkJSONTags(t *testing.T, typ reflect.Type, omitted map[string]bool) {
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		checkJSONTags(t, typ.Elem(), omitted)
+		return
+	case reflect.Struct:
+	default:
+		return
+	}
+	if typ == reflect.TypeOf(time.Time{}) {
+		return
+	}
+
+	names := make(map[string]bool)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		field := typ.Name() + "." + f.Name
+		tag := f.Tag.Get("json")
+		if tag == "" {
+			t.Fatalf("%s: missing json tag", field)
+		}
+
+		parts := strings.Split(tag, ",")
+		if !jsonTagName.MatchString(parts[0]) {
+			t.Fatalf("%s: invalid name in json tag %q", field, tag)
+		}
+		if names[parts[0]] {
+			t.Fatalf("%s: duplicate name in json tag %q", field, tag)
+		}
+		names[parts[0]] = true
+
+		for _, opt := range parts[1:] {
+			if opt != "omitempty" {
+				t.Fatalf("%s: unknown option in json tag %q", field, tag)
+			}
+			if f.Type.Kind() == reflect.Struct {
+				t.Fatalf("%s: structures are never omitted when empty", field)
+			}
+			if !jsonOmitEmptyFields[field] {
+				t.Fatalf("%s: not allowed to be omitted when empty", field)
+			}
+			omitted[field] = true
+		}
+
+		checkJSONTags(t, f.Type, omitted)
+	}
+}
@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// durationUnits are the units of the "duration_unit" option, by symbol.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// FormatOptions are the options that the formatters share to write times
+// and durations. The output of the formatters never depends on the
+// environment of the server: numbers are written without grouping and
+// with a dot as the decimal separator, and operations, types, and
+// statuses are written in lowercase, or in uppercase for HTTP methods,
+// regardless of the locale. Servers given the same options therefore
+// write identical entries, wherever they run.
+type FormatOptions struct {
+	// TimeLocation is the location the times are written in. Nil means
+	// UTC, never the local time zone of the server.
+	TimeLocation *time.Location
+
+	// DurationUnit is the unit the durations are written in, as a decimal
+	// number followed by the symbol of the unit, such as "3600s". It must
+	// be one of the units of the "duration_unit" option. Zero means
+	// seconds.
+	DurationUnit time.Duration
+
+	// Clock returns the time the entries are logged at. Nil means
+	// time.Now. The audit broker sets it to its own clock, see
+	// ClockSetter.
+	Clock func() time.Time
+}
+
+// ParseFormatOptions parses the "time_location" and "duration_unit"
+// options. The location is the name of a time zone of the IANA database,
+// such as "Europe/Paris", and defaults to "UTC". The local time zone of
+// the server isn't allowed, since it differs between servers. The unit is
+// "ns", "us", "ms", "s", "m", or "h", and defaults to "s".
+func ParseFormatOptions(conf map[string]string) (FormatOptions, error) {
+	var opts FormatOptions
+	if v, ok := conf["time_location"]; ok && v != "UTC" {
+		if v == "" || v == "Local" {
+			return opts, fmt.Errorf("invalid time_location: %q", v)
+		}
+		loc, err := time.LoadLocation(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid time_location: %s", v)
+		}
+		opts.TimeLocation = loc
+	}
+
+	if v, ok := conf["duration_unit"]; ok {
+		unit, ok := durationUnits[v]
+		if !ok {
+			return opts, fmt.Errorf("invalid duration_unit: %s", v)
+		}
+		opts.DurationUnit = unit
+	}
+	return opts, nil
+}
+
+// Now returns the current time of the clock in the location of the
+// options.
+func (o FormatOptions) Now() time.Time {
+	if o.Clock != nil {
+		return o.Time(o.Clock())
+	}
+	return o.Time(time.Now())
+}
+
+// Time returns the time in the location of the options.
+func (o FormatOptions) Time(t time.Time) time.Time {
+	if o.TimeLocation == nil {
+		return t.UTC()
+	}
+	return t.In(o.TimeLocation)
+}
+
+// Duration returns the duration in the unit of the options, such as
+// "1.5s". Units that aren't supported are written as seconds.
+func (o FormatOptions) Duration(d time.Duration) string {
+	for symbol, unit := range durationUnits {
+		if unit == o.DurationUnit {
+			return formatDuration(d, unit, symbol)
+		}
+	}
+	return formatDuration(d, time.Second, "s")
+}
+
+func formatDuration(d, unit time.Duration, symbol string) string {
+	// Whole values are divided as integers to keep their precision
+	if d%unit == 0 {
+		return strconv.FormatInt(int64(d/unit), 10) + symbol
+	}
+	return strconv.FormatFloat(float64(d)/float64(unit), 'f', -1, 64) + symbol
+}
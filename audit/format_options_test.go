@@ -0,0 +1,176 @@
+package audit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestParseFormatOptions(t *testing.T) {
+	opts, err := ParseFormatOptions(map[string]string{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if opts.TimeLocation != nil || opts.DurationUnit != 0 {
+		t.Fatalf("bad: %#v", opts)
+	}
+
+	opts, err = ParseFormatOptions(map[string]string{
+		"time_location": "UTC",
+		"duration_unit": "ms",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if opts.TimeLocation != nil || opts.DurationUnit != time.Millisecond {
+		t.Fatalf("bad: %#v", opts)
+	}
+
+	for _, conf := range []map[string]string{
+		{"time_location": ""},
+		{"time_location": "Local"},
+		{"time_location": "Nowhere/Special"},
+		{"duration_unit": "d"},
+		{"duration_unit": "1s"},
+	} {
+		if _, err := ParseFormatOptions(conf); err == nil {
+			t.Fatalf("expected error: %v", conf)
+		}
+	}
+}
+
+func TestFormatOptions_time(t *testing.T) {
+	now := time.Date(2016, 1, 2, 3, 4, 5, 0, time.FixedZone("X", 3600))
+
+	var opts FormatOptions
+	if actual := opts.Time(now).Format(time.RFC3339); actual != "2016-01-02T02:04:05Z" {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	opts.TimeLocation = time.FixedZone("Y", -2*3600)
+	if actual := opts.Time(now).Format(time.RFC3339); actual != "2016-01-02T00:04:05-02:00" {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestFormatOptions_clock(t *testing.T) {
+	now := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	// The clock is set through the formatters that limit the detail
+	for _, format := range []string{"json", "csv", "pretty", "combined"} {
+		for _, detail := range []string{"full", "minimal"} {
+			f, err := NewFormatter(map[string]string{"format": format, "detail": detail})
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			SetClock(f, clock)
+
+			var buf bytes.Buffer
+			req := &logical.Request{Operation: logical.ReadOperation, Path: "secret/foo"}
+			if err := f.FormatResponse(&buf, nil, req, nil, nil); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !strings.Contains(buf.String(), "2016") || !strings.Contains(buf.String(), "04:05") {
+				t.Fatalf("bad: %s %s: %s", format, detail, buf.String())
+			}
+		}
+	}
+}
+
+func TestFormatOptions_duration(t *testing.T) {
+	cases := []struct {
+		Unit     time.Duration
+		Duration time.Duration
+		Expected string
+	}{
+		{0, time.Hour, "3600s"},
+		{time.Second, 1500 * time.Millisecond, "1.5s"},
+		{time.Millisecond, 1500 * time.Millisecond, "1500ms"},
+		{time.Millisecond, 1234567 * time.Nanosecond, "1.234567ms"},
+		{time.Nanosecond, 1234567 * time.Nanosecond, "1234567ns"},
+		{time.Minute, 90 * time.Second, "1.5m"},
+		{time.Hour, 24 * 365 * time.Hour, "8760h"},
+		{3 * time.Second, time.Hour, "3600s"},
+	}
+	for _, tc := range cases {
+		opts := FormatOptions{DurationUnit: tc.Unit}
+		if actual := opts.Duration(tc.Duration); actual != tc.Expected {
+			t.Fatalf("bad: %v in %v: %s", tc.Duration, tc.Unit, actual)
+		}
+	}
+}
+
+func TestNewFormatter_formatOptions(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+	resp := &logical.Response{
+		Secret: &logical.Secret{
+			LeaseOptions: logical.LeaseOptions{Lease: time.Hour},
+			LeaseID:      "secret/foo/1234",
+		},
+	}
+
+	// The times of every format are in UTC by default
+	for _, format := range []string{"json", "csv", "combined", "pretty"} {
+		f, err := NewFormatter(map[string]string{"format": format})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		var buf bytes.Buffer
+		if err := f.FormatResponse(&buf, nil, req, resp, nil); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !strings.Contains(buf.String(), "Z") && !strings.Contains(buf.String(), "+0000") {
+			t.Fatalf("bad: %s: %s", format, buf.String())
+		}
+	}
+
+	f, err := NewFormatter(map[string]string{
+		"format":         "pretty",
+		"pretty_details": "true",
+		"duration_unit":  "m",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var buf bytes.Buffer
+	if err := f.FormatResponse(&buf, nil, req, resp, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(buf.String(), "    lease_duration: 60m\n") {
+		t.Fatalf("bad: %s", buf.String())
+	}
+
+	// The JSON and CSV formats write the lease duration in the unit too
+	expected := map[string]string{
+		"json": `"lease_duration":"60m"`,
+		"csv":  ",60m\n",
+	}
+	for format, lease := range expected {
+		f, err := NewFormatter(map[string]string{
+			"format":        format,
+			"duration_unit": "m",
+		})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		var buf bytes.Buffer
+		if err := f.FormatResponse(&buf, nil, req, resp, nil); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !strings.Contains(buf.String(), lease) {
+			t.Fatalf("bad: %s: %s", format, buf.String())
+		}
+	}
+
+	if _, err := NewFormatter(map[string]string{"time_location": "Local"}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,195 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// prettyPathWidth is the width the path column of FormatPretty is padded
+// to. Longer paths push the following column to the right.
+const prettyPathWidth = 32
+
+// FormatPretty is a Formatter implementation that writes every request
+// and response as a line of aligned columns, for reading the log by hand,
+// such as with tail. The columns are the time, the type of the entry, the
+// status of responses, the operation, the path, and the display name.
+//
+// With Details, each line is followed by an indented block with the
+// policies, capabilities granted, remote address, client type, mount,
+// lease ID and duration, error, and data of the entry, one value per
+// line. Nested data is flattened
+// like with FormatJSON.
+type FormatPretty struct {
+	// Details writes the detail block after each line
+	Details bool
+
+	// Raw writes the fields as they are. Otherwise control characters
+	// are escaped, see sanitizeString.
+	Raw bool
+
+	// Options set the location of the times and the unit of the lease
+	// durations
+	Options FormatOptions
+}
+
+func (f *FormatPretty) FormatRequest(
+	w io.Writer,
+	auth *logical.Auth, req *logical.Request) error {
+	return f.write(w, "request", auth, req, nil, nil)
+}
+
+func (f *FormatPretty) FormatResponse(
+	w io.Writer,
+	auth *logical.Auth,
+	req *logical.Request,
+	resp *logical.Response,
+	err error) error {
+	return f.write(w, "response", auth, req, resp, err)
+}
+
+func (f *FormatPretty) write(
+	w io.Writer,
+	kind string,
+	auth *logical.Auth,
+	req *logical.Request,
+	resp *logical.Response,
+	err error) error {
+	if auth == nil {
+		auth = new(logical.Auth)
+	}
+
+	status := "-"
+	if kind == "response" {
+		status = prettyStatus(resp, err)
+	}
+	name := auth.DisplayName
+	if name == "" {
+		name = "-"
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %-8s %-7s %-8s %-*s %s\n",
+		f.Options.Now().Format(time.RFC3339),
+		kind,
+		status,
+		req.Operation,
+		prettyPathWidth, f.sanitize(req.Path),
+		f.sanitize(name))
+
+	if f.Details {
+		if err := f.writeDetails(&buf, kind, auth, req, resp, err); err != nil {
+			return err
+		}
+	}
+
+	// The line and its details are written at once, so that they aren't
+	// interleaved with other entries
+	_, werr := w.Write(buf.Bytes())
+	return werr
+}
+
+// writeDetails writes the detail block of an entry, with the data of the
+// request for requests, and of the response for responses.
+func (f *FormatPretty) writeDetails(
+	buf *bytes.Buffer,
+	kind string,
+	auth *logical.Auth,
+	req *logical.Request,
+	resp *logical.Response,
+	err error) error {
+	if len(auth.Policies) > 0 {
+		f.writeDetail(buf, "policies", strings.Join(auth.Policies, ", "))
+	}
+	if len(auth.Capabilities) > 0 {
+		f.writeDetail(buf, "capabilities_granted", strings.Join(auth.Capabilities, ", "))
+	}
+	if req.Connection != nil && req.Connection.RemoteAddr != "" {
+		f.writeDetail(buf, "remote_addr", req.Connection.RemoteAddr)
+	}
+	if req.ClientType != "" {
+		f.writeDetail(buf, "client_type", req.ClientType)
+	}
+	if req.MountPoint != "" {
+		f.writeDetail(buf, "mount_point", req.MountPoint)
+		f.writeDetail(buf, "mount_type", req.MountType)
+	}
+
+	data := req.Data
+	if kind == "response" {
+		data = nil
+		if resp != nil {
+			data = resp.Data
+			if resp.Secret != nil && resp.Secret.LeaseID != "" {
+				f.writeDetail(buf, "lease_id", resp.Secret.LeaseID)
+			}
+			if resp.Secret != nil && resp.Secret.Lease > 0 {
+				f.writeDetail(buf, "lease_duration", f.Options.Duration(resp.Secret.Lease))
+			}
+		}
+		if err != nil {
+			f.writeDetail(buf, "error", err.Error())
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	flat, ferr := flattenJSON(map[string]interface{}{"data": data})
+	if ferr != nil {
+		return ferr
+	}
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, ok := flat[k].(string)
+		if !ok {
+			raw, err := json.Marshal(flat[k])
+			if err != nil {
+				return err
+			}
+			v = string(raw)
+		}
+		f.writeDetail(buf, k, v)
+	}
+	return nil
+}
+
+func (f *FormatPretty) writeDetail(buf *bytes.Buffer, key, value string) {
+	fmt.Fprintf(buf, "    %s: %s\n", f.sanitize(key), f.sanitize(value))
+}
+
+func (f *FormatPretty) sanitize(s string) string {
+	if f.Raw {
+		return s
+	}
+	return sanitizeString(s)
+}
+
+// prettyStatus returns the status of a response: "success", "error", or
+// "denied" for the responses denied by the backends.
+func prettyStatus(resp *logical.Response, err error) string {
+	switch {
+	case err == logical.ErrPermissionDenied:
+		return "denied"
+	case err != nil || (resp != nil && resp.IsError()):
+		return "error"
+	default:
+		return "success"
+	}
+}
+
+// SetClock sets the clock the entries are timestamped with, see
+// FormatOptions.Clock.
+func (f *FormatPretty) SetClock(clock func() time.Time) {
+	f.Options.Clock = clock
+}
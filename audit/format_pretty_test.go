@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestFormatPretty_details(t *testing.T) {
+	var buf bytes.Buffer
+	format := &FormatPretty{Details: true}
+	err := format.FormatResponse(&buf, nil, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo\n",
+	}, &logical.Response{
+		Data: map[string]interface{}{
+			"ttl": 60,
+			"nested": map[string]interface{}{
+				"value": "bar",
+			},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) != 4 || lines[3] != "" {
+		t.Fatalf("bad: %q", buf.String())
+	}
+	if !strings.Contains(lines[0], " response success read     secret/foo\\u000a ") {
+		t.Fatalf("bad: %q", lines[0])
+	}
+	if lines[1] != "    data.nested.value: bar" || lines[2] != "    data.ttl: 60" {
+		t.Fatalf("bad: %q", buf.String())
+	}
+}
+
+func TestFormatPretty_denied(t *testing.T) {
+	var buf bytes.Buffer
+	var format FormatPretty
+	err := format.FormatResponse(&buf, &logical.Auth{DisplayName: "root"}, &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/foo",
+	}, nil, logical.ErrPermissionDenied)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(buf.String(), " response denied  write ") {
+		t.Fatalf("bad: %q", buf.String())
+	}
+	if !strings.HasSuffix(buf.String(), " root\n") {
+		t.Fatalf("bad: %q", buf.String())
+	}
+}
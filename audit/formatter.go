@@ -1,7 +1,10 @@
 package audit
 
 import (
+	"bytes"
+	"fmt"
 	"io"
+	"strconv"
 
 	"github.com/hashicorp/vault/logical"
 )
@@ -15,3 +18,199 @@ type Formatter interface {
 	FormatRequest(io.Writer, *logical.Auth, *logical.Request) error
 	FormatResponse(io.Writer, *logical.Auth, *logical.Request, *logical.Response, error) error
 }
+
+// NewFormatter returns the Formatter selected by the "format" option of
+// an audit backend, configured with the other options. JSON is the
+// default format. The "detail" option limits the information formatted,
+// see Detail, and the "hash_list_keys" option hashes the keys returned by
+// list operations when logging raw. The "time_location" and
+// "duration_unit" options are parsed by ParseFormatOptions.
+func NewFormatter(conf map[string]string) (Formatter, error) {
+	var maxEntrySize int
+	if v, ok := conf["max_entry_size"]; ok {
+		var err error
+		maxEntrySize, err = strconv.Atoi(v)
+		if err != nil || maxEntrySize < 0 {
+			return nil, fmt.Errorf("invalid max_entry_size: %s", v)
+		}
+	}
+
+	var flatten bool
+	if v, ok := conf["flatten"]; ok {
+		var err error
+		flatten, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid flatten: %s", v)
+		}
+	}
+
+	var prettyDetails bool
+	if v, ok := conf["pretty_details"]; ok {
+		var err error
+		prettyDetails, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pretty_details: %s", v)
+		}
+	}
+
+	detail, err := ParseDetail(conf["detail"])
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := ParseFormatOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Control characters are escaped unless raw logging is enabled
+	var raw bool
+	if v, ok := conf["log_raw"]; ok {
+		var err error
+		raw, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log_raw: %s", v)
+		}
+	}
+
+	// The keys of list responses can be hashed even when logging raw.
+	// Otherwise they are hashed along with the rest of the data.
+	var listKeys HashCallback
+	if v, ok := conf["hash_list_keys"]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hash_list_keys: %s", v)
+		}
+		if b && raw {
+			hash, err := ParseHashConfig(conf)
+			if err != nil {
+				return nil, err
+			}
+			listKeys = hash.Callback()
+		}
+	}
+
+	// The version of the salt is set by the core when the salt is rotated
+	var saltVersion int
+	if v, ok := conf["salt_version"]; ok {
+		var err error
+		saltVersion, err = strconv.Atoi(v)
+		if err != nil || saltVersion < 0 {
+			return nil, fmt.Errorf("invalid salt_version: %s", v)
+		}
+	}
+
+	name := conf["format"]
+	if flatten && name != "" && name != "json" {
+		return nil, fmt.Errorf("flatten is only supported by the json format")
+	}
+	if prettyDetails && name != "pretty" {
+		return nil, fmt.Errorf("pretty_details is only supported by the pretty format")
+	}
+
+	var f Formatter
+	switch name {
+	case "", "json":
+		f = &FormatJSON{
+			MaxEntrySize: maxEntrySize,
+			Flatten:      flatten,
+			Raw:          raw,
+			SaltVersion:  saltVersion,
+			Options:      opts,
+		}
+	case "combined":
+		f = &FormatCombined{Raw: raw, Options: opts}
+	case "csv":
+		f = &FormatCSV{Raw: raw, Options: opts}
+	case "pretty":
+		f = &FormatPretty{Details: prettyDetails, Raw: raw, Options: opts}
+	default:
+		return nil, fmt.Errorf("unknown format: %s", name)
+	}
+
+	if detail != DetailFull || listKeys != nil {
+		f = &detailFormatter{
+			formatter: f,
+			detail:    detail,
+			listKeys:  listKeys,
+		}
+	}
+	return f, nil
+}
+
+// StatusFormatter is an optional interface implemented by formatters that
+// can format changes in the health of audit backends.
+type StatusFormatter interface {
+	FormatStatus(io.Writer, *Status) error
+}
+
+// HeartbeatFormatter is an optional interface implemented by formatters
+// that can format heartbeats.
+type HeartbeatFormatter interface {
+	FormatHeartbeat(io.Writer, *Heartbeat) error
+}
+
+// SummaryFormatter is an optional interface implemented by formatters
+// that can format the summaries of dropped entries.
+type SummaryFormatter interface {
+	FormatSummary(io.Writer, *Summary) error
+}
+
+// InventoryFormatter is an optional interface implemented by formatters
+// that can format inventories.
+type InventoryFormatter interface {
+	FormatInventory(io.Writer, *Inventory) error
+}
+
+// AuditChangeFormatter is an optional interface implemented by formatters
+// that can format the changes of the audit backends.
+type AuditChangeFormatter interface {
+	FormatAuditChange(io.Writer, *AuditChange) error
+}
+
+// SelfTestFormatter is an optional interface implemented by formatters
+// that can format self-tests.
+type SelfTestFormatter interface {
+	FormatSelfTest(io.Writer, *SelfTest) error
+}
+
+// FormatEvent formats an event other than a request or a response, such
+// as a *Heartbeat or an *Inventory, with the optional interface of the
+// formatter for its type. It returns no entry if the formatter doesn't
+// implement that interface, so that backends can skip the event.
+func FormatEvent(f Formatter, ev interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch ev := ev.(type) {
+	case *Status:
+		if sf, ok := f.(StatusFormatter); ok {
+			err = sf.FormatStatus(&buf, ev)
+		}
+	case *Heartbeat:
+		if hf, ok := f.(HeartbeatFormatter); ok {
+			err = hf.FormatHeartbeat(&buf, ev)
+		}
+	case *Summary:
+		if sf, ok := f.(SummaryFormatter); ok {
+			err = sf.FormatSummary(&buf, ev)
+		}
+	case *Inventory:
+		if inf, ok := f.(InventoryFormatter); ok {
+			err = inf.FormatInventory(&buf, ev)
+		}
+	case *AuditChange:
+		if cf, ok := f.(AuditChangeFormatter); ok {
+			err = cf.FormatAuditChange(&buf, ev)
+		}
+	case *SelfTest:
+		if sf, ok := f.(SelfTestFormatter); ok {
+			err = sf.FormatSelfTest(&buf, ev)
+		}
+	default:
+		return nil, fmt.Errorf("unknown event: %T", ev)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,31 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatEvent(t *testing.T) {
+	hb := &Heartbeat{Backend: "file/"}
+
+	entry, err := FormatEvent(&FormatJSON{}, hb)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(string(entry), `"type":"heartbeat"`) {
+		t.Fatalf("bad: %s", entry)
+	}
+
+	// Formats without heartbeats write nothing
+	entry, err = FormatEvent(&FormatCSV{}, hb)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(entry) != 0 {
+		t.Fatalf("bad: %s", entry)
+	}
+
+	if _, err := FormatEvent(&FormatJSON{}, "heartbeat"); err == nil {
+		t.Fatal("should error with an unknown event")
+	}
+}
@@ -0,0 +1,151 @@
+// +build gofuzz
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// These are the entry points for go-fuzz. They are only built with the
+// gofuzz tag:
+//
+//	go-fuzz-build -func FuzzHashStructure github.com/hashicorp/vault/audit
+//	go-fuzz -bin audit-fuzz.zip -workdir /tmp/fuzz
+//
+
+// FuzzHashStructure hashes arbitrary JSON data and checks that no string
+// is left unhashed.
+func FuzzHashStructure(data []byte) int {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return 0
+	}
+
+	hashed, err := HashStructure(raw, fuzzHash)
+	if err != nil {
+		panic(err)
+	}
+	if err := fuzzCheckHashed(hashed); err != "" {
+		panic(err)
+	}
+
+	// The original must not be modified
+	var orig interface{}
+	json.Unmarshal(data, &orig)
+	a, _ := json.Marshal(orig)
+	b, _ := json.Marshal(raw)
+	if !bytes.Equal(a, b) {
+		panic("input modified")
+	}
+
+	return 1
+}
+
+// FuzzFormat formats requests and responses with arbitrary JSON data with
+// every format and level of detail. The first byte of the input selects
+// the options of the formatter.
+func FuzzFormat(data []byte) int {
+	if len(data) < 1 {
+		return 0
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data[1:], &raw); err != nil {
+		return 0
+	}
+
+	opts := data[0]
+	conf := map[string]string{
+		"format":         []string{"json", "csv", "combined", "pretty"}[int(opts&3)],
+		"detail":         []string{"full", "standard", "minimal"}[int(opts>>2&3)%3],
+		"log_raw":        fuzzBool(opts&16 != 0),
+		"hash_list_keys": fuzzBool(opts&32 != 0),
+	}
+	if conf["format"] == "json" && opts&64 != 0 {
+		conf["flatten"] = "true"
+	}
+	if conf["format"] == "pretty" && opts&64 != 0 {
+		conf["pretty_details"] = "true"
+	}
+	if opts&128 != 0 {
+		conf["max_entry_size"] = "256"
+	}
+
+	f, err := NewFormatter(conf)
+	if err != nil {
+		panic(err)
+	}
+
+	auth := &logical.Auth{
+		ClientToken: "foo",
+		DisplayName: "bar",
+		Metadata:    map[string]string{"baz": "qux"},
+	}
+	req := &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "secret/",
+		Data:      raw,
+	}
+	resp := &logical.Response{Data: raw}
+
+	// Errors, such as entries that are too large, are expected. Only
+	// panics and invalid output are failures.
+	var buf bytes.Buffer
+	if err := f.FormatRequest(&buf, auth, req); err != nil {
+		return 0
+	}
+	if err := f.FormatResponse(&buf, auth, req, resp, nil); err != nil {
+		return 0
+	}
+
+	// Every JSON entry must be a single valid line
+	if conf["format"] == "json" {
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var v interface{}
+			if err := json.Unmarshal([]byte(line), &v); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	return 1
+}
+
+func fuzzHash(v string) (string, error) {
+	return "hashed:" + v, nil
+}
+
+func fuzzBool(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// fuzzCheckHashed returns a description of the first string in v that
+// wasn't hashed, or an empty string if there is none.
+func fuzzCheckHashed(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		if !strings.HasPrefix(v, "hashed:") {
+			return "unhashed value: " + v
+		}
+	case map[string]interface{}:
+		for _, e := range v {
+			if err := fuzzCheckHashed(e); err != "" {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			if err := fuzzCheckHashed(e); err != "" {
+				return err
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,568 @@
+package audit
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// HashConfig is the hashing configuration of an audit backend. It is
+// parsed from the options the backend was enabled with, so that external
+// tools can reproduce exactly the values a backend emits.
+type HashConfig struct {
+	// Raw disables hashing, from the "log_raw" option.
+	Raw bool
+
+	// Salt is appended to values before they are hashed, from the
+	// "salt" option.
+	Salt string
+
+	// Algorithm is "sha1" (the default) or "sha256", from the
+	// "hash_algorithm" option.
+	Algorithm string
+
+	// MaxKeyLength is the maximum length in bytes of the keys of the
+	// request and response data, from the "max_key_length" option.
+	// Longer keys are shortened, see capKey. Zero means no limit.
+	MaxKeyLength int
+
+	// Stability is "per_entry" if a random nonce is mixed into the hashes
+	// of each entry, from the "hash_stability" option, see ForEntry. It is
+	// "stable" or empty if identical values are always hashed to identical
+	// outputs, so that entries can be correlated.
+	Stability string
+
+	// PreviousSalt is the salt the backend used before its salt was
+	// rotated, from the "previous_salt" option. Until PreviousSaltUntil,
+	// from the "previous_salt_until" option in RFC 3339 format, the client
+	// tokens are also hashed with it, see HashEvent.
+	PreviousSalt      string
+	PreviousSaltUntil time.Time
+
+	// PreserveFormatKeys are the glob patterns, such as "card_*", of the
+	// keys of the request and response data whose values are redacted
+	// with HashPreserveFormat rather than hashed, from the comma separated
+	// "preserve_format_keys" option. Keys are matched at any depth, with
+	// the syntax of path.Match.
+	PreserveFormatKeys []string
+
+	// nonce is appended to the salt, see ForEntry
+	nonce string
+
+	// clock tells whether the rotation window is over, see SetClock
+	clock func() time.Time
+}
+
+// minMaxKeyLength is the smallest allowed MaxKeyLength, which leaves room
+// for the suffix that keeps shortened keys distinct.
+const minMaxKeyLength = 16
+
+// ParseHashConfig parses the hashing options out of the options of an
+// audit backend. Options that don't relate to hashing are ignored.
+func ParseHashConfig(conf map[string]string) (*HashConfig, error) {
+	c := &HashConfig{
+		Salt:      conf["salt"],
+		Algorithm: "sha1",
+	}
+
+	if raw, ok := conf["log_raw"]; ok {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		c.Raw = b
+	}
+
+	if v, ok := conf["max_key_length"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || (n != 0 && n < minMaxKeyLength) {
+			return nil, fmt.Errorf(
+				"invalid max_key_length, must be at least %d: %s",
+				minMaxKeyLength, v)
+		}
+		c.MaxKeyLength = n
+	}
+
+	if v, ok := conf["hash_algorithm"]; ok {
+		switch v {
+		case "sha1", "sha256":
+			c.Algorithm = v
+		default:
+			return nil, fmt.Errorf("unknown hash_algorithm: %s", v)
+		}
+	}
+
+	if v, ok := conf["hash_stability"]; ok {
+		switch v {
+		case "stable", "per_entry":
+			c.Stability = v
+		default:
+			return nil, fmt.Errorf("unknown hash_stability: %s", v)
+		}
+	}
+
+	if v, ok := conf["previous_salt_until"]; ok && v != "" {
+		until, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid previous_salt_until: %s", v)
+		}
+		c.PreviousSalt = conf["previous_salt"]
+		c.PreviousSaltUntil = until
+	}
+
+	if v, ok := conf["preserve_format_keys"]; ok && v != "" {
+		for _, glob := range strings.Split(v, ",") {
+			glob = strings.TrimSpace(glob)
+			if _, err := path.Match(glob, ""); err != nil || glob == "" {
+				return nil, fmt.Errorf("invalid preserve_format_keys: %s", v)
+			}
+			c.PreserveFormatKeys = append(c.PreserveFormatKeys, glob)
+		}
+
+		// Without a salt, short values such as card numbers could be
+		// recovered by hashing every candidate
+		if c.Salt == "" {
+			return nil, fmt.Errorf("preserve_format_keys requires a salt")
+		}
+	}
+
+	return c, nil
+}
+
+// ForEntry returns the configuration to hash the events of a single entry
+// with. If the stability is "per_entry", it is a copy with a new random
+// nonce, so that identical values in different entries are hashed
+// differently. Otherwise it is the configuration itself.
+func (c *HashConfig) ForEntry() (*HashConfig, error) {
+	if c.Raw || c.Stability != "per_entry" {
+		return c, nil
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	cp := *c
+	cp.nonce = hex.EncodeToString(nonce)
+	return &cp, nil
+}
+
+// Callback returns the HashCallback for the configured algorithm
+// and salt.
+func (c *HashConfig) Callback() HashCallback {
+	switch c.Algorithm {
+	case "sha256":
+		return HashSHA256(c.Salt + c.nonce)
+	default:
+		return HashSHA1(c.Salt + c.nonce)
+	}
+}
+
+// SetClock sets the clock the end of the rotation window is checked
+// against, see audit.ClockSetter. It defaults to time.Now.
+func (c *HashConfig) SetClock(clock func() time.Time) {
+	c.clock = clock
+}
+
+func (c *HashConfig) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock()
+}
+
+// previousCallback returns the HashCallback for the previous salt, or nil
+// if the rotation window is over. Hashes that differ per entry can't be
+// correlated anyway, so they are never computed with the previous salt.
+func (c *HashConfig) previousCallback() HashCallback {
+	if c.PreviousSalt == "" || c.Stability == "per_entry" ||
+		!c.now().Before(c.PreviousSaltUntil) {
+		return nil
+	}
+	switch c.Algorithm {
+	case "sha256":
+		return HashSHA256(c.PreviousSalt)
+	default:
+		return HashSHA1(c.PreviousSalt)
+	}
+}
+
+// preserveFormat returns whether the values of the key are redacted with
+// HashPreserveFormat.
+func (c *HashConfig) preserveFormat(key string) bool {
+	for _, glob := range c.PreserveFormatKeys {
+		if ok, _ := path.Match(glob, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// HashPreserveFormat returns a HashCallback that replaces each ASCII
+// letter and digit of data with another one of the same kind, chosen from
+// the SHA256 of the data with an optional salt, so that the result has the
+// length and the shape of the data. Other letters and digits are replaced
+// with lowercase ASCII letters and digits, and any other character, such
+// as a separator, is kept. If the digits of the data pass the Luhn check,
+// the last digit is chosen so that the redacted digits pass it too, so
+// that credit card numbers, for example, can still be validated and parsed
+// once redacted. Identical values are redacted identically. Short values
+// can be guessed, however, if the salt is known.
+func HashPreserveFormat(salt string) HashCallback {
+	return func(v string) (string, error) {
+		var stream []byte
+		var block int
+		next := func() int {
+			if len(stream) == 0 {
+				sum := sha256.Sum256([]byte(v + salt + strconv.Itoa(block)))
+				stream = sum[:]
+				block++
+			}
+			b := stream[0]
+			stream = stream[1:]
+			return int(b)
+		}
+
+		result := make([]rune, 0, len(v))
+		var digits, redacted []int
+		for _, r := range v {
+			switch {
+			case r >= 'A' && r <= 'Z':
+				r = 'A' + rune(next()%26)
+			case r >= '0' && r <= '9':
+				digits = append(digits, int(r-'0'))
+				r = '0' + rune(next()%10)
+				redacted = append(redacted, len(result))
+			case unicode.IsDigit(r):
+				r = '0' + rune(next()%10)
+			case unicode.IsLetter(r):
+				r = 'a' + rune(next()%26)
+			}
+			result = append(result, r)
+		}
+
+		if n := len(digits); n > 1 && luhnCheckDigit(digits[:n-1]) == digits[n-1] {
+			payload := make([]int, n-1)
+			for i, pos := range redacted[:n-1] {
+				payload[i] = int(result[pos] - '0')
+			}
+			result[redacted[n-1]] = '0' + rune(luhnCheckDigit(payload))
+		}
+		return string(result), nil
+	}
+}
+
+// luhnCheckDigit returns the digit that makes the digits pass the Luhn
+// check once appended.
+func luhnCheckDigit(digits []int) int {
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 0 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
+
+// HashString returns the value as it would be emitted by a backend with
+// the given configuration.
+func HashString(conf *HashConfig, value string) (string, error) {
+	if conf.Raw {
+		return value, nil
+	}
+	return conf.Callback()(value)
+}
+
+// HashRequest returns the auth and the request of a request entry as they
+// would be emitted by a backend with the given configuration, hashed for
+// the same entry, see ForEntry and HashEvent.
+func HashRequest(conf *HashConfig, auth *logical.Auth,
+	req *logical.Request) (*logical.Auth, *logical.Request, error) {
+	hash, err := conf.ForEntry()
+	if err != nil {
+		return nil, nil, err
+	}
+	return hashRequest(hash, auth, req)
+}
+
+// HashResponse is like HashRequest for the auth, the request, and the
+// response of a response entry.
+func HashResponse(conf *HashConfig, auth *logical.Auth, req *logical.Request,
+	resp *logical.Response) (*logical.Auth, *logical.Request, *logical.Response, error) {
+	hash, err := conf.ForEntry()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	auth, req, err = hashRequest(hash, auth, req)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cp, err := HashEvent(hash, resp)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return auth, req, cp.(*logical.Response), nil
+}
+
+func hashRequest(conf *HashConfig, auth *logical.Auth,
+	req *logical.Request) (*logical.Auth, *logical.Request, error) {
+	cp, err := HashEvent(conf, auth)
+	if err != nil {
+		return nil, nil, err
+	}
+	auth = cp.(*logical.Auth)
+
+	cp, err = HashEvent(conf, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	return auth, cp.(*logical.Request), nil
+}
+
+// HashEvent returns the auth, request, or response as it would be emitted
+// by a backend with the given configuration. The event is never modified,
+// but the result may share the values that aren't hashed with it.
+func HashEvent(conf *HashConfig, event interface{}) (interface{}, error) {
+	if conf.Raw {
+		return event, nil
+	}
+
+	// Only the client tokens and the data are hashed, and the data is
+	// copied when it is hashed, so a shallow copy of the event suffices.
+	var result interface{}
+	switch e := event.(type) {
+	case *logical.Auth:
+		result = shallowCopyAuth(e)
+	case *logical.Request:
+		if e != nil {
+			cp := *e
+			cp.Auth = shallowCopyAuth(e.Auth)
+			result = &cp
+		} else {
+			result = e
+		}
+	case *logical.Response:
+		if e != nil {
+			cp := *e
+			cp.Auth = shallowCopyAuth(e.Auth)
+			result = &cp
+		} else {
+			result = e
+		}
+	default:
+		return nil, fmt.Errorf("unsupported event type: %T", event)
+	}
+
+	if err := hashWith(result, conf.Callback()); err != nil {
+		return nil, err
+	}
+	if fn := conf.previousCallback(); fn != nil {
+		if err := hashPreviousClientToken(event, result, fn); err != nil {
+			return nil, err
+		}
+	}
+
+	// The hashed data is a copy, so its values can be replaced too
+	if len(conf.PreserveFormatKeys) > 0 {
+		fn := HashPreserveFormat(conf.Salt + conf.nonce)
+		switch e := result.(type) {
+		case *logical.Request:
+			if e != nil {
+				if err := conf.redactPreserveFormat(event.(*logical.Request).Data, e.Data, fn); err != nil {
+					return nil, err
+				}
+			}
+		case *logical.Response:
+			if e != nil {
+				if err := conf.redactPreserveFormat(event.(*logical.Response).Data, e.Data, fn); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// The hashed data is a copy, so its keys can be replaced
+	if conf.MaxKeyLength > 0 {
+		switch e := result.(type) {
+		case *logical.Request:
+			if e != nil {
+				e.Data = capKeys(e.Data, conf.MaxKeyLength)
+			}
+		case *logical.Response:
+			if e != nil {
+				e.Data = capKeys(e.Data, conf.MaxKeyLength)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// previousClientTokenKey is the key of the internal data of the hashed
+// copy of an auth that holds its client token hashed with the previous
+// salt. The internal data is never logged, so the key only carries the
+// token from HashEvent to the formatters.
+const previousClientTokenKey = "audit_client_token_previous"
+
+// previousClientToken returns the client token of a hashed auth hashed
+// with the previous salt, if it was during a rotation window.
+func previousClientToken(auth *logical.Auth) string {
+	token, _ := auth.InternalData[previousClientTokenKey].(string)
+	return token
+}
+
+// setPreviousClientToken sets the client token of an auth hashed with the
+// previous salt, on a copy of its internal data.
+func setPreviousClientToken(auth *logical.Auth, token string) {
+	internal := make(map[string]interface{}, len(auth.InternalData)+1)
+	for k, v := range auth.InternalData {
+		internal[k] = v
+	}
+	internal[previousClientTokenKey] = token
+	auth.InternalData = internal
+}
+
+// hashPreviousClientToken sets the client token of the event hashed with
+// the previous salt on the auth of the hashed copy of the event, see
+// previousClientToken.
+func hashPreviousClientToken(event, result interface{}, fn HashCallback) error {
+	var auth, hashed *logical.Auth
+	switch e := event.(type) {
+	case *logical.Auth:
+		auth, hashed = e, result.(*logical.Auth)
+	case *logical.Request:
+		if e != nil {
+			auth, hashed = e.Auth, result.(*logical.Request).Auth
+		}
+	case *logical.Response:
+		if e != nil {
+			auth, hashed = e.Auth, result.(*logical.Response).Auth
+		}
+	}
+	if auth == nil || auth.ClientToken == "" {
+		return nil
+	}
+
+	token, err := fn(auth.ClientToken)
+	if err != nil {
+		return err
+	}
+	setPreviousClientToken(hashed, token)
+	return nil
+}
+
+// redactPreserveFormat replaces the hashed values of the keys of data that
+// match PreserveFormatKeys with the original values redacted with fn,
+// descending into the nested objects and arrays. The hashed data must be
+// a copy of the original data with its values hashed and its keys intact.
+// Only the types produced by decoding JSON are redacted.
+func (c *HashConfig) redactPreserveFormat(data, hashed map[string]interface{}, fn HashCallback) error {
+	for k, v := range data {
+		if c.preserveFormat(k) {
+			redacted, ok, err := hashJSON(v, fn)
+			if err != nil {
+				return err
+			}
+			if ok {
+				hashed[k] = redacted
+			}
+			continue
+		}
+		if err := c.redactPreserveFormatValue(v, hashed[k], fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *HashConfig) redactPreserveFormatValue(v, hashed interface{}, fn HashCallback) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if h, ok := hashed.(map[string]interface{}); ok {
+			return c.redactPreserveFormat(v, h, fn)
+		}
+	case []interface{}:
+		if h, ok := hashed.([]interface{}); ok && len(h) == len(v) {
+			for i := range v {
+				if err := c.redactPreserveFormatValue(v[i], h[i], fn); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func shallowCopyAuth(auth *logical.Auth) *logical.Auth {
+	if auth == nil {
+		return nil
+	}
+	cp := *auth
+	return &cp
+}
+
+// capKeys returns the data with the keys of all the nested objects that
+// are longer than max shortened with capKey. Only the types produced by
+// decoding JSON are descended into.
+func capKeys(data map[string]interface{}, max int) map[string]interface{} {
+	if data == nil {
+		return nil
+	}
+
+	result := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		result[capKey(k, max)] = capKeysValue(v, max)
+	}
+	return result
+}
+
+func capKeysValue(v interface{}, max int) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return capKeys(v, max)
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = capKeysValue(elem, max)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// capKey returns the key if it is at most max bytes of valid UTF-8.
+// Otherwise it returns the longest prefix of whole characters that leaves
+// room for a "~" and the first 8 hex characters of the SHA1 of the key,
+// so that distinct keys remain distinct. Invalid UTF-8 in the prefix is
+// replaced, since it can break parsers of the audit log.
+func capKey(k string, max int) string {
+	if len(k) <= max && utf8.ValidString(k) {
+		return k
+	}
+
+	sum := sha1.Sum([]byte(k))
+	suffix := "~" + hex.EncodeToString(sum[:])[:8]
+
+	var prefix []byte
+	for _, r := range k {
+		if len(prefix)+utf8.RuneLen(r) > max-len(suffix) {
+			break
+		}
+		prefix = append(prefix, string(r)...)
+	}
+	return string(prefix) + suffix
+}
@@ -0,0 +1,494 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestParseHashConfig(t *testing.T) {
+	cases := []struct {
+		Conf     map[string]string
+		Expected *HashConfig
+		Err      bool
+	}{
+		{
+			map[string]string{},
+			&HashConfig{Algorithm: "sha1"},
+			false,
+		},
+		{
+			map[string]string{"log_raw": "true", "path": "/tmp/audit.log"},
+			&HashConfig{Raw: true, Algorithm: "sha1"},
+			false,
+		},
+		{
+			map[string]string{"salt": "foo", "hash_algorithm": "sha256"},
+			&HashConfig{Salt: "foo", Algorithm: "sha256"},
+			false,
+		},
+		{
+			map[string]string{"log_raw": "maybe"},
+			nil,
+			true,
+		},
+		{
+			map[string]string{"hash_algorithm": "md5"},
+			nil,
+			true,
+		},
+		{
+			map[string]string{"max_key_length": "64"},
+			&HashConfig{Algorithm: "sha1", MaxKeyLength: 64},
+			false,
+		},
+		{
+			map[string]string{"max_key_length": "8"},
+			nil,
+			true,
+		},
+		{
+			map[string]string{"hash_stability": "per_entry"},
+			&HashConfig{Algorithm: "sha1", Stability: "per_entry"},
+			false,
+		},
+		{
+			map[string]string{"hash_stability": "never"},
+			nil,
+			true,
+		},
+		{
+			map[string]string{
+				"salt":                "foo",
+				"previous_salt":       "bar",
+				"previous_salt_until": "2016-01-02T03:04:05Z",
+			},
+			&HashConfig{
+				Salt:              "foo",
+				Algorithm:         "sha1",
+				PreviousSalt:      "bar",
+				PreviousSaltUntil: time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC),
+			},
+			false,
+		},
+		{
+			map[string]string{"previous_salt": "bar", "previous_salt_until": "tomorrow"},
+			nil,
+			true,
+		},
+		{
+			map[string]string{"salt": "foo", "preserve_format_keys": "card_number, *_ssn"},
+			&HashConfig{Salt: "foo", Algorithm: "sha1", PreserveFormatKeys: []string{"card_number", "*_ssn"}},
+			false,
+		},
+		{
+			map[string]string{"preserve_format_keys": "card_number"},
+			nil,
+			true,
+		},
+		{
+			map[string]string{"preserve_format_keys": "card_[number"},
+			nil,
+			true,
+		},
+		{
+			map[string]string{"preserve_format_keys": "card_number,"},
+			nil,
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		actual, err := ParseHashConfig(tc.Conf)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%d: err: %s", i, err)
+		}
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Fatalf("%d: bad: %#v", i, actual)
+		}
+	}
+}
+
+func TestHashString(t *testing.T) {
+	cases := []struct {
+		Conf     *HashConfig
+		Expected string
+	}{
+		{
+			&HashConfig{Algorithm: "sha1"},
+			"sha1:0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33",
+		},
+		{
+			&HashConfig{Algorithm: "sha1", Salt: "bar"},
+			"sha1:8843d7f92416211de9ebb963ff4ce28125932878",
+		},
+		{
+			&HashConfig{Algorithm: "sha256"},
+			"sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+		},
+		{
+			&HashConfig{Raw: true, Algorithm: "sha1"},
+			"foo",
+		},
+	}
+
+	for i, tc := range cases {
+		actual, err := HashString(tc.Conf, "foo")
+		if err != nil {
+			t.Fatalf("%d: err: %s", i, err)
+		}
+		if actual != tc.Expected {
+			t.Fatalf("%d: bad: %s", i, actual)
+		}
+	}
+}
+
+func TestHashEvent(t *testing.T) {
+	conf := &HashConfig{Algorithm: "sha1"}
+	req := &logical.Request{
+		Auth: &logical.Auth{ClientToken: "foo"},
+		Path: "secret/foo",
+		Data: map[string]interface{}{
+			"value": "foo",
+		},
+	}
+
+	raw, err := HashEvent(conf, req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	actual := raw.(*logical.Request)
+	expected := "sha1:0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33"
+	if actual.Data["value"] != expected {
+		t.Fatalf("bad: %#v", actual)
+	}
+	if actual.Auth.ClientToken != expected {
+		t.Fatalf("bad: %#v", actual.Auth)
+	}
+	if req.Data["value"] != "foo" || req.Auth.ClientToken != "foo" {
+		t.Fatalf("event should not be modified: %#v", req)
+	}
+
+	if _, err := HashEvent(conf, "foo"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestHashEvent_previousSalt(t *testing.T) {
+	resp := &logical.Response{
+		Auth: &logical.Auth{ClientToken: "foo"},
+	}
+	now := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	conf := &HashConfig{
+		Salt:              "new",
+		Algorithm:         "sha1",
+		PreviousSalt:      "old",
+		PreviousSaltUntil: now.Add(time.Hour),
+	}
+	conf.SetClock(func() time.Time { return now })
+
+	// Both hashes are written during the rotation window
+	cp, err := HashEvent(conf, resp)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	auth := cp.(*logical.Response).Auth
+	expected, _ := HashSHA1("new")("foo")
+	previous, _ := HashSHA1("old")("foo")
+	if auth.ClientToken != expected || previousClientToken(auth) != previous {
+		t.Fatalf("bad: %#v", auth)
+	}
+	if resp.Auth.ClientToken != "foo" || previousClientToken(resp.Auth) != "" {
+		t.Fatalf("modified: %#v", resp.Auth)
+	}
+
+	// and the previous one is written by the formatter
+	var buf bytes.Buffer
+	if err := (&FormatJSON{}).FormatResponse(&buf, nil, &logical.Request{}, cp.(*logical.Response), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(buf.String(), `"client_token_previous":"`+previous+`"`) {
+		t.Fatalf("bad: %s", buf.String())
+	}
+
+	// and only the new one once it is over, or if the hashes differ per
+	// entry
+	now = now.Add(time.Hour)
+	cp, err = HashEvent(conf, resp)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if auth := cp.(*logical.Response).Auth; previousClientToken(auth) != "" {
+		t.Fatalf("bad: %#v", auth)
+	}
+
+	now = now.Add(-time.Second)
+	conf.Stability = "per_entry"
+	cp, err = HashEvent(conf, resp)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if auth := cp.(*logical.Response).Auth; previousClientToken(auth) != "" {
+		t.Fatalf("bad: %#v", auth)
+	}
+}
+
+func TestHashConfig_forEntry(t *testing.T) {
+	req := &logical.Request{
+		Path: "secret/foo",
+		Data: map[string]interface{}{"value": "bar"},
+	}
+	hashValue := func(conf *HashConfig) interface{} {
+		entry, err := conf.ForEntry()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		result, err := HashEvent(entry, req)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return result.(*logical.Request).Data["value"]
+	}
+
+	stable := &HashConfig{Algorithm: "sha1", Stability: "stable"}
+	if a, b := hashValue(stable), hashValue(stable); a != b {
+		t.Fatalf("bad: %v %v", a, b)
+	}
+
+	perEntry := &HashConfig{Algorithm: "sha1", Stability: "per_entry"}
+	if a, b := hashValue(perEntry), hashValue(perEntry); a == b {
+		t.Fatalf("bad: %v %v", a, b)
+	}
+	if a := hashValue(perEntry); a == hashValue(stable) || a == "bar" {
+		t.Fatalf("bad: %v", a)
+	}
+}
+
+func TestHashResponse(t *testing.T) {
+	auth := &logical.Auth{ClientToken: "foo"}
+	req := &logical.Request{
+		Data: map[string]interface{}{"value": "foo"},
+	}
+	resp := &logical.Response{
+		Data: map[string]interface{}{"value": "foo"},
+	}
+
+	// The events of an entry are hashed with the same nonce, so that they
+	// can be correlated within the entry, but not across entries
+	conf := &HashConfig{Algorithm: "sha1", Stability: "per_entry"}
+	hashedAuth, hashedReq, hashedResp, err := HashResponse(conf, auth, req, resp)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	token := hashedAuth.ClientToken
+	if token == "foo" || hashedReq.Data["value"] != token || hashedResp.Data["value"] != token {
+		t.Fatalf("bad: %#v %#v %#v", hashedAuth, hashedReq, hashedResp)
+	}
+	if auth.ClientToken != "foo" || req.Data["value"] != "foo" || resp.Data["value"] != "foo" {
+		t.Fatal("modified")
+	}
+
+	hashedAuth, hashedReq, err = HashRequest(conf, auth, req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if hashedAuth.ClientToken == token || hashedReq.Data["value"] != hashedAuth.ClientToken {
+		t.Fatalf("bad: %#v %#v", hashedAuth, hashedReq)
+	}
+}
+
+func TestCapKey(t *testing.T) {
+	long := strings.Repeat("a", 20)
+	cases := map[string]string{
+		"foo":                   "foo",
+		strings.Repeat("a", 16): strings.Repeat("a", 16),
+		long:                    "aaaaaaa~" + sha1Prefix(long),
+		"ééééééééé":             "ééé~" + sha1Prefix("ééééééééé"),
+		"foo\xffbar":            "foo\ufffdb~" + sha1Prefix("foo\xffbar"),
+	}
+	for k, expected := range cases {
+		actual := capKey(k, 16)
+		if actual != expected {
+			t.Fatalf("bad: %q: %q", k, actual)
+		}
+		if len(actual) > 16 || !utf8.ValidString(actual) {
+			t.Fatalf("bad: %q: %q", k, actual)
+		}
+	}
+}
+
+func TestHashEvent_maxKeyLength(t *testing.T) {
+	long := strings.Repeat("k", 32)
+	conf := &HashConfig{Raw: true, MaxKeyLength: 16}
+	req := &logical.Request{
+		Data: map[string]interface{}{
+			"nested": map[string]interface{}{
+				long: "foo",
+			},
+		},
+	}
+
+	// Keys are preserved when logging raw
+	raw, err := HashEvent(conf, req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if raw.(*logical.Request) != req {
+		t.Fatalf("bad: %#v", raw)
+	}
+
+	conf.Raw = false
+	raw, err = HashEvent(conf, req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	nested := raw.(*logical.Request).Data["nested"].(map[string]interface{})
+	if _, ok := nested[capKey(long, 16)]; !ok || len(nested) != 1 {
+		t.Fatalf("bad: %#v", nested)
+	}
+	if _, ok := req.Data["nested"].(map[string]interface{})[long]; !ok {
+		t.Fatalf("event should not be modified: %#v", req)
+	}
+}
+
+func TestHashPreserveFormat(t *testing.T) {
+	fn := HashPreserveFormat("foo")
+	for _, v := range []string{
+		"4111-1111-1111-1111",
+		"AB12 cd34",
+		"",
+		"é-Ä ٣",
+	} {
+		actual, err := fn(v)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		expected := []rune(v)
+		runes := []rune(actual)
+		if len(runes) != len(expected) {
+			t.Fatalf("bad: %q: %q", v, actual)
+		}
+		for i, r := range runes {
+			e := expected[i]
+			switch {
+			case e >= 'A' && e <= 'Z':
+				if r < 'A' || r > 'Z' {
+					t.Fatalf("bad: %q: %q", v, actual)
+				}
+			case unicode.IsDigit(e):
+				if r < '0' || r > '9' {
+					t.Fatalf("bad: %q: %q", v, actual)
+				}
+			case unicode.IsLetter(e):
+				if r < 'a' || r > 'z' {
+					t.Fatalf("bad: %q: %q", v, actual)
+				}
+			default:
+				if r != e {
+					t.Fatalf("bad: %q: %q", v, actual)
+				}
+			}
+		}
+
+		// Identical values are redacted identically, unless salted
+		// differently
+		again, _ := fn(v)
+		if again != actual {
+			t.Fatalf("bad: %q: %q != %q", v, again, actual)
+		}
+	}
+
+	a, _ := fn("4111-1111-1111-1111")
+	b, _ := HashPreserveFormat("bar")("4111-1111-1111-1111")
+	if a == b || a == "4111-1111-1111-1111" {
+		t.Fatalf("bad: %q %q", a, b)
+	}
+
+	// Card numbers still pass the Luhn check once redacted
+	for _, v := range []string{"4111-1111-1111-1111", "5555 5555 5555 4444", "79927398713"} {
+		for _, salt := range []string{"foo", "bar", "baz"} {
+			actual, _ := HashPreserveFormat(salt)(v)
+			var digits []int
+			for _, r := range actual {
+				if r >= '0' && r <= '9' {
+					digits = append(digits, int(r-'0'))
+				}
+			}
+			n := len(digits)
+			if luhnCheckDigit(digits[:n-1]) != digits[n-1] {
+				t.Fatalf("bad: %q: %q", v, actual)
+			}
+		}
+	}
+}
+
+func TestLuhnCheckDigit(t *testing.T) {
+	cases := []struct {
+		Digits   []int
+		Expected int
+	}{
+		{[]int{7, 9, 9, 2, 7, 3, 9, 8, 7, 1}, 3},
+		{[]int{4, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}, 1},
+		{[]int{0}, 0},
+	}
+	for _, tc := range cases {
+		if actual := luhnCheckDigit(tc.Digits); actual != tc.Expected {
+			t.Fatalf("bad: %v: %d", tc.Digits, actual)
+		}
+	}
+}
+
+func TestHashEvent_preserveFormat(t *testing.T) {
+	conf := &HashConfig{
+		Salt:               "foo",
+		Algorithm:          "sha1",
+		PreserveFormatKeys: []string{"card_*"},
+	}
+	req := &logical.Request{
+		Data: map[string]interface{}{
+			"card_number": "4111-1111-1111-1111",
+			"name":        "bob",
+			"cards": []interface{}{
+				map[string]interface{}{"card_cvc": "123"},
+			},
+		},
+	}
+
+	raw, err := HashEvent(conf, req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	data := raw.(*logical.Request).Data
+
+	redact := HashPreserveFormat("foo")
+	expected, _ := redact("4111-1111-1111-1111")
+	if data["card_number"] != expected {
+		t.Fatalf("bad: %#v", data)
+	}
+	expected, _ = redact("123")
+	cvc := data["cards"].([]interface{})[0].(map[string]interface{})["card_cvc"]
+	if cvc != expected {
+		t.Fatalf("bad: %#v", data)
+	}
+	expected, _ = HashSHA1("foo")("bob")
+	if data["name"] != expected {
+		t.Fatalf("bad: %#v", data)
+	}
+	if req.Data["card_number"] != "4111-1111-1111-1111" {
+		t.Fatalf("event should not be modified: %#v", req)
+	}
+}
+
+func sha1Prefix(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])[:8]
+}
@@ -2,6 +2,7 @@ package audit
 
 import (
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"reflect"
@@ -18,8 +19,12 @@ import (
 //
 // The structure is modified in-place.
 func Hash(raw interface{}) error {
-	fn := HashSHA1("")
+	return hashWith(raw, HashSHA1(""))
+}
 
+// hashWith hashes the given type in-place like Hash, using the given
+// HashCallback.
+func hashWith(raw interface{}, fn HashCallback) error {
 	switch s := raw.(type) {
 	case *logical.Auth:
 		if s == nil {
@@ -38,7 +43,7 @@ func Hash(raw interface{}) error {
 			return nil
 		}
 		if s.Auth != nil {
-			if err := Hash(s.Auth); err != nil {
+			if err := hashWith(s.Auth, fn); err != nil {
 				return err
 			}
 		}
@@ -54,7 +59,7 @@ func Hash(raw interface{}) error {
 			return nil
 		}
 		if s.Auth != nil {
-			if err := Hash(s.Auth); err != nil {
+			if err := hashWith(s.Auth, fn); err != nil {
 				return err
 			}
 		}
@@ -75,19 +80,77 @@ func Hash(raw interface{}) error {
 //
 // For the HashCallback, see the built-in HashCallbacks below.
 func HashStructure(s interface{}, cb HashCallback) (interface{}, error) {
+	// Most data is made up only of the types produced by decoding JSON,
+	// which can be copied and hashed without reflection.
+	if result, ok, err := hashJSON(s, cb); ok || err != nil {
+		return result, err
+	}
+
 	s, err := copystructure.Copy(s)
 	if err != nil {
 		return nil, err
 	}
 
+	// Walk a pointer so that a string at the top level can be replaced.
+	// The walker doesn't follow pointers inside interfaces, so pointers
+	// are walked directly.
+	var walk interface{} = &s
+	if reflect.ValueOf(s).Kind() == reflect.Ptr {
+		walk = s
+	}
+
 	walker := &hashWalker{Callback: cb}
-	if err := reflectwalk.Walk(s, walker); err != nil {
+	if err := reflectwalk.Walk(walk, walker); err != nil {
 		return nil, err
 	}
 
 	return s, nil
 }
 
+// hashJSON returns a hashed copy of data made up only of maps with string
+// keys, slices, strings, numbers, booleans, and nils. If any other type is
+// found, it returns false and the data must be walked with reflection.
+func hashJSON(v interface{}, cb HashCallback) (interface{}, bool, error) {
+	switch v := v.(type) {
+	case nil, bool, int, int64, float64:
+		return v, true, nil
+	case string:
+		hashed, err := cb(v)
+		if err != nil {
+			return nil, false, fmt.Errorf("Error hashing value: %s", err)
+		}
+		return hashed, true, nil
+	case map[string]interface{}:
+		if v == nil {
+			return v, true, nil
+		}
+		result := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			hashed, ok, err := hashJSON(elem, cb)
+			if !ok || err != nil {
+				return nil, ok, err
+			}
+			result[k] = hashed
+		}
+		return result, true, nil
+	case []interface{}:
+		if v == nil {
+			return v, true, nil
+		}
+		result := make([]interface{}, len(v))
+		for i, elem := range v {
+			hashed, ok, err := hashJSON(elem, cb)
+			if !ok || err != nil {
+				return nil, ok, err
+			}
+			result[i] = hashed
+		}
+		return result, true, nil
+	default:
+		return nil, false, nil
+	}
+}
+
 // HashCallback is the callback called for HashStructure to hash
 // a value.
 type HashCallback func(string) (string, error)
@@ -101,6 +164,15 @@ func HashSHA1(salt string) HashCallback {
 	}
 }
 
+// HashSHA256 returns a HashCallback that hashes data with SHA256 and
+// with an optional salt. If salt is a blank string, no salt is used.
+func HashSHA256(salt string) HashCallback {
+	return func(v string) (string, error) {
+		hashed := sha256.Sum256([]byte(v + salt))
+		return "sha256:" + hex.EncodeToString(hashed[:]), nil
+	}
+}
+
 // hashWalker implements interfaces for the reflectwalk package
 // (github.com/mitchellh/reflectwalk) that can be used to automatically
 // replace primitives with a hashed value.
@@ -188,12 +260,13 @@ func (w *hashWalker) Primitive(v reflect.Value) error {
 		return nil
 	}
 
-	replaceVal, err := w.Callback(v.Interface().(string))
+	// Types derived from string are hashed too, keeping their type
+	replaceVal, err := w.Callback(v.String())
 	if err != nil {
 		return fmt.Errorf("Error hashing value: %s", err)
 	}
 
-	resultVal := reflect.ValueOf(replaceVal)
+	resultVal := reflect.ValueOf(replaceVal).Convert(v.Type())
 	switch w.loc {
 	case reflectwalk.MapKey:
 		m := w.cs[len(w.cs)-1]
@@ -2,12 +2,14 @@ package audit
 
 import (
 	"fmt"
+	"math/rand"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/vault/logical"
 	"github.com/mitchellh/copystructure"
+	"github.com/mitchellh/reflectwalk"
 )
 
 func TestCopy_auth(t *testing.T) {
@@ -178,3 +180,199 @@ func TestHashSHA1(t *testing.T) {
 		t.Fatalf("bad: %#v", result)
 	}
 }
+
+// benchHashFlat is representative of the data of most requests and
+// responses, such as writing or reading a generic secret.
+var benchHashFlat = map[string]interface{}{
+	"username": "foo",
+	"password": "bar",
+	"ttl":      3600,
+	"enabled":  true,
+	"role":     "readonly",
+}
+
+// benchHashNested is representative of data with nested structure, such
+// as policies or backend configuration.
+var benchHashNested = map[string]interface{}{
+	"name":     "foo",
+	"policies": []interface{}{"root", "admin", "readonly"},
+	"config": map[string]interface{}{
+		"address": "127.0.0.1:8500",
+		"scheme":  "https",
+		"tags":    []interface{}{"a", "b"},
+		"limits": map[string]interface{}{
+			"max": 10,
+			"min": "1",
+		},
+	},
+}
+
+func BenchmarkHashStructure_flat(b *testing.B) {
+	fn := HashSHA1("")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := HashStructure(benchHashFlat, fn); err != nil {
+			b.Fatalf("err: %s", err)
+		}
+	}
+}
+
+func BenchmarkHashStructure_nested(b *testing.B) {
+	fn := HashSHA1("")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := HashStructure(benchHashNested, fn); err != nil {
+			b.Fatalf("err: %s", err)
+		}
+	}
+}
+
+func BenchmarkHashEvent_request(b *testing.B) {
+	conf := &HashConfig{Algorithm: "sha1"}
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/foo",
+		Data:      benchHashFlat,
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := HashEvent(conf, req); err != nil {
+			b.Fatalf("err: %s", err)
+		}
+	}
+}
+
+func BenchmarkHashEvent_response(b *testing.B) {
+	conf := &HashConfig{Algorithm: "sha1"}
+	resp := &logical.Response{
+		Secret: &logical.Secret{
+			LeaseID: "secret/foo/1234",
+		},
+		Auth: &logical.Auth{
+			ClientToken: "foo",
+			Policies:    []string{"root"},
+		},
+		Data: benchHashNested,
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := HashEvent(conf, resp); err != nil {
+			b.Fatalf("err: %s", err)
+		}
+	}
+}
+
+func TestHashStructure_json(t *testing.T) {
+	fn := HashSHA1("")
+	for _, input := range []interface{}{benchHashFlat, benchHashNested} {
+		actual, err := HashStructure(input, fn)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		// Compare with the result of the reflection based walker
+		expected, err := copystructure.Copy(input)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := reflectwalk.Walk(expected, &hashWalker{Callback: fn}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("bad:\n\n%#v\n\n%#v", actual, expected)
+		}
+	}
+}
+
+// TestHashStructure_random hashes randomly generated nested data. The
+// same checks are run on arbitrary input by FuzzHashStructure with go-fuzz.
+func TestHashStructure_random(t *testing.T) {
+	fn := HashSHA1("")
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		input := map[string]interface{}{"data": randomHashData(r, 0)}
+		orig, err := copystructure.Copy(input)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		actual, err := HashStructure(input, fn)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !reflect.DeepEqual(input, orig) {
+			t.Fatalf("input modified:\n\n%#v\n\n%#v", input, orig)
+		}
+
+		// Compare with the result of the reflection based walker
+		expected, err := copystructure.Copy(input)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := reflectwalk.Walk(&expected, &hashWalker{Callback: fn}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("bad:\n\n%#v\n\n%#v", actual, expected)
+		}
+	}
+}
+
+// randomHashData returns random data made up of the types produced by
+// decoding JSON, nested up to four levels deep.
+func randomHashData(r *rand.Rand, depth int) interface{} {
+	n := 6
+	if depth >= 4 {
+		n = 4
+	}
+	switch r.Intn(n) {
+	case 0:
+		return nil
+	case 1:
+		return r.Intn(2) == 0
+	case 2:
+		return r.Float64()
+	case 3:
+		return fmt.Sprintf("%x", r.Int63())
+	case 4:
+		s := make([]interface{}, r.Intn(4))
+		for i := range s {
+			s[i] = randomHashData(r, depth+1)
+		}
+		return s
+	default:
+		m := make(map[string]interface{})
+		for i := r.Intn(4); i > 0; i-- {
+			m[fmt.Sprintf("%x", r.Int63())] = randomHashData(r, depth+1)
+		}
+		return m
+	}
+}
+
+func TestHashStructure_stringType(t *testing.T) {
+	type name string
+	type entry struct {
+		Name name
+	}
+
+	fn := func(s string) (string, error) { return "hashed:" + s, nil }
+	cases := []struct {
+		Input    interface{}
+		Expected interface{}
+	}{
+		{name("foo"), name("hashed:foo")},
+		{&entry{Name: "foo"}, &entry{Name: "hashed:foo"}},
+		{map[string]name{"foo": "bar"}, map[string]name{"foo": "hashed:bar"}},
+	}
+
+	for _, tc := range cases {
+		actual, err := HashStructure(tc.Input, fn)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Fatalf("bad:\n\n%#v\n\n%#v", actual, tc.Expected)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"bytes"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// Preview returns the request and response entries that a backend enabled
+// with the given options would write for the events, without writing
+// anything. The events are hashed with the hashing options, see
+// ParseHashConfig, and formatted by the formatter of the options, see
+// NewFormatter, the same way as the builtin backends do, so that the
+// effect of the options on sensitive values can be checked beforehand.
+// The events are never modified.
+func Preview(
+	conf map[string]string,
+	auth *logical.Auth,
+	req *logical.Request,
+	resp *logical.Response,
+	respErr error) (string, string, error) {
+	hash, err := ParseHashConfig(conf)
+	if err != nil {
+		return "", "", err
+	}
+	formatter, err := NewFormatter(conf)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Each entry is hashed separately, as the backends do
+	hashedAuth, hashedReq, err := HashRequest(hash, auth, req)
+	if err != nil {
+		return "", "", err
+	}
+	var reqBuf bytes.Buffer
+	if err := formatter.FormatRequest(&reqBuf, hashedAuth, hashedReq); err != nil {
+		return "", "", err
+	}
+
+	hashedAuth, hashedReq, hashedResp, err := HashResponse(hash, auth, req, resp)
+	if err != nil {
+		return "", "", err
+	}
+	var respBuf bytes.Buffer
+	if err := formatter.FormatResponse(
+		&respBuf, hashedAuth, hashedReq, hashedResp, respErr); err != nil {
+		return "", "", err
+	}
+
+	return reqBuf.String(), respBuf.String(), nil
+}
@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPreview(t *testing.T) {
+	auth := &logical.Auth{ClientToken: "foo", DisplayName: "root"}
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/foo",
+		Data:      map[string]interface{}{"value": "bar"},
+	}
+	resp := &logical.Response{
+		Auth: &logical.Auth{ClientToken: "qux", DisplayName: "root"},
+		Data: map[string]interface{}{"value": "baz"},
+	}
+
+	reqEntry, respEntry, err := Preview(map[string]string{"salt": "salty"},
+		auth, req, resp, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var reqJSON JSONRequestEntry
+	if err := json.Unmarshal([]byte(reqEntry), &reqJSON); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	value, _ := HashSHA1("salty")("bar")
+	if reqJSON.Auth.DisplayName != "root" || reqJSON.Request.Data["value"] != value {
+		t.Fatalf("bad: %s", reqEntry)
+	}
+
+	var respJSON JSONResponseEntry
+	if err := json.Unmarshal([]byte(respEntry), &respJSON); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	token, _ := HashSHA1("salty")("qux")
+	value, _ = HashSHA1("salty")("baz")
+	if respJSON.Response.Auth.ClientToken != token || respJSON.Response.Data["value"] != value {
+		t.Fatalf("bad: %s", respEntry)
+	}
+
+	// The events are left as is
+	if resp.Auth.ClientToken != "qux" || req.Data["value"] != "bar" || resp.Data["value"] != "baz" {
+		t.Fatalf("events should not be modified")
+	}
+
+	// The formatting options apply too
+	reqEntry, respEntry, err = Preview(map[string]string{"format": "csv", "detail": "minimal"},
+		auth, req, resp, logical.ErrPermissionDenied)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if strings.Contains(reqEntry, "sha1:") || !strings.Contains(respEntry, "permission denied") {
+		t.Fatalf("bad: %s %s", reqEntry, respEntry)
+	}
+
+	if _, _, err := Preview(map[string]string{"hash_algorithm": "md5"},
+		auth, req, resp, nil); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	factoriesLock sync.RWMutex
+	factories     = make(map[string]Factory)
+	validators    = make(map[string]Validator)
+)
+
+// Register makes an audit backend factory available under the given type
+// name. It is meant to be called from the init function of the package
+// implementing the backend, so that importing the package is enough to
+// make the backend available. It panics if the name is registered twice
+// or if the factory is nil.
+func Register(name string, f Factory) {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+
+	if f == nil {
+		panic("audit: Register factory is nil")
+	}
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("audit: Register called twice for %s", name))
+	}
+	factories[name] = f
+}
+
+// Factories returns a copy of the registered audit backend factories,
+// keyed by type name.
+func Factories() map[string]Factory {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+
+	result := make(map[string]Factory, len(factories))
+	for k, f := range factories {
+		result[k] = f
+	}
+	return result
+}
+
+// RegisterValidator makes the Validator of an audit backend available
+// under the same type name as its factory, so that its options can be
+// checked offline. Like Register, it is meant to be called from an init
+// function and panics if the name is registered twice or if the
+// validator is nil.
+func RegisterValidator(name string, v Validator) {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+
+	if v == nil {
+		panic("audit: RegisterValidator validator is nil")
+	}
+	if _, ok := validators[name]; ok {
+		panic(fmt.Sprintf("audit: RegisterValidator called twice for %s", name))
+	}
+	validators[name] = v
+}
+
+// Validators returns a copy of the registered validators, keyed by type
+// name.
+func Validators() map[string]Validator {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+
+	result := make(map[string]Validator, len(validators))
+	for k, v := range validators {
+		result[k] = v
+	}
+	return result
+}
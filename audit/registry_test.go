@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	f := func(map[string]string) (Backend, error) { return nil, nil }
+	Register("test-register", f)
+
+	result := Factories()
+	if _, ok := result["test-register"]; !ok {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	// The result is a copy
+	delete(result, "test-register")
+	if _, ok := Factories()["test-register"]; !ok {
+		t.Fatal("should still be registered")
+	}
+
+	// Registering twice panics
+	defer func() {
+		if recover() == nil {
+			t.Fatal("should panic")
+		}
+	}()
+	Register("test-register", f)
+}
+
+func TestRegisterValidator(t *testing.T) {
+	v := func(conf map[string]string) (map[string]string, error) { return conf, nil }
+	RegisterValidator("test-register-validator", v)
+
+	if _, ok := Validators()["test-register-validator"]; !ok {
+		t.Fatalf("bad: %#v", Validators())
+	}
+
+	// Registering twice panics
+	defer func() {
+		if recover() == nil {
+			t.Fatal("should panic")
+		}
+	}()
+	RegisterValidator("test-register-validator", v)
+}
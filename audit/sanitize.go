@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// sanitizeString escapes the control characters of s, such as the escape
+// sequences of terminals, so that values chosen by clients cannot corrupt
+// a terminal or forge lines when the audit log is viewed. Each control
+// character is replaced with its \u escape.
+func sanitizeString(s string) string {
+	if strings.IndexFunc(s, unicode.IsControl) < 0 {
+		return s
+	}
+
+	var buf bytes.Buffer
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			fmt.Fprintf(&buf, `\u%04x`, r)
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// isUnescapedJSONControl returns whether r is a control character that
+// encoding/json writes as is: DEL and the C1 control characters.
+func isUnescapedJSONControl(r rune) bool {
+	return r >= 0x7f && unicode.IsControl(r)
+}
+
+// sanitizeJSON returns the encoded JSON with the control characters left
+// as is by encoding/json replaced with \u escapes. These characters can
+// only occur within strings, where the escapes are valid, so the result
+// decodes to the same value. The input is returned if there are none.
+func sanitizeJSON(b []byte) []byte {
+	if bytes.IndexFunc(b, isUnescapedJSONControl) < 0 {
+		return b
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(b))
+	for len(b) > 0 {
+		i := bytes.IndexFunc(b, isUnescapedJSONControl)
+		if i < 0 {
+			buf.Write(b)
+			break
+		}
+		buf.Write(b[:i])
+		r, size := utf8.DecodeRune(b[i:])
+		fmt.Fprintf(&buf, `\u%04x`, r)
+		b = b[i+size:]
+	}
+	return buf.Bytes()
+}
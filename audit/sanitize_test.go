@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestSanitizeString(t *testing.T) {
+	cases := map[string]string{
+		"secret/foo":             "secret/foo",
+		"héllo":                  "héllo",
+		"foo\x1b[2Jbar":          `foo\u001b[2Jbar`,
+		"foo\nbar\r":             `foo\u000abar\u000d`,
+		"del\x7f":                `del\u007f`,
+		"csi\u009b31m":           `csi\u009b31m`,
+		"tab\tseparated\x00null": `tab\u0009separated\u0000null`,
+	}
+	for in, expected := range cases {
+		if out := sanitizeString(in); out != expected {
+			t.Fatalf("bad: %q: %q", in, out)
+		}
+	}
+}
+
+func TestFormatJSON_sanitize(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/\x1b[2J\u009b31m\x7f",
+	}
+
+	for _, raw := range []bool{false, true} {
+		var buf bytes.Buffer
+		format := FormatJSON{Raw: raw}
+		if err := format.FormatRequest(&buf, nil, req); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		// No control characters other than the line ending unless raw
+		line := strings.TrimSuffix(buf.String(), "\n")
+		hasControl := strings.ContainsAny(line, "\x1b\u009b\x7f")
+		if hasControl != raw {
+			t.Fatalf("bad: %v: %q", raw, line)
+		}
+
+		// The entry decodes to the original value either way
+		var entry JSONRequestEntry
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if entry.Request.Path != req.Path {
+			t.Fatalf("bad: %q", entry.Request.Path)
+		}
+	}
+}
+
+func TestFormatCSV_sanitize(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo\x1b[2J",
+	}
+
+	var buf bytes.Buffer
+	var format FormatCSV
+	if err := format.FormatRequest(&buf, nil, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if strings.Contains(buf.String(), "\x1b") {
+		t.Fatalf("bad: %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), `secret/foo\u001b[2J`) {
+		t.Fatalf("bad: %q", buf.String())
+	}
+}
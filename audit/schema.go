@@ -0,0 +1,143 @@
+package audit
+
+//go:generate go run schema_generate.go
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// jsonSchemaDraft is the version of JSON Schema the schemas follow.
+const jsonSchemaDraft = "http://json-schema.org/draft-04/schema#"
+
+// jsonEntryTypes are the structures of the JSON audit entries, by the
+// value of their "type" field.
+var jsonEntryTypes = map[string]interface{}{
+	"request":            JSONRequestEntry{},
+	"response":           JSONResponseEntry{},
+	"audit_status":       JSONStatusEntry{},
+	"heartbeat":          JSONHeartbeatEntry{},
+	"rate_limit_summary": JSONSummaryEntry{},
+	"inventory":          JSONInventoryEntry{},
+	"audit_change":       JSONAuditChangeEntry{},
+	"self_test":          JSONSelfTestEntry{},
+}
+
+// JSONEntryTypes returns the values of the "type" field of the JSON audit
+// entries, sorted.
+func JSONEntryTypes() []string {
+	result := make([]string, 0, len(jsonEntryTypes))
+	for t := range jsonEntryTypes {
+		result = append(result, t)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// JSONEntrySchema returns the JSON Schema of the JSON audit entries of the
+// given type, such as "request", indented, so that pipelines can validate
+// the entries and map their fields. It describes the entries as they are
+// written without the "flatten" option. The fields that are always
+// written are required, and the fields written as null when they are
+// empty, such as the data, allow null.
+//
+// The schemas are generated in the schema directory with go generate.
+func JSONEntrySchema(entryType string) ([]byte, error) {
+	entry, ok := jsonEntryTypes[entryType]
+	if !ok {
+		return nil, fmt.Errorf("unknown entry type: %s", entryType)
+	}
+
+	schema := jsonSchema(reflect.TypeOf(entry))
+	schema["$schema"] = jsonSchemaDraft
+	schema["title"] = fmt.Sprintf("Vault audit %s entry", entryType)
+	schema["properties"].(map[string]interface{})["type"] = map[string]interface{}{
+		"type": "string",
+		"enum": []string{entryType},
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// jsonSchema returns the JSON Schema of the encoding of the type by
+// encoding/json.
+func jsonSchema(t reflect.Type) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		// The pointers of the entries are omitted when they are nil
+		return jsonSchema(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{
+			"type":  []string{"array", "null"},
+			"items": jsonSchema(t.Elem()),
+		}
+	case reflect.Map:
+		schema := map[string]interface{}{"type": []string{"object", "null"}}
+		if t.Elem().Kind() != reflect.Interface {
+			schema["additionalProperties"] = jsonSchema(t.Elem())
+		}
+		return schema
+	case reflect.Struct:
+		return jsonStructSchema(t)
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// jsonStructSchema returns the JSON Schema of a structure. The fields are
+// required unless they are omitted when empty, which structures never are.
+func jsonStructSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		omitEmpty := false
+		for _, opt := range tag[1:] {
+			if opt == "omitempty" {
+				omitEmpty = true
+			}
+		}
+
+		properties[name] = jsonSchema(f.Type)
+		if !omitEmpty || f.Type.Kind() == reflect.Struct {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
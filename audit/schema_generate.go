@@ -0,0 +1,26 @@
+// +build ignore
+
+// This program writes the JSON Schemas of the JSON audit entries to the
+// schema directory, one file per entry type. It is run by go generate.
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"path/filepath"
+
+	"github.com/hashicorp/vault/audit"
+)
+
+func main() {
+	for _, t := range audit.JSONEntryTypes() {
+		schema, err := audit.JSONEntrySchema(t)
+		if err != nil {
+			log.Fatal(err)
+		}
+		path := filepath.Join("schema", t+".json")
+		if err := ioutil.WriteFile(path, append(schema, '\n'), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// TestJSONEntrySchema checks that the generated schemas are up to date.
+// Run go generate in the audit directory to update them.
+func TestJSONEntrySchema(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("schema", "*.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(files) != len(JSONEntryTypes()) {
+		t.Fatalf("bad: %v", files)
+	}
+
+	for _, entryType := range JSONEntryTypes() {
+		schema, err := JSONEntrySchema(entryType)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		path := filepath.Join("schema", entryType+".json")
+		expected, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !bytes.Equal(append(schema, '\n'), expected) {
+			t.Fatalf("%s is out of date, run go generate:\n\n%s", path, schema)
+		}
+	}
+
+	if _, err := JSONEntrySchema("nope"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// TestJSONEntrySchema_required checks that the entries written have the
+// fields their schema requires.
+func TestJSONEntrySchema_required(t *testing.T) {
+	var buf bytes.Buffer
+	f := &FormatJSON{}
+	req := &logical.Request{Operation: logical.ReadOperation, Path: "secret/foo"}
+	if err := f.FormatRequest(&buf, nil, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := f.FormatResponse(&buf, nil, req, nil, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	dec := json.NewDecoder(&buf)
+	for {
+		var entry map[string]interface{}
+		if err := dec.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		raw, err := JSONEntrySchema(entry["type"].(string))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		var schema map[string]interface{}
+		if err := json.Unmarshal(raw, &schema); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		checkJSONSchemaRequired(t, entry["type"].(string), schema, entry)
+	}
+}
+
+func checkJSONSchemaRequired(
+	t *testing.T, path string, schema map[string]interface{}, v map[string]interface{}) {
+	required, _ := schema["required"].([]interface{})
+	for _, name := range required {
+		field, ok := v[name.(string)]
+		if !ok {
+			t.Fatalf("%s: missing required field %s", path, name)
+		}
+
+		properties := schema["properties"].(map[string]interface{})
+		nested, ok := field.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fieldSchema := properties[name.(string)].(map[string]interface{})
+		if fieldSchema["type"] == "object" {
+			checkJSONSchemaRequired(t, path+"."+name.(string), fieldSchema, nested)
+		}
+	}
+}
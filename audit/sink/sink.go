@@ -0,0 +1,147 @@
+// Package sink provides the connection management shared by the audit
+// backends that stream entries over a long lived connection: the
+// connection is opened on first use, reopened after it breaks, and
+// redialed with an exponential backoff while the sink is unreachable, so
+// that logging fails fast during an outage instead of every entry
+// waiting for a dial to time out.
+package sink
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMinBackoff and DefaultMaxBackoff bound the delays between
+	// the dials of a Conn that doesn't set its own.
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// Backoff computes exponentially increasing delays with jitter, so that
+// many Vaults reconnecting to the same sink don't do so in lockstep.
+type Backoff struct {
+	Min time.Duration
+	Max time.Duration
+
+	attempts uint
+}
+
+// Next returns the delay before the next attempt, which is between half
+// and all of Min doubled for each failed attempt, up to Max.
+func (b *Backoff) Next() time.Duration {
+	d := b.Min
+	for i := uint(0); i < b.attempts && d < b.Max; i++ {
+		d *= 2
+	}
+	if d > b.Max {
+		d = b.Max
+	}
+	b.attempts++
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Reset starts the delays over, after a successful attempt.
+func (b *Backoff) Reset() {
+	b.attempts = 0
+}
+
+// Conn manages a connection to a sink. It is safe for concurrent use,
+// and the functions given to Do are serialized.
+type Conn struct {
+	// Dial opens the connection.
+	Dial func() (io.Closer, error)
+
+	// Broken returns whether an error returned by the function given to
+	// Do leaves the connection in an unknown state, so that it must be
+	// reopened. If nil, every error breaks the connection.
+	Broken func(error) bool
+
+	// MinBackoff and MaxBackoff bound the delays between failed dials.
+	// They default to DefaultMinBackoff and DefaultMaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	l       sync.Mutex
+	conn    io.Closer
+	backoff *Backoff
+	retry   time.Time
+	err     error
+}
+
+// Do calls f with the connection, opening it first if needed. If the last
+// dial failed and its backoff hasn't elapsed, Do fails without dialing.
+func (c *Conn) Do(f func(io.Closer) error) error {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	if c.conn == nil {
+		if err := c.dial(); err != nil {
+			return err
+		}
+	}
+
+	err := f(c.conn)
+	if err != nil && (c.Broken == nil || c.Broken(err)) {
+		// A broken connection is redialed right away by the next call,
+		// the backoff only applies once dialing fails
+		c.conn.Close()
+		c.conn = nil
+		c.err = err
+	}
+	return err
+}
+
+func (c *Conn) dial() error {
+	if c.backoff == nil {
+		c.backoff = &Backoff{Min: c.MinBackoff, Max: c.MaxBackoff}
+		if c.backoff.Min <= 0 {
+			c.backoff.Min = DefaultMinBackoff
+		}
+		if c.backoff.Max <= 0 {
+			c.backoff.Max = DefaultMaxBackoff
+		}
+	}
+
+	if now := time.Now(); now.Before(c.retry) {
+		return fmt.Errorf("reconnecting in %s after error: %s",
+			c.retry.Sub(now), c.err)
+	}
+
+	conn, err := c.Dial()
+	if err != nil {
+		c.err = err
+		c.retry = time.Now().Add(c.backoff.Next())
+		return err
+	}
+	c.conn = conn
+	c.err = nil
+	c.backoff.Reset()
+	return nil
+}
+
+// Healthy returns false and the error that broke the connection or failed
+// the last dial, until a dial succeeds.
+func (c *Conn) Healthy() (bool, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.err == nil, c.err
+}
+
+// Close closes the connection, if it is open. It is reopened by the next
+// call to Do.
+func (c *Conn) Close() error {
+	c.l.Lock()
+	defer c.l.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
@@ -0,0 +1,119 @@
+package sink
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+type testCloser struct {
+	closed bool
+}
+
+func (c *testCloser) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestBackoff(t *testing.T) {
+	b := &Backoff{Min: 100 * time.Millisecond, Max: time.Second}
+	for i, max := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		if d := b.Next(); d < max/2 || d > max {
+			t.Fatalf("%d: bad: %s", i, d)
+		}
+	}
+
+	b.Reset()
+	if d := b.Next(); d > 100*time.Millisecond {
+		t.Fatalf("bad: %s", d)
+	}
+}
+
+func TestConn_reconnect(t *testing.T) {
+	var dials []*testCloser
+	errBroken := errors.New("broken")
+	errReply := errors.New("reply")
+	c := &Conn{
+		Dial: func() (io.Closer, error) {
+			conn := &testCloser{}
+			dials = append(dials, conn)
+			return conn, nil
+		},
+		Broken: func(err error) bool {
+			return err == errBroken
+		},
+	}
+
+	// The connection is opened once and kept after errors that don't
+	// break it
+	for _, err := range []error{nil, errReply} {
+		if actual := c.Do(func(io.Closer) error { return err }); actual != err {
+			t.Fatalf("err: %v", actual)
+		}
+	}
+	if len(dials) != 1 {
+		t.Fatalf("bad: %d", len(dials))
+	}
+
+	// A broken connection is closed, and redialed by the next call
+	if err := c.Do(func(io.Closer) error { return errBroken }); err != errBroken {
+		t.Fatalf("err: %v", err)
+	}
+	if !dials[0].closed {
+		t.Fatalf("not closed")
+	}
+	if ok, err := c.Healthy(); ok || err != errBroken {
+		t.Fatalf("bad: %v %v", ok, err)
+	}
+	if err := c.Do(func(io.Closer) error { return nil }); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(dials) != 2 {
+		t.Fatalf("bad: %d", len(dials))
+	}
+	if ok, _ := c.Healthy(); !ok {
+		t.Fatalf("not healthy")
+	}
+}
+
+func TestConn_backoff(t *testing.T) {
+	dials := 0
+	errDial := errors.New("connection refused")
+	c := &Conn{
+		Dial: func() (io.Closer, error) {
+			dials++
+			if dials == 1 {
+				return nil, errDial
+			}
+			return &testCloser{}, nil
+		},
+		MinBackoff: 50 * time.Millisecond,
+	}
+
+	called := false
+	f := func(io.Closer) error {
+		called = true
+		return nil
+	}
+	if err := c.Do(f); err != errDial {
+		t.Fatalf("err: %v", err)
+	}
+
+	// No dial until the backoff elapses
+	if err := c.Do(f); err == nil || called || dials != 1 {
+		t.Fatalf("bad: %v %v %d", err, called, dials)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := c.Do(f); err != nil || !called || dials != 2 {
+		t.Fatalf("bad: %v %v %d", err, called, dials)
+	}
+}
@@ -0,0 +1,147 @@
+package audit
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// tlsVersions are the values of the "tls_min_version" option.
+var tlsVersions = map[string]uint16{
+	"tls10": tls.VersionTLS10,
+	"tls11": tls.VersionTLS11,
+	"tls12": tls.VersionTLS12,
+}
+
+// remoteAddr returns the remote address of a connection, or an empty
+// string for requests without one.
+func remoteAddr(conn *logical.Connection) string {
+	if conn == nil {
+		return ""
+	}
+	return conn.RemoteAddr
+}
+
+// tlsVersion returns the TLS version negotiated on a connection, named
+// as in the "tls_min_version" option, such as "tls12". It is empty for
+// connections without TLS.
+func tlsVersion(conn *logical.Connection) string {
+	if conn == nil || conn.ConnState == nil {
+		return ""
+	}
+	v := conn.ConnState.Version
+	for name, version := range tlsVersions {
+		if version == v {
+			return name
+		}
+	}
+	if v == tls.VersionTLS13 {
+		return "tls13"
+	}
+	return fmt.Sprintf("0x%04x", v)
+}
+
+// tlsCipherSuite returns the name of the cipher suite negotiated on a
+// connection, such as "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256". It is
+// empty for connections without TLS.
+func tlsCipherSuite(conn *logical.Connection) string {
+	if conn == nil || conn.ConnState == nil {
+		return ""
+	}
+	return tls.CipherSuiteName(conn.ConnState.CipherSuite)
+}
+
+// TLSOptions are the TLS options shared by the audit backends that
+// connect over the network, so that every backend names them the same:
+//
+//	tls_ca_file      - PEM file of the CAs verifying the server, instead
+//	                   of the system CAs
+//	tls_cert_file    - PEM file of the client certificate, for mutual TLS
+//	tls_key_file     - PEM file of the key of the client certificate
+//	tls_server_name  - name to verify the certificate of the server with,
+//	                   instead of the host of the address
+//	tls_min_version  - "tls10", "tls11" or "tls12" (the default)
+//	tls_skip_verify  - skips verifying the server, for testing only
+type TLSOptions struct {
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+	MinVersion string
+	SkipVerify bool
+}
+
+// ParseTLSOptions parses the TLS options out of the options of an audit
+// backend, without reading the files. Options that don't relate to TLS
+// are ignored.
+func ParseTLSOptions(conf map[string]string) (*TLSOptions, error) {
+	o := &TLSOptions{
+		CAFile:     conf["tls_ca_file"],
+		CertFile:   conf["tls_cert_file"],
+		KeyFile:    conf["tls_key_file"],
+		ServerName: conf["tls_server_name"],
+		MinVersion: "tls12",
+	}
+
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return nil, fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+	}
+	if v, ok := conf["tls_min_version"]; ok {
+		if _, ok := tlsVersions[v]; !ok {
+			return nil, fmt.Errorf("invalid tls_min_version: %s", v)
+		}
+		o.MinVersion = v
+	}
+	if v, ok := conf["tls_skip_verify"]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tls_skip_verify: %s", v)
+		}
+		o.SkipVerify = b
+	}
+	return o, nil
+}
+
+// Config returns the TLS client configuration, reading the CA and the
+// client certificate files.
+func (o *TLSOptions) Config() (*tls.Config, error) {
+	c := &tls.Config{
+		ServerName:         o.ServerName,
+		MinVersion:         tlsVersions[o.MinVersion],
+		InsecureSkipVerify: o.SkipVerify,
+	}
+
+	if o.CAFile != "" {
+		pem, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading tls_ca_file: %s", err)
+		}
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in tls_ca_file: %s", o.CAFile)
+		}
+	}
+
+	if o.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading TLS client cert: %s", err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return c, nil
+}
+
+// ParseTLSConfig parses the TLS options out of the options of an audit
+// backend and returns the TLS client configuration, see TLSOptions.
+func ParseTLSConfig(conf map[string]string) (*tls.Config, error) {
+	o, err := ParseTLSOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+	return o.Config()
+}
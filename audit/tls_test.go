@@ -0,0 +1,78 @@
+package audit_test
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/audit/audittest"
+)
+
+func TestParseTLSOptions(t *testing.T) {
+	cases := []struct {
+		Conf map[string]string
+		Err  bool
+	}{
+		{map[string]string{}, false},
+		{map[string]string{"tls_cert_file": "cert.pem", "tls_key_file": "key.pem"}, false},
+		{map[string]string{"tls_cert_file": "cert.pem"}, true},
+		{map[string]string{"tls_key_file": "key.pem"}, true},
+		{map[string]string{"tls_min_version": "tls11"}, false},
+		{map[string]string{"tls_min_version": "ssl3"}, true},
+		{map[string]string{"tls_skip_verify": "maybe"}, true},
+	}
+
+	for _, tc := range cases {
+		// The files aren't read
+		if _, err := audit.ParseTLSOptions(tc.Conf); (err != nil) != tc.Err {
+			t.Fatalf("%#v: err: %v", tc.Conf, err)
+		}
+	}
+}
+
+func TestParseTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := audittest.TLSFiles(t, dir)
+
+	c, err := audit.ParseTLSConfig(map[string]string{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if c.MinVersion != tls.VersionTLS12 || c.RootCAs != nil || len(c.Certificates) != 0 {
+		t.Fatalf("bad: %#v", c)
+	}
+
+	c, err = audit.ParseTLSConfig(map[string]string{
+		"tls_ca_file":     certFile,
+		"tls_cert_file":   certFile,
+		"tls_key_file":    keyFile,
+		"tls_server_name": "audit.example.com",
+		"tls_min_version": "tls10",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if c.MinVersion != tls.VersionTLS10 || c.RootCAs == nil ||
+		len(c.Certificates) != 1 || c.ServerName != "audit.example.com" {
+		t.Fatalf("bad: %#v", c)
+	}
+
+	// The files are read
+	_, err = audit.ParseTLSConfig(map[string]string{
+		"tls_ca_file": filepath.Join(dir, "missing.pem"),
+	})
+	if err == nil {
+		t.Fatalf("should fail")
+	}
+	_, err = audit.ParseTLSConfig(map[string]string{"tls_ca_file": keyFile})
+	if err == nil {
+		t.Fatalf("should fail")
+	}
+}
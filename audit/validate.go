@@ -0,0 +1,69 @@
+package audit
+
+// Validator checks the options of an audit backend without creating it,
+// so that no files are opened and no connections are made. It returns the
+// options with the defaults of the options that weren't given, as the
+// backend would use them.
+type Validator func(map[string]string) (map[string]string, error)
+
+// ResolveHashOptions validates the hashing options, see ParseHashConfig,
+// and returns a copy of the options with the defaults of the hashing
+// options that weren't given.
+func ResolveHashOptions(conf map[string]string) (map[string]string, error) {
+	if _, err := ParseHashConfig(conf); err != nil {
+		return nil, err
+	}
+	return withDefaults(conf, map[string]string{
+		"log_raw":        "false",
+		"salt":           "",
+		"hash_algorithm": "sha1",
+		"hash_stability": "stable",
+		"max_key_length": "0",
+	}), nil
+}
+
+// ResolveFormatOptions validates the formatting options, see
+// NewFormatter, and returns a copy of the options with the defaults of
+// the formatting options that weren't given.
+func ResolveFormatOptions(conf map[string]string) (map[string]string, error) {
+	if _, err := NewFormatter(conf); err != nil {
+		return nil, err
+	}
+	return withDefaults(conf, map[string]string{
+		"format":         "json",
+		"detail":         "full",
+		"duration_unit":  "s",
+		"flatten":        "false",
+		"hash_list_keys": "false",
+		"max_entry_size": "0",
+		"pretty_details": "false",
+		"salt_version":   "0",
+		"time_location":  "UTC",
+	}), nil
+}
+
+// ResolveTLSOptions validates the TLS options, see ParseTLSOptions,
+// without reading the files, and returns a copy of the options with the
+// defaults of the TLS options that weren't given.
+func ResolveTLSOptions(conf map[string]string) (map[string]string, error) {
+	if _, err := ParseTLSOptions(conf); err != nil {
+		return nil, err
+	}
+	return withDefaults(conf, map[string]string{
+		"tls_min_version": "tls12",
+		"tls_skip_verify": "false",
+	}), nil
+}
+
+// withDefaults returns a copy of conf with the defaults added for the
+// keys it doesn't have.
+func withDefaults(conf map[string]string, defaults map[string]string) map[string]string {
+	result := make(map[string]string, len(conf)+len(defaults))
+	for k, v := range defaults {
+		result[k] = v
+	}
+	for k, v := range conf {
+		result[k] = v
+	}
+	return result
+}
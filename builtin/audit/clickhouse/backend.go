@@ -0,0 +1,547 @@
+package clickhouse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	audit.Register("clickhouse", Factory)
+	audit.RegisterValidator("clickhouse", Validate)
+}
+
+const (
+	defaultTable         = "vault_audit"
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+	defaultMaxPending    = 10000
+
+	// defaultTimeout is the default timeout of an insert
+	defaultTimeout = 10 * time.Second
+)
+
+// tableRe matches the table names that can be put in the insert query
+// without quoting, optionally qualified with the database.
+var tableRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Validate checks the options of the backend without connecting to
+// ClickHouse.
+func Validate(conf map[string]string) (map[string]string, error) {
+	if _, err := parseConfig(conf); err != nil {
+		return nil, err
+	}
+
+	conf, err := audit.ResolveHashOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+	conf, err = audit.ResolveFormatOptions(formatOptions(conf))
+	if err != nil {
+		return nil, err
+	}
+	conf, err = audit.ResolveTLSOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range map[string]string{
+		"table":          defaultTable,
+		"batch_size":     strconv.Itoa(defaultBatchSize),
+		"flush_interval": defaultFlushInterval.String(),
+		"max_pending":    strconv.Itoa(defaultMaxPending),
+		"compression":    "none",
+		"timeout":        defaultTimeout.String(),
+	} {
+		if _, ok := conf[k]; !ok {
+			conf[k] = v
+		}
+	}
+	return conf, nil
+}
+
+func Factory(conf map[string]string) (audit.Backend, error) {
+	c, err := parseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := audit.ParseHashConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// The entries are always flattened JSON, see formatOptions
+	formatter, err := audit.NewFormatter(formatOptions(conf))
+	if err != nil {
+		return nil, err
+	}
+
+	// The TLS options apply if the address is an HTTPS URL
+	tlsConfig, err := c.tlsOptions.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Backend{
+		conf:      c,
+		hash:      hash,
+		formatter: formatter,
+		client: &http.Client{
+			Timeout: c.timeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		},
+	}
+
+	// The entries left in the spool by a previous run are inserted by
+	// Replay, before the Vault serves requests
+	if c.spoolDir != "" {
+		if b.spool, err = openSpool(c.spoolDir); err != nil {
+			return nil, fmt.Errorf("error opening spool_dir: %s", err)
+		}
+	}
+	return b, nil
+}
+
+// config is the configuration of the backend parsed from its options.
+type config struct {
+	// insertURL is the URL of the HTTP interface with the insert query
+	insertURL string
+
+	username string
+	password string
+
+	batchSize     int
+	flushInterval time.Duration
+	maxPending    int
+	timeout       time.Duration
+
+	// gzip compresses the body of the inserts
+	gzip bool
+
+	// spoolDir is the directory where the pending entries are kept, see
+	// spool
+	spoolDir string
+
+	tlsOptions *audit.TLSOptions
+}
+
+func parseConfig(conf map[string]string) (*config, error) {
+	address, ok := conf["address"]
+	if !ok {
+		return nil, fmt.Errorf("address is required")
+	}
+	u, err := url.Parse(address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid address: %s", address)
+	}
+
+	table := defaultTable
+	if v, ok := conf["table"]; ok {
+		if !tableRe.MatchString(v) {
+			return nil, fmt.Errorf("invalid table: %s", v)
+		}
+		table = v
+	}
+
+	// Unknown fields, such as the keys of the request and response data,
+	// are skipped, and the time is parsed from its RFC 3339 format
+	query := url.Values{}
+	query.Set("query", "INSERT INTO "+table+" FORMAT JSONEachRow")
+	query.Set("input_format_skip_unknown_fields", "1")
+	query.Set("date_time_input_format", "best_effort")
+	u.RawQuery = query.Encode()
+
+	c := &config{
+		insertURL:     u.String(),
+		username:      conf["username"],
+		password:      conf["password"],
+		batchSize:     defaultBatchSize,
+		flushInterval: defaultFlushInterval,
+		maxPending:    defaultMaxPending,
+		timeout:       defaultTimeout,
+	}
+
+	if v, ok := conf["batch_size"]; ok {
+		if c.batchSize, err = strconv.Atoi(v); err != nil || c.batchSize < 1 {
+			return nil, fmt.Errorf("invalid batch_size: %s", v)
+		}
+	}
+	if v, ok := conf["flush_interval"]; ok {
+		if c.flushInterval, err = time.ParseDuration(v); err != nil || c.flushInterval <= 0 {
+			return nil, fmt.Errorf("invalid flush_interval: %s", v)
+		}
+	}
+	if v, ok := conf["max_pending"]; ok {
+		if c.maxPending, err = strconv.Atoi(v); err != nil || c.maxPending < c.batchSize {
+			return nil, fmt.Errorf(
+				"invalid max_pending, must be at least batch_size: %s", v)
+		}
+	}
+	if v, ok := conf["timeout"]; ok {
+		if c.timeout, err = time.ParseDuration(v); err != nil || c.timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout: %s", v)
+		}
+	}
+	if c.tlsOptions, err = audit.ParseTLSOptions(conf); err != nil {
+		return nil, err
+	}
+	if v, ok := conf["compression"]; ok {
+		switch v {
+		case "none":
+		case "gzip":
+			c.gzip = true
+		default:
+			return nil, fmt.Errorf("invalid compression: %s", v)
+		}
+	}
+	if v, ok := conf["spool_dir"]; ok {
+		if v == "" {
+			return nil, fmt.Errorf("invalid spool_dir: %s", v)
+		}
+		c.spoolDir = v
+	}
+	return c, nil
+}
+
+// formatOptions returns a copy of the options for the formatter. Each
+// entry is a single level JSON object, so that its fields match the
+// columns of the table, such as "request.path".
+func formatOptions(conf map[string]string) map[string]string {
+	result := make(map[string]string, len(conf)+1)
+	for k, v := range conf {
+		result[k] = v
+	}
+	if _, ok := result["format"]; !ok {
+		result["format"] = "json"
+	}
+	result["flatten"] = "true"
+	return result
+}
+
+// Backend is the audit backend that inserts entries into a ClickHouse
+// table through its HTTP interface.
+//
+// Entries are inserted asynchronously in batches, in the order they were
+// logged: a batch is sent once it is full or the flush interval has
+// passed since the first pending entry. If an insert fails, the batch is
+// retried with the next one. Entries fail to be logged only once the
+// number of pending entries reaches the maximum, which bounds the memory
+// used while ClickHouse is unavailable.
+//
+// Pending entries are lost when Vault stops, unless a spool directory is
+// configured: the entries are then also kept on disk until they are
+// inserted, and those left by a previous run are replayed before the
+// Vault serves requests, see Replay. An entry may be inserted twice if
+// Vault stops right after inserting it.
+type Backend struct {
+	conf      *config
+	hash      *audit.HashConfig
+	formatter audit.Formatter
+	client    *http.Client
+
+	// l protects the pending entries and the flush timer. While retrying
+	// a failed insert, full batches wait for the timer. Once closed, the
+	// timer isn't started again.
+	l        sync.Mutex
+	pending  []queued
+	timer    *time.Timer
+	retrying bool
+	closed   bool
+
+	// spool keeps the pending entries on disk, if configured
+	spool *spool
+
+	// flushLock serializes the inserts, so that the order is kept
+	flushLock sync.Mutex
+}
+
+// SetClock sets the clock the entries are timestamped with, and the end
+// of the rotation window of the salt is checked against, see
+// audit.ClockSetter.
+func (b *Backend) SetClock(clock func() time.Time) {
+	audit.SetClock(b.formatter, clock)
+	audit.SetClock(b.hash, clock)
+}
+
+func (b *Backend) LogRequest(
+	ctx context.Context, auth *logical.Auth, req *logical.Request) error {
+	// Hash any sensitive information
+	auth, req, err := audit.HashRequest(b.hash, auth, req)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := b.formatter.FormatRequest(&buf, auth, req); err != nil {
+		return err
+	}
+	return b.enqueue(ctx, buf.Bytes())
+}
+
+func (b *Backend) LogResponse(
+	ctx context.Context,
+	auth *logical.Auth,
+	req *logical.Request,
+	resp *logical.Response,
+	respErr error) error {
+	// Hash any sensitive information
+	auth, req, resp, err := audit.HashResponse(b.hash, auth, req, resp)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := b.formatter.FormatResponse(&buf, auth, req, resp, respErr); err != nil {
+		return err
+	}
+	return b.enqueue(ctx, buf.Bytes())
+}
+
+func (b *Backend) LogStatus(ctx context.Context, status *audit.Status) error {
+	return b.logEvent(ctx, status)
+}
+
+func (b *Backend) LogHeartbeat(ctx context.Context, hb *audit.Heartbeat) error {
+	return b.logEvent(ctx, hb)
+}
+
+func (b *Backend) LogSummary(ctx context.Context, s *audit.Summary) error {
+	return b.logEvent(ctx, s)
+}
+
+func (b *Backend) LogInventory(ctx context.Context, inv *audit.Inventory) error {
+	return b.logEvent(ctx, inv)
+}
+
+func (b *Backend) LogAuditChange(ctx context.Context, change *audit.AuditChange) error {
+	return b.logEvent(ctx, change)
+}
+
+func (b *Backend) Test(ctx context.Context, st *audit.SelfTest) error {
+	return b.logEvent(ctx, st)
+}
+
+// logEvent formats an event other than a request or a response, see
+// audit.FormatEvent, and enqueues it.
+func (b *Backend) logEvent(ctx context.Context, ev interface{}) error {
+	entry, err := audit.FormatEvent(b.formatter, ev)
+	if err != nil {
+		return err
+	}
+	return b.enqueue(ctx, entry)
+}
+
+// Pending returns the number of entries left in the spool by a previous
+// run.
+func (b *Backend) Pending() (int, error) {
+	if b.spool == nil {
+		return 0, nil
+	}
+	return b.spool.pendingReplay(), nil
+}
+
+// Replay inserts the entries left in the spool by a previous run, in
+// batches and in order. It fails on the first failed insert, leaving the
+// remaining entries to be replayed on the next unseal.
+func (b *Backend) Replay(progress func()) error {
+	b.flushLock.Lock()
+	defer b.flushLock.Unlock()
+
+	if b.spool == nil {
+		return nil
+	}
+	for {
+		batch := b.spool.replayBatch(b.conf.batchSize)
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := b.insert(batch); err != nil {
+			return err
+		}
+		b.ack(batch)
+		b.spool.replayed(len(batch))
+		for range batch {
+			progress()
+		}
+	}
+}
+
+// enqueue adds a formatted entry to the pending entries, and schedules
+// them to be flushed.
+func (b *Backend) enqueue(ctx context.Context, entry []byte) error {
+	if len(entry) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.l.Lock()
+	defer b.l.Unlock()
+	if b.closed {
+		return fmt.Errorf("backend is closed")
+	}
+	if len(b.pending) >= b.conf.maxPending {
+		return fmt.Errorf(
+			"%d entries are pending insertion into ClickHouse", len(b.pending))
+	}
+	q := queued{entry: entry}
+	if b.spool != nil {
+		seq, err := b.spool.append(entry)
+		if err != nil {
+			return err
+		}
+		q.seq = seq
+	}
+	b.pending = append(b.pending, q)
+
+	switch {
+	case len(b.pending) >= b.conf.batchSize && !b.retrying:
+		go b.flush()
+	case b.timer == nil:
+		b.timer = time.AfterFunc(b.conf.flushInterval, b.flush)
+	}
+	return nil
+}
+
+// Close stops the flush timer and inserts the pending entries, see
+// audit.Closer. Entries that fail to be inserted are lost, unless they
+// are kept in the spool directory.
+func (b *Backend) Close() error {
+	b.l.Lock()
+	b.closed = true
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.l.Unlock()
+
+	b.flush()
+
+	b.l.Lock()
+	defer b.l.Unlock()
+	if n := len(b.pending); n > 0 {
+		return fmt.Errorf("%d entries could not be inserted into ClickHouse", n)
+	}
+	return nil
+}
+
+// flush inserts the pending entries in batches. The entries of a failed
+// insert are put back in front of the pending entries to be retried.
+func (b *Backend) flush() {
+	b.flushLock.Lock()
+	defer b.flushLock.Unlock()
+
+	for {
+		b.l.Lock()
+		if b.timer != nil {
+			b.timer.Stop()
+			b.timer = nil
+		}
+		n := len(b.pending)
+		if n > b.conf.batchSize {
+			n = b.conf.batchSize
+		}
+		batch := b.pending[:n:n]
+		b.pending = b.pending[n:]
+		b.l.Unlock()
+
+		if len(batch) == 0 {
+			return
+		}
+
+		err := b.insert(batch)
+		if err == nil {
+			b.ack(batch)
+		}
+
+		b.l.Lock()
+		b.retrying = err != nil
+		if err != nil {
+			b.pending = append(batch, b.pending...)
+			if b.timer == nil && !b.closed {
+				b.timer = time.AfterFunc(b.conf.flushInterval, b.flush)
+			}
+		}
+		b.l.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// ack records in the spool, if any, that the entries were inserted. If the
+// spool fails to record it, the entries are only inserted again after a
+// restart.
+func (b *Backend) ack(batch []queued) {
+	if b.spool == nil {
+		return
+	}
+	seqs := make([]int64, len(batch))
+	for i, q := range batch {
+		seqs[i] = q.seq
+	}
+	b.spool.ack(seqs)
+}
+
+// insert sends a batch of entries to ClickHouse in a single request.
+func (b *Backend) insert(batch []queued) error {
+	var body []byte
+	for _, q := range batch {
+		body = append(body, q.entry...)
+	}
+	if b.conf.gzip {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(body); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequest("POST", b.conf.insertURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if b.conf.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if b.conf.username != "" {
+		req.Header.Set("X-ClickHouse-User", b.conf.username)
+	}
+	if b.conf.password != "" {
+		req.Header.Set("X-ClickHouse-Key", b.conf.password)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %d inserting into ClickHouse: %s",
+			resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
@@ -0,0 +1,482 @@
+package clickhouse
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+// testServer is a fake of the ClickHouse HTTP interface that records the
+// rows of the inserts, failing them while fail is set.
+type testServer struct {
+	*httptest.Server
+
+	l       sync.Mutex
+	rows    []map[string]interface{}
+	fail    bool
+	gzipped int
+}
+
+func newTestServer(t *testing.T) *testServer {
+	s := &testServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.l.Lock()
+		defer s.l.Unlock()
+
+		query := r.URL.Query().Get("query")
+		if query != "INSERT INTO audit FORMAT JSONEachRow" {
+			t.Errorf("bad query: %s", query)
+		}
+		if user := r.Header.Get("X-ClickHouse-User"); user != "vault" {
+			t.Errorf("bad user: %s", user)
+		}
+		if s.fail {
+			http.Error(w, "Code: 210. Connection refused", http.StatusInternalServerError)
+			return
+		}
+
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("err: %s", err)
+				return
+			}
+			body = zr
+			s.gzipped++
+		}
+
+		scanner := bufio.NewScanner(body)
+		for scanner.Scan() {
+			var row map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+				t.Errorf("err: %s", err)
+			}
+			s.rows = append(s.rows, row)
+		}
+	}))
+	return s
+}
+
+func (s *testServer) setFail(fail bool) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.fail = fail
+}
+
+// waitRows waits until the server has received n rows.
+func (s *testServer) waitRows(t *testing.T, n int) []map[string]interface{} {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.l.Lock()
+		rows := s.rows
+		s.l.Unlock()
+		if len(rows) >= n {
+			return rows
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d rows", n)
+	return nil
+}
+
+func testBackend(t *testing.T, addr string, conf map[string]string) audit.Backend {
+	opts := map[string]string{
+		"address":  addr,
+		"table":    "audit",
+		"username": "vault",
+		"password": "secret",
+	}
+	for k, v := range conf {
+		opts[k] = v
+	}
+	b, err := Factory(opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return b
+}
+
+func TestBackend_batch(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	// The flush interval is long enough that only full batches are sent
+	b := testBackend(t, s.URL, map[string]string{
+		"batch_size":     "2",
+		"flush_interval": "1m",
+	})
+
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/foo",
+		Data:      map[string]interface{}{"foo": "bar"},
+	}
+	for i := 0; i < 2; i++ {
+		if err := b.LogRequest(context.Background(), nil, req); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	rows := s.waitRows(t, 2)
+	for _, row := range rows {
+		if row["type"] != "request" || row["request.path"] != "secret/foo" {
+			t.Fatalf("bad: %#v", row)
+		}
+
+		// The data is hashed
+		if v, _ := row["request.data.foo"].(string); v == "" || v == "bar" {
+			t.Fatalf("bad: %#v", row)
+		}
+	}
+}
+
+func TestBackend_flushInterval(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	b := testBackend(t, s.URL, map[string]string{
+		"flush_interval": "10ms",
+	})
+	err := b.LogResponse(context.Background(), nil,
+		&logical.Request{Path: "secret/foo"}, nil, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	rows := s.waitRows(t, 1)
+	if rows[0]["type"] != "response" {
+		t.Fatalf("bad: %#v", rows[0])
+	}
+}
+
+func TestBackend_close(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	// The pending entry is inserted when the backend is closed, rather
+	// than when the flush interval passes
+	raw := testBackend(t, s.URL, map[string]string{
+		"flush_interval": "1h",
+	})
+	b := raw.(*Backend)
+	err := b.LogResponse(context.Background(), nil,
+		&logical.Request{Path: "secret/foo"}, nil, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if rows := s.waitRows(t, 1); rows[0]["type"] != "response" {
+		t.Fatalf("bad: %#v", rows[0])
+	}
+	if b.timer != nil {
+		t.Fatal("timer should be stopped")
+	}
+
+	// and nothing is logged once closed
+	err = b.LogResponse(context.Background(), nil,
+		&logical.Request{Path: "secret/foo"}, nil, nil)
+	if err == nil {
+		t.Fatal("should fail once closed")
+	}
+}
+
+func TestBackend_gzip(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	b := testBackend(t, s.URL, map[string]string{
+		"batch_size":  "2",
+		"compression": "gzip",
+	})
+	for _, path := range []string{"secret/a", "secret/b"} {
+		req := &logical.Request{Path: path}
+		if err := b.LogRequest(context.Background(), nil, req); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	rows := s.waitRows(t, 2)
+	if rows[0]["request.path"] != "secret/a" || rows[1]["request.path"] != "secret/b" {
+		t.Fatalf("bad: %#v", rows)
+	}
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.gzipped != 1 {
+		t.Fatalf("bad: %d", s.gzipped)
+	}
+}
+
+func TestBackend_retry(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	s.setFail(true)
+
+	b := testBackend(t, s.URL, map[string]string{
+		"batch_size":     "1",
+		"max_pending":    "2",
+		"flush_interval": "10ms",
+	})
+
+	// Entries are kept while the inserts fail, up to the maximum
+	for _, path := range []string{"secret/a", "secret/b"} {
+		req := &logical.Request{Path: path}
+		if err := b.LogRequest(context.Background(), nil, req); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	req := &logical.Request{Path: "secret/c"}
+	if err := b.LogRequest(context.Background(), nil, req); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// The pending entries are inserted in order once ClickHouse is back
+	s.setFail(false)
+	rows := s.waitRows(t, 2)
+	if rows[0]["request.path"] != "secret/a" || rows[1]["request.path"] != "secret/b" {
+		t.Fatalf("bad: %#v", rows)
+	}
+}
+
+func TestBackend_timeout(t *testing.T) {
+	// The first insert hangs until the end of the test, the next ones
+	// succeed
+	release := make(chan struct{})
+	var l sync.Mutex
+	var inserts int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.Lock()
+		inserts++
+		first := inserts == 1
+		l.Unlock()
+		if first {
+			<-release
+		}
+	}))
+	defer s.Close()
+	defer close(release)
+
+	b := testBackend(t, s.URL, map[string]string{
+		"batch_size":     "1",
+		"flush_interval": "10ms",
+		"timeout":        "50ms",
+	})
+	if timeout := b.(*Backend).client.Timeout; timeout != 50*time.Millisecond {
+		t.Fatalf("bad: %s", timeout)
+	}
+
+	// The insert that hangs times out, and the batch is retried
+	if err := b.LogRequest(context.Background(), nil, &logical.Request{Path: "secret/foo"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		l.Lock()
+		n := inserts
+		l.Unlock()
+		if n >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("insert should time out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBackend_spool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-clickhouse")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := newTestServer(t)
+	defer s.Close()
+	s.setFail(true)
+
+	b := testBackend(t, s.URL, map[string]string{
+		"batch_size":     "1",
+		"flush_interval": "10ms",
+		"spool_dir":      dir,
+	})
+	for _, path := range []string{"secret/a", "secret/b"} {
+		req := &logical.Request{Path: path}
+		if err := b.LogRequest(context.Background(), nil, req); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// Restart, dropping the spool of the process as a new Vault would not
+	// have it open
+	spoolsLock.Lock()
+	for k, sp := range spools {
+		sp.f.Close()
+		delete(spools, k)
+	}
+	spoolsLock.Unlock()
+
+	// The spooled entries are replayed by the new backend. A failed replay
+	// leaves them to the backend set up on the next unseal.
+	conf := map[string]string{
+		"batch_size":     "1",
+		"flush_interval": "10ms",
+		"spool_dir":      dir,
+	}
+	r := testBackend(t, s.URL, conf).(audit.Replayer)
+	if n, err := r.Pending(); err != nil || n != 2 {
+		t.Fatalf("bad: %d %v", n, err)
+	}
+	if err := r.Replay(func() {}); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	s2 := newTestServer(t)
+	defer s2.Close()
+	r = testBackend(t, s2.URL, conf).(audit.Replayer)
+	if n, err := r.Pending(); err != nil || n != 2 {
+		t.Fatalf("bad: %d %v", n, err)
+	}
+	replayed := 0
+	if err := r.Replay(func() { replayed++ }); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n, _ := r.Pending(); n != 0 || replayed != 2 {
+		t.Fatalf("bad: %d %d", n, replayed)
+	}
+	rows := s2.waitRows(t, 2)
+	if len(rows) != 2 || rows[0]["request.path"] != "secret/a" || rows[1]["request.path"] != "secret/b" {
+		t.Fatalf("bad: %#v", rows)
+	}
+
+	// Once inserted, the entries are removed from the spool
+	fi, err := os.Stat(filepath.Join(dir, "entries"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fi.Size() != 0 {
+		t.Fatalf("bad: %d", fi.Size())
+	}
+}
+
+func TestSpool_compact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-clickhouse")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := openSpool(dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	s.compactSize = 4
+
+	var seqs []int64
+	for _, entry := range []string{"a\n", "b\n", "c\n"} {
+		seq, err := s.append([]byte(entry))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		seqs = append(seqs, seq)
+	}
+
+	// The inserted prefix is dropped once it passes the size, while an
+	// entry is still pending
+	if err := s.ack(seqs[:2]); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	entries, err := ioutil.ReadFile(filepath.Join(dir, "entries"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	offset, err := ioutil.ReadFile(filepath.Join(dir, "offset"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(entries) != "c\n" || string(offset) != "0" {
+		t.Fatalf("bad: %q %q", entries, offset)
+	}
+
+	// Entries are still appended after the compaction, and only the
+	// pending ones are recovered
+	if _, err := s.append([]byte("d\n")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	spoolsLock.Lock()
+	s.f.Close()
+	delete(spools, s.dir)
+	spoolsLock.Unlock()
+
+	s, err = openSpool(dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer func() {
+		spoolsLock.Lock()
+		s.f.Close()
+		delete(spools, s.dir)
+		spoolsLock.Unlock()
+	}()
+	if len(s.recovered) != 2 || string(s.recovered[0].entry) != "c\n" ||
+		string(s.recovered[1].entry) != "d\n" {
+		t.Fatalf("bad: %#v", s.recovered)
+	}
+}
+
+func TestBackend_canceled(t *testing.T) {
+	b := testBackend(t, "http://127.0.0.1:8123", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := b.LogRequest(ctx, nil, &logical.Request{Path: "secret/foo"})
+	if err != context.Canceled {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		Conf map[string]string
+		Err  bool
+	}{
+		{map[string]string{"address": "http://127.0.0.1:8123"}, false},
+		{map[string]string{}, true},
+		{map[string]string{"address": "127.0.0.1:8123"}, true},
+		{map[string]string{"address": "http://127.0.0.1:8123", "table": "vault; DROP TABLE x"}, true},
+		{map[string]string{"address": "http://127.0.0.1:8123", "table": "logs.vault_audit"}, false},
+		{map[string]string{"address": "http://127.0.0.1:8123", "batch_size": "0"}, true},
+		{map[string]string{"address": "http://127.0.0.1:8123", "max_pending": "10"}, true},
+		{map[string]string{"address": "http://127.0.0.1:8123", "format": "csv"}, true},
+		{map[string]string{"address": "http://127.0.0.1:8123", "compression": "gzip"}, false},
+		{map[string]string{"address": "http://127.0.0.1:8123", "compression": "zstd"}, true},
+		{map[string]string{"address": "http://127.0.0.1:8123", "spool_dir": ""}, true},
+		{map[string]string{"address": "http://127.0.0.1:8123", "timeout": "5s"}, false},
+		{map[string]string{"address": "http://127.0.0.1:8123", "timeout": "0s"}, true},
+	}
+
+	for _, tc := range cases {
+		resolved, err := Validate(tc.Conf)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%#v: err: %v", tc.Conf, err)
+		}
+		if err == nil && (resolved["flatten"] != "true" || resolved["batch_size"] == "") {
+			t.Fatalf("bad: %#v", resolved)
+		}
+	}
+}
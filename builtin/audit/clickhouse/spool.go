@@ -0,0 +1,257 @@
+package clickhouse
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	spoolsLock sync.Mutex
+	spools     = make(map[string]*spool)
+)
+
+// spoolCompactSize is the size of the inserted prefix of the entries file
+// past which the file is compacted, see compact.
+const spoolCompactSize = 1 << 20
+
+// queued is a pending entry, with its sequence number in the spool of the
+// backend, if any.
+type queued struct {
+	entry []byte
+	seq   int64
+}
+
+// spool is the journal of the entries that haven't been inserted yet, kept
+// in the "spool_dir" directory so that they are inserted once the Vault
+// restarts. The entries are appended to the "entries" file as they are
+// logged, and the "offset" file has the size of the prefix of the entries
+// that were inserted. The entries file is truncated once every entry is
+// inserted, and compacted once the inserted prefix passes compactSize, so
+// that it doesn't grow while entries are always pending.
+//
+// Backends with the same spool directory in a process, such as a backend
+// set up again after the Vault is sealed and unsealed, share the spool,
+// so the entries still pending in the previous backend aren't lost.
+type spool struct {
+	l           sync.Mutex
+	dir         string
+	f           *os.File
+	offset      int64
+	size        int64
+	compactSize int64
+
+	// sizes are the sizes of the entries after the offset, in order, and
+	// done is set for those that were inserted. first is the sequence
+	// number of the first of them.
+	first int64
+	sizes []int64
+	done  []bool
+
+	// recovered are the entries found in the file when it was opened,
+	// oldest first, until they are replayed. They are kept with the
+	// spool, so that a backend set up again after a failed replay still
+	// replays them.
+	recovered []queued
+}
+
+// openSpool returns the spool in the directory, opening it if no backend
+// has it open yet.
+func openSpool(dir string) (*spool, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	spoolsLock.Lock()
+	defer spoolsLock.Unlock()
+
+	if s, ok := spools[dir]; ok {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	var offset int64
+	raw, err := ioutil.ReadFile(filepath.Join(dir, "offset"))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	default:
+		if offset, err = strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64); err != nil {
+			offset = 0
+		}
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, "entries"),
+		os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// An entry cut short by a crash is dropped, and an offset beyond the
+	// entries, such as one not reset after the truncation, starts over
+	size := int64(bytes.LastIndexByte(data, '\n') + 1)
+	if size < int64(len(data)) {
+		if err := f.Truncate(size); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if offset < 0 || offset > size {
+		offset = 0
+	}
+
+	s := &spool{
+		dir:         dir,
+		f:           f,
+		offset:      offset,
+		size:        size,
+		compactSize: spoolCompactSize,
+	}
+	rest := data[offset:size]
+	for len(rest) > 0 {
+		n := bytes.IndexByte(rest, '\n') + 1
+		s.recovered = append(s.recovered, queued{entry: rest[:n:n], seq: s.add(int64(n))})
+		rest = rest[n:]
+	}
+
+	spools[dir] = s
+	return s, nil
+}
+
+// pendingReplay returns the number of recovered entries to replay.
+func (s *spool) pendingReplay() int {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return len(s.recovered)
+}
+
+// replayBatch returns up to n of the oldest recovered entries.
+func (s *spool) replayBatch(n int) []queued {
+	s.l.Lock()
+	defer s.l.Unlock()
+	if n > len(s.recovered) {
+		n = len(s.recovered)
+	}
+	return s.recovered[:n:n]
+}
+
+// replayed removes the n oldest recovered entries once they were
+// inserted.
+func (s *spool) replayed(n int) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.recovered = s.recovered[n:]
+}
+
+// append writes the entry to the spool and returns its sequence number.
+func (s *spool) append(entry []byte) (int64, error) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if _, err := s.f.Write(entry); err != nil {
+		return 0, err
+	}
+	s.size += int64(len(entry))
+	return s.add(int64(len(entry))), nil
+}
+
+func (s *spool) add(size int64) int64 {
+	seq := s.first + int64(len(s.sizes))
+	s.sizes = append(s.sizes, size)
+	s.done = append(s.done, false)
+	return seq
+}
+
+// ack records that the entries with the given sequence numbers were
+// inserted. The offset only moves past the entries inserted in a row, so
+// that entries inserted out of order, by different backends sharing the
+// spool, are still inserted again after a restart if an earlier one
+// wasn't.
+func (s *spool) ack(seqs []int64) error {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	for _, seq := range seqs {
+		if i := seq - s.first; i >= 0 && i < int64(len(s.done)) {
+			s.done[i] = true
+		}
+	}
+
+	n := 0
+	for n < len(s.done) && s.done[n] {
+		s.offset += s.sizes[n]
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	s.first += int64(n)
+	s.sizes = s.sizes[n:]
+	s.done = s.done[n:]
+
+	switch {
+	case len(s.sizes) == 0:
+		if err := s.f.Truncate(0); err != nil {
+			return err
+		}
+		s.offset, s.size = 0, 0
+	case s.offset >= s.compactSize:
+		return s.compact()
+	}
+	return s.writeOffset(s.offset)
+}
+
+// compact rewrites the entries file without its inserted prefix. The
+// offset is reset before the file is replaced, so that a crash in between
+// only inserts the prefix again rather than skipping entries.
+func (s *spool) compact() error {
+	rest := make([]byte, s.size-s.offset)
+	if _, err := s.f.ReadAt(rest, s.offset); err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, "entries")
+	if err := ioutil.WriteFile(path+".tmp", rest, 0600); err != nil {
+		return err
+	}
+	if err := s.writeOffset(0); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	s.f.Close()
+	s.f = f
+	s.size -= s.offset
+	s.offset = 0
+	return nil
+}
+
+// writeOffset replaces the offset file atomically, so that it is never
+// read partly written.
+func (s *spool) writeOffset(offset int64) error {
+	tmp := filepath.Join(s.dir, "offset.tmp")
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatInt(offset, 10)), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, "offset"))
+}
@@ -0,0 +1,318 @@
+package eventhubs
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	audit.Register("eventhubs", Factory)
+	audit.RegisterValidator("eventhubs", Validate)
+}
+
+const (
+	// defaultTimeout is the default timeout of sending an entry
+	defaultTimeout = 10 * time.Second
+
+	// sasValidity is how long the shared access signatures are valid
+	sasValidity = time.Hour
+)
+
+// Validate checks the options of the backend without connecting to
+// Event Hubs.
+func Validate(conf map[string]string) (map[string]string, error) {
+	if _, err := parseConfig(conf); err != nil {
+		return nil, err
+	}
+
+	conf, err := audit.ResolveHashOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+	conf, err = audit.ResolveFormatOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+	conf, err = audit.ResolveTLSOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := conf["timeout"]; !ok {
+		conf["timeout"] = defaultTimeout.String()
+	}
+	return conf, nil
+}
+
+func Factory(conf map[string]string) (audit.Backend, error) {
+	c, err := parseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := audit.ParseHashConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the format or default to JSON
+	formatter, err := audit.NewFormatter(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := c.tlsOptions.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Backend{
+		conf:      c,
+		hash:      hash,
+		formatter: formatter,
+		client: &http.Client{
+			Timeout: c.timeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		},
+	}
+	return b, nil
+}
+
+// config is the configuration of the backend parsed from its options.
+type config struct {
+	// resource is the URI of the event hub that is signed, and sendURL
+	// the URL events are sent to, which may be that of a partition
+	resource string
+	sendURL  string
+
+	keyName      string
+	key          string
+	partitionKey string
+	timeout      time.Duration
+	tlsOptions   *audit.TLSOptions
+}
+
+func parseConfig(conf map[string]string) (*config, error) {
+	address, ok := conf["address"]
+	if !ok {
+		return nil, fmt.Errorf("address is required")
+	}
+	u, err := url.Parse(address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid address: %s", address)
+	}
+
+	hub, ok := conf["event_hub"]
+	if !ok || hub == "" || strings.Contains(hub, "/") {
+		return nil, fmt.Errorf("event_hub is required and must be a name")
+	}
+
+	c := &config{
+		keyName:      conf["sas_key_name"],
+		key:          conf["sas_secret"],
+		partitionKey: conf["partition_key"],
+		timeout:      defaultTimeout,
+	}
+	if c.keyName == "" || c.key == "" {
+		return nil, fmt.Errorf("sas_key_name and sas_secret are required")
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + hub
+	c.resource = u.String()
+
+	// Entries can be sent to a fixed partition, or be assigned one by
+	// their partition key, but not both
+	if v, ok := conf["partition_id"]; ok {
+		if c.partitionKey != "" {
+			return nil, fmt.Errorf("partition_id and partition_key are exclusive")
+		}
+		if _, err := strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid partition_id: %s", v)
+		}
+		u.Path += "/partitions/" + v
+	}
+	u.Path += "/messages"
+	c.sendURL = u.String()
+
+	if v, ok := conf["timeout"]; ok {
+		if c.timeout, err = time.ParseDuration(v); err != nil || c.timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout: %s", v)
+		}
+	}
+	if c.tlsOptions, err = audit.ParseTLSOptions(conf); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Backend is the audit backend that sends each entry as an event to an
+// Azure Event Hub, using its HTTPS interface and shared access signature
+// authentication. Entries are sent synchronously, so an entry is only
+// logged once Event Hubs has accepted it.
+type Backend struct {
+	conf      *config
+	hash      *audit.HashConfig
+	formatter audit.Formatter
+	client    *http.Client
+}
+
+// SetClock sets the clock the entries are timestamped with, and the end
+// of the rotation window of the salt is checked against, see
+// audit.ClockSetter.
+func (b *Backend) SetClock(clock func() time.Time) {
+	audit.SetClock(b.formatter, clock)
+	audit.SetClock(b.hash, clock)
+}
+
+func (b *Backend) LogRequest(
+	ctx context.Context, auth *logical.Auth, req *logical.Request) error {
+	// Hash any sensitive information
+	auth, req, err := audit.HashRequest(b.hash, auth, req)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := b.formatter.FormatRequest(&buf, auth, req); err != nil {
+		return err
+	}
+	return b.send(ctx, buf.Bytes())
+}
+
+func (b *Backend) LogResponse(
+	ctx context.Context,
+	auth *logical.Auth,
+	req *logical.Request,
+	resp *logical.Response,
+	respErr error) error {
+	// Hash any sensitive information
+	auth, req, resp, err := audit.HashResponse(b.hash, auth, req, resp)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := b.formatter.FormatResponse(&buf, auth, req, resp, respErr); err != nil {
+		return err
+	}
+	return b.send(ctx, buf.Bytes())
+}
+
+func (b *Backend) LogStatus(ctx context.Context, status *audit.Status) error {
+	return b.logEvent(ctx, status)
+}
+
+func (b *Backend) LogHeartbeat(ctx context.Context, hb *audit.Heartbeat) error {
+	return b.logEvent(ctx, hb)
+}
+
+func (b *Backend) LogSummary(ctx context.Context, s *audit.Summary) error {
+	return b.logEvent(ctx, s)
+}
+
+func (b *Backend) LogInventory(ctx context.Context, inv *audit.Inventory) error {
+	return b.logEvent(ctx, inv)
+}
+
+func (b *Backend) LogAuditChange(ctx context.Context, change *audit.AuditChange) error {
+	return b.logEvent(ctx, change)
+}
+
+func (b *Backend) Test(ctx context.Context, st *audit.SelfTest) error {
+	return b.logEvent(ctx, st)
+}
+
+// logEvent formats an event other than a request or a response, see
+// audit.FormatEvent, and sends it.
+func (b *Backend) logEvent(ctx context.Context, ev interface{}) error {
+	entry, err := audit.FormatEvent(b.formatter, ev)
+	if err != nil {
+		return err
+	}
+	return b.send(ctx, entry)
+}
+
+// send sends a formatted entry as a single event. If the context is done
+// first, its error is returned and the event is left to the timeout of
+// the client.
+func (b *Backend) send(ctx context.Context, entry []byte) error {
+	if len(entry) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", b.conf.sendURL, bytes.NewReader(entry))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", b.signature(time.Now()))
+	req.Header.Set("Content-Type", "application/atom+xml;type=entry;charset=utf-8")
+	if b.conf.partitionKey != "" {
+		props, err := json.Marshal(map[string]string{
+			"PartitionKey": b.conf.partitionKey,
+		})
+		if err != nil {
+			return err
+		}
+		req.Header.Set("BrokerProperties", string(props))
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- b.do(req)
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Backend) do(req *http.Request) error {
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %d sending to Event Hubs: %s",
+			resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// signature returns the shared access signature authorizing requests to
+// the event hub until sasValidity after now.
+func (b *Backend) signature(now time.Time) string {
+	resource := url.QueryEscape(b.conf.resource)
+	expiry := strconv.FormatInt(now.Add(sasValidity).Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(b.conf.key))
+	mac.Write([]byte(resource + "\n" + expiry))
+	sig := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return fmt.Sprintf("SharedAccessSignature sr=%s&sig=%s&se=%s&skn=%s",
+		resource, url.QueryEscape(sig), expiry, url.QueryEscape(b.conf.keyName))
+}
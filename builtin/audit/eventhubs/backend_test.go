@@ -0,0 +1,186 @@
+package eventhubs
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+func testConf(addr string) map[string]string {
+	return map[string]string{
+		"address":      addr,
+		"event_hub":    "audit",
+		"sas_key_name": "vault",
+		"sas_secret":   "foo",
+	}
+}
+
+func TestBackend_send(t *testing.T) {
+	var (
+		path  string
+		props string
+		auth  string
+		entry map[string]interface{}
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		props = r.Header.Get("BrokerProperties")
+		auth = r.Header.Get("Authorization")
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &entry); err != nil {
+			t.Errorf("err: %s", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	conf := testConf(ts.URL)
+	conf["partition_key"] = "vault-1"
+	b, err := Factory(conf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/foo",
+		Data:      map[string]interface{}{"foo": "bar"},
+	}
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if path != "/audit/messages" {
+		t.Fatalf("bad: %s", path)
+	}
+	if props != `{"PartitionKey":"vault-1"}` {
+		t.Fatalf("bad: %s", props)
+	}
+	if !strings.HasPrefix(auth, "SharedAccessSignature sr=") ||
+		!strings.HasSuffix(auth, "&skn=vault") {
+		t.Fatalf("bad: %s", auth)
+	}
+	request, _ := entry["request"].(map[string]interface{})
+	data, _ := request["data"].(map[string]interface{})
+	if request["path"] != "secret/foo" || data["foo"] == "bar" {
+		t.Fatalf("bad: %#v", entry)
+	}
+}
+
+func TestBackend_sendError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "SubCode=40100: Unauthorized", http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	b, err := Factory(testConf(ts.URL))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = b.LogRequest(context.Background(), nil, &logical.Request{Path: "secret/foo"})
+	if err == nil || !strings.Contains(err.Error(), "40100") {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestBackend_canceled(t *testing.T) {
+	block := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer ts.Close()
+	defer close(block)
+
+	b, err := Factory(testConf(ts.URL))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	err = b.LogRequest(ctx, nil, &logical.Request{Path: "secret/foo"})
+	if err != context.Canceled {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestBackend_signature(t *testing.T) {
+	b, err := Factory(testConf("https://vault.servicebus.windows.net"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	now := time.Unix(1434000000, 0)
+	sig := b.(*Backend).signature(now)
+
+	resource := url.QueryEscape("https://vault.servicebus.windows.net/audit")
+	mac := hmac.New(sha256.New, []byte("foo"))
+	mac.Write([]byte(resource + "\n1434003600"))
+	expected := "SharedAccessSignature sr=" + resource +
+		"&sig=" + url.QueryEscape(base64.StdEncoding.EncodeToString(mac.Sum(nil))) +
+		"&se=1434003600&skn=vault"
+	if sig != expected {
+		t.Fatalf("bad: %s\n\n%s", sig, expected)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	base := testConf("https://vault.servicebus.windows.net")
+	cases := []struct {
+		Conf map[string]string
+		Err  bool
+	}{
+		{map[string]string{}, false},
+		{map[string]string{"partition_id": "3"}, false},
+		{map[string]string{"partition_id": "a"}, true},
+		{map[string]string{"partition_id": "3", "partition_key": "foo"}, true},
+		{map[string]string{"event_hub": "foo/bar"}, true},
+		{map[string]string{"sas_secret": ""}, true},
+		{map[string]string{"address": "vault.servicebus.windows.net"}, true},
+		{map[string]string{"timeout": "0s"}, true},
+	}
+
+	for _, tc := range cases {
+		conf := make(map[string]string)
+		for k, v := range base {
+			conf[k] = v
+		}
+		for k, v := range tc.Conf {
+			conf[k] = v
+		}
+
+		resolved, err := Validate(conf)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%#v: err: %v", tc.Conf, err)
+		}
+		if err == nil && resolved["timeout"] == "" {
+			t.Fatalf("bad: %#v", resolved)
+		}
+	}
+}
+
+func TestParseConfig_partition(t *testing.T) {
+	conf := testConf("https://vault.servicebus.windows.net/")
+	conf["partition_id"] = "3"
+	c, err := parseConfig(conf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if c.sendURL != "https://vault.servicebus.windows.net/audit/partitions/3/messages" {
+		t.Fatalf("bad: %s", c.sendURL)
+	}
+	if c.resource != "https://vault.servicebus.windows.net/audit" {
+		t.Fatalf("bad: %s", c.resource)
+	}
+}
@@ -1,36 +1,96 @@
 package file
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
-	"strconv"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/logical"
-	"github.com/mitchellh/copystructure"
+	"golang.org/x/net/context"
 )
 
+func init() {
+	audit.Register("file", Factory)
+	audit.RegisterValidator("file", Validate)
+}
+
+// Validate checks the options of the backend without opening the file.
+func Validate(conf map[string]string) (map[string]string, error) {
+	path, ok := conf["path"]
+	if !ok {
+		return nil, fmt.Errorf("path is required")
+	}
+	if _, err := expandPath(path, time.Time{}); err != nil {
+		return nil, err
+	}
+	if _, err := parseDiskGuard(conf); err != nil {
+		return nil, err
+	}
+
+	conf, err := audit.ResolveHashOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+	return audit.ResolveFormatOptions(conf)
+}
+
 func Factory(conf map[string]string) (audit.Backend, error) {
 	path, ok := conf["path"]
 	if !ok {
 		return nil, fmt.Errorf("path is required")
 	}
+	if _, err := expandPath(path, time.Time{}); err != nil {
+		return nil, err
+	}
+
+	// The time tokens of the path are expanded in the time zone of the
+	// entries
+	opts, err := audit.ParseFormatOptions(conf)
+	if err != nil {
+		return nil, err
+	}
 
 	// Check if raw logging is enabled
-	logRaw := false
-	if raw, ok := conf["log_raw"]; ok {
-		b, err := strconv.ParseBool(raw)
-		if err != nil {
-			return nil, err
-		}
-		logRaw = b
+	hash, err := audit.ParseHashConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the format or default to JSON
+	formatter, err := audit.NewFormatter(conf)
+	if err != nil {
+		return nil, err
 	}
 
 	b := &Backend{
-		Path:   path,
-		LogRaw: logRaw,
+		Path:      path,
+		hash:      hash,
+		formatter: formatter,
+		opts:      opts,
+		now:       time.Now,
+	}
+
+	b.disk, err = parseDiskGuard(conf)
+	if err != nil {
+		return nil, err
+	}
+	if b.disk != nil && b.disk.action == "reduce" {
+		// The data of the requests and responses is dropped first
+		b.reduced = formatter
+		if detail, _ := audit.ParseDetail(conf["detail"]); detail == audit.DetailFull {
+			reduced := make(map[string]string, len(conf)+1)
+			for k, v := range conf {
+				reduced[k] = v
+			}
+			reduced["detail"] = "standard"
+			if b.reduced, err = audit.NewFormatter(reduced); err != nil {
+				return nil, err
+			}
+		}
 	}
 	return b, nil
 }
@@ -38,104 +98,232 @@ func Factory(conf map[string]string) (audit.Backend, error) {
 // Backend is the audit backend for the file-based audit store.
 //
 // NOTE: This audit backend is currently very simple: it appends to a file.
-// It doesn't do anything more at the moment to assist with rotation
-// or reset the write cursor, this should be done in the future.
+// Other than rolling over to dated files, it doesn't do anything more at
+// the moment to assist with rotation or reset the write cursor, this
+// should be done in the future.
+//
+// Backends enabled at different paths but writing to the same file share
+// the file, see sink.
+//
+// The path may have time tokens, such as "audit-%Y%m%d.log", see
+// expandPath. The file is then chosen when each entry is written, and the
+// backend rolls over to a new file once the expanded path changes.
 type Backend struct {
-	Path   string
-	LogRaw bool
+	Path      string
+	hash      *audit.HashConfig
+	formatter audit.Formatter
+	opts      audit.FormatOptions
+	now       func() time.Time
+
+	// disk, if set, guards the free space of the filesystem of the file,
+	// and reduced formats the requests and responses while it is low
+	disk    *diskGuard
+	reduced audit.Formatter
 
-	once sync.Once
-	f    *os.File
+	// l guards the sink, and is held while writing so that the sink isn't
+	// released under a write when the backend rolls over
+	l    sync.Mutex
+	sink *sink
 }
 
-func (b *Backend) LogRequest(auth *logical.Auth, req *logical.Request) error {
+// SetClock sets the clock the entries are timestamped with, and the end
+// of the rotation window of the salt is checked against, see
+// audit.ClockSetter. The file the entries are written to is chosen with
+// it too, so that an entry is in the file of its time.
+func (b *Backend) SetClock(clock func() time.Time) {
+	b.now = clock
+	audit.SetClock(b.formatter, clock)
+	audit.SetClock(b.reduced, clock)
+	audit.SetClock(b.hash, clock)
+}
+
+func (b *Backend) LogRequest(
+	ctx context.Context, auth *logical.Auth, req *logical.Request) error {
 	if err := b.open(); err != nil {
 		return err
 	}
-	if !b.LogRaw {
-		// Copy the structures
-		cp, err := copystructure.Copy(auth)
-		if err != nil {
-			return err
-		}
-		auth = cp.(*logical.Auth)
-
-		cp, err = copystructure.Copy(req)
-		if err != nil {
-			return err
-		}
-		req = cp.(*logical.Request)
-
-		// Hash any sensitive information
-		if err := audit.Hash(auth); err != nil {
-			return err
-		}
-		if err := audit.Hash(req); err != nil {
-			return err
-		}
+	// Hash any sensitive information
+	auth, req, err := audit.HashRequest(b.hash, auth, req)
+	if err != nil {
+		return err
 	}
 
-	var format audit.FormatJSON
-	return format.FormatRequest(b.f, auth, req)
+	var buf bytes.Buffer
+	if err := b.eventFormatter().FormatRequest(&buf, auth, req); err != nil {
+		return err
+	}
+	return b.write(ctx, buf.Bytes())
 }
 
 func (b *Backend) LogResponse(
+	ctx context.Context,
 	auth *logical.Auth,
 	req *logical.Request,
 	resp *logical.Response,
-	err error) error {
+	respErr error) error {
 	if err := b.open(); err != nil {
 		return err
 	}
-	if !b.LogRaw {
-		// Copy the structure
-		cp, err := copystructure.Copy(auth)
-		if err != nil {
-			return err
-		}
-		auth = cp.(*logical.Auth)
+	// Hash any sensitive information
+	auth, req, resp, err := audit.HashResponse(b.hash, auth, req, resp)
+	if err != nil {
+		return err
+	}
 
-		cp, err = copystructure.Copy(req)
-		if err != nil {
-			return err
-		}
-		req = cp.(*logical.Request)
+	var buf bytes.Buffer
+	if err := b.eventFormatter().FormatResponse(&buf, auth, req, resp, respErr); err != nil {
+		return err
+	}
+	return b.write(ctx, buf.Bytes())
+}
 
-		cp, err = copystructure.Copy(resp)
-		if err != nil {
-			return err
-		}
-		resp = cp.(*logical.Response)
+func (b *Backend) LogStatus(ctx context.Context, status *audit.Status) error {
+	return b.logEvent(ctx, status)
+}
 
-		// Hash any sensitive information
-		if err := audit.Hash(auth); err != nil {
-			return err
-		}
-		if err := audit.Hash(req); err != nil {
-			return err
+func (b *Backend) LogHeartbeat(ctx context.Context, hb *audit.Heartbeat) error {
+	return b.logEvent(ctx, hb)
+}
+
+func (b *Backend) LogSummary(ctx context.Context, s *audit.Summary) error {
+	return b.logEvent(ctx, s)
+}
+
+func (b *Backend) LogInventory(ctx context.Context, inv *audit.Inventory) error {
+	return b.logEvent(ctx, inv)
+}
+
+func (b *Backend) LogAuditChange(ctx context.Context, change *audit.AuditChange) error {
+	return b.logEvent(ctx, change)
+}
+
+// Test opens the file, so that a bad path is reported when the backend
+// is enabled, and writes the self-test entry if the format supports it.
+func (b *Backend) Test(ctx context.Context, st *audit.SelfTest) error {
+	if err := b.open(); err != nil {
+		return err
+	}
+	return b.logEvent(ctx, st)
+}
+
+// Close releases the sink of the backend, closing the file once no other
+// backend writes to it.
+func (b *Backend) Close() error {
+	b.l.Lock()
+	defer b.l.Unlock()
+	if b.sink == nil {
+		return nil
+	}
+	err := releaseSink(b.sink)
+	b.sink = nil
+	return err
+}
+
+// logEvent formats an event other than a request or a response, see
+// audit.FormatEvent, and writes it.
+func (b *Backend) logEvent(ctx context.Context, ev interface{}) error {
+	entry, err := audit.FormatEvent(b.formatter, ev)
+	if err != nil {
+		return err
+	}
+	if len(entry) == 0 {
+		return nil
+	}
+	if err := b.open(); err != nil {
+		return err
+	}
+	return b.write(ctx, entry)
+}
+
+// Degraded returns why the entries are logged without their data, or
+// fail to be logged, when the free disk space is below the minimum, see
+// diskGuard. It returns an empty string otherwise.
+func (b *Backend) Degraded() string {
+	if b.disk == nil {
+		return ""
+	}
+	now := b.now()
+	path, err := expandPath(b.Path, b.opts.Time(now))
+	if err != nil {
+		return ""
+	}
+	return b.disk.check(filepath.Dir(path), now)
+}
+
+// eventFormatter returns the formatter of the requests and responses,
+// which drops their data while the free disk space is low if the
+// low_disk_action is "reduce".
+func (b *Backend) eventFormatter() audit.Formatter {
+	if b.reduced != nil && b.Degraded() != "" {
+		return b.reduced
+	}
+	return b.formatter
+}
+
+func (b *Backend) open() error {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	_, err := b.currentSink()
+	return err
+}
+
+// currentSink returns the sink of the file the entries are written to
+// now, opening it if the expanded path changed since the last entry and
+// releasing the sink of the previous file. b.l must be held.
+func (b *Backend) currentSink() (*sink, error) {
+	if b.sink != nil && !isPathTemplate(b.Path) {
+		return b.sink, nil
+	}
+
+	path, err := expandPath(b.Path, b.opts.Time(b.now()))
+	if err != nil {
+		return nil, err
+	}
+	if b.sink != nil {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
 		}
-		if err := audit.Hash(resp); err != nil {
-			return err
+		if abs == b.sink.path {
+			return b.sink, nil
 		}
 	}
 
-	var format audit.FormatJSON
-	return format.FormatResponse(b.f, auth, req, resp, err)
+	s, err := openSink(path)
+	if err != nil {
+		return nil, err
+	}
+	if b.sink != nil {
+		// The previous file is complete, so failing to close it
+		// doesn't lose any entry
+		releaseSink(b.sink)
+	}
+	b.sink = s
+	return s, nil
 }
 
-func (b *Backend) open() error {
-	if b.f != nil {
+// write writes a formatted entry to the file in a single write, unless
+// the context is done.
+func (b *Backend) write(ctx context.Context, entry []byte) error {
+	if len(entry) == 0 {
 		return nil
 	}
-	if err := os.MkdirAll(filepath.Dir(b.Path), 0600); err != nil {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
+	if b.disk != nil && b.disk.action == "fail" {
+		if low := b.Degraded(); low != "" {
+			return errors.New(low)
+		}
+	}
 
-	var err error
-	b.f, err = os.OpenFile(b.Path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	b.l.Lock()
+	defer b.l.Unlock()
+	s, err := b.currentSink()
 	if err != nil {
 		return err
 	}
-
-	return nil
+	_, err = s.Write(entry)
+	return err
 }
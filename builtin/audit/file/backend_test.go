@@ -0,0 +1,413 @@
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/audit/audittest"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+func TestBackend_sharedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	// Two backends writing to the same file, one through a relative path
+	b1, err := Factory(map[string]string{"path": path})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b2, err := Factory(map[string]string{"path": rel})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		for _, b := range []*Backend{b1.(*Backend), b2.(*Backend)} {
+			wg.Add(1)
+			go func(b *Backend, i int) {
+				defer wg.Done()
+				req := &logical.Request{
+					Operation: logical.WriteOperation,
+					Path:      fmt.Sprintf("secret/%d", i),
+					Data: map[string]interface{}{
+						"value": fmt.Sprintf("%01000d", i),
+					},
+				}
+				if err := b.LogRequest(context.Background(), nil, req); err != nil {
+					t.Errorf("err: %s", err)
+				}
+			}(b, i)
+		}
+	}
+	wg.Wait()
+
+	if b1.(*Backend).sink != b2.(*Backend).sink {
+		t.Fatal("backends should share the sink")
+	}
+
+	// Every line must be a whole entry
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	var lines int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("err: %s\n\n%s", err, scanner.Text())
+		}
+		lines++
+	}
+	if lines != 100 {
+		t.Fatalf("bad: %d", lines)
+	}
+}
+
+func TestBackend_close(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	// A backend replaced by another writing to the same file, such as
+	// when its salt is rotated, releases its reference to the sink
+	var prev *Backend
+	for i := 0; i < 3; i++ {
+		raw, err := Factory(map[string]string{"path": path})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		b := raw.(*Backend)
+		if err := b.open(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if prev != nil {
+			if err := prev.Close(); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+		prev = b
+
+		sinksLock.Lock()
+		refs := sinks[path].refs
+		sinksLock.Unlock()
+		if refs != 1 {
+			t.Fatalf("bad: %d", refs)
+		}
+	}
+
+	// and the file is closed once the last backend is
+	if err := prev.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	sinksLock.Lock()
+	_, ok := sinks[path]
+	sinksLock.Unlock()
+	if ok {
+		t.Fatal("sink should be released")
+	}
+}
+
+func TestBackend_pathTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	raw, err := Factory(map[string]string{
+		"path": filepath.Join(dir, "audit-%Y%m%d.log"),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b := raw.(*Backend)
+	now := time.Date(2016, 1, 1, 23, 59, 59, 0, time.UTC)
+	b.SetClock(func() time.Time { return now })
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	first := b.sink
+
+	// The backend rolls over to a new file at midnight
+	now = now.Add(2 * time.Second)
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if b.sink == first {
+		t.Fatal("should have rolled over")
+	}
+
+	// and the previous file is closed
+	sinksLock.Lock()
+	_, ok := sinks[first.path]
+	sinksLock.Unlock()
+	if ok {
+		t.Fatal("previous sink should be released")
+	}
+
+	// The entries are timestamped with the same clock as the path
+	files := map[string]string{
+		"audit-20160101.log": "2016-01-01T23:59:59Z",
+		"audit-20160102.log": "2016-01-02T00:00:01Z",
+	}
+	for name, logged := range files {
+		output, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if lines := strings.Count(string(output), "\n"); lines != 1 {
+			t.Fatalf("bad: %s: %d", name, lines)
+		}
+		if !strings.Contains(string(output), `"time":"`+logged+`"`) {
+			t.Fatalf("bad: %s: %s", name, output)
+		}
+	}
+
+	if _, err := Factory(map[string]string{"path": filepath.Join(dir, "audit-%Q.log")}); err == nil {
+		t.Fatal("should error with an unknown token")
+	}
+}
+
+func TestBackend_dirMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The missing directories of the path are created, and only the
+	// Vault can list them
+	raw, err := Factory(map[string]string{
+		"path": filepath.Join(dir, "%Y", "%m", "audit.log"),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b := raw.(*Backend)
+	b.SetClock(func() time.Time {
+		return time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	})
+	if err := b.LogRequest(context.Background(), nil, &logical.Request{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer b.Close()
+
+	for _, name := range []string{"2016", filepath.Join("2016", "01")} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if mode := info.Mode(); !mode.IsDir() || mode.Perm() != 0700 {
+			t.Fatalf("bad: %s %s", name, mode)
+		}
+	}
+}
+
+func TestBackend_diskGuard(t *testing.T) {
+	if !diskSpaceSupported {
+		t.Skip("disk space is not supported on this platform")
+	}
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/foo",
+		Data:      map[string]interface{}{"value": "bar"},
+	}
+	var free uint64 = 100
+	space := func(string) (uint64, uint64, error) { return free, 1000, nil }
+
+	// Failing to log while the free space is low
+	raw, err := Factory(map[string]string{
+		"path":           filepath.Join(dir, "fail.log"),
+		"min_free_bytes": "500",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b := raw.(*Backend)
+	b.disk.space = space
+	if err := b.LogRequest(context.Background(), nil, req); err == nil {
+		t.Fatal("should fail while the free space is low")
+	}
+	if b.Degraded() == "" {
+		t.Fatal("should be degraded")
+	}
+
+	// The free space is read again once the cache expires
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	free = 200
+	b.disk.minFreeBytes, b.disk.minFreePercent = 0, 10
+	now = now.Add(diskCheckInterval)
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if b.Degraded() != "" {
+		t.Fatalf("bad: %s", b.Degraded())
+	}
+
+	// Dropping the data while the free space is low
+	path := filepath.Join(dir, "reduce.log")
+	raw, err = Factory(map[string]string{
+		"path":             path,
+		"min_free_percent": "50",
+		"low_disk_action":  "reduce",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b = raw.(*Backend)
+	b.disk.space = space
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	output, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(string(output), "secret/foo") || strings.Contains(string(output), "sha1:") {
+		t.Fatalf("bad: %s", output)
+	}
+
+	for _, conf := range []map[string]string{
+		{"min_free_bytes": "-1"},
+		{"min_free_percent": "100"},
+		{"min_free_bytes": "1", "low_disk_action": "panic"},
+	} {
+		conf["path"] = filepath.Join(dir, "audit.log")
+		if _, err := Factory(conf); err == nil {
+			t.Fatalf("expected error: %v", conf)
+		}
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	now := time.Date(2016, 2, 3, 4, 5, 6, 0, time.UTC)
+	cases := map[string]string{
+		"/var/log/audit.log":               "/var/log/audit.log",
+		"/var/log/audit-%Y%m%d.log":        "/var/log/audit-20160203.log",
+		"/var/log/%Y/%m/audit-%d-%H%M.log": "/var/log/2016/02/audit-03-0405.log",
+		"/var/log/audit-100%%.log":         "/var/log/audit-100%.log",
+	}
+	for path, expected := range cases {
+		actual, err := expandPath(path, now)
+		if err != nil {
+			t.Fatalf("err: %s: %s", path, err)
+		}
+		if actual != expected {
+			t.Fatalf("bad: %s: %s", path, actual)
+		}
+	}
+
+	for _, path := range []string{"/var/log/audit-%s.log", "/var/log/audit-%"} {
+		if _, err := expandPath(path, now); err == nil {
+			t.Fatalf("expected error: %s", path)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	conf, err := Validate(map[string]string{"path": path, "format": "csv"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if conf["format"] != "csv" || conf["hash_algorithm"] != "sha1" || conf["detail"] != "full" {
+		t.Fatalf("bad: %#v", conf)
+	}
+
+	// The file is not created
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, err := Validate(map[string]string{}); err == nil {
+		t.Fatal("should error without a path")
+	}
+	if _, err := Validate(map[string]string{"path": path, "format": "xml"}); err == nil {
+		t.Fatal("should error with an unknown format")
+	}
+}
+
+func TestBackend_golden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	b, err := Factory(map[string]string{"path": path, "format": "csv"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	audittest.Replay(t, b, []audittest.Entry{
+		{
+			Auth: &logical.Auth{DisplayName: "root", Policies: []string{"root"}},
+			Request: &logical.Request{
+				Operation: logical.WriteOperation,
+				Path:      "secret/foo",
+			},
+		},
+		{
+			Request: &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      "secret/foo",
+			},
+			Response: &logical.Response{
+				Secret: &logical.Secret{LeaseID: "secret/foo/1234"},
+			},
+		},
+	})
+
+	output, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	audittest.AssertGolden(t, "testdata/replay.csv.golden", output)
+}
@@ -0,0 +1,95 @@
+package file
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// diskCheckInterval is how long the free space of the filesystem is
+// cached, so that it isn't read for every entry.
+const diskCheckInterval = time.Second
+
+// diskGuard keeps the file backend from filling the filesystem of its
+// file, from the "min_free_bytes", "min_free_percent", and
+// "low_disk_action" options. When the free space drops below either
+// minimum, the backend fails to log entries if the action is "fail", or
+// logs them without their request and response data if it is "reduce".
+type diskGuard struct {
+	minFreeBytes   uint64
+	minFreePercent float64
+	action         string
+
+	// space returns the free and total bytes of the filesystem of the
+	// directory, see diskSpace
+	space func(dir string) (uint64, uint64, error)
+
+	l       sync.Mutex
+	checked time.Time
+	dir     string
+	low     string
+}
+
+// parseDiskGuard parses the disk space options, returning nil if neither
+// minimum is set.
+func parseDiskGuard(conf map[string]string) (*diskGuard, error) {
+	g := &diskGuard{action: "fail", space: diskSpace}
+	if v, ok := conf["min_free_bytes"]; ok {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min_free_bytes: %s", v)
+		}
+		g.minFreeBytes = n
+	}
+	if v, ok := conf["min_free_percent"]; ok {
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil || p < 0 || p >= 100 {
+			return nil, fmt.Errorf("invalid min_free_percent: %s", v)
+		}
+		g.minFreePercent = p
+	}
+	if v, ok := conf["low_disk_action"]; ok {
+		switch v {
+		case "fail", "reduce":
+			g.action = v
+		default:
+			return nil, fmt.Errorf("invalid low_disk_action: %s", v)
+		}
+	}
+
+	if g.minFreeBytes == 0 && g.minFreePercent == 0 {
+		return nil, nil
+	}
+	if !diskSpaceSupported {
+		return nil, fmt.Errorf("min_free_bytes and min_free_percent are not supported on this platform")
+	}
+	return g, nil
+}
+
+// check returns why the free space of the filesystem of the directory is
+// below the minimum, or an empty string if it isn't. The result is cached
+// for diskCheckInterval. A filesystem whose free space can't be read,
+// such as one whose directory doesn't exist yet, isn't considered low, so
+// that the error of opening the file is the one reported.
+func (g *diskGuard) check(dir string, now time.Time) string {
+	g.l.Lock()
+	defer g.l.Unlock()
+
+	if dir == g.dir && now.Sub(g.checked) < diskCheckInterval {
+		return g.low
+	}
+	g.dir, g.checked, g.low = dir, now, ""
+
+	free, total, err := g.space(dir)
+	if err != nil || total == 0 {
+		return ""
+	}
+	percent := float64(free) / float64(total) * 100
+	if free < g.minFreeBytes || percent < g.minFreePercent {
+		g.low = fmt.Sprintf(
+			"free disk space of %s is %d bytes (%.1f%%), below the minimum",
+			dir, free, percent)
+	}
+	return g.low
+}
@@ -0,0 +1,17 @@
+// +build linux darwin freebsd
+
+package file
+
+import "syscall"
+
+const diskSpaceSupported = true
+
+// diskSpace returns the bytes available to unprivileged users and the
+// total bytes of the filesystem of the directory.
+func diskSpace(dir string) (uint64, uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), uint64(st.Blocks) * uint64(st.Bsize), nil
+}
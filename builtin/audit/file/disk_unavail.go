@@ -0,0 +1,12 @@
+// +build !linux,!darwin,!freebsd
+
+package file
+
+import "fmt"
+
+const diskSpaceSupported = false
+
+func diskSpace(dir string) (uint64, uint64, error) {
+	// XXX: Windows needs GetDiskFreeSpaceEx, which isn't in syscall.
+	return 0, 0, fmt.Errorf("disk space is not supported on this platform")
+}
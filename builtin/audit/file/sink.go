@@ -0,0 +1,81 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	sinksLock sync.Mutex
+	sinks     = make(map[string]*sink)
+)
+
+// sink is an open audit log file. Backends writing to the same file share
+// a single sink, so that their entries are serialized rather than racing
+// on separate file handles.
+type sink struct {
+	l    sync.Mutex
+	f    *os.File
+	path string
+
+	// refs is the number of backends writing to the sink, guarded by
+	// sinksLock
+	refs int
+}
+
+// openSink returns the sink for the file at the given path, opening the
+// file if no backend has it open yet. Sinks stay open until every backend
+// that opened them releases them, see releaseSink.
+func openSink(path string) (*sink, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sinksLock.Lock()
+	defer sinksLock.Unlock()
+
+	if s, ok := sinks[path]; ok {
+		s.refs++
+		return s, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &sink{f: f, path: path, refs: 1}
+	sinks[path] = s
+	return s, nil
+}
+
+// releaseSink is called by a backend that no longer writes to the sink,
+// such as once its path template rolled over to a new file or once it is
+// closed. The file is
+// closed when no backend writes to it anymore.
+func releaseSink(s *sink) error {
+	sinksLock.Lock()
+	defer sinksLock.Unlock()
+
+	s.refs--
+	if s.refs > 0 {
+		return nil
+	}
+	delete(sinks, s.path)
+
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.f.Close()
+}
+
+// Write writes a whole entry to the file.
+func (s *sink) Write(p []byte) (int, error) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.f.Write(p)
+}
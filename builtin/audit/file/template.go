@@ -0,0 +1,54 @@
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// isPathTemplate returns whether the path has time tokens, see
+// expandPath, so that the file changes over time.
+func isPathTemplate(path string) bool {
+	return strings.Contains(path, "%")
+}
+
+// expandPath returns the path with its time tokens replaced with the
+// given time: "%Y" with the year, "%m" with the month, "%d" with the day
+// of the month, "%H" with the hour, and "%M" with the minute, all padded
+// with zeros, and "%%" with a percent sign. Any other token is an error.
+func expandPath(path string, t time.Time) (string, error) {
+	if !isPathTemplate(path) {
+		return path, nil
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < len(path); i++ {
+		if path[i] != '%' {
+			buf.WriteByte(path[i])
+			continue
+		}
+		if i+1 == len(path) {
+			return "", fmt.Errorf("invalid path, ends with %%: %s", path)
+		}
+
+		i++
+		switch path[i] {
+		case 'Y':
+			fmt.Fprintf(&buf, "%04d", t.Year())
+		case 'm':
+			fmt.Fprintf(&buf, "%02d", int(t.Month()))
+		case 'd':
+			fmt.Fprintf(&buf, "%02d", t.Day())
+		case 'H':
+			fmt.Fprintf(&buf, "%02d", t.Hour())
+		case 'M':
+			fmt.Fprintf(&buf, "%02d", t.Minute())
+		case '%':
+			buf.WriteByte('%')
+		default:
+			return "", fmt.Errorf("invalid path, unknown token %%%c: %s", path[i], path)
+		}
+	}
+	return buf.String(), nil
+}
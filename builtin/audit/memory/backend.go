@@ -0,0 +1,193 @@
+package memory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	audit.Register("memory", Factory)
+	audit.RegisterValidator("memory", Validate)
+}
+
+// defaultSize is the default number of entries kept.
+const defaultSize = 1000
+
+// Validate checks the options of the backend.
+func Validate(conf map[string]string) (map[string]string, error) {
+	if _, err := parseSize(conf); err != nil {
+		return nil, err
+	}
+
+	conf, err := audit.ResolveHashOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+	conf, err = audit.ResolveFormatOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+	if conf["format"] != "json" {
+		return nil, fmt.Errorf("the memory backend only supports the json format")
+	}
+	if _, ok := conf["size"]; !ok {
+		conf["size"] = strconv.Itoa(defaultSize)
+	}
+	return conf, nil
+}
+
+func Factory(conf map[string]string) (audit.Backend, error) {
+	size, err := parseSize(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// The entries are hashed before they are kept, so that they are read
+	// back through sys/audit-entries as they would be logged
+	hash, err := audit.ParseHashConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// The entries are decoded when they are read, so only JSON is allowed
+	if v, ok := conf["format"]; ok && v != "json" {
+		return nil, fmt.Errorf("the memory backend only supports the json format")
+	}
+	formatter, err := audit.NewFormatter(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Backend{
+		hash:      hash,
+		formatter: formatter,
+		entries:   make([][]byte, size),
+	}
+	return b, nil
+}
+
+func parseSize(conf map[string]string) (int, error) {
+	v, ok := conf["size"]
+	if !ok {
+		return defaultSize, nil
+	}
+	size, err := strconv.Atoi(v)
+	if err != nil || size < 1 {
+		return 0, fmt.Errorf("invalid size: %s", v)
+	}
+	return size, nil
+}
+
+// Backend is the audit backend that keeps the last entries in memory, in
+// a ring buffer, for debugging on servers without an audit log. The
+// entries are read back through the sys/audit-entries endpoint, and are
+// lost when Vault is sealed or stopped.
+type Backend struct {
+	hash      *audit.HashConfig
+	formatter audit.Formatter
+
+	// l protects the ring buffer. next is the index the next entry is
+	// written at, and full is set once the buffer has wrapped around.
+	l       sync.Mutex
+	entries [][]byte
+	next    int
+	full    bool
+}
+
+// SetClock sets the clock the entries are timestamped with, and the end
+// of the rotation window of the salt is checked against, see
+// audit.ClockSetter.
+func (b *Backend) SetClock(clock func() time.Time) {
+	audit.SetClock(b.formatter, clock)
+	audit.SetClock(b.hash, clock)
+}
+
+func (b *Backend) LogRequest(
+	ctx context.Context, auth *logical.Auth, req *logical.Request) error {
+	// Hash any sensitive information
+	auth, req, err := audit.HashRequest(b.hash, auth, req)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := b.formatter.FormatRequest(&buf, auth, req); err != nil {
+		return err
+	}
+	b.add(buf.Bytes())
+	return nil
+}
+
+func (b *Backend) LogResponse(
+	ctx context.Context,
+	auth *logical.Auth,
+	req *logical.Request,
+	resp *logical.Response,
+	respErr error) error {
+	// Hash any sensitive information
+	auth, req, resp, err := audit.HashResponse(b.hash, auth, req, resp)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := b.formatter.FormatResponse(&buf, auth, req, resp, respErr); err != nil {
+		return err
+	}
+	b.add(buf.Bytes())
+	return nil
+}
+
+func (b *Backend) LogStatus(ctx context.Context, status *audit.Status) error {
+	entry, err := audit.FormatEvent(b.formatter, status)
+	if err != nil {
+		return err
+	}
+	b.add(entry)
+	return nil
+}
+
+// add adds an entry to the ring buffer, replacing the oldest entry once
+// the buffer is full.
+func (b *Backend) add(entry []byte) {
+	if len(entry) == 0 {
+		return
+	}
+
+	b.l.Lock()
+	defer b.l.Unlock()
+	b.entries[b.next] = entry
+	b.next++
+	if b.next == len(b.entries) {
+		b.next = 0
+		b.full = true
+	}
+}
+
+// Entries returns the entries kept, oldest first.
+func (b *Backend) Entries() ([]map[string]interface{}, error) {
+	b.l.Lock()
+	entries := b.entries[:b.next]
+	if b.full {
+		entries = append(b.entries[b.next:len(b.entries):len(b.entries)], entries...)
+	}
+	b.l.Unlock()
+
+	result := make([]map[string]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		var v map[string]interface{}
+		if err := json.Unmarshal(entry, &v); err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+func TestValidate(t *testing.T) {
+	conf, err := Validate(map[string]string{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if conf["size"] != "1000" {
+		t.Fatalf("bad: %#v", conf)
+	}
+
+	for _, size := range []string{"0", "-1", "foo"} {
+		if _, err := Validate(map[string]string{"size": size}); err == nil {
+			t.Fatalf("expected error for size %s", size)
+		}
+	}
+	if _, err := Validate(map[string]string{"format": "jsonx"}); err == nil {
+		t.Fatal("expected error for jsonx")
+	}
+}
+
+func TestBackend_Entries(t *testing.T) {
+	b, err := Factory(map[string]string{"salt": "foo", "size": "2"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	backend := b.(*Backend)
+
+	entries, err := backend.Entries()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("bad: %#v", entries)
+	}
+
+	// Only the last two entries are kept, oldest first
+	for _, path := range []string{"a", "b", "c"} {
+		req := &logical.Request{Operation: logical.ReadOperation, Path: path}
+		if err := backend.LogRequest(context.Background(), &logical.Auth{}, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		entries, err := backend.Entries()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		var paths []string
+		for _, entry := range entries {
+			paths = append(paths, entry["request"].(map[string]interface{})["path"].(string))
+		}
+		expected := map[string][]string{
+			"a": {"a"},
+			"b": {"a", "b"},
+			"c": {"b", "c"},
+		}[path]
+		if !reflect.DeepEqual(paths, expected) {
+			t.Fatalf("bad after %s: %#v", path, paths)
+		}
+	}
+}
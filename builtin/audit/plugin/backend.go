@@ -0,0 +1,326 @@
+package plugin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	audit.Register("plugin", Factory)
+	audit.RegisterValidator("plugin", Validate)
+}
+
+// closeTimeout is how long Close waits for the plugin to exit once its
+// connection is closed, before killing it.
+const closeTimeout = 10 * time.Second
+
+var (
+	directoryLock sync.RWMutex
+	directory     string
+)
+
+// SetDirectory sets the directory the plugins are run from, the
+// "plugin_directory" of the server configuration. Until it is set, the
+// backend can't be enabled, so that the API can only run the binaries
+// the operator put in the directory.
+func SetDirectory(dir string) {
+	directoryLock.Lock()
+	defer directoryLock.Unlock()
+	directory = dir
+}
+
+// Validate checks the options of the backend without starting the plugin.
+// Whether the plugin directory is configured is only checked by Factory,
+// so that options can be checked offline.
+func Validate(conf map[string]string) (map[string]string, error) {
+	command, ok := conf["command"]
+	if !ok {
+		return nil, fmt.Errorf("command is required")
+	}
+	if _, err := cleanCommand(command); err != nil {
+		return nil, err
+	}
+	if _, err := parseChecksum(conf); err != nil {
+		return nil, err
+	}
+
+	conf, err := audit.ResolveHashOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := conf["args"]; !ok {
+		conf["args"] = ""
+	}
+	return conf, nil
+}
+
+func Factory(conf map[string]string) (audit.Backend, error) {
+	command, ok := conf["command"]
+	if !ok {
+		return nil, fmt.Errorf("command is required")
+	}
+	path, err := pluginPath(command)
+	if err != nil {
+		return nil, err
+	}
+	checksum, err := parseChecksum(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// The entries are hashed before they are sent to the plugin
+	hash, err := audit.ParseHashConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Backend{
+		path:     path,
+		args:     strings.Fields(conf["args"]),
+		checksum: checksum,
+		hash:     hash,
+	}
+	return b, nil
+}
+
+// cleanCommand returns the command cleaned, or an error if it is absolute
+// or leads outside of the plugin directory.
+func cleanCommand(command string) (string, error) {
+	clean := filepath.Clean(command)
+	if command == "" || filepath.IsAbs(clean) || clean == ".." ||
+		strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf(
+			"invalid command, must be a path within the plugin directory: %s", command)
+	}
+	return clean, nil
+}
+
+// pluginPath returns the path of the binary of the command in the plugin
+// directory.
+func pluginPath(command string) (string, error) {
+	directoryLock.RLock()
+	dir := directory
+	directoryLock.RUnlock()
+	if dir == "" {
+		return "", fmt.Errorf(
+			"plugin audit backends are disabled, plugin_directory is not configured")
+	}
+
+	clean, err := cleanCommand(command)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, clean), nil
+}
+
+// parseChecksum parses the "sha256" option, the hex encoded SHA-256 of
+// the binary, if set.
+func parseChecksum(conf map[string]string) ([]byte, error) {
+	v, ok := conf["sha256"]
+	if !ok {
+		return nil, nil
+	}
+	sum, err := hex.DecodeString(v)
+	if err != nil || len(sum) != sha256.Size {
+		return nil, fmt.Errorf("invalid sha256: %s", v)
+	}
+	return sum, nil
+}
+
+// checkBinary checks that the binary at the path has the checksum.
+func checkBinary(path string, checksum []byte) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if sum := h.Sum(nil); !bytes.Equal(sum, checksum) {
+		return fmt.Errorf("checksum mismatch for %s: %x", path, sum)
+	}
+	return nil
+}
+
+// Backend is the audit backend that sends entries to an external plugin
+// process. The plugin is started on first use and speaks JSON-RPC over
+// its stdin and stdout, see Serve. If the plugin exits, it is restarted
+// on the next entry. If a checksum is configured, the binary is checked
+// every time the plugin is started.
+type Backend struct {
+	path     string
+	args     []string
+	checksum []byte
+	hash     *audit.HashConfig
+
+	l      sync.Mutex
+	cmd    *exec.Cmd
+	client *rpc.Client
+}
+
+// SetClock sets the clock the end of the rotation window of the salt is
+// checked against, see audit.ClockSetter.
+func (b *Backend) SetClock(clock func() time.Time) {
+	audit.SetClock(b.hash, clock)
+}
+
+func (b *Backend) LogRequest(
+	ctx context.Context, auth *logical.Auth, req *logical.Request) error {
+	// Hash any sensitive information
+	auth, req, err := audit.HashRequest(b.hash, auth, req)
+	if err != nil {
+		return err
+	}
+
+	return b.call(ctx, "LogRequest", &LogRequestArgs{
+		Auth:    auth,
+		Request: rpcRequest(req),
+	})
+}
+
+func (b *Backend) LogResponse(ctx context.Context, auth *logical.Auth,
+	req *logical.Request, resp *logical.Response, respErr error) error {
+	// Hash any sensitive information
+	auth, req, resp, err := audit.HashResponse(b.hash, auth, req, resp)
+	if err != nil {
+		return err
+	}
+
+	args := &LogResponseArgs{
+		Auth:     auth,
+		Request:  rpcRequest(req),
+		Response: resp,
+	}
+	if respErr != nil {
+		args.Error = respErr.Error()
+	}
+	return b.call(ctx, "LogResponse", args)
+}
+
+// call invokes the named method of the plugin, starting it if needed.
+// If the context is done before the plugin replies, the plugin is stopped
+// so that the pending call does not stay blocked, and it is restarted on
+// the next call.
+func (b *Backend) call(ctx context.Context, method string, args interface{}) error {
+	client, err := b.rpcClient()
+	if err != nil {
+		return err
+	}
+
+	call := client.Go(rpcName+"."+method, args, new(struct{}), nil)
+	select {
+	case <-call.Done:
+	case <-ctx.Done():
+		b.stop(client)
+		return ctx.Err()
+	}
+
+	if call.Error == rpc.ErrShutdown {
+		// The plugin has exited, restart it on the next call
+		b.stop(client)
+	}
+	return call.Error
+}
+
+// rpcClient returns the client of the running plugin, starting it if
+// it is not running.
+func (b *Backend) rpcClient() (*rpc.Client, error) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	if b.client != nil {
+		return b.client, nil
+	}
+
+	if b.checksum != nil {
+		if err := checkBinary(b.path, b.checksum); err != nil {
+			return nil, fmt.Errorf("failed to start plugin: %s", err)
+		}
+	}
+
+	cmd := exec.Command(b.path, b.args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin: %s", err)
+	}
+
+	b.cmd = cmd
+	b.client = jsonrpc.NewClient(&pipeConn{
+		ReadCloser:  stdout,
+		WriteCloser: stdin,
+	})
+	return b.client, nil
+}
+
+// Close stops the plugin, if it is running, and waits for it to exit, see
+// audit.Closer. A plugin that doesn't exit within closeTimeout of its
+// connection being closed is killed.
+func (b *Backend) Close() error {
+	b.l.Lock()
+	client, cmd := b.client, b.cmd
+	b.client = nil
+	b.cmd = nil
+	b.l.Unlock()
+	if client == nil {
+		return nil
+	}
+
+	// Closing the connection causes the plugin to exit
+	client.Close()
+	if cmd == nil {
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(closeTimeout):
+		cmd.Process.Kill()
+		return fmt.Errorf("plugin did not exit within %s and was killed", closeTimeout)
+	}
+}
+
+// stop is used to stop the plugin if the given client is still in use
+func (b *Backend) stop(client *rpc.Client) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	if b.client != client {
+		return
+	}
+
+	// Closing the connection causes the plugin to exit
+	b.client.Close()
+	if b.cmd != nil {
+		go b.cmd.Wait()
+	}
+	b.client = nil
+	b.cmd = nil
+}
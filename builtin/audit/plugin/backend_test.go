@@ -0,0 +1,122 @@
+package plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFactory_directory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-plugin")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	defer SetDirectory("")
+
+	// The backend is disabled until the directory is configured
+	SetDirectory("")
+	if _, err := Factory(map[string]string{"command": "foo"}); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	SetDirectory(dir)
+	b, err := Factory(map[string]string{"command": "sub/../foo"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if path := b.(*Backend).path; path != filepath.Join(dir, "foo") {
+		t.Fatalf("bad: %s", path)
+	}
+
+	for _, command := range []string{"", "/bin/sh", "../foo", "sub/../../foo"} {
+		if _, err := Factory(map[string]string{"command": command}); err == nil {
+			t.Fatalf("expected error: %s", command)
+		}
+		if _, err := Validate(map[string]string{"command": command}); err == nil {
+			t.Fatalf("expected error: %s", command)
+		}
+	}
+}
+
+func TestBackend_checksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-plugin")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	defer SetDirectory("")
+	SetDirectory(dir)
+
+	binary := []byte("#!/bin/sh\n")
+	if err := ioutil.WriteFile(filepath.Join(dir, "foo"), binary, 0700); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := Validate(map[string]string{"command": "foo", "sha256": "abcd"}); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// A binary that doesn't match the checksum isn't started
+	other := sha256.Sum256([]byte("other"))
+	b, err := Factory(map[string]string{
+		"command": "foo",
+		"sha256":  hex.EncodeToString(other[:]),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := b.(*Backend).rpcClient(); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("err: %v", err)
+	}
+
+	sum := sha256.Sum256(binary)
+	if err := checkBinary(filepath.Join(dir, "foo"), sum[:]); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestBackend_close(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-plugin")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	defer SetDirectory("")
+	SetDirectory(dir)
+
+	// The plugin exits once its stdin is closed
+	binary := []byte("#!/bin/sh\ncat >/dev/null\n")
+	if err := ioutil.WriteFile(filepath.Join(dir, "foo"), binary, 0700); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	raw, err := Factory(map[string]string{"command": "foo"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b := raw.(*Backend)
+	if _, err := b.rpcClient(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cmd := b.cmd
+
+	if err := b.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if cmd.ProcessState == nil || !cmd.ProcessState.Exited() {
+		t.Fatalf("plugin should have exited: %v", cmd.ProcessState)
+	}
+	if b.client != nil || b.cmd != nil {
+		t.Fatal("plugin should be stopped")
+	}
+
+	// Closing a backend whose plugin isn't running does nothing
+	if err := b.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -0,0 +1,96 @@
+package plugin
+
+import (
+	"errors"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+// rpcName is the name the audit backend is served under by the plugin.
+const rpcName = "Plugin"
+
+// LogRequestArgs are the arguments of the LogRequest RPC call.
+type LogRequestArgs struct {
+	Auth    *logical.Auth
+	Request *logical.Request
+}
+
+// LogResponseArgs are the arguments of the LogResponse RPC call. The
+// error is sent as its message, since errors cannot be encoded.
+type LogResponseArgs struct {
+	Auth     *logical.Auth
+	Request  *logical.Request
+	Response *logical.Response
+	Error    string
+}
+
+// RPCServer is the net/rpc server that exposes an audit.Backend
+// implemented by a plugin to Vault.
+type RPCServer struct {
+	Backend audit.Backend
+}
+
+func (s *RPCServer) LogRequest(args *LogRequestArgs, reply *struct{}) error {
+	return s.Backend.LogRequest(context.Background(), args.Auth, args.Request)
+}
+
+func (s *RPCServer) LogResponse(args *LogResponseArgs, reply *struct{}) error {
+	var err error
+	if args.Error != "" {
+		err = errors.New(args.Error)
+	}
+	return s.Backend.LogResponse(context.Background(),
+		args.Auth, args.Request, args.Response, err)
+}
+
+// Serve is called by a plugin binary to serve the given audit backend to
+// Vault. Requests are read from stdin and replies written to stdout, so
+// the plugin must not write anything else to stdout. This blocks until
+// Vault closes the connection.
+func Serve(b audit.Backend) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName(rpcName, &RPCServer{Backend: b}); err != nil {
+		return err
+	}
+	server.ServeCodec(jsonrpc.NewServerCodec(&pipeConn{
+		ReadCloser:  os.Stdin,
+		WriteCloser: os.Stdout,
+	}))
+	return nil
+}
+
+// rpcRequest returns a shallow copy of the request without the fields
+// that cannot be sent to the plugin.
+func rpcRequest(req *logical.Request) *logical.Request {
+	if req == nil {
+		return nil
+	}
+	cp := *req
+	cp.Storage = nil
+	if cp.Connection != nil {
+		cp.Connection = &logical.Connection{
+			RemoteAddr: cp.Connection.RemoteAddr,
+		}
+	}
+	return &cp
+}
+
+// pipeConn joins a pair of pipes into a single connection.
+type pipeConn struct {
+	io.ReadCloser
+	io.WriteCloser
+}
+
+func (c *pipeConn) Close() error {
+	rerr := c.ReadCloser.Close()
+	if err := c.WriteCloser.Close(); err != nil {
+		return err
+	}
+	return rerr
+}
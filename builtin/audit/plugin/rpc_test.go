@@ -0,0 +1,140 @@
+package plugin
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+type testBackend struct {
+	Auth    *logical.Auth
+	Request *logical.Request
+	Resp    *logical.Response
+	Err     error
+
+	// Block, if set, is received from before returning
+	Block chan struct{}
+}
+
+func (b *testBackend) LogRequest(ctx context.Context, a *logical.Auth, r *logical.Request) error {
+	if b.Block != nil {
+		<-b.Block
+	}
+	b.Auth = a
+	b.Request = r
+	return nil
+}
+
+func (b *testBackend) LogResponse(ctx context.Context, a *logical.Auth, r *logical.Request, re *logical.Response, err error) error {
+	b.Auth = a
+	b.Request = r
+	b.Resp = re
+	b.Err = err
+	return errors.New("failed")
+}
+
+func TestRPC(t *testing.T) {
+	backend := new(testBackend)
+	server := rpc.NewServer()
+	if err := server.RegisterName(rpcName, &RPCServer{Backend: backend}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	c1, c2 := net.Pipe()
+	go server.ServeCodec(jsonrpc.NewServerCodec(c1))
+	b := &Backend{client: jsonrpc.NewClient(c2)}
+	defer c2.Close()
+
+	auth := &logical.Auth{
+		DisplayName: "foo",
+		Policies:    []string{"dev"},
+	}
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/foo",
+		Data: map[string]interface{}{
+			"value": "bar",
+		},
+		Storage: new(logical.InmemStorage),
+		Connection: &logical.Connection{
+			RemoteAddr: "127.0.0.1",
+		},
+	}
+	if err := b.call(context.Background(), "LogRequest", &LogRequestArgs{
+		Auth:    auth,
+		Request: rpcRequest(req),
+	}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expected := rpcRequest(req)
+	if !reflect.DeepEqual(backend.Auth, auth) {
+		t.Fatalf("bad: %#v", backend.Auth)
+	}
+	if !reflect.DeepEqual(backend.Request, expected) {
+		t.Fatalf("bad: %#v", backend.Request)
+	}
+
+	err := b.call(context.Background(), "LogResponse", &LogResponseArgs{
+		Auth:    auth,
+		Request: rpcRequest(req),
+		Response: &logical.Response{
+			Redirect: "foo",
+		},
+		Error: "permission denied",
+	})
+	if err == nil || err.Error() != "failed" {
+		t.Fatalf("err: %v", err)
+	}
+	if backend.Resp.Redirect != "foo" {
+		t.Fatalf("bad: %#v", backend.Resp)
+	}
+	if backend.Err == nil || backend.Err.Error() != "permission denied" {
+		t.Fatalf("bad: %#v", backend.Err)
+	}
+}
+
+func TestRPC_canceled(t *testing.T) {
+	backend := &testBackend{Block: make(chan struct{})}
+	defer close(backend.Block)
+	server := rpc.NewServer()
+	if err := server.RegisterName(rpcName, &RPCServer{Backend: backend}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	c1, c2 := net.Pipe()
+	go server.ServeCodec(jsonrpc.NewServerCodec(c1))
+	b := &Backend{client: jsonrpc.NewClient(c2)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- b.call(ctx, "LogRequest", &LogRequestArgs{
+			Request: &logical.Request{Path: "secret/foo"},
+		})
+	}()
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if err != context.Canceled {
+			t.Fatalf("err: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("call was not aborted")
+	}
+
+	// The plugin is restarted on the next call
+	b.l.Lock()
+	defer b.l.Unlock()
+	if b.client != nil {
+		t.Fatalf("client was not stopped")
+	}
+}
@@ -0,0 +1,313 @@
+package redis
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/audit/sink"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	audit.Register("redis", Factory)
+	audit.RegisterValidator("redis", Validate)
+}
+
+const (
+	defaultStream  = "vault-audit"
+	defaultMaxLen  = 100000
+	defaultTimeout = 5 * time.Second
+)
+
+// Validate checks the options of the backend without connecting to
+// Redis.
+func Validate(conf map[string]string) (map[string]string, error) {
+	if _, err := parseConfig(conf); err != nil {
+		return nil, err
+	}
+
+	conf, err := audit.ResolveHashOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+	conf, err = audit.ResolveFormatOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+	conf, err = audit.ResolveTLSOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range map[string]string{
+		"stream":  defaultStream,
+		"max_len": strconv.Itoa(defaultMaxLen),
+		"db":      "0",
+		"tls":     "false",
+		"timeout": defaultTimeout.String(),
+	} {
+		if _, ok := conf[k]; !ok {
+			conf[k] = v
+		}
+	}
+	return conf, nil
+}
+
+func Factory(conf map[string]string) (audit.Backend, error) {
+	c, err := parseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := audit.ParseHashConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the format or default to JSON
+	formatter, err := audit.NewFormatter(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// The certificates are loaded once, rather than on every connection
+	if c.tls {
+		if c.tlsConfig, err = c.tlsOptions.Config(); err != nil {
+			return nil, err
+		}
+	}
+
+	b := &Backend{
+		conf:      c,
+		hash:      hash,
+		formatter: formatter,
+		conn: &sink.Conn{
+			Dial: func() (io.Closer, error) {
+				return dial(c)
+			},
+
+			// Error replies leave the connection usable
+			Broken: func(err error) bool {
+				_, ok := err.(redisError)
+				return !ok
+			},
+		},
+	}
+	return b, nil
+}
+
+// config is the configuration of the backend parsed from its options.
+type config struct {
+	address  string
+	password string
+	db       int
+
+	tls        bool
+	tlsOptions *audit.TLSOptions
+	tlsConfig  *tls.Config
+
+	stream  string
+	maxLen  int
+	timeout time.Duration
+}
+
+func parseConfig(conf map[string]string) (*config, error) {
+	address, ok := conf["address"]
+	if !ok {
+		return nil, fmt.Errorf("address is required")
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("invalid address: %s", address)
+	}
+
+	c := &config{
+		address:  address,
+		password: conf["password"],
+		stream:   defaultStream,
+		maxLen:   defaultMaxLen,
+		timeout:  defaultTimeout,
+	}
+
+	var err error
+	if v, ok := conf["stream"]; ok {
+		if v == "" {
+			return nil, fmt.Errorf("stream must not be empty")
+		}
+		c.stream = v
+	}
+	if v, ok := conf["max_len"]; ok {
+		if c.maxLen, err = strconv.Atoi(v); err != nil || c.maxLen < 0 {
+			return nil, fmt.Errorf("invalid max_len: %s", v)
+		}
+	}
+	if v, ok := conf["db"]; ok {
+		if c.db, err = strconv.Atoi(v); err != nil || c.db < 0 {
+			return nil, fmt.Errorf("invalid db: %s", v)
+		}
+	}
+	if v, ok := conf["tls"]; ok {
+		if c.tls, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid tls: %s", v)
+		}
+	}
+	if c.tlsOptions, err = audit.ParseTLSOptions(conf); err != nil {
+		return nil, err
+	}
+	if v, ok := conf["timeout"]; ok {
+		if c.timeout, err = time.ParseDuration(v); err != nil || c.timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout: %s", v)
+		}
+	}
+	return c, nil
+}
+
+// Backend is the audit backend that adds entries to a Redis stream with
+// XADD, trimming the stream to about max_len entries. Consumers can tail
+// the stream, or read it with consumer groups.
+//
+// Entries are added synchronously over a single connection, which is
+// opened on the first entry and reopened after a connection error. While
+// Redis is unreachable, it is redialed with a backoff, see sink.Conn.
+type Backend struct {
+	conf      *config
+	hash      *audit.HashConfig
+	formatter audit.Formatter
+	conn      *sink.Conn
+}
+
+// SetClock sets the clock the entries are timestamped with, and the end
+// of the rotation window of the salt is checked against, see
+// audit.ClockSetter.
+func (b *Backend) SetClock(clock func() time.Time) {
+	audit.SetClock(b.formatter, clock)
+	audit.SetClock(b.hash, clock)
+}
+
+// Close closes the connection to Redis, see audit.Closer.
+func (b *Backend) Close() error {
+	return b.conn.Close()
+}
+
+func (b *Backend) LogRequest(
+	ctx context.Context, auth *logical.Auth, req *logical.Request) error {
+	// Hash any sensitive information
+	auth, req, err := audit.HashRequest(b.hash, auth, req)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := b.formatter.FormatRequest(&buf, auth, req); err != nil {
+		return err
+	}
+	return b.add(ctx, "request", buf.Bytes())
+}
+
+func (b *Backend) LogResponse(
+	ctx context.Context,
+	auth *logical.Auth,
+	req *logical.Request,
+	resp *logical.Response,
+	respErr error) error {
+	// Hash any sensitive information
+	auth, req, resp, err := audit.HashResponse(b.hash, auth, req, resp)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := b.formatter.FormatResponse(&buf, auth, req, resp, respErr); err != nil {
+		return err
+	}
+	return b.add(ctx, "response", buf.Bytes())
+}
+
+func (b *Backend) LogStatus(ctx context.Context, status *audit.Status) error {
+	return b.logEvent(ctx, "status", status)
+}
+
+func (b *Backend) LogHeartbeat(ctx context.Context, hb *audit.Heartbeat) error {
+	return b.logEvent(ctx, "heartbeat", hb)
+}
+
+func (b *Backend) LogSummary(ctx context.Context, s *audit.Summary) error {
+	return b.logEvent(ctx, "rate_limit_summary", s)
+}
+
+func (b *Backend) LogInventory(ctx context.Context, inv *audit.Inventory) error {
+	return b.logEvent(ctx, "inventory", inv)
+}
+
+func (b *Backend) LogAuditChange(ctx context.Context, change *audit.AuditChange) error {
+	return b.logEvent(ctx, "audit_change", change)
+}
+
+func (b *Backend) Test(ctx context.Context, st *audit.SelfTest) error {
+	return b.logEvent(ctx, "self_test", st)
+}
+
+// logEvent formats an event other than a request or a response, see
+// audit.FormatEvent, and adds it to the stream with the given type.
+func (b *Backend) logEvent(ctx context.Context, kind string, ev interface{}) error {
+	entry, err := audit.FormatEvent(b.formatter, ev)
+	if err != nil {
+		return err
+	}
+	return b.add(ctx, kind, entry)
+}
+
+// add adds a formatted entry to the stream, with the type of the entry
+// as a field so that consumers can filter without parsing it.
+func (b *Backend) add(ctx context.Context, kind string, entry []byte) error {
+	entry = bytes.TrimRight(entry, "\n")
+	if len(entry) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	args := []string{"XADD", b.conf.stream}
+	if b.conf.maxLen > 0 {
+		args = append(args, "MAXLEN", "~", strconv.Itoa(b.conf.maxLen))
+	}
+	args = append(args, "*", "type", kind, "entry", string(entry))
+
+	err := b.conn.Do(func(c io.Closer) error {
+		cn := c.(*conn)
+
+		// The deadline is that of the context if it is sooner
+		deadline := time.Now().Add(b.conf.timeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		cn.SetDeadline(deadline)
+
+		// Abort the command if the context is done first
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				cn.SetDeadline(time.Now())
+			case <-done:
+			}
+		}()
+
+		_, err := cn.do(args...)
+		return err
+	})
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+	}
+	return err
+}
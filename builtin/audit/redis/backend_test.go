@@ -0,0 +1,274 @@
+package redis
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/audit/audittest"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+// testServer is a fake Redis server that records the commands it
+// receives. The command named by block never gets a reply.
+type testServer struct {
+	ln net.Listener
+
+	l        sync.Mutex
+	commands [][]string
+	block    string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return serveTestServer(ln)
+}
+
+// newTLSTestServer is a testServer requiring client certificates signed
+// by the certificate it serves with.
+func newTLSTestServer(t *testing.T, certFile, keyFile string) *testServer {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(mustParseCert(t, cert.Certificate[0]))
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return serveTestServer(ln)
+}
+
+func mustParseCert(t *testing.T, der []byte) *x509.Certificate {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return cert
+}
+
+func serveTestServer(ln net.Listener) *testServer {
+	s := &testServer{ln: ln}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		v, err := readReply(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range v.([]interface{}) {
+			args = append(args, arg.(string))
+		}
+
+		s.l.Lock()
+		s.commands = append(s.commands, args)
+		block := s.block == args[0]
+		s.l.Unlock()
+
+		switch {
+		case block:
+		case args[0] == "AUTH" && args[1] != "secret":
+			fmt.Fprintf(c, "-WRONGPASS invalid password\r\n")
+		case args[0] == "XADD":
+			fmt.Fprintf(c, "$15\r\n1526919030474-0\r\n")
+		default:
+			fmt.Fprintf(c, "+OK\r\n")
+		}
+	}
+}
+
+func (s *testServer) Commands() [][]string {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.commands
+}
+
+func testBackend(t *testing.T, s *testServer, conf map[string]string) audit.Backend {
+	opts := map[string]string{
+		"address": s.ln.Addr().String(),
+	}
+	for k, v := range conf {
+		opts[k] = v
+	}
+	b, err := Factory(opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return b
+}
+
+func TestBackend_xadd(t *testing.T) {
+	s := newTestServer(t)
+	defer s.ln.Close()
+
+	b := testBackend(t, s, map[string]string{
+		"password": "secret",
+		"db":       "2",
+		"stream":   "audit",
+		"max_len":  "1000",
+	})
+
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "secret/foo",
+		Data:      map[string]interface{}{"foo": "bar"},
+	}
+	for i := 0; i < 2; i++ {
+		if err := b.LogRequest(context.Background(), nil, req); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// The connection is authenticated and selects the database once
+	commands := s.Commands()
+	if len(commands) != 4 {
+		t.Fatalf("bad: %#v", commands)
+	}
+	if strings.Join(commands[0], " ") != "AUTH secret" ||
+		strings.Join(commands[1], " ") != "SELECT 2" {
+		t.Fatalf("bad: %#v", commands)
+	}
+
+	args := commands[2]
+	if strings.Join(args[:8], " ") != "XADD audit MAXLEN ~ 1000 * type request" {
+		t.Fatalf("bad: %#v", args)
+	}
+	if args[8] != "entry" || !strings.Contains(args[9], `"path":"secret/foo"`) ||
+		strings.Contains(args[9], `"bar"`) || strings.HasSuffix(args[9], "\n") {
+		t.Fatalf("bad: %#v", args)
+	}
+}
+
+func TestBackend_tls(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := audittest.TLSFiles(t, dir)
+
+	s := newTLSTestServer(t, certFile, keyFile)
+	defer s.ln.Close()
+
+	// Without a client certificate, the handshake fails
+	req := &logical.Request{Path: "secret/foo"}
+	b := testBackend(t, s, map[string]string{
+		"tls":         "true",
+		"tls_ca_file": certFile,
+	})
+	if err := b.LogRequest(context.Background(), nil, req); err == nil {
+		t.Fatalf("should fail")
+	}
+
+	b = testBackend(t, s, map[string]string{
+		"tls":           "true",
+		"tls_ca_file":   certFile,
+		"tls_cert_file": certFile,
+		"tls_key_file":  keyFile,
+	})
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if commands := s.Commands(); len(commands) != 1 || commands[0][0] != "XADD" {
+		t.Fatalf("bad: %#v", commands)
+	}
+}
+
+func TestBackend_errorReply(t *testing.T) {
+	s := newTestServer(t)
+	defer s.ln.Close()
+
+	b := testBackend(t, s, map[string]string{"password": "wrong"})
+	err := b.LogRequest(context.Background(), nil, &logical.Request{Path: "secret/foo"})
+	if _, ok := err.(redisError); !ok || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestBackend_reconnect(t *testing.T) {
+	s := newTestServer(t)
+	defer s.ln.Close()
+
+	b := testBackend(t, s, map[string]string{"max_len": "0"})
+	s.l.Lock()
+	s.block = "XADD"
+	s.l.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	err := b.LogRequest(ctx, nil, &logical.Request{Path: "secret/foo"})
+	if err != context.Canceled {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The aborted connection is replaced by a new one
+	s.l.Lock()
+	s.block = ""
+	s.l.Unlock()
+	if err := b.LogRequest(context.Background(), nil, &logical.Request{Path: "secret/foo"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	commands := s.Commands()
+	if len(commands) != 2 || strings.Join(commands[1][:3], " ") != "XADD vault-audit *" {
+		t.Fatalf("bad: %#v", commands)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		Conf map[string]string
+		Err  bool
+	}{
+		{map[string]string{"address": "127.0.0.1:6379"}, false},
+		{map[string]string{}, true},
+		{map[string]string{"address": "127.0.0.1"}, true},
+		{map[string]string{"address": "127.0.0.1:6379", "max_len": "-1"}, true},
+		{map[string]string{"address": "127.0.0.1:6379", "db": "a"}, true},
+		{map[string]string{"address": "127.0.0.1:6379", "stream": ""}, true},
+		{map[string]string{"address": "127.0.0.1:6379", "tls": "yes please"}, true},
+		{map[string]string{"address": "127.0.0.1:6379", "tls_key_file": "key.pem"}, true},
+	}
+
+	for _, tc := range cases {
+		resolved, err := Validate(tc.Conf)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%#v: err: %v", tc.Conf, err)
+		}
+		if err == nil && resolved["stream"] != "vault-audit" {
+			t.Fatalf("bad: %#v", resolved)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package router
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	audit.Register("router", Factory)
+	audit.RegisterValidator("router", Validate)
+}
+
+// The options of a sink are prefixed with its name and a dot. These are
+// the options of the router itself, the others are passed to the sink.
+// The rules are prefixed so that they don't shadow the options of the
+// backends, such as the path of the file backend.
+const (
+	sinkTypeOption      = "type"
+	sinkPathOption      = "route_path"
+	sinkOperationOption = "route_operation"
+	sinkStatusOption    = "route_status"
+)
+
+// sharedOptions are the options of the router that are passed to every
+// sink, so that rotating the salt of the router rotates that of its sinks.
+// The heartbeat_interval option is only used by the core.
+var sharedOptions = []string{"salt", "salt_version"}
+
+// The statuses of responses that a sink can be limited to.
+const (
+	statusSuccess = "success"
+	statusError   = "error"
+	statusDenied  = "denied"
+)
+
+// Validate checks the options of the router and of every sink, using the
+// validator of the type of the sink, without creating the sinks.
+func Validate(conf map[string]string) (map[string]string, error) {
+	sinks, err := parseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	validators := audit.Validators()
+	result := map[string]string{"sinks": conf["sinks"]}
+	for _, k := range append([]string{"heartbeat_interval"}, sharedOptions...) {
+		if v, ok := conf[k]; ok {
+			result[k] = v
+		}
+	}
+	for _, s := range sinks {
+		v, ok := validators[s.typ]
+		if !ok {
+			return nil, fmt.Errorf("sink %s: unknown backend type: %s", s.name, s.typ)
+		}
+		resolved, err := v(s.options)
+		if err != nil {
+			return nil, fmt.Errorf("sink %s: %s", s.name, err)
+		}
+
+		prefix := s.name + "."
+		for k, v := range resolved {
+			result[prefix+k] = v
+		}
+		for _, k := range []string{
+			sinkTypeOption, sinkPathOption, sinkOperationOption, sinkStatusOption,
+		} {
+			if v, ok := conf[prefix+k]; ok {
+				result[prefix+k] = v
+			}
+		}
+	}
+	return result, nil
+}
+
+func Factory(conf map[string]string) (audit.Backend, error) {
+	sinks, err := parseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	factories := audit.Factories()
+	for _, s := range sinks {
+		f, ok := factories[s.typ]
+		if !ok {
+			return nil, fmt.Errorf("sink %s: unknown backend type: %s", s.name, s.typ)
+		}
+		if s.backend, err = f(s.options); err != nil {
+			return nil, fmt.Errorf("sink %s: %s", s.name, err)
+		}
+	}
+	return &Backend{sinks: sinks}, nil
+}
+
+// sink is a child backend of the router with the rules of the entries
+// routed to it. An empty rule matches every entry.
+type sink struct {
+	name    string
+	typ     string
+	options map[string]string
+	backend audit.Backend
+
+	// paths are glob patterns matched against the request path, see
+	// path.Match, so a "*" does not match a "/"
+	paths      []string
+	operations []string
+
+	// statuses limits the sink to the responses with one of the
+	// statuses, so that it gets no requests
+	statuses []string
+}
+
+func parseConfig(conf map[string]string) ([]*sink, error) {
+	names := splitList(conf["sinks"])
+	if len(names) == 0 {
+		return nil, fmt.Errorf("sinks is required")
+	}
+
+	sinks := make([]*sink, 0, len(names))
+	byName := make(map[string]*sink, len(names))
+	for _, name := range names {
+		if strings.Contains(name, ".") {
+			return nil, fmt.Errorf("invalid sink name: %s", name)
+		}
+		if _, ok := byName[name]; ok {
+			return nil, fmt.Errorf("duplicate sink: %s", name)
+		}
+		s := &sink{name: name, options: make(map[string]string)}
+		sinks = append(sinks, s)
+		byName[name] = s
+	}
+
+	for k, v := range conf {
+		if k == "sinks" || k == "heartbeat_interval" || contains(sharedOptions, k) {
+			continue
+		}
+		idx := strings.Index(k, ".")
+		if idx == -1 {
+			return nil, fmt.Errorf("unknown option: %s", k)
+		}
+		s, ok := byName[k[:idx]]
+		if !ok {
+			return nil, fmt.Errorf("option %s of unknown sink: %s", k, k[:idx])
+		}
+
+		switch opt := k[idx+1:]; opt {
+		case sinkTypeOption:
+			s.typ = v
+		case sinkPathOption:
+			s.paths = splitList(v)
+			for _, p := range s.paths {
+				if _, err := path.Match(p, ""); err != nil {
+					return nil, fmt.Errorf("sink %s: invalid route_path: %s", s.name, p)
+				}
+			}
+		case sinkOperationOption:
+			s.operations = splitList(v)
+		case sinkStatusOption:
+			s.statuses = splitList(v)
+			for _, status := range s.statuses {
+				switch status {
+				case statusSuccess, statusError, statusDenied:
+				default:
+					return nil, fmt.Errorf("sink %s: invalid route_status: %s", s.name, status)
+				}
+			}
+		default:
+			s.options[opt] = v
+		}
+	}
+
+	for _, s := range sinks {
+		for _, k := range sharedOptions {
+			if v, ok := conf[k]; ok {
+				s.options[k] = v
+			}
+		}
+		if s.typ == "" {
+			return nil, fmt.Errorf("sink %s: type is required", s.name)
+		}
+		if s.typ == "router" {
+			return nil, fmt.Errorf("sink %s: routers can't be nested", s.name)
+		}
+	}
+	return sinks, nil
+}
+
+// splitList splits a comma separated list, ignoring empty elements.
+func splitList(v string) []string {
+	var result []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// matchRequest returns whether a request, or its response if status is
+// not empty, is routed to the sink.
+func (s *sink) matchRequest(req *logical.Request, status string) bool {
+	if len(s.statuses) > 0 && !contains(s.statuses, status) {
+		return false
+	}
+	if len(s.operations) > 0 && !contains(s.operations, string(req.Operation)) {
+		return false
+	}
+	if len(s.paths) == 0 {
+		return true
+	}
+	for _, p := range s.paths {
+		if ok, _ := path.Match(p, req.Path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, v string) bool {
+	for _, e := range list {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// responseStatus returns the status of a response by its error.
+func responseStatus(err error) string {
+	switch err {
+	case nil:
+		return statusSuccess
+	case logical.ErrPermissionDenied:
+		return statusDenied
+	default:
+		return statusError
+	}
+}
+
+// Backend is the audit backend that routes each entry to the child
+// backends, or sinks, whose rules match it. An entry is logged once every
+// sink it is routed to has logged it. Status and heartbeat entries are
+// passed to every sink, regardless of the rules.
+type Backend struct {
+	sinks []*sink
+}
+
+// SetClock sets the clock of the sinks, see audit.ClockSetter.
+func (b *Backend) SetClock(clock func() time.Time) {
+	for _, s := range b.sinks {
+		audit.SetClock(s.backend, clock)
+	}
+}
+
+// Close closes the sinks, see audit.Closer.
+func (b *Backend) Close() error {
+	var errs error
+	for _, s := range b.sinks {
+		if err := audit.Close(s.backend); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("sink %s: %s", s.name, err))
+		}
+	}
+	return errs
+}
+
+func (b *Backend) LogRequest(
+	ctx context.Context, auth *logical.Auth, req *logical.Request) error {
+	var errs error
+	for _, s := range b.sinks {
+		if !s.matchRequest(req, "") {
+			continue
+		}
+		if err := s.backend.LogRequest(ctx, auth, req); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("sink %s: %s", s.name, err))
+		}
+	}
+	return errs
+}
+
+func (b *Backend) LogResponse(
+	ctx context.Context,
+	auth *logical.Auth,
+	req *logical.Request,
+	resp *logical.Response,
+	respErr error) error {
+	status := responseStatus(respErr)
+
+	var errs error
+	for _, s := range b.sinks {
+		if !s.matchRequest(req, status) {
+			continue
+		}
+		if err := s.backend.LogResponse(ctx, auth, req, resp, respErr); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("sink %s: %s", s.name, err))
+		}
+	}
+	return errs
+}
+
+func (b *Backend) LogStatus(ctx context.Context, status *audit.Status) error {
+	return b.forward(ctx, status)
+}
+
+func (b *Backend) LogHeartbeat(ctx context.Context, hb *audit.Heartbeat) error {
+	return b.forward(ctx, hb)
+}
+
+func (b *Backend) LogSummary(ctx context.Context, summary *audit.Summary) error {
+	return b.forward(ctx, summary)
+}
+
+func (b *Backend) LogInventory(ctx context.Context, inv *audit.Inventory) error {
+	return b.forward(ctx, inv)
+}
+
+func (b *Backend) LogAuditChange(ctx context.Context, change *audit.AuditChange) error {
+	return b.forward(ctx, change)
+}
+
+// Pending returns the number of entries the sinks have to replay.
+func (b *Backend) Pending() (int, error) {
+	pending := 0
+	for _, s := range b.sinks {
+		r, ok := s.backend.(audit.Replayer)
+		if !ok {
+			continue
+		}
+		n, err := r.Pending()
+		if err != nil {
+			return 0, fmt.Errorf("sink %s: %s", s.name, err)
+		}
+		pending += n
+	}
+	return pending, nil
+}
+
+// Replay replays the pending entries of the sinks, one sink after the
+// other.
+func (b *Backend) Replay(progress func()) error {
+	for _, s := range b.sinks {
+		r, ok := s.backend.(audit.Replayer)
+		if !ok {
+			continue
+		}
+		if err := r.Replay(progress); err != nil {
+			return fmt.Errorf("sink %s: %s", s.name, err)
+		}
+	}
+	return nil
+}
+
+func (b *Backend) Test(ctx context.Context, st *audit.SelfTest) error {
+	return b.forward(ctx, st)
+}
+
+// forward logs an event other than a request or a response through every
+// sink whose backend supports it, collecting the errors.
+func (b *Backend) forward(ctx context.Context, ev interface{}) error {
+	var errs error
+	for _, s := range b.sinks {
+		var err error
+		switch ev := ev.(type) {
+		case *audit.Status:
+			if l, ok := s.backend.(audit.StatusLogger); ok {
+				err = l.LogStatus(ctx, ev)
+			}
+		case *audit.Heartbeat:
+			if l, ok := s.backend.(audit.HeartbeatLogger); ok {
+				err = l.LogHeartbeat(ctx, ev)
+			}
+		case *audit.Summary:
+			if l, ok := s.backend.(audit.SummaryLogger); ok {
+				err = l.LogSummary(ctx, ev)
+			}
+		case *audit.Inventory:
+			if l, ok := s.backend.(audit.InventoryLogger); ok {
+				err = l.LogInventory(ctx, ev)
+			}
+		case *audit.AuditChange:
+			if l, ok := s.backend.(audit.AuditChangeLogger); ok {
+				err = l.LogAuditChange(ctx, ev)
+			}
+		case *audit.SelfTest:
+			if t, ok := s.backend.(audit.SelfTester); ok {
+				err = t.Test(ctx, ev)
+			}
+		default:
+			return fmt.Errorf("unknown event: %T", ev)
+		}
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("sink %s: %s", s.name, err))
+		}
+	}
+	return errs
+}
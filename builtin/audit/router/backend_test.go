@@ -0,0 +1,266 @@
+package router
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+// testSinks are the sinks created by the test factory, by their "id"
+// option.
+var (
+	testSinksLock sync.Mutex
+	testSinks     = make(map[string]*testSink)
+)
+
+func init() {
+	audit.Register("router-test", func(conf map[string]string) (audit.Backend, error) {
+		s := &testSink{fail: conf["fail"] == "true", salt: conf["salt"]}
+		s.pending, _ = strconv.Atoi(conf["pending"])
+		testSinksLock.Lock()
+		testSinks[conf["id"]] = s
+		testSinksLock.Unlock()
+		return s, nil
+	})
+	audit.RegisterValidator("router-test", func(conf map[string]string) (map[string]string, error) {
+		if _, ok := conf["id"]; !ok {
+			return nil, fmt.Errorf("id is required")
+		}
+		result := map[string]string{"fail": "false"}
+		for k, v := range conf {
+			result[k] = v
+		}
+		return result, nil
+	})
+}
+
+// testSink records the paths of the entries it logs, prefixed with their
+// type.
+type testSink struct {
+	l       sync.Mutex
+	entries []string
+	fail    bool
+	salt    string
+	pending int
+}
+
+func (s *testSink) log(entry string) error {
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.fail {
+		return fmt.Errorf("failed")
+	}
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *testSink) LogRequest(
+	ctx context.Context, auth *logical.Auth, req *logical.Request) error {
+	return s.log("request " + req.Path)
+}
+
+func (s *testSink) LogResponse(ctx context.Context, auth *logical.Auth,
+	req *logical.Request, resp *logical.Response, err error) error {
+	return s.log("response " + req.Path)
+}
+
+func (s *testSink) LogHeartbeat(ctx context.Context, hb *audit.Heartbeat) error {
+	return s.log("heartbeat " + hb.Backend)
+}
+
+func (s *testSink) Pending() (int, error) {
+	return s.pending, nil
+}
+
+func (s *testSink) Replay(progress func()) error {
+	for ; s.pending > 0; s.pending-- {
+		if err := s.log("replay"); err != nil {
+			return err
+		}
+		progress()
+	}
+	return nil
+}
+
+func (s *testSink) Entries() string {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return strings.Join(s.entries, ", ")
+}
+
+func testSinkByID(t *testing.T, id string) *testSink {
+	testSinksLock.Lock()
+	defer testSinksLock.Unlock()
+	s, ok := testSinks[id]
+	if !ok {
+		t.Fatalf("no sink %s", id)
+	}
+	return s
+}
+
+func TestBackend_route(t *testing.T) {
+	b, err := Factory(map[string]string{
+		"sinks":                  "denials, writes, all",
+		"denials.type":           "router-test",
+		"denials.id":             "route-denials",
+		"denials.route_status":   "denied",
+		"writes.type":            "router-test",
+		"writes.id":              "route-writes",
+		"writes.route_operation": "write,delete",
+		"writes.route_path":      "secret/*,sys/*",
+		"all.type":               "router-test",
+		"all.id":                 "route-all",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ctx := context.Background()
+	read := &logical.Request{Operation: logical.ReadOperation, Path: "secret/foo"}
+	write := &logical.Request{Operation: logical.WriteOperation, Path: "secret/foo"}
+	nested := &logical.Request{Operation: logical.WriteOperation, Path: "secret/foo/bar"}
+	for _, req := range []*logical.Request{read, write, nested} {
+		if err := b.LogRequest(ctx, nil, req); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := b.LogResponse(ctx, nil, read, nil, logical.ErrPermissionDenied); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := b.LogResponse(ctx, nil, write, nil, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	hl := b.(audit.HeartbeatLogger)
+	if err := hl.LogHeartbeat(ctx, &audit.Heartbeat{Backend: "router/"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]string{
+		"route-denials": "response secret/foo, heartbeat router/",
+		"route-writes":  "request secret/foo, response secret/foo, heartbeat router/",
+		"route-all": "request secret/foo, request secret/foo, request secret/foo/bar, " +
+			"response secret/foo, response secret/foo, heartbeat router/",
+	}
+	for id, entries := range expected {
+		if actual := testSinkByID(t, id).Entries(); actual != entries {
+			t.Fatalf("%s: bad: %s", id, actual)
+		}
+	}
+}
+
+func TestBackend_sinkFailure(t *testing.T) {
+	b, err := Factory(map[string]string{
+		"sinks":             "ok,broken",
+		"ok.type":           "router-test",
+		"ok.id":             "failure-ok",
+		"broken.type":       "router-test",
+		"broken.id":         "failure-broken",
+		"broken.fail":       "true",
+		"broken.route_path": "secret/*",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The entry is still logged by the other sinks
+	req := &logical.Request{Operation: logical.ReadOperation, Path: "secret/foo"}
+	err = b.LogRequest(context.Background(), nil, req)
+	if err == nil || !strings.Contains(err.Error(), "sink broken: failed") {
+		t.Fatalf("err: %v", err)
+	}
+	if actual := testSinkByID(t, "failure-ok").Entries(); actual != "request secret/foo" {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	// Entries that aren't routed to the failing sink are logged
+	req = &logical.Request{Operation: logical.ReadOperation, Path: "sys/mounts"}
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestBackend_replay(t *testing.T) {
+	b, err := Factory(map[string]string{
+		"sinks":     "a,b",
+		"a.type":    "router-test",
+		"a.id":      "replay-a",
+		"a.pending": "2",
+		"b.type":    "router-test",
+		"b.id":      "replay-b",
+		"b.pending": "1",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	r := b.(audit.Replayer)
+	if n, err := r.Pending(); err != nil || n != 3 {
+		t.Fatalf("bad: %d %v", n, err)
+	}
+	replayed := 0
+	if err := r.Replay(func() { replayed++ }); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if replayed != 3 || testSinkByID(t, "replay-a").Entries() != "replay, replay" {
+		t.Fatalf("bad: %d", replayed)
+	}
+}
+
+func TestBackend_sharedOptions(t *testing.T) {
+	_, err := Factory(map[string]string{
+		"sinks":              "a,b",
+		"a.type":             "router-test",
+		"a.id":               "shared-a",
+		"a.salt":             "foo",
+		"b.type":             "router-test",
+		"b.id":               "shared-b",
+		"salt":               "bar",
+		"salt_version":       "1",
+		"heartbeat_interval": "1m",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The salt of the router, as set by a rotation, applies to every sink
+	for _, id := range []string{"shared-a", "shared-b"} {
+		if s := testSinkByID(t, id); s.salt != "bar" {
+			t.Fatalf("%s: bad: %s", id, s.salt)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		Conf map[string]string
+		Err  bool
+	}{
+		{map[string]string{"sinks": "a", "a.type": "router-test", "a.id": "a"}, false},
+		{map[string]string{}, true},
+		{map[string]string{"sinks": "a"}, true},
+		{map[string]string{"sinks": "a", "a.type": "router-test"}, true},
+		{map[string]string{"sinks": "a", "a.type": "bogus", "a.id": "a"}, true},
+		{map[string]string{"sinks": "a", "a.type": "router", "a.id": "a"}, true},
+		{map[string]string{"sinks": "a,a", "a.type": "router-test", "a.id": "a"}, true},
+		{map[string]string{"sinks": "a", "a.type": "router-test", "a.id": "a", "b.id": "b"}, true},
+		{map[string]string{"sinks": "a", "a.type": "router-test", "a.id": "a", "id": "b"}, true},
+		{map[string]string{"sinks": "a", "a.type": "router-test", "a.id": "a", "a.route_status": "ok"}, true},
+		{map[string]string{"sinks": "a", "a.type": "router-test", "a.id": "a", "a.route_path": "["}, true},
+	}
+
+	for _, tc := range cases {
+		resolved, err := Validate(tc.Conf)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%#v: err: %v", tc.Conf, err)
+		}
+		if err == nil && (resolved["a.fail"] != "false" || resolved["a.type"] != "router-test") {
+			t.Fatalf("bad: %#v", resolved)
+		}
+	}
+}
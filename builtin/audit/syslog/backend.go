@@ -2,14 +2,49 @@ package file
 
 import (
 	"bytes"
+	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/hashicorp/go-syslog"
 	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/logical"
-	"github.com/mitchellh/copystructure"
+	"golang.org/x/net/context"
 )
 
+func init() {
+	audit.Register("syslog", Factory)
+	audit.RegisterValidator("syslog", Validate)
+}
+
+// Validate checks the options of the backend without connecting to
+// syslog.
+func Validate(conf map[string]string) (map[string]string, error) {
+	if _, err := parseSplitSize(conf); err != nil {
+		return nil, err
+	}
+
+	conf, err := audit.ResolveHashOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+	conf, err = audit.ResolveFormatOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range map[string]string{
+		"facility":   "AUTH",
+		"tag":        "vault",
+		"split_size": "0",
+	} {
+		if _, ok := conf[k]; !ok {
+			conf[k] = v
+		}
+	}
+	return conf, nil
+}
+
 func Factory(conf map[string]string) (audit.Backend, error) {
 	// Get facility or default to AUTH
 	facility, ok := conf["facility"]
@@ -24,13 +59,21 @@ func Factory(conf map[string]string) (audit.Backend, error) {
 	}
 
 	// Check if raw logging is enabled
-	logRaw := false
-	if raw, ok := conf["log_raw"]; ok {
-		b, err := strconv.ParseBool(raw)
-		if err != nil {
-			return nil, err
-		}
-		logRaw = b
+	hash, err := audit.ParseHashConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the format or default to JSON
+	formatter, err := audit.NewFormatter(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if oversized entries are split into several messages
+	splitSize, err := parseSplitSize(conf)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get the logger
@@ -40,96 +83,147 @@ func Factory(conf map[string]string) (audit.Backend, error) {
 	}
 
 	b := &Backend{
-		logger: logger,
-		logRaw: logRaw,
+		logger:    logger,
+		hash:      hash,
+		formatter: formatter,
+		splitSize: splitSize,
 	}
 	return b, nil
 }
 
+// parseSplitSize returns the split_size option, zero if not given.
+func parseSplitSize(conf map[string]string) (int, error) {
+	v, ok := conf["split_size"]
+	if !ok {
+		return 0, nil
+	}
+
+	splitSize, err := strconv.Atoi(v)
+	if err != nil || splitSize < minSplitSize {
+		return 0, fmt.Errorf(
+			"split_size must be a number of at least %d", minSplitSize)
+	}
+	return splitSize, nil
+}
+
 // Backend is the audit backend for the syslog-based audit store.
 type Backend struct {
-	logger gsyslog.Syslogger
-	logRaw bool
+	logger    gsyslog.Syslogger
+	hash      *audit.HashConfig
+	formatter audit.Formatter
+	splitSize int
 }
 
-func (b *Backend) LogRequest(auth *logical.Auth, req *logical.Request) error {
-	if !b.logRaw {
-		// Copy the structures
-		cp, err := copystructure.Copy(auth)
-		if err != nil {
-			return err
-		}
-		auth = cp.(*logical.Auth)
+// SetClock sets the clock the entries are timestamped with, and the end
+// of the rotation window of the salt is checked against, see
+// audit.ClockSetter.
+func (b *Backend) SetClock(clock func() time.Time) {
+	audit.SetClock(b.formatter, clock)
+	audit.SetClock(b.hash, clock)
+}
 
-		cp, err = copystructure.Copy(req)
-		if err != nil {
-			return err
-		}
-		req = cp.(*logical.Request)
+func (b *Backend) LogRequest(
+	ctx context.Context, auth *logical.Auth, req *logical.Request) error {
+	// Hash any sensitive information
+	auth, req, err := audit.HashRequest(b.hash, auth, req)
+	if err != nil {
+		return err
+	}
 
-		// Hash any sensitive information
-		if err := audit.Hash(auth); err != nil {
-			return err
-		}
-		if err := audit.Hash(req); err != nil {
-			return err
-		}
+	// Encode the entry as JSON
+	var buf bytes.Buffer
+	if err := b.formatter.FormatRequest(&buf, auth, req); err != nil {
+		return err
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	// Write out to syslog
+	return b.write(ctx, buf.Bytes())
+}
+
+func (b *Backend) LogResponse(ctx context.Context, auth *logical.Auth,
+	req *logical.Request, resp *logical.Response, respErr error) error {
+	// Hash any sensitive information
+	auth, req, resp, err := audit.HashResponse(b.hash, auth, req, resp)
+	if err != nil {
+		return err
 	}
 
 	// Encode the entry as JSON
 	var buf bytes.Buffer
-	var format audit.FormatJSON
-	if err := format.FormatRequest(&buf, auth, req); err != nil {
+	if err := b.formatter.FormatResponse(&buf, auth, req, resp, respErr); err != nil {
 		return err
 	}
+	if buf.Len() == 0 {
+		return nil
+	}
 
 	// Write out to syslog
-	_, err := b.logger.Write(buf.Bytes())
-	return err
+	return b.write(ctx, buf.Bytes())
 }
 
-func (b *Backend) LogResponse(auth *logical.Auth, req *logical.Request,
-	resp *logical.Response, err error) error {
-	if !b.logRaw {
-		// Copy the structure
-		cp, err := copystructure.Copy(auth)
-		if err != nil {
-			return err
-		}
-		auth = cp.(*logical.Auth)
+func (b *Backend) LogStatus(ctx context.Context, status *audit.Status) error {
+	return b.logEvent(ctx, status)
+}
 
-		cp, err = copystructure.Copy(req)
-		if err != nil {
-			return err
-		}
-		req = cp.(*logical.Request)
+func (b *Backend) LogHeartbeat(ctx context.Context, hb *audit.Heartbeat) error {
+	return b.logEvent(ctx, hb)
+}
 
-		cp, err = copystructure.Copy(resp)
-		if err != nil {
-			return err
-		}
-		resp = cp.(*logical.Response)
+func (b *Backend) LogSummary(ctx context.Context, s *audit.Summary) error {
+	return b.logEvent(ctx, s)
+}
 
-		// Hash any sensitive information
-		if err := audit.Hash(auth); err != nil {
-			return err
-		}
-		if err := audit.Hash(req); err != nil {
-			return err
-		}
-		if err := audit.Hash(resp); err != nil {
-			return err
-		}
+func (b *Backend) LogInventory(ctx context.Context, inv *audit.Inventory) error {
+	return b.logEvent(ctx, inv)
+}
+
+func (b *Backend) LogAuditChange(ctx context.Context, change *audit.AuditChange) error {
+	return b.logEvent(ctx, change)
+}
+
+func (b *Backend) Test(ctx context.Context, st *audit.SelfTest) error {
+	return b.logEvent(ctx, st)
+}
+
+// logEvent formats an event other than a request or a response, see
+// audit.FormatEvent, and writes it.
+func (b *Backend) logEvent(ctx context.Context, ev interface{}) error {
+	entry, err := audit.FormatEvent(b.formatter, ev)
+	if err != nil {
+		return err
 	}
+	if len(entry) == 0 {
+		return nil
+	}
+	return b.write(ctx, entry)
+}
 
-	// Encode the entry as JSON
-	var buf bytes.Buffer
-	var format audit.FormatJSON
-	if err := format.FormatResponse(&buf, auth, req, resp, err); err != nil {
+// write writes the entry to syslog, split into several messages if it is
+// larger than the split size. The remaining messages are not written once
+// the context is done.
+func (b *Backend) write(ctx context.Context, entry []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if b.splitSize == 0 || len(entry) <= b.splitSize {
+		_, err := b.logger.Write(entry)
 		return err
 	}
 
-	// Write otu to syslog
-	_, err = b.logger.Write(buf.Bytes())
-	return err
+	id, err := logical.UUID()
+	if err != nil {
+		return err
+	}
+	for _, msg := range splitEntry(entry, b.splitSize, id) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := b.logger.Write(msg); err != nil {
+			return err
+		}
+	}
+	return nil
 }
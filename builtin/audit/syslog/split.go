@@ -0,0 +1,119 @@
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SegmentMarker starts the messages that hold a segment of an entry that
+// was split because it was larger than the split_size option. It is
+// followed by the entry ID, the sequence number and total number of
+// segments as "seq/total", and the segment itself, separated by spaces.
+const SegmentMarker = "vault-audit-segment"
+
+// minSplitSize is the smallest split_size accepted, which leaves room for
+// the segment header.
+const minSplitSize = 128
+
+// splitEntry splits the entry into messages of at most size bytes, each
+// with a segment header. Segments never split a UTF-8 sequence.
+func splitEntry(entry []byte, size int, id string) [][]byte {
+	entry = bytes.TrimRight(entry, "\n")
+
+	// The number of segments is at most the length of the entry, so size
+	// the header for that many digits
+	digits := len(strconv.Itoa(len(entry)))
+	chunk := size - len(SegmentMarker) - len(id) - 2*digits - 4
+
+	var chunks [][]byte
+	for len(entry) > 0 {
+		n := chunk
+		if n >= len(entry) {
+			n = len(entry)
+		} else {
+			for n > 0 && !utf8.RuneStart(entry[n]) {
+				n--
+			}
+		}
+		chunks = append(chunks, entry[:n])
+		entry = entry[n:]
+	}
+
+	messages := make([][]byte, len(chunks))
+	for i, c := range chunks {
+		messages[i] = []byte(fmt.Sprintf("%s %s %d/%d %s",
+			SegmentMarker, id, i+1, len(chunks), c))
+	}
+	return messages
+}
+
+// Reassembler reassembles the entries that the syslog backend split into
+// segments. Messages are given to it one at a time, in any order.
+type Reassembler struct {
+	partial map[string][]string
+}
+
+// Add takes a single message. If the message completes an entry, the entry
+// is returned with true. Messages that aren't segments are returned as is.
+// A segment may be preceded by anything, such as a syslog header.
+func (r *Reassembler) Add(message string) (string, bool, error) {
+	idx := strings.Index(message, SegmentMarker+" ")
+	if idx == -1 {
+		return message, true, nil
+	}
+
+	parts := strings.SplitN(message[idx+len(SegmentMarker)+1:], " ", 3)
+	if len(parts) != 3 {
+		return "", false, fmt.Errorf("malformed segment: %s", message)
+	}
+	id := parts[0]
+	seqParts := strings.SplitN(parts[1], "/", 2)
+	if len(seqParts) != 2 {
+		return "", false, fmt.Errorf("malformed segment: %s", message)
+	}
+	seq, err := strconv.Atoi(seqParts[0])
+	if err != nil {
+		return "", false, fmt.Errorf("malformed segment: %s", message)
+	}
+	total, err := strconv.Atoi(seqParts[1])
+	if err != nil || seq < 1 || seq > total {
+		return "", false, fmt.Errorf("malformed segment: %s", message)
+	}
+
+	if r.partial == nil {
+		r.partial = make(map[string][]string)
+	}
+	segments, ok := r.partial[id]
+	if !ok {
+		segments = make([]string, total)
+		r.partial[id] = segments
+	}
+	if len(segments) != total {
+		return "", false, fmt.Errorf(
+			"segment %d of entry %s has total %d, expected %d",
+			seq, id, total, len(segments))
+	}
+	segments[seq-1] = parts[2]
+
+	for _, s := range segments {
+		if s == "" {
+			return "", false, nil
+		}
+	}
+	delete(r.partial, id)
+	return strings.Join(segments, ""), true, nil
+}
+
+// Incomplete returns the IDs of the entries that are missing segments.
+func (r *Reassembler) Incomplete() []string {
+	ids := make([]string, 0, len(r.partial))
+	for id := range r.partial {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
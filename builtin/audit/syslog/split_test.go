@@ -0,0 +1,80 @@
+package file
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitEntry(t *testing.T) {
+	entry := []byte(`{"type":"request","request":{"path":"secret/` +
+		strings.Repeat("é", 200) + `"}}` + "\n")
+
+	messages := splitEntry(entry, minSplitSize, "1234")
+	if len(messages) < 2 {
+		t.Fatalf("bad: %d", len(messages))
+	}
+
+	var r Reassembler
+	var result string
+	for i, msg := range messages {
+		if len(msg) > minSplitSize {
+			t.Fatalf("%d: too large: %d", i, len(msg))
+		}
+
+		// Syslog headers are ignored
+		out, ok, err := r.Add("Jun  1 10:00:00 host vault[1]: " + string(msg))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if ok != (i == len(messages)-1) {
+			t.Fatalf("%d: bad: %v", i, ok)
+		}
+		result = out
+	}
+
+	if result != string(bytes.TrimRight(entry, "\n")) {
+		t.Fatalf("bad: %s", result)
+	}
+	if ids := r.Incomplete(); len(ids) != 0 {
+		t.Fatalf("bad: %#v", ids)
+	}
+}
+
+func TestReassembler(t *testing.T) {
+	var r Reassembler
+
+	// Messages that aren't segments pass through
+	out, ok, err := r.Add(`{"type":"request"}`)
+	if err != nil || !ok || out != `{"type":"request"}` {
+		t.Fatalf("bad: %s %v %v", out, ok, err)
+	}
+
+	// Segments may arrive out of order and interleaved
+	messages := []string{
+		SegmentMarker + " b 2/2 def",
+		SegmentMarker + " a 1/2 abc",
+		SegmentMarker + " b 1/2 abc",
+	}
+	var complete []string
+	for _, msg := range messages {
+		out, ok, err := r.Add(msg)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if ok {
+			complete = append(complete, out)
+		}
+	}
+	if !reflect.DeepEqual(complete, []string{"abcdef"}) {
+		t.Fatalf("bad: %#v", complete)
+	}
+	if ids := r.Incomplete(); !reflect.DeepEqual(ids, []string{"a"}) {
+		t.Fatalf("bad: %#v", ids)
+	}
+
+	if _, _, err := r.Add(SegmentMarker + " c 3/2 abc"); err == nil {
+		t.Fatal("should error")
+	}
+}
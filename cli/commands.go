@@ -5,8 +5,15 @@ import (
 	"os/signal"
 	"syscall"
 
-	auditFile "github.com/hashicorp/vault/builtin/audit/file"
-	auditSyslog "github.com/hashicorp/vault/builtin/audit/syslog"
+	// The audit backends register themselves when imported
+	_ "github.com/hashicorp/vault/builtin/audit/clickhouse"
+	_ "github.com/hashicorp/vault/builtin/audit/eventhubs"
+	_ "github.com/hashicorp/vault/builtin/audit/file"
+	_ "github.com/hashicorp/vault/builtin/audit/memory"
+	_ "github.com/hashicorp/vault/builtin/audit/plugin"
+	_ "github.com/hashicorp/vault/builtin/audit/redis"
+	_ "github.com/hashicorp/vault/builtin/audit/router"
+	_ "github.com/hashicorp/vault/builtin/audit/syslog"
 
 	credAppId "github.com/hashicorp/vault/builtin/credential/app-id"
 	credCert "github.com/hashicorp/vault/builtin/credential/cert"
@@ -20,7 +27,6 @@ import (
 	"github.com/hashicorp/vault/builtin/logical/postgresql"
 	"github.com/hashicorp/vault/builtin/logical/transit"
 
-	"github.com/hashicorp/vault/audit"
 	tokenDisk "github.com/hashicorp/vault/builtin/token/disk"
 	"github.com/hashicorp/vault/command"
 	"github.com/hashicorp/vault/logical"
@@ -42,6 +48,15 @@ func Commands(metaPtr *command.Meta) map[string]cli.CommandFactory {
 		}
 	}
 
+	// The CLI identifies itself to the server with its version, so that
+	// its requests are told apart in the audit logs
+	if meta.UserAgent == "" {
+		meta.UserAgent = "vault-cli/" + Version
+		if VersionPrerelease != "" {
+			meta.UserAgent += "-" + VersionPrerelease
+		}
+	}
+
 	return map[string]cli.CommandFactory{
 		"init": func() (cli.Command, error) {
 			return &command.InitCommand{
@@ -52,10 +67,6 @@ func Commands(metaPtr *command.Meta) map[string]cli.CommandFactory {
 		"server": func() (cli.Command, error) {
 			return &command.ServerCommand{
 				Meta: meta,
-				AuditBackends: map[string]audit.Factory{
-					"file":   auditFile.Factory,
-					"syslog": auditSyslog.Factory,
-				},
 				CredentialBackends: map[string]logical.Factory{
 					"cert":     credCert.Factory,
 					"app-id":   credAppId.Factory,
@@ -109,6 +120,36 @@ func Commands(metaPtr *command.Meta) map[string]cli.CommandFactory {
 			}, nil
 		},
 
+		"audit-export": func() (cli.Command, error) {
+			return &command.AuditExportCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"audit-replay": func() (cli.Command, error) {
+			return &command.AuditReplayCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"audit-summary": func() (cli.Command, error) {
+			return &command.AuditSummaryCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"audit-reassemble": func() (cli.Command, error) {
+			return &command.AuditReassembleCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"audit-validate": func() (cli.Command, error) {
+			return &command.AuditValidateCommand{
+				Meta: meta,
+			}, nil
+		},
+
 		"audit-disable": func() (cli.Command, error) {
 			return &command.AuditDisableCommand{
 				Meta: meta,
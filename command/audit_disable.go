@@ -11,7 +11,9 @@ type AuditDisableCommand struct {
 }
 
 func (c *AuditDisableCommand) Run(args []string) int {
+	var force bool
 	flags := c.Meta.FlagSet("mount", FlagSetDefault)
+	flags.BoolVar(&force, "force", false, "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
@@ -34,7 +36,11 @@ func (c *AuditDisableCommand) Run(args []string) int {
 		return 2
 	}
 
-	if err := client.Sys().DisableAudit(id); err != nil {
+	disable := client.Sys().DisableAudit
+	if force {
+		disable = client.Sys().ForceDisableAudit
+	}
+	if err := disable(id); err != nil {
 		c.Ui.Error(fmt.Sprintf(
 			"Error disabling audit backend: %s", err))
 		return 2
@@ -62,20 +68,17 @@ Usage: vault audit-disable [options] id
   no specific ID was specified, then it is the name of the backend (the
   type of the backend).
 
-General Options:
+  Disabling the last remaining audit backend requires -force, since
+  afterwards requests are no longer audited.
 
-  -address=addr           The address of the Vault server.
+General Options:
 
-  -ca-cert=path           Path to a PEM encoded CA cert file to use to
-                          verify the Vault server SSL certificate.
+` + generalOptionsUsage() + `
 
-  -ca-path=path           Path to a directory of PEM encoded CA cert files
-                          to verify the Vault server SSL certificate. If both
-                          -ca-cert and -ca-path are specified, -ca-path is used.
+Audit Disable Options:
 
-  -tls-skip-verify        Do not verify TLS certificate. This is highly
-                          not recommended. This is especially not recommended
-                          for unsealing a vault.
+  -force                  Disable the audit backend even if it is the
+                          last one enabled.
 
 `
 	return strings.TrimSpace(helpText)
@@ -23,6 +23,7 @@ func TestAuditDisable(t *testing.T) {
 
 	args := []string{
 		"-address", addr,
+		"-force",
 		"noop",
 	}
 
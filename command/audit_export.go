@@ -0,0 +1,271 @@
+package command
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// AuditExportCommand is a Command that filters the entries written by
+// the file audit backend and re-emits them for other tools.
+type AuditExportCommand struct {
+	Meta
+}
+
+// auditExportEntry holds the fields of an audit entry that are used for
+// filtering and for the CSV output.
+type auditExportEntry struct {
+	Type  string    `json:"type"`
+	Time  time.Time `json:"time"`
+	Error string    `json:"error"`
+	Auth  struct {
+		ClientToken string `json:"client_token"`
+		DisplayName string `json:"display_name"`
+	} `json:"auth"`
+	Request struct {
+		Operation string `json:"operation"`
+		Path      string `json:"path"`
+	} `json:"request"`
+}
+
+// auditExportFilter is the set of conditions an entry must match to be
+// exported.
+type auditExportFilter struct {
+	Since time.Time
+	Until time.Time
+	Path  string
+
+	// ClientToken is the hash of a client token, as logged by the audit
+	// backend, to export the entries of the requests made with it.
+	ClientToken string
+}
+
+// parseTimes sets the range of the filter from the values of the -since
+// and -until flags, which are in RFC 3339 format when given.
+func (f *auditExportFilter) parseTimes(since, until string) error {
+	var err error
+	if since != "" {
+		if f.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return fmt.Errorf("Invalid -since time: %s", err)
+		}
+	}
+	if until != "" {
+		if f.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return fmt.Errorf("Invalid -until time: %s", err)
+		}
+	}
+	return nil
+}
+
+func (f *auditExportFilter) Match(e *auditExportEntry) (bool, error) {
+	if !f.Since.IsZero() || !f.Until.IsZero() {
+		// Entries written before the time field was added can't be
+		// placed in the range
+		if e.Time.IsZero() {
+			return false, nil
+		}
+		if !f.Since.IsZero() && e.Time.Before(f.Since) {
+			return false, nil
+		}
+		if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+			return false, nil
+		}
+	}
+
+	if f.ClientToken != "" && e.Auth.ClientToken != f.ClientToken {
+		return false, nil
+	}
+
+	if f.Path != "" {
+		return path.Match(f.Path, e.Request.Path)
+	}
+
+	return true, nil
+}
+
+func (c *AuditExportCommand) Run(args []string) int {
+	var format, since, until string
+	var filter auditExportFilter
+	flags := c.Meta.FlagSet("audit-export", FlagSetNone)
+	flags.StringVar(&format, "format", "json", "")
+	flags.StringVar(&since, "since", "", "")
+	flags.StringVar(&until, "until", "", "")
+	flags.StringVar(&filter.Path, "path", "", "")
+	flags.StringVar(&filter.ClientToken, "client-token", "", "")
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) == 0 {
+		c.Ui.Error("audit-export expects at least one file")
+		flags.Usage()
+		return 1
+	}
+	if format != "json" && format != "csv" {
+		c.Ui.Error(fmt.Sprintf("Unknown format: %s", format))
+		flags.Usage()
+		return 1
+	}
+	if _, err := path.Match(filter.Path, ""); err != nil {
+		c.Ui.Error(fmt.Sprintf("Invalid path pattern: %s", err))
+		return 1
+	}
+
+	if err := filter.parseTimes(since, until); err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	if format == "csv" {
+		c.Ui.Output(auditExportCSV([]string{
+			"time", "type", "operation", "path", "display_name", "error"}))
+	}
+
+	for _, name := range args {
+		if err := c.export(name, format, &filter); err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Error exporting '%s': %s", name, err))
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// export writes the entries of a single audit log that match the filter.
+func (c *AuditExportCommand) export(
+	name string, format string, filter *auditExportFilter) error {
+	return readAuditLog(name, func(raw json.RawMessage, entry *auditExportEntry) error {
+		ok, err := filter.Match(entry)
+		if err != nil || !ok {
+			return err
+		}
+
+		switch format {
+		case "csv":
+			var t string
+			if !entry.Time.IsZero() {
+				t = entry.Time.Format(time.RFC3339Nano)
+			}
+			c.Ui.Output(auditExportCSV([]string{
+				t,
+				entry.Type,
+				entry.Request.Operation,
+				entry.Request.Path,
+				entry.Auth.DisplayName,
+				entry.Error,
+			}))
+		default:
+			c.Ui.Output(string(raw))
+		}
+		return nil
+	})
+}
+
+// readAuditLog calls fn with each entry of an audit log written by the
+// file audit backend, in order. Logs ending in ".gz" are decompressed.
+func readAuditLog(
+	name string, fn func(json.RawMessage, *auditExportEntry) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	dec := json.NewDecoder(r)
+	for i := 1; ; i++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("entry %d: %s", i, err)
+		}
+
+		var entry auditExportEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return fmt.Errorf("entry %d: %s", i, err)
+		}
+
+		if err := fn(raw, &entry); err != nil {
+			return err
+		}
+	}
+}
+
+// auditExportCSV returns a single CSV record without the line ending.
+func auditExportCSV(record []string) string {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(record)
+	w.Flush()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func (c *AuditExportCommand) Synopsis() string {
+	return "Filters and exports the entries of audit log files"
+}
+
+func (c *AuditExportCommand) Help() string {
+	helpText := `
+Usage: vault audit-export [options] file...
+
+  Filters the entries of audit logs written by the file audit backend
+  and writes them to stdout, for example to hand off to auditors.
+
+  Any number of log files may be given, and they are read in the order
+  given. Files ending in ".gz" are decompressed, so rotated and compressed
+  logs can be read directly.
+
+  This command reads the files locally and does not contact the Vault
+  server.
+
+Export Options:
+
+  -format=json            The output format: "json" for the original
+                          entries, one per line, or "csv" for the time,
+                          type, operation, path, display name, and error
+                          of each entry.
+
+  -since=time             Only export entries logged at or after this time,
+                          in RFC 3339 format such as 2015-06-01T00:00:00Z.
+
+  -until=time             Only export entries logged before this time, in
+                          RFC 3339 format. Entries without a time, from
+                          older versions of Vault, are skipped when either
+                          -since or -until is given.
+
+  -path=pattern           Only export entries whose request path matches
+                          this glob pattern, such as "secret/*". A "*" does
+                          not match a "/".
+
+  -client-token=hash      Only export the entries of the requests made with
+                          the client token of this hash, as logged in the
+                          "client_token" field of the authentication, such
+                          as in the response of the login that created the
+                          token. Tokens are only hashed identically in every
+                          entry if the backend doesn't set hash_stability
+                          to "per_entry".
+
+`
+	return strings.TrimSpace(helpText)
+}
@@ -0,0 +1,177 @@
+package command
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/logical"
+	"github.com/mitchellh/cli"
+)
+
+const testAuditExportLog = `{"type":"request","schema_version":2,"time":"2015-06-01T10:00:00Z","auth":{"display_name":"root"},"request":{"operation":"read","path":"secret/foo"}}
+{"type":"request","schema_version":2,"time":"2015-06-02T10:00:00Z","auth":{"display_name":"root"},"request":{"operation":"write","path":"secret/bar"}}
+{"type":"response","schema_version":2,"time":"2015-06-02T10:00:00Z","error":"permission denied","auth":{"display_name":"app"},"request":{"operation":"read","path":"sys/mounts"}}
+{"type":"request","schema_version":1,"auth":{"display_name":"root"},"request":{"operation":"read","path":"secret/old"}}
+`
+
+func testAuditExportFiles(t *testing.T) (string, string) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	plain := filepath.Join(dir, "audit.log")
+	if err := ioutil.WriteFile(plain, []byte(testAuditExportLog), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	compressed := filepath.Join(dir, "audit.log.1.gz")
+	f, err := os.Create(compressed)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	gz := gzip.NewWriter(f)
+	if _, err := gz.Write([]byte(testAuditExportLog)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	gz.Close()
+	f.Close()
+
+	return plain, compressed
+}
+
+func TestAuditExport(t *testing.T) {
+	plain, compressed := testAuditExportFiles(t)
+	defer os.RemoveAll(filepath.Dir(plain))
+
+	ui := new(cli.MockUi)
+	c := &AuditExportCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	args := []string{plain, compressed}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	expected := testAuditExportLog + testAuditExportLog
+	if actual := ui.OutputWriter.String(); actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestAuditExport_filter(t *testing.T) {
+	plain, compressed := testAuditExportFiles(t)
+	defer os.RemoveAll(filepath.Dir(plain))
+
+	ui := new(cli.MockUi)
+	c := &AuditExportCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	args := []string{
+		"-format", "csv",
+		"-since", "2015-06-02T00:00:00Z",
+		"-path", "s*/*",
+		compressed,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	expected := strings.Join([]string{
+		"time,type,operation,path,display_name,error",
+		"2015-06-02T10:00:00Z,request,write,secret/bar,root,",
+		"2015-06-02T10:00:00Z,response,read,sys/mounts,app,permission denied",
+	}, "\n") + "\n"
+	if actual := ui.OutputWriter.String(); actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestAuditExport_clientToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Log a login, and requests with the token it created and another one,
+	// the way the audit backends do
+	hash, err := audit.ParseHashConfig(map[string]string{"salt": "foo"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	format := &audit.FormatJSON{}
+	var buf bytes.Buffer
+	logResponse := func(auth *logical.Auth, req *logical.Request, resp *logical.Response) {
+		cp, err := audit.HashEvent(hash, auth)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		auth = cp.(*logical.Auth)
+		if cp, err = audit.HashEvent(hash, resp); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		resp = cp.(*logical.Response)
+		if err := format.FormatResponse(&buf, auth, req, resp, nil); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	logResponse(&logical.Auth{},
+		&logical.Request{Operation: logical.WriteOperation, Path: "auth/github/login"},
+		&logical.Response{Auth: &logical.Auth{ClientToken: "foo", DisplayName: "github-armon"}})
+	logResponse(&logical.Auth{ClientToken: "foo", DisplayName: "github-armon"},
+		&logical.Request{Operation: logical.ReadOperation, Path: "secret/foo"}, nil)
+	logResponse(&logical.Auth{ClientToken: "bar", DisplayName: "root"},
+		&logical.Request{Operation: logical.ReadOperation, Path: "secret/bar"}, nil)
+
+	path := filepath.Join(dir, "audit.log")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The hash of the token is taken from the response of the login
+	var login struct {
+		Response struct {
+			Auth struct {
+				ClientToken string `json:"client_token"`
+			} `json:"auth"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(bytes.SplitN(buf.Bytes(), []byte("\n"), 2)[0], &login); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	token := login.Response.Auth.ClientToken
+	if token == "" || token == "foo" {
+		t.Fatalf("bad: %q", token)
+	}
+
+	ui := new(cli.MockUi)
+	c := &AuditExportCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	args := []string{"-format", "csv", "-client-token", token, path}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(ui.OutputWriter.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[1], ",response,read,secret/foo,github-armon,") {
+		t.Fatalf("bad: %#v", lines)
+	}
+}
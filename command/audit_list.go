@@ -47,16 +47,17 @@ func (c *AuditListCommand) Run(args []string) int {
 	}
 	sort.Strings(paths)
 
-	columns := []string{"Type | Description | Options"}
+	columns := []string{"Path | Type | Description | Options"}
 	for _, path := range paths {
 		audit := audits[path]
 		opts := make([]string, 0, len(audit.Options))
 		for k, v := range audit.Options {
 			opts = append(opts, k+"="+v)
 		}
+		sort.Strings(opts)
 
 		columns = append(columns, fmt.Sprintf(
-			"%s | %s | %s", audit.Type, audit.Description, strings.Join(opts, " ")))
+			"%s | %s | %s | %s", path, audit.Type, audit.Description, strings.Join(opts, " ")))
 	}
 
 	c.Ui.Output(columnize.SimpleFormat(columns))
@@ -75,22 +76,12 @@ Usage: vault audit-list [options]
 
   The output lists the enabled audit backends and the options for those
   backends. The options may contain sensitive information, and therefore
-  only a root Vault user can view this.
+  only a root Vault user can view this. The values of options such as
+  tokens and passwords are always redacted.
 
 General Options:
 
-  -address=addr           The address of the Vault server.
-
-  -ca-cert=path           Path to a PEM encoded CA cert file to use to
-                          verify the Vault server SSL certificate.
-
-  -ca-path=path           Path to a directory of PEM encoded CA cert files
-                          to verify the Vault server SSL certificate. If both
-                          -ca-cert and -ca-path are specified, -ca-path is used.
-
-  -tls-skip-verify        Do not verify TLS certificate. This is highly
-                          not recommended. This is especially not recommended
-                          for unsealing a vault.
+` + generalOptionsUsage() + `
 
 `
 	return strings.TrimSpace(helpText)
@@ -0,0 +1,107 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	auditSyslog "github.com/hashicorp/vault/builtin/audit/syslog"
+)
+
+// AuditReassembleCommand is a Command that reassembles the audit entries
+// that the syslog audit backend split into several messages.
+type AuditReassembleCommand struct {
+	Meta
+
+	// testStdin is the input for tests
+	testStdin io.Reader
+}
+
+func (c *AuditReassembleCommand) Run(args []string) int {
+	flags := c.Meta.FlagSet("audit-reassemble", FlagSetNone)
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	var r auditSyslog.Reassembler
+	args = flags.Args()
+	if len(args) == 0 {
+		var stdin io.Reader = os.Stdin
+		if c.testStdin != nil {
+			stdin = c.testStdin
+		}
+		if err := c.reassemble(&r, stdin); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error reading stdin: %s", err))
+			return 1
+		}
+	}
+	for _, name := range args {
+		f, err := os.Open(name)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error opening '%s': %s", name, err))
+			return 1
+		}
+		err = c.reassemble(&r, f)
+		f.Close()
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error reading '%s': %s", name, err))
+			return 1
+		}
+	}
+
+	if ids := r.Incomplete(); len(ids) > 0 {
+		c.Ui.Error(fmt.Sprintf(
+			"%d entries are missing segments: %s",
+			len(ids), strings.Join(ids, ", ")))
+		return 1
+	}
+
+	return 0
+}
+
+// reassemble outputs the messages read from r, one per line, reassembling
+// the entries that were split.
+func (c *AuditReassembleCommand) reassemble(
+	r *auditSyslog.Reassembler, in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		entry, ok, err := r.Add(scanner.Text())
+		if err != nil {
+			return err
+		}
+		if ok {
+			c.Ui.Output(entry)
+		}
+	}
+	return scanner.Err()
+}
+
+func (c *AuditReassembleCommand) Synopsis() string {
+	return "Reassembles audit entries split by the syslog audit backend"
+}
+
+func (c *AuditReassembleCommand) Help() string {
+	helpText := `
+Usage: vault audit-reassemble [file...]
+
+  Reassembles the audit entries that the syslog audit backend split into
+  several messages because of its split_size option.
+
+  The messages are read one per line from the given files, or from stdin
+  if no files are given. Lines that hold a segment are collected until the
+  entry is complete, and the entry is then written to stdout. All other
+  lines are written unchanged, so the input should hold just the messages,
+  as written by "journalctl -o cat" for example.
+
+  If any entry is still missing segments at the end of the input, its ID
+  is reported and the exit code is 1.
+
+  This command reads the messages locally and does not contact the Vault
+  server.
+`
+	return strings.TrimSpace(helpText)
+}
@@ -0,0 +1,50 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	auditSyslog "github.com/hashicorp/vault/builtin/audit/syslog"
+	"github.com/mitchellh/cli"
+)
+
+func TestAuditReassemble(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &AuditReassembleCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+		testStdin: strings.NewReader(strings.Join([]string{
+			auditSyslog.SegmentMarker + ` 1234 2/2 "request"}`,
+			`{"type":"response"}`,
+			auditSyslog.SegmentMarker + ` 1234 1/2 {"type":`,
+		}, "\n")),
+	}
+
+	if code := c.Run(nil); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	expected := `{"type":"response"}` + "\n" + `{"type":"request"}` + "\n"
+	if actual := ui.OutputWriter.String(); actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestAuditReassemble_incomplete(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &AuditReassembleCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+		testStdin: strings.NewReader(
+			auditSyslog.SegmentMarker + ` 1234 1/2 {"type":`),
+	}
+
+	if code := c.Run(nil); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "1234") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+}
@@ -0,0 +1,235 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/flag-slice"
+	"github.com/hashicorp/vault/helper/kv-builder"
+	"github.com/mitchellh/mapstructure"
+	"golang.org/x/net/context"
+)
+
+// AuditReplayCommand is a Command that replays the entries of audit logs
+// written by the file audit backend into an audit backend created
+// locally, without a Vault server.
+type AuditReplayCommand struct {
+	Meta
+
+	// Factories are the factories of the audit backends, by type. If nil,
+	// the registered factories are used.
+	Factories map[string]audit.Factory
+
+	// A test stdin that can be used for tests
+	testStdin io.Reader
+}
+
+func (c *AuditReplayCommand) Run(args []string) int {
+	var logs []string
+	var since, until string
+	var filter auditExportFilter
+	var wait time.Duration
+	flags := c.Meta.FlagSet("audit-replay", FlagSetOutputFormat)
+	flags.Var((*sliceflag.StringFlag)(&logs), "log", "")
+	flags.StringVar(&since, "since", "", "")
+	flags.StringVar(&until, "until", "", "")
+	flags.StringVar(&filter.Path, "path", "", "")
+	flags.DurationVar(&wait, "wait", 0, "")
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) < 1 {
+		flags.Usage()
+		c.Ui.Error(fmt.Sprintf(
+			"\naudit-replay expects at least one argument: the type to replay into"))
+		return 1
+	}
+	if len(logs) == 0 {
+		c.Ui.Error("At least one log must be specified with -log")
+		flags.Usage()
+		return 1
+	}
+	if err := c.CheckOutputFormat(); err != nil {
+		c.Ui.Error(err.Error())
+		flags.Usage()
+		return 1
+	}
+	if _, err := path.Match(filter.Path, ""); err != nil {
+		c.Ui.Error(fmt.Sprintf("Invalid path pattern: %s", err))
+		return 1
+	}
+	if err := filter.parseTimes(since, until); err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	auditType := args[0]
+	factories := c.Factories
+	if factories == nil {
+		factories = audit.Factories()
+	}
+	factory, ok := factories[auditType]
+	if !ok {
+		c.Ui.Error(fmt.Sprintf("Unknown audit backend type: %s", auditType))
+		return 1
+	}
+
+	// Build the options the same way as audit-enable
+	var stdin io.Reader = os.Stdin
+	if c.testStdin != nil {
+		stdin = c.testStdin
+	}
+	builder := &kvbuilder.Builder{Stdin: stdin}
+	if err := builder.Add(args[1:]...); err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Error parsing options: %s", err))
+		return 1
+	}
+
+	var opts map[string]string
+	if err := mapstructure.WeakDecode(builder.Map(), &opts); err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Error parsing options: %s", err))
+		return 1
+	}
+	if opts == nil {
+		opts = make(map[string]string)
+	}
+
+	backend, err := factory(opts)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Error creating the '%s' audit backend: %s", auditType, err))
+		return 1
+	}
+
+	replayed, skipped := 0, 0
+	for _, name := range logs {
+		i := 0
+		err := readAuditLog(name, func(raw json.RawMessage, entry *auditExportEntry) error {
+			i++
+			ok, err := filter.Match(entry)
+			if err != nil || !ok {
+				return err
+			}
+
+			ok, err = auditReplayEntry(backend, raw, entry.Type)
+			if err != nil {
+				return fmt.Errorf("entry %d: %s", i, err)
+			}
+			if ok {
+				replayed++
+			} else {
+				skipped++
+			}
+			return nil
+		})
+		if err != nil {
+			c.OutputFailure(fmt.Sprintf(
+				"Error replaying '%s': %s", name, err),
+				map[string]interface{}{
+					"log":      name,
+					"error":    err.Error(),
+					"replayed": replayed,
+				})
+			return 1
+		}
+	}
+
+	// Give the backends that log asynchronously time to send the entries
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+
+	c.OutputSuccess(fmt.Sprintf(
+		"Replayed %d entries, skipped %d", replayed, skipped),
+		map[string]interface{}{
+			"replayed": replayed,
+			"skipped":  skipped,
+		})
+	return 0
+}
+
+// auditReplayEntry logs a request or response entry of an audit log
+// through the backend. Other entries, such as statuses and heartbeats,
+// are skipped, which is reported by returning false.
+func auditReplayEntry(
+	backend audit.Backend, raw json.RawMessage, kind string) (bool, error) {
+	ctx := context.Background()
+	switch kind {
+	case "request":
+		var entry audit.JSONRequestEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return false, err
+		}
+		return true, backend.LogRequest(ctx,
+			entry.Auth.Auth(), entry.Request.Request())
+	case "response":
+		var entry audit.JSONResponseEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return false, err
+		}
+
+		return true, backend.LogResponse(ctx,
+			entry.Auth.Auth(), entry.Request.Request(),
+			entry.Response.Response(), audit.ParseJSONError(entry.Error))
+	default:
+		return false, nil
+	}
+}
+
+func (c *AuditReplayCommand) Synopsis() string {
+	return "Replays audit log files into an audit backend"
+}
+
+func (c *AuditReplayCommand) Help() string {
+	helpText := `
+Usage: vault audit-replay [options] type [config...]
+
+  Replays the entries of audit logs written by the file audit backend
+  into an audit backend of the given type, for example to check a new
+  SIEM pipeline against historical data before switching to it.
+
+  The backend is created locally by this binary with the options given
+  as for the "vault audit-enable" command, and the Vault server is not
+  contacted. Only the request and response entries are replayed, in the
+  order of the logs. The entries are logged with the current time, and
+  with their values as they are in the logs, so values that were hashed
+  are hashed again unless the backend is given log_raw=true.
+
+  Example: vault audit-replay -log=audit.log clickhouse \
+               address=http://clickhouse:8123 log_raw=true
+
+Replay Options:
+
+  -log=path               An audit log to replay. This can be specified
+                          multiple times. Files ending in ".gz" are
+                          decompressed.
+
+  -since=time             Only replay entries logged at or after this time,
+                          in RFC 3339 format such as 2015-06-01T00:00:00Z.
+
+  -until=time             Only replay entries logged before this time, in
+                          RFC 3339 format.
+
+  -path=pattern           Only replay entries whose request path matches
+                          this glob pattern, such as "secret/*".
+
+  -wait=0                 The time to wait after the last entry, for the
+                          backends that send entries asynchronously, such
+                          as the clickhouse backend.
+
+` + outputFormatUsage() + `
+
+`
+	return strings.TrimSpace(helpText)
+}
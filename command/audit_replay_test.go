@@ -0,0 +1,89 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/audit/audittest"
+	"github.com/hashicorp/vault/logical"
+	"github.com/mitchellh/cli"
+)
+
+func testAuditReplayCommand(t *testing.T) (*cli.MockUi, *AuditReplayCommand, *audittest.Backend) {
+	ui := new(cli.MockUi)
+	sink := new(audittest.Backend)
+	return ui, &AuditReplayCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+		Factories: map[string]audit.Factory{
+			"test": func(conf map[string]string) (audit.Backend, error) {
+				return sink, nil
+			},
+		},
+	}, sink
+}
+
+func TestAuditReplay(t *testing.T) {
+	plain, compressed := testAuditExportFiles(t)
+	defer os.RemoveAll(filepath.Dir(plain))
+
+	ui, c, sink := testAuditReplayCommand(t)
+	args := []string{"-log", plain, "-log", compressed, "-path", "secret/*", "test"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	requests := sink.Requests()
+	if len(requests) != 6 {
+		t.Fatalf("bad: %#v", requests)
+	}
+	req := requests[1]
+	if req.Request.Operation != logical.WriteOperation ||
+		req.Request.Path != "secret/bar" || req.Auth.DisplayName != "root" {
+		t.Fatalf("bad: %#v", req)
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "Replayed 6 entries, skipped 0") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+}
+
+func TestAuditReplay_response(t *testing.T) {
+	plain, _ := testAuditExportFiles(t)
+	defer os.RemoveAll(filepath.Dir(plain))
+
+	ui, c, sink := testAuditReplayCommand(t)
+	args := []string{"-log", plain, "-path", "sys/*", "-format", "json", "test"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	responses := sink.Responses()
+	if len(responses) != 1 {
+		t.Fatalf("bad: %#v", responses)
+	}
+	resp := responses[0]
+	if resp.Err != logical.ErrPermissionDenied || resp.Response != nil ||
+		resp.Request.Path != "sys/mounts" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if !strings.Contains(ui.OutputWriter.String(), `"replayed":1`) {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+}
+
+func TestAuditReplay_unknownType(t *testing.T) {
+	plain, _ := testAuditExportFiles(t)
+	defer os.RemoveAll(filepath.Dir(plain))
+
+	ui, c, _ := testAuditReplayCommand(t)
+	if code := c.Run([]string{"-log", plain, "nope"}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "Unknown audit backend type: nope") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+}
@@ -0,0 +1,219 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// auditSummaryDayFormat is the format of the days of the summary.
+const auditSummaryDayFormat = "2006-01-02"
+
+// AuditSummaryCommand is a Command that rolls up the entries written by
+// the file audit backend into daily counts per path and per user, so
+// that dashboards don't need to scan the raw logs.
+type AuditSummaryCommand struct {
+	Meta
+}
+
+// auditSummaryRecord is the rollup of the responses of one day, for all
+// of them or those of a single path or display name.
+type auditSummaryRecord struct {
+	Day       string  `json:"day"`
+	Type      string  `json:"type"`
+	Key       string  `json:"key,omitempty"`
+	Requests  int     `json:"requests"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// auditSummary accumulates the records of a summary.
+type auditSummary struct {
+	records map[[3]string]*auditSummaryRecord
+}
+
+// Add counts the entry in the records of its day. Only responses are
+// counted, since every request that completes has one.
+func (s *auditSummary) Add(e *auditExportEntry) {
+	if e.Type != "response" {
+		return
+	}
+
+	day := e.Time.UTC().Format(auditSummaryDayFormat)
+	s.add(day, "total", "", e.Error != "")
+	s.add(day, "path", e.Request.Path, e.Error != "")
+	s.add(day, "display_name", e.Auth.DisplayName, e.Error != "")
+}
+
+func (s *auditSummary) add(day, kind, key string, failed bool) {
+	id := [3]string{day, kind, key}
+	r, ok := s.records[id]
+	if !ok {
+		r = &auditSummaryRecord{Day: day, Type: kind, Key: key}
+		s.records[id] = r
+	}
+
+	r.Requests++
+	if failed {
+		r.Errors++
+	}
+	r.ErrorRate = float64(r.Errors) / float64(r.Requests)
+}
+
+// Records returns the records sorted by day, type, and key.
+func (s *auditSummary) Records() []*auditSummaryRecord {
+	ids := make([][3]string, 0, len(s.records))
+	for id := range s.records {
+		ids = append(ids, id)
+	}
+	sort.Sort(auditSummaryIDs(ids))
+
+	result := make([]*auditSummaryRecord, len(ids))
+	for i, id := range ids {
+		result[i] = s.records[id]
+	}
+	return result
+}
+
+type auditSummaryIDs [][3]string
+
+func (s auditSummaryIDs) Len() int      { return len(s) }
+func (s auditSummaryIDs) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s auditSummaryIDs) Less(i, j int) bool {
+	for k := range s[i] {
+		if s[i][k] != s[j][k] {
+			return s[i][k] < s[j][k]
+		}
+	}
+	return false
+}
+
+func (c *AuditSummaryCommand) Run(args []string) int {
+	var day, output string
+	flags := c.Meta.FlagSet("audit-summary", FlagSetNone)
+	flags.StringVar(&day, "day", "", "")
+	flags.StringVar(&output, "output", "", "")
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) == 0 {
+		c.Ui.Error("audit-summary expects at least one file")
+		flags.Usage()
+		return 1
+	}
+
+	var filter auditExportFilter
+	if day != "" {
+		start, err := time.Parse(auditSummaryDayFormat, day)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Invalid -day: %s", err))
+			return 1
+		}
+		filter.Since = start
+		filter.Until = start.AddDate(0, 0, 1)
+	}
+
+	summary := &auditSummary{
+		records: make(map[[3]string]*auditSummaryRecord),
+	}
+	for _, name := range args {
+		err := readAuditLog(name, func(_ json.RawMessage, e *auditExportEntry) error {
+			// Entries without a time can't be placed in a day
+			if e.Time.IsZero() {
+				return nil
+			}
+			ok, err := filter.Match(e)
+			if ok {
+				summary.Add(e)
+			}
+			return err
+		})
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Error reading '%s': %s", name, err))
+			return 1
+		}
+	}
+
+	if err := c.write(output, summary.Records()); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error writing summary: %s", err))
+		return 1
+	}
+	return 0
+}
+
+// write writes the records as JSON, one per line, to the output file, or
+// to stdout if no output file is given.
+func (c *AuditSummaryCommand) write(
+	output string, records []*auditSummaryRecord) error {
+	if output == "" {
+		for _, r := range records {
+			line, err := json.Marshal(r)
+			if err != nil {
+				return err
+			}
+			c.Ui.Output(string(line))
+		}
+		return nil
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := writeAuditSummary(f, records); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeAuditSummary(w io.Writer, records []*auditSummaryRecord) error {
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *AuditSummaryCommand) Synopsis() string {
+	return "Rolls up the entries of audit log files into daily counts"
+}
+
+func (c *AuditSummaryCommand) Help() string {
+	helpText := `
+Usage: vault audit-summary [options] file...
+
+  Rolls up the entries of audit logs written by the file audit backend
+  into daily counts, so that dashboards don't need to scan the raw logs.
+
+  A JSON record is written per day for all the responses of the day, and
+  one for each path and for each display name. Each record has the
+  "day", its "type" ("total", "path", or "display_name"), the path or
+  display name as its "key", and the number of "requests", "errors", and
+  the "error_rate" of the responses. Days are in UTC, and entries without
+  a time, from older versions of Vault, are skipped.
+
+  Any number of log files may be given. Files ending in ".gz" are
+  decompressed. This command reads the files locally and does not contact
+  the Vault server.
+
+Summary Options:
+
+  -day=2015-06-01         Only summarize the entries of this day.
+
+  -output=path            Write the records to this file instead of stdout.
+                          The file is replaced if it exists.
+
+`
+	return strings.TrimSpace(helpText)
+}
@@ -0,0 +1,150 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/logical"
+	"github.com/mitchellh/cli"
+)
+
+// testAuditSummaryLog writes a log with entries of the JSON formatter of
+// the audit backends, with their times set to the given ones. A zero time
+// removes the time, as in the entries of older versions of Vault.
+func testAuditSummaryLog(t *testing.T, path string) {
+	entries := []struct {
+		Time        string
+		Response    bool
+		DisplayName string
+		Operation   logical.Operation
+		Path        string
+		Err         error
+	}{
+		{"2015-06-01T10:00:00Z", false, "root", logical.ReadOperation, "secret/foo", nil},
+		{"2015-06-01T10:00:00Z", true, "root", logical.ReadOperation, "secret/foo", nil},
+		{"2015-06-01T11:00:00Z", true, "app", logical.ReadOperation, "secret/foo", logical.ErrPermissionDenied},
+		{"2015-06-02T10:00:00Z", true, "app", logical.WriteOperation, "secret/bar", nil},
+		{"", true, "root", logical.ReadOperation, "secret/old", nil},
+	}
+
+	var buf bytes.Buffer
+	format := &audit.FormatJSON{}
+	for _, e := range entries {
+		auth := &logical.Auth{DisplayName: e.DisplayName}
+		req := &logical.Request{Operation: e.Operation, Path: e.Path}
+
+		var line bytes.Buffer
+		var err error
+		if e.Response {
+			err = format.FormatResponse(&line, auth, req, nil, e.Err)
+		} else {
+			err = format.FormatRequest(&line, auth, req)
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal(line.Bytes(), &entry); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if e.Time == "" {
+			delete(entry, "time")
+		} else {
+			entry["time"] = e.Time
+		}
+		raw, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		buf.Write(append(raw, '\n'))
+	}
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+const testAuditSummaryDay = `{"day":"2015-06-01","type":"display_name","key":"app","requests":1,"errors":1,"error_rate":1}
+{"day":"2015-06-01","type":"display_name","key":"root","requests":1,"errors":0,"error_rate":0}
+{"day":"2015-06-01","type":"path","key":"secret/foo","requests":2,"errors":1,"error_rate":0.5}
+{"day":"2015-06-01","type":"total","requests":2,"errors":1,"error_rate":0.5}
+`
+
+func TestAuditSummary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	testAuditSummaryLog(t, path)
+
+	ui := new(cli.MockUi)
+	c := &AuditSummaryCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	if code := c.Run([]string{path}); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	expected := testAuditSummaryDay + `{"day":"2015-06-02","type":"display_name","key":"app","requests":1,"errors":0,"error_rate":0}
+{"day":"2015-06-02","type":"path","key":"secret/bar","requests":1,"errors":0,"error_rate":0}
+{"day":"2015-06-02","type":"total","requests":1,"errors":0,"error_rate":0}
+`
+	if actual := ui.OutputWriter.String(); actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestAuditSummary_dayOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	testAuditSummaryLog(t, path)
+
+	ui := new(cli.MockUi)
+	c := &AuditSummaryCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	output := filepath.Join(dir, "summary.json")
+	args := []string{"-day", "2015-06-01", "-output", output, path}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(actual) != testAuditSummaryDay {
+		t.Fatalf("bad: %s", actual)
+	}
+	if ui.OutputWriter != nil && ui.OutputWriter.Len() != 0 {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+
+	if code := c.Run([]string{"-day", "June 1", path}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "Invalid -day") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+}
@@ -0,0 +1,69 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/mitchellh/cli"
+)
+
+func testAuditValidateCommand(t *testing.T) (*cli.MockUi, *AuditValidateCommand) {
+	ui := new(cli.MockUi)
+	return ui, &AuditValidateCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+		Validators: map[string]audit.Validator{
+			"test": func(conf map[string]string) (map[string]string, error) {
+				if conf["path"] == "" {
+					return nil, fmt.Errorf("path is required")
+				}
+				return audit.ResolveHashOptions(conf)
+			},
+		},
+	}
+}
+
+func TestAuditValidate(t *testing.T) {
+	ui, c := testAuditValidateCommand(t)
+
+	args := []string{"test", "path=audit.log", "salt=foo", "hash_algorithm=sha256"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	for _, expected := range []string{
+		"path            audit.log",
+		"hash_algorithm  sha256",
+		"log_raw         false",
+		"salt            <redacted>",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("missing %q: %s", expected, output)
+		}
+	}
+	if strings.Contains(output, "foo") {
+		t.Fatalf("salt should be redacted: %s", output)
+	}
+}
+
+func TestAuditValidate_invalid(t *testing.T) {
+	ui, c := testAuditValidateCommand(t)
+	if code := c.Run([]string{"test", "path=audit.log", "hash_algorithm=md5"}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "unknown hash_algorithm") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	ui, c = testAuditValidateCommand(t)
+	if code := c.Run([]string{"nope"}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "unknown") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+}
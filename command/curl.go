@@ -0,0 +1,109 @@
+package command
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// curlStringError is returned by the curlTransport in place of performing
+// a request, and carries the equivalent curl command.
+type curlStringError struct {
+	Command string
+}
+
+func (e *curlStringError) Error() string {
+	return e.Command
+}
+
+// curlTransport is an http.RoundTripper that never performs a request,
+// but builds the equivalent curl command for it. The TLS flags mirror
+// the settings given to the command.
+type curlTransport struct {
+	CACert     string
+	CAPath     string
+	ClientCert string
+	ClientKey  string
+	Insecure   bool
+}
+
+func (t *curlTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	args := []string{"curl", "-X", req.Method}
+	if t.Insecure {
+		args = append(args, "--insecure")
+	}
+	if t.CACert != "" {
+		args = append(args, "--cacert", shellQuote(t.CACert))
+	}
+	if t.ClientCert != "" {
+		args = append(args, "--cert", shellQuote(t.ClientCert))
+	}
+	if t.ClientKey != "" {
+		args = append(args, "--key", shellQuote(t.ClientKey))
+	}
+	if t.CAPath != "" {
+		args = append(args, "--capath", shellQuote(t.CAPath))
+	}
+
+	// The token is taken from the environment rather than printed, and the
+	// default User-Agent is left to curl
+	headers := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		headers = append(headers, k)
+	}
+	sort.Strings(headers)
+	for _, k := range headers {
+		if k == "Cookie" {
+			if _, err := req.Cookie(api.AuthCookieName); err == nil {
+				args = append(args, "-H", `"X-Vault-Token: $VAULT_TOKEN"`)
+			}
+			continue
+		}
+		if k == "User-Agent" && req.Header.Get(k) == api.DefaultUserAgent {
+			continue
+		}
+		for _, v := range req.Header[k] {
+			args = append(args, "-H", shellQuote(k+": "+v))
+		}
+	}
+
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if body = bytes.TrimSpace(body); len(body) > 0 {
+			args = append(args, "-d", shellQuote(string(body)))
+		}
+	}
+
+	args = append(args, shellQuote(req.URL.String()))
+	return nil, &curlStringError{Command: strings.Join(args, " ")}
+}
+
+// shellQuote quotes the value so that it is a single shell word
+func shellQuote(v string) string {
+	return "'" + strings.Replace(v, "'", `'"'"'`, -1) + "'"
+}
+
+// OutputCurlString checks if the error came from a command run with
+// -output-curl-string and outputs the curl command if so. It returns
+// true if the error was handled.
+func (m *Meta) OutputCurlString(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	curlErr, ok := err.(*curlStringError)
+	if !ok {
+		return false
+	}
+
+	m.Ui.Output(curlErr.Command)
+	return true
+}
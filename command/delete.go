@@ -1,30 +1,57 @@
 package command
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"os"
 	"strings"
+	"sync"
+
+	"github.com/hashicorp/vault/api"
 )
 
 // DeleteCommand is a Command that puts data into the Vault.
 type DeleteCommand struct {
 	Meta
+
+	// A test stdin that can be used for tests
+	testStdin io.Reader
 }
 
 func (c *DeleteCommand) Run(args []string) int {
-	flags := c.Meta.FlagSet("delete", FlagSetDefault)
+	var stdin bool
+	var concurrency int
+	flags := c.Meta.FlagSet("delete",
+		FlagSetDefault|FlagSetOutputCurlString|FlagSetOutputFormat)
+	flags.BoolVar(&stdin, "stdin", false, "")
+	flags.IntVar(&concurrency, "concurrency", 10, "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
 	}
 
 	args = flags.Args()
-	if len(args) != 1 {
+	if stdin && len(args) != 0 {
+		c.Ui.Error("delete -stdin expects no arguments")
+		flags.Usage()
+		return 1
+	}
+	if !stdin && len(args) != 1 {
 		c.Ui.Error("delete expects one argument")
 		flags.Usage()
 		return 1
 	}
-
-	path := args[0]
+	if concurrency < 1 {
+		c.Ui.Error("concurrency must be at least one")
+		flags.Usage()
+		return 1
+	}
+	if err := c.CheckOutputFormat(); err != nil {
+		c.Ui.Error(err.Error())
+		flags.Usage()
+		return 1
+	}
 
 	client, err := c.Client()
 	if err != nil {
@@ -33,13 +60,109 @@ func (c *DeleteCommand) Run(args []string) int {
 		return 2
 	}
 
+	if stdin {
+		return c.deleteStdin(client, concurrency)
+	}
+
+	path := c.PrefixPath(args[0])
+
 	if _, err := client.Logical().Delete(path); err != nil {
+		if c.OutputCurlString(err) {
+			return 0
+		}
+		c.OutputFailure(fmt.Sprintf(
+			"Error deleting '%s': %s", path, err),
+			map[string]interface{}{"path": path, "error": err.Error()})
+		return 1
+	}
+
+	c.OutputSuccess(fmt.Sprintf("Success! Deleted '%s'", path),
+		map[string]interface{}{"path": path})
+	return 0
+}
+
+// deleteResult is the outcome of deleting a single path read from stdin
+type deleteResult struct {
+	path string
+	err  error
+}
+
+// deleteStdin deletes the newline-separated paths read from stdin, with
+// at most concurrency requests in flight, and reports a summary.
+func (c *DeleteCommand) deleteStdin(client *api.Client, concurrency int) int {
+	var stdin io.Reader = os.Stdin
+	if c.testStdin != nil {
+		stdin = c.testStdin
+	}
+
+	paths := make(chan string)
+	results := make(chan deleteResult)
+
+	// Start the workers that perform the deletes
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				_, err := client.Logical().Delete(path)
+				results <- deleteResult{path: path, err: err}
+			}
+		}()
+	}
+
+	// Feed the paths to the workers, skipping blank lines
+	var scanErr error
+	go func() {
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			path := strings.TrimSpace(scanner.Text())
+			if path == "" {
+				continue
+			}
+			paths <- c.PrefixPath(path)
+		}
+		scanErr = scanner.Err()
+		close(paths)
+		wg.Wait()
+		close(results)
+	}()
+
+	// Collect the results, the Ui is only used from this goroutine
+	deleted, failed := 0, 0
+	for result := range results {
+		if c.OutputCurlString(result.err) {
+			continue
+		}
+		if result.err != nil {
+			failed++
+			c.OutputFailure(fmt.Sprintf(
+				"Error deleting '%s': %s", result.path, result.err),
+				map[string]interface{}{
+					"path":  result.path,
+					"error": result.err.Error(),
+				})
+			continue
+		}
+		deleted++
+	}
+	if scanErr != nil {
 		c.Ui.Error(fmt.Sprintf(
-			"Error deleting '%s': %s", path, err))
+			"Error reading paths from stdin: %s", scanErr))
 		return 1
 	}
 
-	c.Ui.Output(fmt.Sprintf("Success! Deleted '%s'", path))
+	if c.flagOutputCurlString {
+		return 0
+	}
+
+	message := fmt.Sprintf("Deleted %d paths, %d failed", deleted, failed)
+	fields := map[string]interface{}{"deleted": deleted, "failed": failed}
+	if failed > 0 {
+		c.OutputFailure(message, fields)
+		return 1
+	}
+	c.OutputSuccess(message, fields)
 	return 0
 }
 
@@ -50,6 +173,7 @@ func (c *DeleteCommand) Synopsis() string {
 func (c *DeleteCommand) Help() string {
 	helpText := `
 Usage: vault delete [options] path
+       vault delete [options] -stdin
 
   Delete data (secrets or configuration) from Vault.
 
@@ -59,20 +183,25 @@ Usage: vault delete [options] path
   policy for the AWS backend. Use "vault help" for more details on
   whether delete is supported for a path and what the behavior is.
 
+  With -stdin, newline-separated paths are read from stdin and deleted.
+  A summary is output once all the paths have been processed.
+
 General Options:
 
-  -address=addr           The address of the Vault server.
+` + generalOptionsUsage() + `
+
+Delete Options:
+
+  -stdin                  Read the paths to delete from stdin, one per line.
 
-  -ca-cert=path           Path to a PEM encoded CA cert file to use to
-                          verify the Vault server SSL certificate.
+  -concurrency=10         The maximum number of deletes in flight when
+                          reading paths from stdin.
 
-  -ca-path=path           Path to a directory of PEM encoded CA cert files
-                          to verify the Vault server SSL certificate. If both
-                          -ca-cert and -ca-path are specified, -ca-path is used.
+  -output-curl-string     Output the equivalent curl command instead of
+                          performing the delete. The token is read by curl
+                          from the VAULT_TOKEN environment variable.
 
-  -tls-skip-verify        Do not verify TLS certificate. This is highly
-                          not recommended. This is especially not recommended
-                          for unsealing a vault.
+` + outputFormatUsage() + `
 
 `
 	return strings.TrimSpace(helpText)
@@ -1,6 +1,7 @@
 package command
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/vault/http"
@@ -53,3 +54,106 @@ func TestDelete(t *testing.T) {
 		t.Fatalf("bad: %#v", resp)
 	}
 }
+
+func TestDelete_stdin(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := http.TestServer(t, core)
+	defer ln.Close()
+
+	ui := new(cli.MockUi)
+	c := &DeleteCommand{
+		Meta: Meta{
+			ClientToken:  token,
+			Ui:           ui,
+			ForceAddress: addr,
+		},
+		testStdin: strings.NewReader("secret/foo\n\nsecret/bar\nsecret/baz\n"),
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	paths := []string{"secret/foo", "secret/bar", "secret/baz"}
+	data := map[string]interface{}{"value": "bar"}
+	for _, path := range paths {
+		if _, err := client.Logical().Write(path, data); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	args := []string{
+		"-stdin",
+		"-concurrency", "2",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "Deleted 3 paths, 0 failed") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+
+	for _, path := range paths {
+		resp, err := client.Logical().Read(path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if resp != nil {
+			t.Fatalf("bad: %#v", resp)
+		}
+	}
+}
+
+func TestDelete_outputCurlString(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &DeleteCommand{
+		Meta: Meta{
+			ClientToken: "foo",
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-address", "https://127.0.0.1:8200",
+		"-tls-skip-verify",
+		"-output-curl-string",
+		"secret/foo",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	expected := `curl -X DELETE --insecure ` +
+		`-H "X-Vault-Token: $VAULT_TOKEN" ` +
+		`'https://127.0.0.1:8200/v1/secret/foo'` + "\n"
+	if actual := ui.OutputWriter.String(); actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestDelete_pathPrefix(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &DeleteCommand{
+		Meta: Meta{
+			ClientToken: "foo",
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-address", "https://127.0.0.1:8200",
+		"-tls-skip-verify",
+		"-output-curl-string",
+		"-path-prefix", "secret",
+		"foo",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	expected := "'https://127.0.0.1:8200/v1/secret/foo'\n"
+	if actual := ui.OutputWriter.String(); !strings.HasSuffix(actual, expected) {
+		t.Fatalf("bad: %s", actual)
+	}
+}
@@ -11,9 +11,11 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/vault/api"
@@ -26,15 +28,21 @@ const EnvVaultAddress = "VAULT_ADDR"
 const EnvVaultCACert = "VAULT_CACERT"
 const EnvVaultCAPath = "VAULT_CAPATH"
 const EnvVaultInsecure = "VAULT_SKIP_VERIFY"
+const EnvVaultClientCert = "VAULT_CLIENT_CERT"
+const EnvVaultClientKey = "VAULT_CLIENT_KEY"
+const EnvVaultHTTPProxy = "VAULT_HTTP_PROXY"
+const EnvVaultPathPrefix = "VAULT_PATH_PREFIX"
 
 // FlagSetFlags is an enum to define what flags are present in the
 // default FlagSet returned by Meta.FlagSet.
 type FlagSetFlags uint
 
 const (
-	FlagSetNone    FlagSetFlags = 0
-	FlagSetServer  FlagSetFlags = 1 << iota
-	FlagSetDefault              = FlagSetServer
+	FlagSetNone   FlagSetFlags = 0
+	FlagSetServer FlagSetFlags = 1 << iota
+	FlagSetOutputCurlString
+	FlagSetOutputFormat
+	FlagSetDefault = FlagSetServer
 )
 
 // Meta contains the meta-options and functionality that nearly every
@@ -46,6 +54,7 @@ type Meta struct {
 	// The things below can be set, but aren't common
 	ForceAddress string  // Address to force for API clients
 	ForceConfig  *Config // Force a config, don't load from disk
+	UserAgent    string  // User-Agent of the API clients, if not the default
 
 	// These are set by the command line flags.
 	flagAddress  string
@@ -53,6 +62,22 @@ type Meta struct {
 	flagCAPath   string
 	flagInsecure bool
 
+	flagClientCert string
+	flagClientKey  string
+
+	flagProxy string
+
+	flagPathPrefix string
+
+	flagRetries       int
+	flagRetryInterval time.Duration
+	flagTimeout       time.Duration
+
+	flagOutputCurlString bool
+
+	flagFormat string
+	flagColor  bool
+
 	// These are internal and shouldn't be modified or access by anyone
 	// except Meta.
 	config *Config
@@ -71,6 +96,9 @@ func (m *Meta) Client() (*api.Client, error) {
 	if m.ForceAddress != "" {
 		config.Address = m.ForceAddress
 	}
+	if m.UserAgent != "" {
+		config.UserAgent = m.UserAgent
+	}
 	if v := os.Getenv(EnvVaultCACert); v != "" {
 		m.flagCACert = v
 	}
@@ -84,8 +112,30 @@ func (m *Meta) Client() (*api.Client, error) {
 			return nil, fmt.Errorf("Invalid value passed in for -insecure flag: %s", err)
 		}
 	}
-	// If we need custom TLS configuration, then set it
-	if m.flagCACert != "" || m.flagCAPath != "" || m.flagInsecure {
+	if v := os.Getenv(EnvVaultClientCert); v != "" {
+		m.flagClientCert = v
+	}
+	if v := os.Getenv(EnvVaultClientKey); v != "" {
+		m.flagClientKey = v
+	}
+	if (m.flagClientCert == "") != (m.flagClientKey == "") {
+		return nil, fmt.Errorf(
+			"Both -client-cert and -client-key must be specified to use a client certificate")
+	}
+	if v := os.Getenv(EnvVaultHTTPProxy); v != "" {
+		m.flagProxy = v
+	}
+	proxy := http.ProxyFromEnvironment
+	if m.flagProxy != "" {
+		proxyURL, err := parseProxyURL(m.flagProxy)
+		if err != nil {
+			return nil, err
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+	// If we need custom TLS or proxy configuration, then set it
+	if m.flagCACert != "" || m.flagCAPath != "" || m.flagInsecure ||
+		m.flagClientCert != "" || m.flagProxy != "" {
 		var certPool *x509.CertPool
 		var err error
 		if m.flagCACert != "" {
@@ -103,9 +153,17 @@ func (m *Meta) Client() (*api.Client, error) {
 			RootCAs:            certPool,
 		}
 
+		if m.flagClientCert != "" {
+			cert, err := tls.LoadX509KeyPair(m.flagClientCert, m.flagClientKey)
+			if err != nil {
+				return nil, fmt.Errorf("Error loading client certificate: %s", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+
 		client := *http.DefaultClient
 		client.Transport = &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
+			Proxy: proxy,
 			Dial: (&net.Dialer{
 				Timeout:   30 * time.Second,
 				KeepAlive: 30 * time.Second,
@@ -117,6 +175,36 @@ func (m *Meta) Client() (*api.Client, error) {
 		config.HttpClient = &client
 	}
 
+	// Retry requests that fail due to a connection error or a gateway
+	// error, for example from a load balancer
+	if m.flagRetries > 0 {
+		client := *config.HttpClient
+		client.Transport = &retryTransport{
+			Transport: client.Transport,
+			Retries:   m.flagRetries,
+			Interval:  m.flagRetryInterval,
+		}
+		config.HttpClient = &client
+	}
+	if m.flagTimeout > 0 {
+		client := *config.HttpClient
+		client.Timeout = m.flagTimeout
+		config.HttpClient = &client
+	}
+
+	// Build curl commands rather than performing the requests
+	if m.flagOutputCurlString {
+		config.HttpClient = &http.Client{
+			Transport: &curlTransport{
+				CACert:     m.flagCACert,
+				CAPath:     m.flagCAPath,
+				ClientCert: m.flagClientCert,
+				ClientKey:  m.flagClientKey,
+				Insecure:   m.flagInsecure,
+			},
+		}
+	}
+
 	// Build the client
 	client, err := api.NewClient(config)
 	if err != nil {
@@ -152,6 +240,25 @@ func (m *Meta) Client() (*api.Client, error) {
 	return client, nil
 }
 
+// PrefixPath returns the given path with the prefix from -path-prefix or
+// VAULT_PATH_PREFIX prepended, if one is set. Leading slashes are removed.
+func (m *Meta) PrefixPath(path string) string {
+	prefix := m.flagPathPrefix
+	if prefix == "" {
+		prefix = os.Getenv(EnvVaultPathPrefix)
+	}
+
+	path = strings.TrimLeft(path, "/")
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return path
+	}
+	if path == "" {
+		return prefix
+	}
+	return prefix + "/" + path
+}
+
 // Config loads the configuration and returns it. If the configuration
 // is already loaded, it is returned.
 func (m *Meta) Config() (*Config, error) {
@@ -186,6 +293,26 @@ func (m *Meta) FlagSet(n string, fs FlagSetFlags) *flag.FlagSet {
 		f.StringVar(&m.flagCAPath, "ca-path", "", "")
 		f.BoolVar(&m.flagInsecure, "insecure", false, "")
 		f.BoolVar(&m.flagInsecure, "tls-skip-verify", false, "")
+		f.StringVar(&m.flagClientCert, "client-cert", "", "")
+		f.StringVar(&m.flagClientKey, "client-key", "", "")
+		f.StringVar(&m.flagProxy, "proxy", "", "")
+		f.StringVar(&m.flagPathPrefix, "path-prefix", "", "")
+		f.IntVar(&m.flagRetries, "retries", 0, "")
+		f.DurationVar(&m.flagRetryInterval, "retry-interval", time.Second, "")
+		f.DurationVar(&m.flagTimeout, "timeout", 0, "")
+	}
+
+	// FlagSetOutputCurlString enables outputting the equivalent curl
+	// command rather than performing the request.
+	if fs&FlagSetOutputCurlString != 0 {
+		f.BoolVar(&m.flagOutputCurlString, "output-curl-string", false, "")
+	}
+
+	// FlagSetOutputFormat enables the output format and color of the
+	// results reported with OutputSuccess and OutputFailure.
+	if fs&FlagSetOutputFormat != 0 {
+		f.StringVar(&m.flagFormat, "format", OutputFormatTable, "")
+		f.BoolVar(&m.flagColor, "color", false, "")
 	}
 
 	// Create an io.Writer that writes to our Ui properly for errors.
@@ -204,6 +331,74 @@ func (m *Meta) FlagSet(n string, fs FlagSetFlags) *flag.FlagSet {
 	return f
 }
 
+// parseProxyURL parses and validates the URL given with -proxy.
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid proxy URL: %s", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf(
+			"Invalid proxy URL %q: scheme must be http, https, or socks5", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("Invalid proxy URL %q: missing host", raw)
+	}
+	return u, nil
+}
+
+// generalOptionsUsage returns the usage documentation for the general
+// options that are added to the FlagSet with FlagSetServer.
+func generalOptionsUsage() string {
+	general := `
+  -address=addr           The address of the Vault server.
+
+  -ca-cert=path           Path to a PEM encoded CA cert file to use to
+                          verify the Vault server SSL certificate.
+
+  -ca-path=path           Path to a directory of PEM encoded CA cert files
+                          to verify the Vault server SSL certificate. If both
+                          -ca-cert and -ca-path are specified, -ca-path is used.
+
+  -tls-skip-verify        Do not verify TLS certificate. This is highly
+                          not recommended. This is especially not recommended
+                          for unsealing a vault.
+
+  -client-cert=path       Path to a PEM encoded client certificate for TLS
+                          authentication to the Vault server. Must be
+                          used together with -client-key.
+
+  -client-key=path        Path to the unencrypted PEM encoded private key
+                          matching the client certificate.
+
+  -proxy=url              The proxy to connect to the Vault server through,
+                          such as http://proxy:3128 or socks5://bastion:1080.
+                          This can also be specified via the VAULT_HTTP_PROXY
+                          environment variable.
+
+  -path-prefix=prefix     A prefix prepended to the paths given to the read,
+                          write, and delete commands. This can also be
+                          specified via the VAULT_PATH_PREFIX environment
+                          variable.
+
+  -retries=0              The number of times to retry a request that fails
+                          due to a connection error or a gateway error (502,
+                          503, or 504). Reads and deletes are retried, while
+                          writes are only retried if they didn't reach a
+                          server, or were answered with a 503 such as by a
+                          sealed Vault.
+
+  -retry-interval=1s      The interval before the first retry. The interval
+                          doubles with each retry and is randomly jittered.
+
+  -timeout=0              The time limit for each request to the Vault
+                          server, including retries. No limit by default.
+`
+	return strings.Trim(general, "\n")
+}
+
 // TokenHelper returns the token helper that is configured for Vault.
 func (m *Meta) TokenHelper() (*token.Helper, error) {
 	config, err := m.Config()
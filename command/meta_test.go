@@ -2,10 +2,16 @@ package command
 
 import (
 	"flag"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFlagSet(t *testing.T) {
@@ -19,7 +25,8 @@ func TestFlagSet(t *testing.T) {
 		},
 		{
 			FlagSetServer,
-			[]string{"address", "ca-cert", "ca-path", "insecure", "tls-skip-verify"},
+			[]string{"address", "ca-cert", "ca-path", "insecure", "tls-skip-verify",
+				"client-cert", "client-key", "proxy", "path-prefix", "retries", "retry-interval", "timeout"},
 		},
 	}
 
@@ -64,3 +71,284 @@ func TestEnvSettings(t *testing.T) {
 		t.Fatalf("bad: %s", m.flagAddress)
 	}
 }
+
+func TestEnvSettings_clientCert(t *testing.T) {
+	os.Setenv("VAULT_CLIENT_CERT", "/path/to/fake/client.crt")
+	os.Setenv("VAULT_CLIENT_KEY", "/path/to/fake/client.key")
+	defer os.Setenv("VAULT_CLIENT_CERT", "")
+	defer os.Setenv("VAULT_CLIENT_KEY", "")
+	var m Meta
+
+	// The fake paths cause an error loading the key pair; just check
+	// the flag settings
+	if _, err := m.Client(); err == nil {
+		t.Fatal("should error")
+	}
+
+	if m.flagClientCert != "/path/to/fake/client.crt" {
+		t.Fatalf("bad: %s", m.flagClientCert)
+	}
+	if m.flagClientKey != "/path/to/fake/client.key" {
+		t.Fatalf("bad: %s", m.flagClientKey)
+	}
+}
+
+func TestClient_clientCertWithoutKey(t *testing.T) {
+	m := Meta{flagClientCert: "/path/to/fake/client.crt"}
+	if _, err := m.Client(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	var attempts int
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	client := &http.Client{
+		Transport: &retryTransport{
+			Retries:  3,
+			Interval: time.Millisecond,
+		},
+	}
+	resp, err := client.Post(ts.URL, "application/json", strings.NewReader("foo"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("bad: %d", resp.StatusCode)
+	}
+	if attempts != 3 {
+		t.Fatalf("bad: %d", attempts)
+	}
+	for _, b := range bodies {
+		if b != "foo" {
+			t.Fatalf("bad: %#v", bodies)
+		}
+	}
+}
+
+func TestRetryTransport_exhausted(t *testing.T) {
+	var attempts int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	client := &http.Client{
+		Transport: &retryTransport{
+			Retries:  2,
+			Interval: time.Millisecond,
+		},
+	}
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("bad: %d", resp.StatusCode)
+	}
+	if attempts != 3 {
+		t.Fatalf("bad: %d", attempts)
+	}
+}
+
+func TestRetryTransport_notRetryable(t *testing.T) {
+	var attempts int
+	var status int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	client := &http.Client{
+		Transport: &retryTransport{
+			Retries:  2,
+			Interval: time.Millisecond,
+		},
+	}
+	cases := []struct {
+		Method string
+		Status int
+	}{
+		// The write may have been handled behind the gateway
+		{"PUT", http.StatusBadGateway},
+		{"POST", http.StatusGatewayTimeout},
+
+		// and other server errors aren't transient
+		{"GET", http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		attempts, status = 0, tc.Status
+		req, err := http.NewRequest(tc.Method, ts.URL, strings.NewReader("foo"))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.Status || attempts != 1 {
+			t.Fatalf("bad: %s %d: %d attempts", tc.Method, tc.Status, attempts)
+		}
+	}
+}
+
+// countingTransport is an http.RoundTripper that counts the requests it
+// sends.
+type countingTransport struct {
+	http.Transport
+	attempts int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.attempts++
+	return t.Transport.RoundTrip(req)
+}
+
+func TestRetryTransport_dial(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	// A write that could not connect is retried
+	transport := &countingTransport{}
+	client := &http.Client{
+		Transport: &retryTransport{
+			Transport: transport,
+			Retries:   2,
+			Interval:  time.Millisecond,
+		},
+	}
+	if _, err := client.Post("http://"+addr, "application/json", strings.NewReader("foo")); err == nil {
+		t.Fatalf("expected error")
+	}
+	if transport.attempts != 3 {
+		t.Fatalf("bad: %d", transport.attempts)
+	}
+}
+
+func TestRetryTransport_timeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	// The timeout of the client stops the wait for the next retry
+	client := &http.Client{
+		Transport: &retryTransport{
+			Retries:  2,
+			Interval: time.Hour,
+		},
+		Timeout: 50 * time.Millisecond,
+	}
+	start := time.Now()
+	if _, err := client.Get(ts.URL); err == nil {
+		t.Fatalf("expected error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("bad: %s", elapsed)
+	}
+}
+
+func TestClient_proxy(t *testing.T) {
+	var proxied string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	m := Meta{
+		ClientToken:  "foo",
+		ForceAddress: "http://vault.example.com:8200",
+		flagProxy:    ts.URL,
+	}
+	client, err := m.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := client.Logical().Delete("secret/foo"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := "http://vault.example.com:8200/v1/secret/foo"
+	if proxied != expected {
+		t.Fatalf("bad: %s", proxied)
+	}
+}
+
+func TestParseProxyURL(t *testing.T) {
+	cases := map[string]bool{
+		"http://proxy:3128":     true,
+		"https://proxy:3128":    true,
+		"socks5://bastion:1080": true,
+		"ftp://proxy:21":        false,
+		"proxy:3128":            false,
+		"http://":               false,
+	}
+
+	for raw, ok := range cases {
+		_, err := parseProxyURL(raw)
+		if (err == nil) != ok {
+			t.Fatalf("%s: err: %v", raw, err)
+		}
+	}
+}
+
+func TestPrefixPath(t *testing.T) {
+	cases := []struct {
+		Prefix   string
+		Path     string
+		Expected string
+	}{
+		{"", "secret/foo", "secret/foo"},
+		{"", "/secret/foo", "secret/foo"},
+		{"teams/payments", "secret/foo", "teams/payments/secret/foo"},
+		{"/teams/payments/", "/secret/foo", "teams/payments/secret/foo"},
+		{"teams/payments", "", "teams/payments"},
+	}
+
+	for _, tc := range cases {
+		m := Meta{flagPathPrefix: tc.Prefix}
+		if actual := m.PrefixPath(tc.Path); actual != tc.Expected {
+			t.Fatalf("%q %q: bad: %s", tc.Prefix, tc.Path, actual)
+		}
+	}
+}
+
+func TestPrefixPath_env(t *testing.T) {
+	os.Setenv("VAULT_PATH_PREFIX", "teams/payments")
+	defer os.Setenv("VAULT_PATH_PREFIX", "")
+
+	var m Meta
+	if actual := m.PrefixPath("secret/foo"); actual != "teams/payments/secret/foo" {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	m.flagPathPrefix = "teams/billing"
+	if actual := m.PrefixPath("secret/foo"); actual != "teams/billing/secret/foo" {
+		t.Fatalf("bad: %s", actual)
+	}
+}
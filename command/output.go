@@ -0,0 +1,92 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mitchellh/cli"
+)
+
+// The output formats of the commands that take the -format flag of
+// FlagSetOutputFormat, the same as those of the commands that output
+// secrets. "text" is accepted as OutputFormatTable.
+const (
+	OutputFormatTable = "table"
+	OutputFormatText  = "text"
+	OutputFormatJSON  = "json"
+)
+
+// CheckOutputFormat returns an error if the format given with -format
+// isn't supported.
+func (m *Meta) CheckOutputFormat() error {
+	switch m.flagFormat {
+	case "", OutputFormatTable, OutputFormatText, OutputFormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("Invalid output format: %s", m.flagFormat)
+	}
+}
+
+// OutputSuccess reports that the command succeeded. The message is written
+// to the output, in green with -color. In JSON, an object with
+// the fields, "success" set to true, and the message is written instead.
+func (m *Meta) OutputSuccess(message string, fields map[string]interface{}) {
+	m.outputResult(true, message, fields)
+}
+
+// OutputFailure reports that the command, or a part of it, failed, like
+// OutputSuccess but to the error output, in red with -color, and with
+// "success" set to false.
+func (m *Meta) OutputFailure(message string, fields map[string]interface{}) {
+	m.outputResult(false, message, fields)
+}
+
+func (m *Meta) outputResult(success bool, message string, fields map[string]interface{}) {
+	ui := m.Ui
+	if m.flagFormat == OutputFormatJSON {
+		result := make(map[string]interface{}, len(fields)+2)
+		for k, v := range fields {
+			result[k] = v
+		}
+		result["success"] = success
+		result["message"] = message
+
+		raw, err := json.Marshal(result)
+		if err != nil {
+			ui.Error(fmt.Sprintf("Error encoding output: %s", err))
+			return
+		}
+		message = string(raw)
+	} else if m.flagColor {
+		ui = &cli.ColoredUi{
+			OutputColor: cli.UiColorGreen,
+			InfoColor:   cli.UiColorNone,
+			ErrorColor:  cli.UiColorRed,
+			WarnColor:   cli.UiColorYellow,
+			Ui:          m.Ui,
+		}
+	}
+
+	if success {
+		ui.Output(message)
+	} else {
+		ui.Error(message)
+	}
+}
+
+// formatUsage returns the usage of the -format flag, shared by the
+// commands that output secrets and those of FlagSetOutputFormat.
+func formatUsage() string {
+	return `  -format=table           The format for output, "table" or "json". By
+                          default it is a whitespace-delimited table. In
+                          JSON, the results of commands such as delete are
+                          objects with "success", "message", and details.`
+}
+
+// outputFormatUsage returns the usage of the flags of FlagSetOutputFormat.
+func outputFormatUsage() string {
+	return formatUsage() + `
+
+  -color                  Color the results, green for successes and red for
+                          failures, unless the format is json.`
+}
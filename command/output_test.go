@@ -0,0 +1,63 @@
+package command
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestMeta_OutputSuccess(t *testing.T) {
+	ui := new(cli.MockUi)
+	m := &Meta{Ui: ui}
+	m.OutputSuccess("Success!", map[string]interface{}{"path": "secret/foo"})
+	if actual := ui.OutputWriter.String(); actual != "Success!\n" {
+		t.Fatalf("bad: %q", actual)
+	}
+
+	ui = new(cli.MockUi)
+	m = &Meta{Ui: ui, flagColor: true}
+	m.OutputFailure("Failed!", nil)
+	if actual := ui.ErrorWriter.String(); actual != "\033[0;31mFailed!\033[0m\n" {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestMeta_OutputSuccess_json(t *testing.T) {
+	ui := new(cli.MockUi)
+
+	// JSON is never colored
+	m := &Meta{Ui: ui, flagFormat: OutputFormatJSON, flagColor: true}
+	m.OutputFailure("Failed!", map[string]interface{}{"path": "secret/foo"})
+	if ui.OutputWriter.Len() != 0 {
+		t.Fatalf("bad: %q", ui.OutputWriter.String())
+	}
+
+	var actual map[string]interface{}
+	if err := json.Unmarshal(ui.ErrorWriter.Bytes(), &actual); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := map[string]interface{}{
+		"success": false,
+		"message": "Failed!",
+		"path":    "secret/foo",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestMeta_CheckOutputFormat(t *testing.T) {
+	for _, format := range []string{"", "table", "text", "json"} {
+		m := &Meta{flagFormat: format}
+		if err := m.CheckOutputFormat(); err != nil {
+			t.Fatalf("%s: err: %s", format, err)
+		}
+	}
+
+	m := &Meta{flagFormat: "yaml"}
+	if err := m.CheckOutputFormat(); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -13,7 +13,7 @@ type ReadCommand struct {
 func (c *ReadCommand) Run(args []string) int {
 	var format string
 	var field string
-	flags := c.Meta.FlagSet("read", FlagSetDefault)
+	flags := c.Meta.FlagSet("read", FlagSetDefault|FlagSetOutputCurlString)
 	flags.StringVar(&format, "format", "table", "")
 	flags.StringVar(&field, "field", "", "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
@@ -28,10 +28,7 @@ func (c *ReadCommand) Run(args []string) int {
 		return 1
 	}
 
-	path := args[0]
-	if path[0] == '/' {
-		path = path[1:]
-	}
+	path := c.PrefixPath(args[0])
 
 	client, err := c.Client()
 	if err != nil {
@@ -42,6 +39,9 @@ func (c *ReadCommand) Run(args []string) int {
 
 	secret, err := client.Logical().Read(path)
 	if err != nil {
+		if c.OutputCurlString(err) {
+			return 0
+		}
 		c.Ui.Error(fmt.Sprintf(
 			"Error reading %s: %s", path, err))
 		return 1
@@ -84,27 +84,19 @@ Usage: vault read [options] path
 
 General Options:
 
-  -address=addr           The address of the Vault server.
-
-  -ca-cert=path           Path to a PEM encoded CA cert file to use to
-                          verify the Vault server SSL certificate.
-
-  -ca-path=path           Path to a directory of PEM encoded CA cert files
-                          to verify the Vault server SSL certificate. If both
-                          -ca-cert and -ca-path are specified, -ca-path is used.
-
-  -tls-skip-verify        Do not verify TLS certificate. This is highly
-                          not recommended. This is especially not recommended
-                          for unsealing a vault.
+` + generalOptionsUsage() + `
 
 Read Options:
 
-  -format=table           The format for output. By default it is a whitespace-
-                          delimited table. This can also be json.
+` + formatUsage() + `
 
   -field=field            If included, the raw value of the specified field
   						  will be output raw to stdout.
 
+  -output-curl-string     Output the equivalent curl command instead of
+                          performing the read. The token is read by curl
+                          from the VAULT_TOKEN environment variable.
+
 `
 	return strings.TrimSpace(helpText)
 }
@@ -0,0 +1,179 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// errRetryCanceled is returned when a request is canceled, such as by the
+// timeout of the client, while it waits to be retried.
+var errRetryCanceled = errors.New("net/http: request canceled while waiting to retry")
+
+// retryTransport is an http.RoundTripper that retries requests that fail
+// with a connection error or a gateway error (502, 503, or 504). The wait
+// between attempts starts at Interval, doubles with each retry, and has
+// up to half of itself added as random jitter.
+//
+// Requests that may have been handled are only retried if their method is
+// idempotent, since most writes to Vault, such as creating a token or
+// generating credentials, can't be repeated safely. Requests that could
+// not connect, and those answered with a 503 as Vault does while it is
+// sealed, never reached a server that handled them and are retried
+// whatever their method.
+//
+// The transport implements CancelRequest, which the timeout of an
+// http.Client requires, and a canceled request isn't retried.
+type retryTransport struct {
+	Transport http.RoundTripper
+	Retries   int
+	Interval  time.Duration
+
+	l        sync.Mutex
+	inFlight map[*http.Request]*retryState
+}
+
+// retryState is the state of a request being retried.
+type retryState struct {
+	// attempt is the copy of the request sent by the current attempt.
+	attempt *http.Request
+
+	canceled bool
+	cancelCh chan struct{}
+}
+
+type canceler interface {
+	CancelRequest(*http.Request)
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.transport()
+
+	// Buffer the body so that it can be sent again on each attempt
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	state := &retryState{cancelCh: make(chan struct{})}
+	t.l.Lock()
+	if t.inFlight == nil {
+		t.inFlight = make(map[*http.Request]*retryState)
+	}
+	t.inFlight[req] = state
+	t.l.Unlock()
+	defer func() {
+		t.l.Lock()
+		delete(t.inFlight, req)
+		t.l.Unlock()
+	}()
+
+	for attempt := 0; ; attempt++ {
+		// Each attempt sends its own copy of the request, since the
+		// request of the caller must not be modified
+		r := new(http.Request)
+		*r = *req
+		if body != nil {
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		t.l.Lock()
+		canceled := state.canceled
+		state.attempt = r
+		t.l.Unlock()
+		if canceled {
+			return nil, errRetryCanceled
+		}
+
+		resp, err := transport.RoundTrip(r)
+		if attempt >= t.Retries || !retryable(req.Method, resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(t.backoff(attempt)):
+		case <-state.cancelCh:
+			return nil, errRetryCanceled
+		}
+	}
+}
+
+// CancelRequest cancels the attempt in flight of the request, and stops
+// it from being retried.
+func (t *retryTransport) CancelRequest(req *http.Request) {
+	var attempt *http.Request
+	t.l.Lock()
+	if state, ok := t.inFlight[req]; ok {
+		if !state.canceled {
+			state.canceled = true
+			close(state.cancelCh)
+		}
+		attempt = state.attempt
+	}
+	t.l.Unlock()
+
+	if c, ok := t.transport().(canceler); ok && attempt != nil {
+		c.CancelRequest(attempt)
+	}
+}
+
+func (t *retryTransport) transport() http.RoundTripper {
+	if t.Transport == nil {
+		return http.DefaultTransport
+	}
+	return t.Transport
+}
+
+// retryable returns whether a request with the given method that ended
+// with the given response or error can be retried.
+func retryable(method string, resp *http.Response, err error) bool {
+	if err != nil {
+		if opErr, ok := err.(*net.OpError); ok && opErr.Op == "dial" {
+			return true
+		}
+		return idempotent(method)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent(method)
+	default:
+		return false
+	}
+}
+
+// idempotent returns whether requests with the given method can be sent
+// again without changing their effect. PUT and POST aren't, as Vault
+// uses them for operations such as creating tokens.
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "DELETE":
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff returns the time to wait before the given retry attempt.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	wait := t.Interval << uint(attempt)
+	if wait <= 0 {
+		return 0
+	}
+	return wait + time.Duration(rand.Int63n(int64(wait)/2+1))
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/armon/go-metrics"
 	"github.com/hashicorp/logutils"
 	"github.com/hashicorp/vault/audit"
+	auditPlugin "github.com/hashicorp/vault/builtin/audit/plugin"
 	"github.com/hashicorp/vault/command/server"
 	"github.com/hashicorp/vault/helper/flag-slice"
 	"github.com/hashicorp/vault/helper/gated-writer"
@@ -111,6 +112,28 @@ func (c *ServerCommand) Run(args []string) int {
 		return 1
 	}
 
+	// In dev mode, the requests are printed by an audit backend enabled
+	// once the core is unsealed, which writes through the log gate
+	auditBackends := c.AuditBackends
+	var devAudit bool
+	if dev {
+		devAudit, err = devAuditEnabled()
+		if err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
+	}
+	if devAudit {
+		auditBackends = make(map[string]audit.Factory, len(c.AuditBackends)+1)
+		for k, f := range c.AuditBackends {
+			auditBackends[k] = f
+		}
+		color := os.Getenv("TERM") != "dumb"
+		auditBackends[devAuditType] = func(map[string]string) (audit.Backend, error) {
+			return newDevAuditBackend(logGate, color), nil
+		}
+	}
+
 	// Attempt to detect the advertise address possible
 	if detect, ok := backend.(physical.AdvertiseDetect); ok && config.Backend.AdvertiseAddr == "" {
 		advertise, err := c.detectAdvertise(detect, config)
@@ -123,15 +146,31 @@ func (c *ServerCommand) Run(args []string) int {
 		}
 	}
 
+	// Parse the interval at which the audit inventory is logged again
+	var inventoryInterval time.Duration
+	if config.AuditInventoryInterval != "" {
+		inventoryInterval, err = time.ParseDuration(config.AuditInventoryInterval)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Error parsing audit_inventory_interval: %s", err))
+			return 1
+		}
+	}
+
+	// Plugin audit backends only run the binaries of the plugin directory
+	auditPlugin.SetDirectory(config.PluginDirectory)
+
 	// Initialize the core
 	core, err := vault.NewCore(&vault.CoreConfig{
-		AdvertiseAddr:      config.Backend.AdvertiseAddr,
-		Physical:           backend,
-		AuditBackends:      c.AuditBackends,
-		CredentialBackends: c.CredentialBackends,
-		LogicalBackends:    c.LogicalBackends,
-		Logger:             logger,
-		DisableMlock:       config.DisableMlock,
+		AdvertiseAddr:          config.Backend.AdvertiseAddr,
+		Physical:               backend,
+		AuditBackends:          auditBackends,
+		CredentialBackends:     c.CredentialBackends,
+		LogicalBackends:        c.LogicalBackends,
+		Logger:                 logger,
+		DisableMlock:           config.DisableMlock,
+		RequireAudit:           config.RequireAudit,
+		AuditInventoryInterval: inventoryInterval,
 	})
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf("Error initializing core: %s", err))
@@ -146,6 +185,13 @@ func (c *ServerCommand) Run(args []string) int {
 				"Error initializing dev mode: %s", err))
 			return 1
 		}
+		if devAudit {
+			if err := enableDevAudit(core, init.RootToken); err != nil {
+				c.Ui.Error(fmt.Sprintf(
+					"Error enabling the dev audit backend: %s", err))
+				return 1
+			}
+		}
 
 		c.Ui.Output(fmt.Sprintf(
 			"==> WARNING: Dev mode is enabled!\n\n"+
@@ -158,9 +204,12 @@ func (c *ServerCommand) Run(args []string) int {
 				"    export VAULT_ADDR='http://127.0.0.1:8200'\n\n"+
 				"The unseal key and root token are reproduced below in case you\n"+
 				"want to seal/unseal the Vault or play with authentication.\n\n"+
-				"Unseal Key: %s\nRoot Token: %s\n",
+				"Unseal Key: %s\nRoot Token: %s\n\n"+
+				"The requests are printed below as they are handled, unless\n"+
+				"the %s environment variable is set to true.\n",
 			hex.EncodeToString(init.SecretShares[0]),
 			init.RootToken,
+			EnvVaultDevAuditDisable,
 		))
 	}
 
@@ -416,8 +465,9 @@ General Options:
                       files with a ".hcl" or ".json" suffix will be loaded.
 
   -dev                Enables Dev mode. In this mode, Vault is completely
-                      in-memory and unsealed. Do not run the Dev server in
-                      production!
+                      in-memory and unsealed. The requests are printed as
+                      they are handled, unless VAULT_DEV_AUDIT_DISABLE is
+                      set to true. Do not run the Dev server in production!
 
   -log-level=info     Log verbosity. Defaults to "info", will be outputted
                       to stderr.
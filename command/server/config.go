@@ -17,9 +17,12 @@ type Config struct {
 	Listeners []*Listener `hcl:"-"`
 	Backend   *Backend    `hcl:"-"`
 
-	DisableMlock bool   `hcl:"disable_mlock"`
-	StatsiteAddr string `hcl:"statsite_addr"`
-	StatsdAddr   string `hcl:"statsd_addr"`
+	DisableMlock           bool   `hcl:"disable_mlock"`
+	RequireAudit           bool   `hcl:"require_audit"`
+	AuditInventoryInterval string `hcl:"audit_inventory_interval"`
+	PluginDirectory        string `hcl:"plugin_directory"`
+	StatsiteAddr           string `hcl:"statsite_addr"`
+	StatsdAddr             string `hcl:"statsd_addr"`
 }
 
 // DevConfig is a Config that is used for dev mode of Vault.
@@ -78,6 +81,18 @@ func (c *Config) Merge(c2 *Config) *Config {
 		result.Backend = c2.Backend
 	}
 
+	result.RequireAudit = c.RequireAudit || c2.RequireAudit
+
+	result.AuditInventoryInterval = c.AuditInventoryInterval
+	if c2.AuditInventoryInterval != "" {
+		result.AuditInventoryInterval = c2.AuditInventoryInterval
+	}
+
+	result.PluginDirectory = c.PluginDirectory
+	if c2.PluginDirectory != "" {
+		result.PluginDirectory = c2.PluginDirectory
+	}
+
 	if c2.StatsiteAddr != "" {
 		result.StatsiteAddr = c2.StatsiteAddr
 	}
@@ -29,9 +29,11 @@ func TestLoadConfigFile(t *testing.T) {
 			},
 		},
 
-		DisableMlock: true,
-		StatsiteAddr: "foo",
-		StatsdAddr:   "bar",
+		DisableMlock:           true,
+		AuditInventoryInterval: "24h",
+		PluginDirectory:        "/etc/vault/plugins",
+		StatsiteAddr:           "foo",
+		StatsdAddr:             "bar",
 	}
 	if !reflect.DeepEqual(config, expected) {
 		t.Fatalf("bad: %#v", config)
@@ -1,6 +1,8 @@
 disable_mlock = true
 statsd_addr = "bar"
 statsite_addr = "foo"
+audit_inventory_interval = "24h"
+plugin_directory = "/etc/vault/plugins"
 
 listener "tcp" {
     address = "127.0.0.1:443"
@@ -0,0 +1,134 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/vault"
+	"golang.org/x/net/context"
+)
+
+// EnvVaultDevAuditDisable can be set to true to stop the dev server from
+// printing the requests it handles.
+const EnvVaultDevAuditDisable = "VAULT_DEV_AUDIT_DISABLE"
+
+// devAuditType is the type, and the path, of the audit backend enabled by
+// the dev server to print the requests it handles.
+const devAuditType = "dev-console"
+
+// devAuditMaxPending is the number of requests whose start is tracked by
+// the dev audit backend. Requests that fail before their response is
+// audited are never removed, so the tracking is reset beyond it.
+const devAuditMaxPending = 1000
+
+// ANSI escape codes of the colors of the statuses.
+const (
+	colorReset  = "\033[0m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorRed    = "\033[31m"
+)
+
+// devAuditEnabled returns whether the dev server prints the requests it
+// handles, which it does unless EnvVaultDevAuditDisable is true.
+func devAuditEnabled() (bool, error) {
+	v := os.Getenv(EnvVaultDevAuditDisable)
+	if v == "" {
+		return true, nil
+	}
+	disable, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %s", EnvVaultDevAuditDisable, v)
+	}
+	return !disable, nil
+}
+
+// enableDevAudit enables the dev audit backend with the root token.
+func enableDevAudit(core *vault.Core, token string) error {
+	_, err := core.HandleRequest(&logical.Request{
+		Operation:   logical.WriteOperation,
+		Path:        "sys/audit/" + devAuditType,
+		ClientToken: token,
+		Data: map[string]interface{}{
+			"type":        devAuditType,
+			"description": "prints the requests of the dev server",
+		},
+	})
+	return err
+}
+
+// devAuditBackend is the audit backend of the dev server. It writes a
+// line for every response, with its status, operation, path and duration,
+// so that developers can follow the traffic as it happens. Nothing
+// sensitive is written, so nothing is hashed.
+type devAuditBackend struct {
+	w     io.Writer
+	color bool
+
+	// l protects the start of the requests whose response is pending.
+	// The requests are tracked by pointer, since the core passes the same
+	// request to LogRequest and LogResponse.
+	l       sync.Mutex
+	pending map[*logical.Request]time.Time
+}
+
+func newDevAuditBackend(w io.Writer, color bool) *devAuditBackend {
+	return &devAuditBackend{
+		w:       w,
+		color:   color,
+		pending: make(map[*logical.Request]time.Time),
+	}
+}
+
+func (b *devAuditBackend) LogRequest(
+	ctx context.Context, auth *logical.Auth, req *logical.Request) error {
+	b.l.Lock()
+	defer b.l.Unlock()
+	if len(b.pending) >= devAuditMaxPending {
+		b.pending = make(map[*logical.Request]time.Time)
+	}
+	b.pending[req] = time.Now()
+	return nil
+}
+
+func (b *devAuditBackend) LogResponse(ctx context.Context, auth *logical.Auth,
+	req *logical.Request, resp *logical.Response, err error) error {
+	now := time.Now()
+	b.l.Lock()
+	start, ok := b.pending[req]
+	delete(b.pending, req)
+	b.l.Unlock()
+
+	duration := "-"
+	if ok {
+		d := now.Sub(start)
+		duration = (d - d%time.Microsecond).String()
+	}
+
+	status, color := devAuditStatus(resp, err)
+	status = fmt.Sprintf("%-7s", status)
+	if b.color {
+		status = color + status + colorReset
+	}
+
+	_, werr := fmt.Fprintf(b.w, "%s [AUDIT] %s %-8s %s (%s)\n",
+		now.Format("2006/01/02 15:04:05"), status, req.Operation, req.Path, duration)
+	return werr
+}
+
+// devAuditStatus returns the status of a response, and its color.
+func devAuditStatus(resp *logical.Response, err error) (string, string) {
+	switch {
+	case err == logical.ErrPermissionDenied:
+		return "denied", colorYellow
+	case err != nil || (resp != nil && resp.IsError()):
+		return "error", colorRed
+	default:
+		return "success", colorGreen
+	}
+}
@@ -0,0 +1,83 @@
+package command
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+func TestDevAuditBackend(t *testing.T) {
+	var buf bytes.Buffer
+	b := newDevAuditBackend(&buf, false)
+
+	req := &logical.Request{Operation: logical.ReadOperation, Path: "secret/foo"}
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("bad: %q", buf.String())
+	}
+	if err := b.LogResponse(context.Background(), nil, req, nil, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(b.pending) != 0 {
+		t.Fatalf("bad: %#v", b.pending)
+	}
+
+	// The duration of a request that wasn't seen is unknown
+	req = &logical.Request{Operation: logical.WriteOperation, Path: "secret/bar"}
+	if err := b.LogResponse(context.Background(), nil, req, nil, logical.ErrPermissionDenied); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expected := regexp.MustCompile(`^\S+ \S+ \[AUDIT\] success read     secret/foo \(\S+s\)
+\S+ \S+ \[AUDIT\] denied  write    secret/bar \(-\)
+$`)
+	if !expected.MatchString(buf.String()) {
+		t.Fatalf("bad: %q", buf.String())
+	}
+}
+
+func TestDevAuditBackend_color(t *testing.T) {
+	var buf bytes.Buffer
+	b := newDevAuditBackend(&buf, true)
+
+	req := &logical.Request{Operation: logical.ReadOperation, Path: "secret/foo"}
+	resp := logical.ErrorResponse("failed")
+	if err := b.LogResponse(context.Background(), nil, req, resp, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(colorRed+"error  "+colorReset+" read")) {
+		t.Fatalf("bad: %q", buf.String())
+	}
+}
+
+func TestDevAuditEnabled(t *testing.T) {
+	defer os.Setenv(EnvVaultDevAuditDisable, os.Getenv(EnvVaultDevAuditDisable))
+
+	cases := []struct {
+		value   string
+		enabled bool
+		err     bool
+	}{
+		{"", true, false},
+		{"false", true, false},
+		{"true", false, false},
+		{"1", false, false},
+		{"foo", false, true},
+	}
+	for _, tc := range cases {
+		os.Setenv(EnvVaultDevAuditDisable, tc.value)
+		enabled, err := devAuditEnabled()
+		if (err != nil) != tc.err {
+			t.Fatalf("%q: err: %v", tc.value, err)
+		}
+		if enabled != tc.enabled {
+			t.Fatalf("%q: bad: %v", tc.value, enabled)
+		}
+	}
+}
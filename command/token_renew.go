@@ -76,23 +76,11 @@ Usage: vault token-renew [options] token [increment]
 
 General Options:
 
-  -address=addr           The address of the Vault server.
-
-  -ca-cert=path           Path to a PEM encoded CA cert file to use to
-                          verify the Vault server SSL certificate.
-
-  -ca-path=path           Path to a directory of PEM encoded CA cert files
-                          to verify the Vault server SSL certificate. If both
-                          -ca-cert and -ca-path are specified, -ca-path is used.
-
-  -tls-skip-verify        Do not verify TLS certificate. This is highly
-                          not recommended. This is especially not recommended
-                          for unsealing a vault.
+` + generalOptionsUsage() + `
 
 Token Renew Options:
 
-  -format=table           The format for output. By default it is a whitespace-
-                          delimited table. This can also be json.
+` + formatUsage() + `
 
 `
 	return strings.TrimSpace(helpText)
@@ -20,7 +20,7 @@ type WriteCommand struct {
 func (c *WriteCommand) Run(args []string) int {
 	var format string
 	var force bool
-	flags := c.Meta.FlagSet("write", FlagSetDefault)
+	flags := c.Meta.FlagSet("write", FlagSetDefault|FlagSetOutputCurlString)
 	flags.StringVar(&format, "format", "table", "")
 	flags.BoolVar(&force, "force", false, "")
 	flags.BoolVar(&force, "f", false, "")
@@ -36,10 +36,7 @@ func (c *WriteCommand) Run(args []string) int {
 		return 1
 	}
 
-	path := args[0]
-	if path[0] == '/' {
-		path = path[1:]
-	}
+	path := c.PrefixPath(args[0])
 
 	data, err := c.parseData(args[1:])
 	if err != nil {
@@ -57,6 +54,9 @@ func (c *WriteCommand) Run(args []string) int {
 
 	secret, err := client.Logical().Write(path, data)
 	if err != nil {
+		if c.OutputCurlString(err) {
+			return 0
+		}
 		c.Ui.Error(fmt.Sprintf(
 			"Error writing data to %s: %s", path, err))
 		return 1
@@ -107,18 +107,7 @@ Usage: vault write [options] path [data]
 
 General Options:
 
-  -address=addr           The address of the Vault server.
-
-  -ca-cert=path           Path to a PEM encoded CA cert file to use to
-                          verify the Vault server SSL certificate.
-
-  -ca-path=path           Path to a directory of PEM encoded CA cert files
-                          to verify the Vault server SSL certificate. If both
-                          -ca-cert and -ca-path are specified, -ca-path is used.
-
-  -tls-skip-verify        Do not verify TLS certificate. This is highly
-                          not recommended. This is especially not recommended
-                          for unsealing a vault.
+` + generalOptionsUsage() + `
 
 Write Options:
 
@@ -126,6 +115,12 @@ Write Options:
                           specified. This allows writing to keys that do not
                           need or expect any fields to be specified.
 
+  -output-curl-string     Output the equivalent curl command instead of
+                          performing the write. The token is read by curl
+                          from the VAULT_TOKEN environment variable.
+
+` + formatUsage() + `
+
 `
 	return strings.TrimSpace(helpText)
 }
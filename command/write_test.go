@@ -269,3 +269,30 @@ func TestWrite_force(t *testing.T) {
 		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
 }
+
+func TestWrite_outputCurlString(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &WriteCommand{
+		Meta: Meta{
+			ClientToken: "foo",
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-address", "http://127.0.0.1:8200",
+		"-output-curl-string",
+		"secret/foo",
+		"value=it's",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	expected := `curl -X PUT -H "X-Vault-Token: $VAULT_TOKEN" ` +
+		`-d '{"value":"it'"'"'s"}' ` +
+		`'http://127.0.0.1:8200/v1/secret/foo'` + "\n"
+	if actual := ui.OutputWriter.String(); actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+}
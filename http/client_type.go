@@ -0,0 +1,48 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+)
+
+// The client types of the requests, see logical.Request.ClientType.
+const (
+	ClientTypeCLI = "cli"
+	ClientTypeUI  = "ui"
+	ClientTypeSDK = "sdk"
+	ClientTypeAPI = "api"
+)
+
+// sdkUserAgents are the prefixes of the User-Agent headers of the client
+// libraries of Vault, compared case insensitively.
+var sdkUserAgents = []string{
+	"vault-api-go",
+	"hvac",
+	"node-vault",
+	"vaultruby",
+	"vault-java-driver",
+}
+
+// clientType returns the kind of client that sent the request, from its
+// User-Agent header. The Vault CLI identifies itself with its version,
+// such as "vault-cli/0.1.3", and the API client of this repository with
+// "vault-api-go". Browsers are recognized by the "Mozilla/" prefix they
+// all send, or by the X-Requested-With header of scripts. Any other
+// client, including curl or a client without a User-Agent, is "api".
+func clientType(r *http.Request) string {
+	ua := strings.ToLower(r.Header.Get("User-Agent"))
+	switch {
+	case strings.HasPrefix(ua, "vault-cli/"):
+		return ClientTypeCLI
+	case strings.HasPrefix(ua, "mozilla/"),
+		r.Header.Get("X-Requested-With") == "XMLHttpRequest":
+		return ClientTypeUI
+	}
+
+	for _, prefix := range sdkUserAgents {
+		if strings.HasPrefix(ua, prefix) {
+			return ClientTypeSDK
+		}
+	}
+	return ClientTypeAPI
+}
@@ -0,0 +1,40 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestClientType(t *testing.T) {
+	cases := []struct {
+		UserAgent   string
+		RequestedBy string
+		Expected    string
+	}{
+		{"vault-cli/0.1.3-dev", "", ClientTypeCLI},
+		{"Mozilla/5.0 (X11; Linux x86_64) Gecko/20100101 Firefox/40.0", "", ClientTypeUI},
+		{"", "XMLHttpRequest", ClientTypeUI},
+		{"vault-api-go", "", ClientTypeSDK},
+		{"hvac/0.2.5", "", ClientTypeSDK},
+		{"VaultRuby/0.1.5 (+github.com/hashicorp/vault-ruby)", "", ClientTypeSDK},
+		{"curl/7.43.0", "", ClientTypeAPI},
+		{"Go 1.1 package http", "", ClientTypeAPI},
+		{"", "", ClientTypeAPI},
+	}
+	for _, tc := range cases {
+		req, err := http.NewRequest("GET", "http://127.0.0.1:8200/v1/secret/foo", nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if tc.UserAgent != "" {
+			req.Header.Set("User-Agent", tc.UserAgent)
+		}
+		if tc.RequestedBy != "" {
+			req.Header.Set("X-Requested-With", tc.RequestedBy)
+		}
+
+		if actual := clientType(req); actual != tc.Expected {
+			t.Fatalf("bad: %q %q: %s", tc.UserAgent, tc.RequestedBy, actual)
+		}
+	}
+}
@@ -38,6 +38,9 @@ func Handler(core *vault.Core) http.Handler {
 	mux.Handle("/v1/sys/auth/", handleSysAuth(core))
 	mux.Handle("/v1/sys/audit", handleSysListAudit(core))
 	mux.Handle("/v1/sys/audit/", handleSysAudit(core))
+	mux.Handle("/v1/sys/audit-replay", handleSysAuditReplay(core))
+	mux.Handle("/v1/sys/audit-rotate/", handleSysAuditRotate(core))
+	mux.Handle("/v1/sys/audit-tune/", handleSysAuditTune(core))
 	mux.Handle("/v1/sys/leader", handleSysLeader(core))
 	mux.Handle("/v1/sys/health", handleSysHealth(core))
 	mux.Handle("/v1/sys/rotate", handleSysRotate(core))
@@ -147,6 +150,9 @@ func requestAuth(r *http.Request, req *logical.Request) *logical.Request {
 		req.ClientToken = v
 	}
 
+	// Attach the kind of client for the audit logs
+	req.ClientType = clientType(r)
+
 	return req
 }
 
@@ -56,9 +56,16 @@ func handleSysDisableAudit(core *vault.Core, w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	// Disabling the last backend must be forced
+	data := make(map[string]interface{})
+	if v := r.URL.Query().Get("force"); v != "" {
+		data["force"] = v
+	}
+
 	_, ok := request(core, w, r, requestAuth(r, &logical.Request{
 		Operation: logical.DeleteOperation,
 		Path:      "sys/audit/" + path,
+		Data:      data,
 	}))
 	if !ok {
 		return
@@ -103,6 +110,88 @@ func handleSysEnableAudit(core *vault.Core, w http.ResponseWriter, r *http.Reque
 	respondOk(w, nil)
 }
 
+func handleSysAuditRotate(core *vault.Core) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+		case "PUT":
+		default:
+			respondError(w, http.StatusMethodNotAllowed, nil)
+			return
+		}
+
+		// Determine the path...
+		prefix := "/v1/sys/audit-rotate/"
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			respondError(w, http.StatusNotFound, nil)
+			return
+		}
+		path := r.URL.Path[len(prefix):]
+		if path == "" {
+			respondError(w, http.StatusNotFound, nil)
+			return
+		}
+
+		resp, ok := request(core, w, r, requestAuth(r, &logical.Request{
+			Operation: logical.WriteOperation,
+			Path:      "sys/audit-rotate/" + path,
+		}))
+		if !ok {
+			return
+		}
+
+		respondOk(w, resp.Data)
+	})
+}
+
+func handleSysAuditTune(core *vault.Core) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+		case "PUT":
+		default:
+			respondError(w, http.StatusMethodNotAllowed, nil)
+			return
+		}
+
+		// Determine the path...
+		prefix := "/v1/sys/audit-tune/"
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			respondError(w, http.StatusNotFound, nil)
+			return
+		}
+		path := r.URL.Path[len(prefix):]
+		if path == "" {
+			respondError(w, http.StatusNotFound, nil)
+			return
+		}
+
+		// Parse the request if we can
+		var req tuneAuditRequest
+		if err := parseRequest(r, &req); err != nil {
+			respondError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		_, ok := request(core, w, r, requestAuth(r, &logical.Request{
+			Operation: logical.WriteOperation,
+			Path:      "sys/audit-tune/" + path,
+			Data: map[string]interface{}{
+				"mode": req.Mode,
+			},
+		}))
+		if !ok {
+			return
+		}
+
+		respondOk(w, nil)
+	})
+}
+
+type tuneAuditRequest struct {
+	Mode string `json:"mode"`
+}
+
 type enableAuditRequest struct {
 	Type        string            `json:"type"`
 	Description string            `json:"description"`
@@ -0,0 +1,33 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/hashicorp/vault/vault"
+)
+
+func handleSysAuditReplay(core *vault.Core) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handleSysAuditReplayGet(core, w, r)
+		default:
+			respondError(w, http.StatusMethodNotAllowed, nil)
+		}
+	})
+}
+
+func handleSysAuditReplayGet(core *vault.Core, w http.ResponseWriter, r *http.Request) {
+	status := core.AuditReplayStatus()
+	respondOk(w, &AuditReplayResponse{
+		InProgress: status.InProgress,
+		Pending:    status.Pending,
+		Replayed:   status.Replayed,
+	})
+}
+
+type AuditReplayResponse struct {
+	InProgress bool `json:"in_progress"`
+	Pending    int  `json:"pending"`
+	Replayed   int  `json:"replayed"`
+}
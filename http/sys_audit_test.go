@@ -51,6 +51,9 @@ func TestSysDisableAudit(t *testing.T) {
 	testResponseStatus(t, resp, 204)
 
 	resp = testHttpDelete(t, addr+"/v1/sys/audit/foo")
+	testResponseStatus(t, resp, 400)
+
+	resp = testHttpDelete(t, addr+"/v1/sys/audit/foo?force=true")
 	testResponseStatus(t, resp, 204)
 
 	resp, err := http.Get(addr + "/v1/sys/audit")
@@ -66,3 +69,74 @@ func TestSysDisableAudit(t *testing.T) {
 		t.Fatalf("bad: %#v", actual)
 	}
 }
+
+func TestSysAuditRotate(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	resp := testHttpPost(t, addr+"/v1/sys/audit/foo", map[string]interface{}{
+		"type": "noop",
+	})
+	testResponseStatus(t, resp, 204)
+
+	for i := 1; i <= 2; i++ {
+		resp = testHttpPut(t, addr+"/v1/sys/audit-rotate/foo", nil)
+
+		var actual map[string]interface{}
+		expected := map[string]interface{}{
+			"salt_version": float64(i),
+		}
+		testResponseStatus(t, resp, 200)
+		testResponseBody(t, resp, &actual)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("bad: %#v", actual)
+		}
+	}
+
+	resp = testHttpPut(t, addr+"/v1/sys/audit-rotate/bar", nil)
+	testResponseStatus(t, resp, 400)
+}
+
+func TestSysAuditTune(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	resp := testHttpPost(t, addr+"/v1/sys/audit/foo", map[string]interface{}{
+		"type":    "noop",
+		"options": map[string]string{"mode": "shadow"},
+	})
+	testResponseStatus(t, resp, 204)
+
+	resp = testHttpPut(t, addr+"/v1/sys/audit-tune/foo", map[string]interface{}{
+		"mode": "enforced",
+	})
+	testResponseStatus(t, resp, 204)
+
+	resp, err := http.Get(addr + "/v1/sys/audit")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var actual map[string]interface{}
+	expected := map[string]interface{}{
+		"foo/": map[string]interface{}{
+			"type":        "noop",
+			"description": "",
+			"options":     map[string]interface{}{"mode": "enforced"},
+		},
+	}
+	testResponseStatus(t, resp, 200)
+	testResponseBody(t, resp, &actual)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	resp = testHttpPut(t, addr+"/v1/sys/audit-tune/bar", map[string]interface{}{
+		"mode": "shadow",
+	})
+	testResponseStatus(t, resp, 400)
+}
@@ -41,9 +41,10 @@ func handleSysHealthGet(core *vault.Core, w http.ResponseWriter, r *http.Request
 
 	// Format the body
 	body := &HealthResponse{
-		Initialized: init,
-		Sealed:      sealed,
-		Standby:     standby,
+		Initialized:   init,
+		Sealed:        sealed,
+		Standby:       standby,
+		AuditDegraded: !core.AuditHealthy(),
 	}
 
 	// Generate the response
@@ -57,4 +58,9 @@ type HealthResponse struct {
 	Initialized bool `json:"initialized"`
 	Sealed      bool `json:"sealed"`
 	Standby     bool `json:"standby"`
+
+	// AuditDegraded is set if the audit backends aren't healthy, see
+	// Core.AuditHealthy. The details, which disclose the paths of the
+	// backends, are only returned by the authenticated sys/audit-health.
+	AuditDegraded bool `json:"audit_degraded,omitempty"`
 }
@@ -33,6 +33,20 @@ type Auth struct {
 	// This will be filled in by Vault core when an auth structure is
 	// returned. Setting this manually will have no effect.
 	ClientToken string
+
+	// Method is the mount path of the credential backend the token was
+	// created through, such as "github/", so that the audit log records
+	// how the user authenticated. This will be filled in by Vault core.
+	// Setting this manually will have no effect.
+	Method string
+
+	// Capabilities are the policies the token is granted on the path of
+	// the request, such as "read" and "write", so that the audit log
+	// records what the request was allowed to do. This will be filled in
+	// by Vault core for authenticated requests. Logins have no token yet,
+	// and the auth returned by a credential backend is audited as is, so
+	// credential backends should leave it empty.
+	Capabilities []string
 }
 
 func (a *Auth) GoString() string {
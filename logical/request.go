@@ -3,6 +3,7 @@ package logical
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 // Request is a struct that stores the parameters and context
@@ -52,6 +53,26 @@ type Request struct {
 	// paths relative to itself. The `Path` is effectively the client
 	// request path with the MountPoint trimmed off.
 	MountPoint string
+
+	// MountType is the type of the backend mounted at the MountPoint, such
+	// as "generic" or "aws". It is set by the core along with the
+	// MountPoint before the request is audited, so that the audit entries
+	// of both the request and the response have them.
+	MountType string
+
+	// ClientType is the kind of client that sent the request, derived by
+	// the HTTP API from its headers: "cli" for the Vault CLI, "ui" for
+	// browsers, "sdk" for the known client libraries, and "api" for any
+	// other client. It is empty for the requests Vault makes itself.
+	ClientType string
+
+	// StartTime and EndTime are when the core started and finished
+	// handling the request, set by the core with the clock of the audit
+	// broker so that the audit entry of the response records its latency.
+	// With the default clock they keep the monotonic reading of time.Now,
+	// so their difference isn't affected by changes to the wall clock.
+	StartTime time.Time
+	EndTime   time.Time
 }
 
 // Get returns a data field and guards for nil Data
@@ -107,3 +107,25 @@ func (a *ACL) RootPrivilege(path string) bool {
 	policyLevel := rule.(int)
 	return policyLevel == pathPolicyLevel[PathPolicySudo]
 }
+
+// Capabilities returns the policies granted on the given path, in
+// increasing order of privilege, such as "read" and "write". Each policy
+// grants the ones below it, so they are all listed. It is empty if the
+// path is denied.
+func (a *ACL) Capabilities(path string) []string {
+	policyLevel := pathPolicyLevel[PathPolicySudo]
+	if !a.root {
+		policyLevel = 0
+		if _, rule, ok := a.pathRules.LongestPrefix(path); ok {
+			policyLevel = rule.(int)
+		}
+	}
+
+	var result []string
+	for _, policy := range []string{PathPolicyRead, PathPolicyWrite, PathPolicySudo} {
+		if pathPolicyLevel[policy] <= policyLevel {
+			result = append(result, policy)
+		}
+	}
+	return result
+}
@@ -1,6 +1,7 @@
 package vault
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/hashicorp/vault/logical"
@@ -20,6 +21,9 @@ func TestACL_Root(t *testing.T) {
 	if !acl.AllowOperation(logical.WriteOperation, "sys/mount/foo") {
 		t.Fatalf("expected permission")
 	}
+	if caps := acl.Capabilities("sys/mount/foo"); !reflect.DeepEqual(caps, []string{"read", "write", "sudo"}) {
+		t.Fatalf("bad: %v", caps)
+	}
 }
 
 func TestACL_Single(t *testing.T) {
@@ -68,6 +72,32 @@ func TestACL_Single(t *testing.T) {
 	}
 }
 
+func TestACL_Capabilities(t *testing.T) {
+	policy, err := Parse(aclPolicy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	acl, err := NewACL([]*Policy{policy})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	tcases := map[string][]string{
+		"root":                 nil,
+		"dev/foo":              []string{"read", "write", "sudo"},
+		"stage/foo":            []string{"read", "write"},
+		"stage/aws/foo":        []string{"read"},
+		"stage/aws/policy/foo": []string{"read", "write", "sudo"},
+		"prod/aws/foo":         nil,
+	}
+	for path, expect := range tcases {
+		if out := acl.Capabilities(path); !reflect.DeepEqual(out, expect) {
+			t.Fatalf("bad: %s: %v", path, out)
+		}
+	}
+}
+
 func TestACL_Layered(t *testing.T) {
 	policy1, err := Parse(aclPolicy)
 	if err != nil {
@@ -5,13 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/armon/go-metrics"
+	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
 )
 
 const (
@@ -23,17 +27,91 @@ const (
 	// auditBarrierPrefix is the prefix to the UUID used in the
 	// barrier view for the audit backends.
 	auditBarrierPrefix = "audit/"
+
+	// auditOptionsKey is the key in the barrier view of an audit backend
+	// used to store the sensitive options kept out of the audit table.
+	auditOptionsKey = "core/options"
+
+	// auditFailureReportInterval is the minimum interval between the log
+	// lines reporting that no audit backend succeeded. Failures in between
+	// are counted and reported with the next line.
+	auditFailureReportInterval = 10 * time.Second
+
+	// auditHeartbeatOption is the option of an audit backend that sets
+	// the interval of its heartbeats. Heartbeats are disabled by default.
+	auditHeartbeatOption = "heartbeat_interval"
+
+	// auditSaltVersionOption is the option of an audit backend holding
+	// the number of times its salt was rotated. It is set by the core.
+	auditSaltVersionOption = "salt_version"
+
+	// auditRotationWindowOption is the option of an audit backend that
+	// sets how long the client tokens are also hashed with the previous
+	// salt once the salt is rotated. The previous salt is kept with the
+	// sensitive options until then, with its expiry. The window is
+	// disabled by default.
+	auditRotationWindowOption = "rotation_window"
+
+	// auditModeOption is the option of an audit backend that puts it on
+	// trial when it is "shadow", see AuditBroker.RegisterShadow. The
+	// default is "enforced". It can be changed without recreating the
+	// backend, see tuneAuditMode.
+	auditModeOption = "mode"
+
+	// auditMinHeartbeatInterval is the shortest heartbeat interval allowed,
+	// so that heartbeats can't flood the audit log.
+	auditMinHeartbeatInterval = time.Second
+
+	// auditMinInventoryInterval is the shortest interval allowed between
+	// the inventories logged periodically, which list every mount.
+	auditMinInventoryInterval = time.Minute
+
+	// auditSelfTestTimeout is how long the self-test of an audit backend
+	// may take, so that an unreachable collector can't hold up an unseal.
+	auditSelfTestTimeout = 10 * time.Second
 )
 
 var (
 	// loadAuditFailed if loading audit tables encounters an error
 	loadAuditFailed = errors.New("failed to setup audit table")
+
+	// sensitiveAuditOptions are the substrings of option names whose
+	// values are masked when the audit backends are listed.
+	sensitiveAuditOptions = []string{
+		"password",
+		"salt",
+		"secret",
+		"token",
+	}
+
+	// auditRemoveDataPaths are the path prefixes whose request data is
+	// never given to the audit backends, not even hashed, since it holds
+	// key shards or other key material. The data is replaced with
+	// auditRemovedData regardless of the backend configuration.
+	auditRemoveDataPaths = []string{
+		"sys/init",
+		"sys/rekey/",
+		"sys/unseal",
+	}
 )
 
-// enableAudit is used to enable a new audit backend
-func (c *Core) enableAudit(entry *MountEntry) error {
-	c.audit.Lock()
-	defer c.audit.Unlock()
+const (
+	// redactedAuditOption replaces the value of sensitive options
+	// when the audit backends are listed.
+	redactedAuditOption = "<redacted>"
+
+	// auditRemovedDataKey is the key of the marker that replaces the
+	// request data of the paths in auditRemoveDataPaths. Its value is
+	// true, which is left as is by the hashing of the backends.
+	auditRemovedDataKey = "removed"
+)
+
+// enableAudit is used to enable a new audit backend. The change is logged
+// by the enabled backends on behalf of the actor once the backend passed
+// its self-test.
+func (c *Core) enableAudit(entry *MountEntry, actor *audit.AuditActor) error {
+	c.auditLock.Lock()
+	defer c.auditLock.Unlock()
 
 	// Ensure we end the path in a slash
 	if !strings.HasSuffix(entry.Path, "/") {
@@ -57,35 +135,81 @@ func (c *Core) enableAudit(entry *MountEntry) error {
 		}
 	}
 
+	heartbeat, err := auditHeartbeatInterval(entry.Options)
+	if err != nil {
+		return err
+	}
+	if _, err := auditRotationWindow(entry.Options); err != nil {
+		return err
+	}
+	shadow, err := auditShadowMode(entry.Options)
+	if err != nil {
+		return err
+	}
+
 	// Lookup the new backend
 	backend, err := c.newAuditBackend(entry.Type, entry.Options)
 	if err != nil {
 		return err
 	}
+	wrapped, err := wrapAuditRateLimit(entry.Path, backend, entry.Options)
+	if err != nil {
+		c.closeAuditBackend(entry.Path, backend)
+		return err
+	}
+	backend = wrapped
+
+	// Check that the backend can log before anything is stored
+	if err := c.auditBroker.SelfTest(entry.Path, backend); err != nil {
+		c.closeAuditBackend(entry.Path, backend)
+		return errwrap.Wrapf("audit backend self-test failed: {{err}}", err)
+	}
+	c.logAuditChange("enable", entry, entry.Options, actor)
 
 	// Generate a new UUID and view
-	entry.UUID = generateUUID()
+	entry.UUID = c.auditBroker.NewID()
 	view := NewBarrierView(c.barrier, auditBarrierPrefix+entry.UUID+"/")
 
-	// Update the audit table
+	// Store the sensitive options in the view rather than the table
+	var sensitive map[string]string
+	entry.Options, sensitive = splitAuditOptions(entry.Options)
+	if err := persistAuditOptions(view, sensitive); err != nil {
+		c.auditStorageError(entry.Path, "write", err)
+		c.closeAuditBackend(entry.Path, backend)
+		return errwrap.Wrapf("failed to store audit options: {{err}}", err)
+	}
+	c.auditStorageOK(entry.Path)
+
+	// Update the audit table. The backend is only registered once the
+	// table is persisted, so a failure leaves the broker untouched.
 	newTable := c.audit.Clone()
 	newTable.Entries = append(newTable.Entries, entry)
 	if err := c.persistAudit(newTable); err != nil {
-		return errors.New("failed to update audit table")
+		if err := view.Delete(auditOptionsKey); err != nil {
+			c.auditStorageError(entry.Path, "delete", err)
+		}
+		c.closeAuditBackend(entry.Path, backend)
+		return errwrap.Wrapf("failed to update audit table: {{err}}", err)
 	}
 	c.audit = newTable
 
-	// Register the backend
-	c.auditBroker.Register(entry.Path, backend, view)
+	// Register the backend, which was tested above
+	c.auditBroker.register(entry.Path, backend, view, shadow)
+	if heartbeat > 0 {
+		c.auditBroker.StartHeartbeat(c.auditContext(), entry.Path, heartbeat)
+	}
 	c.logger.Printf("[INFO] core: enabled audit backend '%s' type: %s",
 		entry.Path, entry.Type)
 	return nil
 }
 
-// disableAudit is used to disable an existing audit backend
-func (c *Core) disableAudit(path string) error {
-	c.audit.Lock()
-	defer c.audit.Unlock()
+// disableAudit is used to disable an existing audit backend. The change is
+// logged on behalf of the actor by every backend, including the one being
+// disabled, before it is deregistered. Disabling the last remaining
+// backend requires force.
+func (c *Core) disableAudit(path string, force bool, actor *audit.AuditActor) error {
+	c.auditLock.Lock()
+	defer c.auditLock.Unlock()
 
 	// Ensure we end the path in a slash
 	if !strings.HasSuffix(path, "/") {
@@ -94,6 +218,7 @@ func (c *Core) disableAudit(path string) error {
 
 	// Remove the entry from the mount table
 	newTable := c.audit.Clone()
+	entry := newTable.Find(path)
 	found := newTable.Remove(path)
 
 	// Ensure there was a match
@@ -101,20 +226,301 @@ func (c *Core) disableAudit(path string) error {
 		return fmt.Errorf("no matching backend")
 	}
 
-	// Update the audit table
+	// Require force to stop auditing altogether, which shadow backends
+	// don't do
+	shadow, _ := auditShadowMode(entry.Options)
+	if !force && !shadow && countEnforcedAudit(newTable) == 0 {
+		return fmt.Errorf("cannot disable the last audit backend without force")
+	}
+
+	// The view is cleared below, so it is read for the change first
+	view := NewBarrierView(c.barrier, auditBarrierPrefix+entry.UUID+"/")
+	options, err := loadAuditOptions(view, entry.Options)
+	if err != nil {
+		c.auditStorageError(path, "read", err)
+		options = entry.Options
+	} else {
+		c.auditStorageOK(path)
+	}
+	c.logAuditChange("disable", entry, options, actor)
+
+	// Update the audit table. The backend remains registered if the
+	// table cannot be persisted, matching the stored table.
 	if err := c.persistAudit(newTable); err != nil {
-		return errors.New("failed to update audit table")
+		return errwrap.Wrapf("failed to update audit table: {{err}}", err)
 	}
 	c.audit = newTable
 
 	// Unmount the backend
 	c.auditBroker.Deregister(path)
+
+	// Clear the data in the view, including the stored options
+	if err := ClearView(view); err != nil {
+		c.auditStorageError(path, "clear", err)
+	} else {
+		c.auditStorageOK(path)
+	}
 	c.logger.Printf("[INFO] core: disabled audit backend '%s'", path)
 	return nil
 }
 
+// rotateAuditSalt replaces the salt of the audit backend at the given path
+// with a new random salt, so that values hashed before can no longer be
+// correlated with values hashed after. The version of the salt, which is
+// written with each entry, is incremented and returned. Both are stored
+// with the sensitive options of the backend, which is recreated with them.
+// The change is logged on behalf of the actor once the new backend passed
+// its self-test.
+func (c *Core) rotateAuditSalt(path string, actor *audit.AuditActor) (int, error) {
+	c.auditLock.Lock()
+	defer c.auditLock.Unlock()
+
+	// Ensure we end the path in a slash
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+
+	entry := c.audit.Find(path)
+	if entry == nil {
+		return 0, fmt.Errorf("no matching backend")
+	}
+
+	view := NewBarrierView(c.barrier, auditBarrierPrefix+entry.UUID+"/")
+	options, err := loadAuditOptions(view, entry.Options)
+	if err != nil {
+		c.auditStorageError(path, "read", err)
+		return 0, errwrap.Wrapf("failed to read audit options: {{err}}", err)
+	}
+	c.auditStorageOK(path)
+
+	var version int
+	if v, ok := options[auditSaltVersionOption]; ok {
+		if version, err = strconv.Atoi(v); err != nil {
+			return 0, fmt.Errorf("invalid %s: %s", auditSaltVersionOption, v)
+		}
+	}
+	version++
+
+	window, err := auditRotationWindow(options)
+	if err != nil {
+		return 0, err
+	}
+
+	rotated := make(map[string]string, len(options)+4)
+	for k, v := range options {
+		rotated[k] = v
+	}
+	rotated["salt"] = generateUUID()
+	rotated[auditSaltVersionOption] = strconv.Itoa(version)
+
+	// Keep the previous salt during the rotation window, replacing the
+	// one of an earlier rotation
+	delete(rotated, "previous_salt")
+	delete(rotated, "previous_salt_until")
+	if window > 0 {
+		rotated["previous_salt"] = options["salt"]
+		rotated["previous_salt_until"] = c.auditBroker.Now().Add(window).UTC().Format(time.RFC3339)
+	}
+
+	// Create the backend before storing anything, so that a failure
+	// leaves the current backend in place
+	backend, err := c.newAuditBackend(entry.Type, rotated)
+	if err != nil {
+		return 0, err
+	}
+	wrapped, err := wrapAuditRateLimit(path, backend, rotated)
+	if err != nil {
+		c.closeAuditBackend(path, backend)
+		return 0, err
+	}
+	backend = wrapped
+
+	if err := c.auditBroker.SelfTest(path, backend); err != nil {
+		c.closeAuditBackend(path, backend)
+		return 0, errwrap.Wrapf("audit backend self-test failed: {{err}}", err)
+	}
+
+	heartbeat, err := auditHeartbeatInterval(rotated)
+	if err != nil {
+		c.closeAuditBackend(path, backend)
+		return 0, err
+	}
+	shadow, err := auditShadowMode(rotated)
+	if err != nil {
+		c.closeAuditBackend(path, backend)
+		return 0, err
+	}
+	c.logAuditChange("rotate", entry, rotated, actor)
+
+	// The salt and its version are both sensitive, so only the view is
+	// written and the audit table is left as is
+	_, sensitive := splitAuditOptions(rotated)
+	if err := persistAuditOptions(view, sensitive); err != nil {
+		c.auditStorageError(path, "write", err)
+		c.closeAuditBackend(path, backend)
+		return 0, errwrap.Wrapf("failed to store audit options: {{err}}", err)
+	}
+	c.auditStorageOK(path)
+
+	// Registering the backend closes the one with the previous salt
+	c.auditBroker.register(path, backend, view, shadow)
+	if heartbeat > 0 {
+		c.auditBroker.StartHeartbeat(c.auditContext(), path, heartbeat)
+	}
+	c.logger.Printf("[INFO] core: rotated salt of audit backend '%s' to version %d",
+		path, version)
+	return version, nil
+}
+
+// tuneAuditMode switches the audit backend at the given path between the
+// "shadow" and "enforced" modes. The mode is persisted in the audit table
+// and the registered backend is flagged in place, so that no entry is
+// missed while a backend is promoted or demoted. The change is logged on
+// behalf of the actor with the new mode.
+func (c *Core) tuneAuditMode(path, mode string, actor *audit.AuditActor) error {
+	c.auditLock.Lock()
+	defer c.auditLock.Unlock()
+
+	// Ensure we end the path in a slash
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+
+	if mode == "" {
+		return fmt.Errorf("missing %s", auditModeOption)
+	}
+	shadow, err := auditShadowMode(map[string]string{auditModeOption: mode})
+	if err != nil {
+		return err
+	}
+
+	newTable := c.audit.Clone()
+	entry := newTable.Find(path)
+	if entry == nil {
+		return fmt.Errorf("no matching backend")
+	}
+	if entry.Options == nil {
+		entry.Options = make(map[string]string)
+	}
+	entry.Options[auditModeOption] = mode
+
+	view := NewBarrierView(c.barrier, auditBarrierPrefix+entry.UUID+"/")
+	options, err := loadAuditOptions(view, entry.Options)
+	if err != nil {
+		c.auditStorageError(path, "read", err)
+		options = entry.Options
+	} else {
+		c.auditStorageOK(path)
+	}
+	c.logAuditChange("tune", entry, options, actor)
+
+	// Update the audit table first, so that the backend keeps its mode
+	// if the table cannot be persisted
+	if err := c.persistAudit(newTable); err != nil {
+		return errwrap.Wrapf("failed to update audit table: {{err}}", err)
+	}
+	c.audit = newTable
+
+	c.auditBroker.SetShadow(path, shadow)
+	c.logger.Printf("[INFO] core: tuned audit backend '%s' to mode: %s", path, mode)
+	return nil
+}
+
+// auditEntries returns the entries kept in memory by the audit backend at
+// the given path, oldest first.
+func (c *Core) auditEntries(path string) ([]map[string]interface{}, error) {
+	c.auditLock.RLock()
+	defer c.auditLock.RUnlock()
+
+	// Ensure we end the path in a slash
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return c.auditBroker.Entries(path)
+}
+
+// previewAudit returns the request and response entries that the audit
+// backend at the given path would write for the events, hashed with its
+// salt and formatted with its options, without writing anything, see
+// audit.Preview. The request data of the paths in auditRemoveDataPaths is
+// removed, as it is for the entries logged.
+func (c *Core) previewAudit(path string, auth *logical.Auth, req *logical.Request,
+	resp *logical.Response, respErr error) (string, string, error) {
+	c.auditLock.RLock()
+	defer c.auditLock.RUnlock()
+
+	// Ensure we end the path in a slash
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+
+	entry := c.audit.Find(path)
+	if entry == nil {
+		return "", "", fmt.Errorf("no matching backend")
+	}
+
+	view := NewBarrierView(c.barrier, auditBarrierPrefix+entry.UUID+"/")
+	options, err := loadAuditOptions(view, entry.Options)
+	if err != nil {
+		c.auditStorageError(path, "read", err)
+		return "", "", errwrap.Wrapf("failed to read audit options: {{err}}", err)
+	}
+	c.auditStorageOK(path)
+
+	// The data of key material is removed as the broker does
+	return audit.Preview(options, auth, removeAuditData(req), resp, respErr)
+}
+
+// auditActor returns the actor of a change of the audit backends made by
+// the request.
+func auditActor(req *logical.Request) *audit.AuditActor {
+	actor := &audit.AuditActor{
+		DisplayName: req.DisplayName,
+		ClientType:  req.ClientType,
+	}
+	if req.Connection != nil {
+		actor.RemoteAddr = req.Connection.RemoteAddr
+	}
+	return actor
+}
+
+// logAuditChange logs an "audit_change" entry through every audit backend
+// before the audit backend of the entry is changed, once the change was
+// validated. The options are those of the backend after the change,
+// including the ones stored in its view, and the values of the sensitive
+// ones are redacted. The audit lock must be held, so that changes are
+// logged in the order they are made.
+func (c *Core) logAuditChange(op string, entry *MountEntry, options map[string]string,
+	actor *audit.AuditActor) {
+	if c.auditBroker == nil {
+		return
+	}
+	if actor == nil {
+		actor = &audit.AuditActor{}
+	}
+
+	change := &audit.AuditChange{
+		Operation:   op,
+		Path:        entry.Path,
+		Type:        entry.Type,
+		Description: entry.Description,
+		Options:     make(map[string]string, len(options)),
+		Actor:       *actor,
+	}
+	for k, v := range options {
+		if isSensitiveAuditOption(k) {
+			v = redactedAuditOption
+		}
+		change.Options[k] = v
+	}
+	c.auditBroker.LogAuditChange(c.auditContext(), change)
+}
+
 // loadAudits is invoked as part of postUnseal to load the audit table
 func (c *Core) loadAudits() error {
+	c.auditLock.Lock()
+	defer c.auditLock.Unlock()
+
 	// Load the existing audit table
 	raw, err := c.barrier.Get(coreAuditConfigPath)
 	if err != nil {
@@ -142,6 +548,102 @@ func (c *Core) loadAudits() error {
 	return nil
 }
 
+// auditTable returns a copy of the audit table that is safe to read
+// without holding the audit lock. Nil is returned if it is not loaded.
+func (c *Core) auditTable() *MountTable {
+	c.auditLock.RLock()
+	defer c.auditLock.RUnlock()
+	if c.audit == nil {
+		return nil
+	}
+	return c.audit.Clone()
+}
+
+// listAudits returns the enabled audit backends sorted by path. The
+// values of sensitive options such as tokens and passwords are masked.
+func (c *Core) listAudits() []*MountEntry {
+	table := c.auditTable()
+	if table == nil {
+		return nil
+	}
+
+	entries := table.Entries
+	sort.Sort(mountEntriesByPath(entries))
+	for _, entry := range entries {
+		for k := range entry.Options {
+			if isSensitiveAuditOption(k) {
+				entry.Options[k] = redactedAuditOption
+			}
+		}
+	}
+	return entries
+}
+
+// isSensitiveAuditOption checks if the value of the named option
+// should not be exposed when listing the audit backends.
+func isSensitiveAuditOption(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range sensitiveAuditOptions {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitAuditOptions separates the sensitive options, which must not be
+// stored in the audit table, from the rest of the options.
+func splitAuditOptions(options map[string]string) (map[string]string, map[string]string) {
+	public := make(map[string]string)
+	sensitive := make(map[string]string)
+	for k, v := range options {
+		if isSensitiveAuditOption(k) {
+			sensitive[k] = v
+		} else {
+			public[k] = v
+		}
+	}
+	return public, sensitive
+}
+
+// persistAuditOptions is used to store the sensitive options of an
+// audit backend encrypted within its barrier view.
+func persistAuditOptions(view *BarrierView, sensitive map[string]string) error {
+	if len(sensitive) == 0 {
+		return nil
+	}
+	entry, err := logical.StorageEntryJSON(auditOptionsKey, sensitive)
+	if err != nil {
+		return err
+	}
+	return view.Put(entry)
+}
+
+// loadAuditOptions is used to merge the sensitive options stored in the
+// barrier view of an audit backend with the options from the audit table.
+func loadAuditOptions(view *BarrierView, options map[string]string) (map[string]string, error) {
+	raw, err := view.Get(auditOptionsKey)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return options, nil
+	}
+
+	var sensitive map[string]string
+	if err := raw.DecodeJSON(&sensitive); err != nil {
+		return nil, err
+	}
+	merged := make(map[string]string, len(options)+len(sensitive))
+	for k, v := range options {
+		merged[k] = v
+	}
+	for k, v := range sensitive {
+		merged[k] = v
+	}
+	return merged, nil
+}
+
 // persistAudit is used to persist the audit table after modification
 func (c *Core) persistAudit(table *MountTable) error {
 	// Marshal the table
@@ -165,13 +667,51 @@ func (c *Core) persistAudit(table *MountTable) error {
 	return nil
 }
 
+// AuditReplayStatus is the progress of replaying pending audit entries
+// to the backends during the post-unseal setup.
+type AuditReplayStatus struct {
+	InProgress bool
+	Pending    int
+	Replayed   int
+}
+
+// AuditReplayStatus returns the progress of replaying pending audit
+// entries. The state lock is not used, so that the progress can be
+// checked while the post-unseal setup is still running.
+func (c *Core) AuditReplayStatus() AuditReplayStatus {
+	c.auditReplayLock.RLock()
+	defer c.auditReplayLock.RUnlock()
+	return c.auditReplay
+}
+
 // setupAudit is invoked after we've loaded the audit able to
 // initialize the audit backends
-func (c *Core) setupAudits() error {
-	broker := NewAuditBroker(c.logger)
-	for _, entry := range c.audit.Entries {
+func (c *Core) setupAudits() (err error) {
+	broker := NewAuditBroker(c.logger, c.auditBrokerConfig)
+
+	// The backends set up are released if the audits can't be
+	defer func() {
+		if err != nil {
+			broker.Close()
+		}
+	}()
+
+	replayers := make(map[string]audit.Replayer)
+	heartbeats := make(map[string]time.Duration)
+	for _, entry := range c.auditTable().Entries {
+		// Create a barrier view using the UUID
+		view := NewBarrierView(c.barrier, auditBarrierPrefix+entry.UUID+"/")
+
+		// Restore the sensitive options stored in the view
+		options, err := loadAuditOptions(view, entry.Options)
+		if err != nil {
+			c.auditStorageError(entry.Path, "read", err)
+			return loadAuditFailed
+		}
+		c.auditStorageOK(entry.Path)
+
 		// Initialize the backend
-		audit, err := c.newAuditBackend(entry.Type, entry.Options)
+		backend, err := c.newAuditBackend(entry.Type, options)
 		if err != nil {
 			c.logger.Printf(
 				"[ERR] core: failed to create audit entry %#v: %v",
@@ -179,24 +719,333 @@ func (c *Core) setupAudits() error {
 			return loadAuditFailed
 		}
 
-		// Create a barrier view using the UUID
-		view := NewBarrierView(c.barrier, auditBarrierPrefix+entry.UUID+"/")
+		heartbeat, err := auditHeartbeatInterval(options)
+		if err != nil {
+			c.logger.Printf(
+				"[ERR] core: invalid heartbeat interval for audit entry '%s': %v",
+				entry.Path, err)
+			c.closeAuditBackend(entry.Path, backend)
+			return loadAuditFailed
+		}
+
+		// Track any backends with entries to replay. Replays aren't rate
+		// limited, so the backend is checked before it is wrapped.
+		if r, ok := backend.(audit.Replayer); ok {
+			replayers[entry.Path] = r
+		}
+
+		wrapped, err := wrapAuditRateLimit(entry.Path, backend, options)
+		if err != nil {
+			c.logger.Printf(
+				"[ERR] core: invalid rate limit for audit entry '%s': %v",
+				entry.Path, err)
+			c.closeAuditBackend(entry.Path, backend)
+			return loadAuditFailed
+		}
+		backend = wrapped
 
-		// Mount the backend
-		broker.Register(entry.Path, audit, view)
+		shadow, err := auditShadowMode(options)
+		if err != nil {
+			c.logger.Printf(
+				"[ERR] core: invalid mode for audit entry '%s': %v",
+				entry.Path, err)
+			c.closeAuditBackend(entry.Path, backend)
+			return loadAuditFailed
+		}
+
+		// Mount the backend. A backend failing its self-test is mounted
+		// anyway, flagged as failed, so that the Vault can still unseal.
+		register := broker.Register
+		if shadow {
+			register = broker.RegisterShadow
+		}
+		if err := register(entry.Path, backend, view); err != nil {
+			c.logger.Printf(
+				"[ERR] core: audit backend '%s' failed its self-test: %v",
+				entry.Path, err)
+		}
+		if heartbeat > 0 {
+			heartbeats[entry.Path] = heartbeat
+		}
+	}
+
+	// Flush pending entries before any requests are served
+	if err := c.replayAudits(replayers); err != nil {
+		return loadAuditFailed
 	}
 	c.auditBroker = broker
+
+	c.auditCtxLock.Lock()
+	c.auditCtx, c.auditCancel = context.WithCancel(context.Background())
+	ctx := c.auditCtx
+	c.auditCtxLock.Unlock()
+
+	// Heartbeats stop when the context is canceled by teardownAudits
+	for path, interval := range heartbeats {
+		broker.StartHeartbeat(ctx, path, interval)
+	}
+	return nil
+}
+
+// replayAudits is used to replay the pending entries of the given
+// backends, updating the replay progress as entries are written.
+func (c *Core) replayAudits(replayers map[string]audit.Replayer) error {
+	// Determine the total number of pending entries
+	pending := 0
+	for path, r := range replayers {
+		n, err := r.Pending()
+		if err != nil {
+			c.logger.Printf(
+				"[ERR] core: failed to read pending audit entries for '%s': %v",
+				path, err)
+			return err
+		}
+		pending += n
+	}
+	if pending == 0 {
+		return nil
+	}
+
+	c.auditReplayLock.Lock()
+	c.auditReplay = AuditReplayStatus{
+		InProgress: true,
+		Pending:    pending,
+	}
+	c.auditReplayLock.Unlock()
+	defer func() {
+		c.auditReplayLock.Lock()
+		c.auditReplay.InProgress = false
+		c.auditReplayLock.Unlock()
+	}()
+
+	c.logger.Printf("[INFO] core: replaying %d pending audit entries", pending)
+	progress := func() {
+		c.auditReplayLock.Lock()
+		c.auditReplay.Replayed++
+		c.auditReplayLock.Unlock()
+	}
+	for path, r := range replayers {
+		if err := r.Replay(progress); err != nil {
+			c.logger.Printf(
+				"[ERR] core: failed to replay audit entries for '%s': %v",
+				path, err)
+			return err
+		}
+	}
+	c.logger.Printf("[INFO] core: replayed pending audit entries")
 	return nil
 }
 
+// auditStorageError records a failed read or write of the barrier view
+// of the audit backend at the given path. The failures are logged with
+// the backend and the operation, and counted per backend. The backend is
+// reported as failing until its view is read or written again, see
+// auditStorageOK.
+func (c *Core) auditStorageError(path, op string, err error) {
+	c.logger.Printf(
+		"[ERR] core: audit storage failure: backend=%q op=%s error=%q",
+		path, op, err)
+	metrics.IncrCounter([]string{"audit", path, "storage_error"}, 1)
+
+	c.auditStorageLock.Lock()
+	defer c.auditStorageLock.Unlock()
+	if c.auditStorageErrors == nil {
+		c.auditStorageErrors = make(map[string]int)
+		c.auditStorageFailing = make(map[string]bool)
+	}
+	c.auditStorageErrors[path]++
+	c.auditStorageFailing[path] = true
+}
+
+// auditStorageOK records a successful read or write of the barrier view
+// of the audit backend at the given path, so that a backend that failed
+// before is no longer reported as failing. Its failures stay counted.
+func (c *Core) auditStorageOK(path string) {
+	c.auditStorageLock.Lock()
+	defer c.auditStorageLock.Unlock()
+	delete(c.auditStorageFailing, path)
+}
+
+// emitAuditMetrics emits the metrics of the audit broker, if unsealed.
+func (c *Core) emitAuditMetrics() {
+	c.auditLock.RLock()
+	defer c.auditLock.RUnlock()
+	if c.auditBroker != nil {
+		c.auditBroker.emitMetrics()
+	}
+}
+
+// AuditLastSuccess returns the time each audit backend last logged a
+// request or a response since the Vault was unsealed, by path. Backends
+// that haven't logged one are omitted, and nothing is returned while the
+// Vault is sealed.
+func (c *Core) AuditLastSuccess() map[string]time.Time {
+	c.auditLock.RLock()
+	defer c.auditLock.RUnlock()
+	if c.auditBroker == nil {
+		return nil
+	}
+	return c.auditBroker.LastSuccess()
+}
+
+// AuditDegraded returns why each degraded audit backend is degraded, by
+// path, see audit.DegradedReporter. Nothing is returned while the Vault
+// is sealed.
+func (c *Core) AuditDegraded() map[string]string {
+	c.auditLock.RLock()
+	defer c.auditLock.RUnlock()
+	if c.auditBroker == nil {
+		return nil
+	}
+	return c.auditBroker.Degraded()
+}
+
+// AuditHealthy returns false if the last read or write of the storage of
+// an audit backend failed, or if a backend is degraded.
+func (c *Core) AuditHealthy() bool {
+	c.auditStorageLock.Lock()
+	failing := len(c.auditStorageFailing)
+	c.auditStorageLock.Unlock()
+	return failing == 0 && len(c.AuditDegraded()) == 0
+}
+
+// AuditStorageErrors returns the number of failed reads and writes of the
+// barrier views of the audit backends since the Vault started, by path.
+// Backends without failures are omitted.
+func (c *Core) AuditStorageErrors() map[string]int {
+	c.auditStorageLock.Lock()
+	defer c.auditStorageLock.Unlock()
+	result := make(map[string]int, len(c.auditStorageErrors))
+	for path, n := range c.auditStorageErrors {
+		result[path] = n
+	}
+	return result
+}
+
 // teardownAudit is used before we seal the vault to reset the audit
 // backends to their unloaded state. This is reversed by loadAudits.
 func (c *Core) teardownAudits() error {
+	c.auditLock.Lock()
+	defer c.auditLock.Unlock()
+
 	c.audit = nil
+	c.cancelAudits()
+
+	// Release the backends, such as the processes of plugins
+	if c.auditBroker != nil {
+		c.auditBroker.Close()
+	}
 	c.auditBroker = nil
 	return nil
 }
 
+// closeAuditBackend closes an audit backend that won't be registered,
+// such as one that failed its self-test, see audit.Closer.
+func (c *Core) closeAuditBackend(path string, backend audit.Backend) {
+	if err := audit.Close(backend); err != nil {
+		c.logger.Printf("[ERR] core: failed to close audit backend '%s': %v", path, err)
+	}
+}
+
+// auditContext returns the context given to the audit backends with
+// each entry.
+func (c *Core) auditContext() context.Context {
+	c.auditCtxLock.Lock()
+	defer c.auditCtxLock.Unlock()
+	if c.auditCtx == nil {
+		return context.Background()
+	}
+	return c.auditCtx
+}
+
+// cancelAudits cancels the context given to the audit backends, so that
+// entries they are blocked writing are aborted. This does not need the
+// state lock, so that a seal is not held up by requests blocked in a
+// backend. A new context is created by setupAudits.
+func (c *Core) cancelAudits() {
+	c.auditCtxLock.Lock()
+	defer c.auditCtxLock.Unlock()
+	if c.auditCancel != nil {
+		c.auditCancel()
+	}
+}
+
+// auditHeartbeatInterval returns the interval of the heartbeats of an
+// audit backend set by its heartbeat_interval option, or zero if the
+// option isn't set.
+func auditHeartbeatInterval(options map[string]string) (time.Duration, error) {
+	v, ok := options[auditHeartbeatOption]
+	if !ok {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", auditHeartbeatOption, v)
+	}
+	if interval < auditMinHeartbeatInterval {
+		return 0, fmt.Errorf("%s must be at least %s",
+			auditHeartbeatOption, auditMinHeartbeatInterval)
+	}
+	return interval, nil
+}
+
+// auditRotationWindow returns how long the previous salt of an audit
+// backend is still used once its salt is rotated, set by its
+// rotation_window option, or zero if the option isn't set.
+func auditRotationWindow(options map[string]string) (time.Duration, error) {
+	v, ok := options[auditRotationWindowOption]
+	if !ok {
+		return 0, nil
+	}
+	window, err := time.ParseDuration(v)
+	if err != nil || window < 0 {
+		return 0, fmt.Errorf("invalid %s: %s", auditRotationWindowOption, v)
+	}
+	return window, nil
+}
+
+// auditShadowMode returns whether an audit backend is on trial, from its
+// mode option.
+func auditShadowMode(options map[string]string) (bool, error) {
+	switch v := options[auditModeOption]; v {
+	case "", "enforced", "active":
+		return false, nil
+	case "shadow":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid %s: %s", auditModeOption, v)
+	}
+}
+
+// countEnforcedAudit returns the number of audit backends of the table
+// that are not in shadow mode.
+func countEnforcedAudit(table *MountTable) int {
+	count := 0
+	for _, entry := range table.Entries {
+		if shadow, _ := auditShadowMode(entry.Options); !shadow {
+			count++
+		}
+	}
+	return count
+}
+
+// checkAuditRequired is used to refuse a request to a secret path if an
+// audit backend is required but none are enabled outside of shadow mode.
+// The system and credential paths are exempt, so that an operator can
+// still log in and enable an audit backend.
+func (c *Core) checkAuditRequired(path string) error {
+	if !c.requireAudit {
+		return nil
+	}
+	if strings.HasPrefix(path, "sys/") || strings.HasPrefix(path, credentialRoutePrefix) {
+		return nil
+	}
+	if c.auditBroker.Count() == 0 {
+		return ErrAuditRequired
+	}
+	return nil
+}
+
 // newAuditBackend is used to create and configure a new audit backend by name
 func (c *Core) newAuditBackend(t string, conf map[string]string) (audit.Backend, error) {
 	f, ok := c.auditBackends[t]
@@ -215,40 +1064,364 @@ func defaultAuditTable() *MountTable {
 type backendEntry struct {
 	backend audit.Backend
 	view    *BarrierView
+
+	// registered is the time the backend was registered
+	registered time.Time
+
+	// shadow is set for backends on trial, whose entries don't count
+	// toward the backend that must succeed, see RegisterShadow
+	shadow bool
+
+	// stopHeartbeat is closed to stop the heartbeats of the backend, if
+	// they were started
+	stopHeartbeat chan struct{}
 }
 
 // AuditBroker is used to provide a single ingest interface to auditable
 // events given that multiple backends may be configured.
+//
+// Entries are delivered to the backends synchronously: LogRequest and
+// LogResponse return only once every backend has been called. Since the
+// core logs the response of a request only after logging the request,
+// every backend receives the request entry of a request before its
+// response entry, even when many requests are handled concurrently.
 type AuditBroker struct {
 	l        sync.RWMutex
 	backends map[string]backendEntry
 	logger   *log.Logger
+
+	// failureLock protects the state used to rate limit the reports of
+	// entries that no backend succeeded in logging
+	failureLock       sync.Mutex
+	lastFailureReport time.Time
+	suppressed        int
+
+	// healthLock protects the set of backends that failed to log the
+	// last entry given to them, and the time each backend last logged a
+	// request or a response
+	healthLock  sync.Mutex
+	failed      map[string]bool
+	lastSuccess map[string]time.Time
+
+	// clock and newID are the sources of time and identifiers
+	clock func() time.Time
+	newID func() string
 }
 
-// NewAuditBroker creates a new audit broker
-func NewAuditBroker(log *log.Logger) *AuditBroker {
+// AuditBrokerConfig sets the sources of time and identifiers of an audit
+// broker, so that tests of the times and identifiers it hands out can be
+// deterministic. See TestAuditClock and TestAuditIDs.
+type AuditBrokerConfig struct {
+	// Clock returns the current time. It defaults to time.Now.
+	Clock func() time.Time
+
+	// NewID returns a new unique identifier. It defaults to a random UUID.
+	NewID func() string
+}
+
+// NewAuditBroker creates a new audit broker. The configuration may be
+// nil, and its unset fields default to the real implementations.
+func NewAuditBroker(log *log.Logger, conf *AuditBrokerConfig) *AuditBroker {
 	b := &AuditBroker{
-		backends: make(map[string]backendEntry),
-		logger:   log,
+		backends:    make(map[string]backendEntry),
+		logger:      log,
+		failed:      make(map[string]bool),
+		lastSuccess: make(map[string]time.Time),
+		clock:       time.Now,
+		newID:       generateUUID,
+	}
+	if conf != nil && conf.Clock != nil {
+		b.clock = conf.Clock
+	}
+	if conf != nil && conf.NewID != nil {
+		b.newID = conf.NewID
 	}
 	return b
 }
 
-// Register is used to add new audit backend to the broker
-func (a *AuditBroker) Register(name string, b audit.Backend, v *BarrierView) {
+// Now returns the current time of the clock of the broker.
+func (a *AuditBroker) Now() time.Time {
+	return a.clock()
+}
+
+// NewID returns a new unique identifier from the source of the broker.
+func (a *AuditBroker) NewID() string {
+	return a.newID()
+}
+
+// Register is used to add new audit backend to the broker. A backend
+// already registered with the name is replaced, and its heartbeats stop.
+// The backend is self-tested first, see SelfTest. If the test fails, the
+// backend is registered anyway but flagged as failed until it logs an
+// entry, and the error is returned.
+func (a *AuditBroker) Register(name string, b audit.Backend, v *BarrierView) error {
+	return a.registerTested(name, b, v, false)
+}
+
+// RegisterShadow is used to add an audit backend on trial to the broker,
+// like Register. The backend is given every entry and its results are
+// counted in metrics, but neither its successes nor its failures count
+// toward the backend that must succeed in logging each entry, so that a
+// new sink can be vetted in production safely.
+func (a *AuditBroker) RegisterShadow(name string, b audit.Backend, v *BarrierView) error {
+	return a.registerTested(name, b, v, true)
+}
+
+func (a *AuditBroker) registerTested(name string, b audit.Backend, v *BarrierView, shadow bool) error {
+	err := a.SelfTest(name, b)
+	a.register(name, b, v, shadow)
+	if err != nil {
+		a.healthLock.Lock()
+		a.failed[name] = true
+		a.healthLock.Unlock()
+	}
+	return err
+}
+
+// SelfTest logs a self-test entry through the backend, if it implements
+// audit.SelfTester, and returns the error if it couldn't. The backend is
+// set to timestamp its entries with the clock of the broker first, see
+// audit.ClockSetter, since every backend is tested before it is
+// registered.
+func (a *AuditBroker) SelfTest(name string, b audit.Backend) error {
+	audit.SetClock(b, a.clock)
+
+	tester, ok := b.(audit.SelfTester)
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), auditSelfTestTimeout)
+	defer cancel()
+	if err := tester.Test(ctx, &audit.SelfTest{Backend: name}); err != nil {
+		metrics.IncrCounter([]string{"audit", name, "self_test_failure"}, 1)
+		return err
+	}
+	return nil
+}
+
+// register adds the backend to the broker without testing it.
+func (a *AuditBroker) register(name string, b audit.Backend, v *BarrierView, shadow bool) {
 	a.l.Lock()
-	defer a.l.Unlock()
+	old, replaced := a.backends[name]
+	if replaced && old.stopHeartbeat != nil {
+		close(old.stopHeartbeat)
+	}
 	a.backends[name] = backendEntry{
-		backend: b,
-		view:    v,
+		backend:    b,
+		view:       v,
+		registered: a.clock(),
+		shadow:     shadow,
+	}
+	a.l.Unlock()
+
+	// The backend replaced, such as by a salt rotation, is closed
+	if replaced && old.backend != b {
+		a.closeBackend(name, old.backend)
 	}
 }
 
-// Deregister is used to remove an audit backend from the broker
-func (a *AuditBroker) Deregister(name string) {
+// SetShadow puts the registered backend on trial, or takes it off trial,
+// without replacing it, see RegisterShadow. Unknown backends are ignored.
+func (a *AuditBroker) SetShadow(name string, shadow bool) {
 	a.l.Lock()
 	defer a.l.Unlock()
+	be, ok := a.backends[name]
+	if !ok {
+		return
+	}
+	be.shadow = shadow
+	a.backends[name] = be
+}
+
+// Deregister is used to remove an audit backend from the broker. The
+// backend is closed, see audit.Closer.
+func (a *AuditBroker) Deregister(name string) {
+	a.l.Lock()
+	be, ok := a.backends[name]
+	if ok && be.stopHeartbeat != nil {
+		close(be.stopHeartbeat)
+	}
 	delete(a.backends, name)
+	a.l.Unlock()
+
+	a.healthLock.Lock()
+	delete(a.failed, name)
+	delete(a.lastSuccess, name)
+	a.healthLock.Unlock()
+
+	// The backend is closed without the lock, since closing may wait
+	// for its pending entries to be written
+	if ok {
+		a.closeBackend(name, be.backend)
+	}
+}
+
+// Close deregisters and closes every backend, when the Vault is sealed.
+func (a *AuditBroker) Close() {
+	a.l.RLock()
+	names := make([]string, 0, len(a.backends))
+	for name := range a.backends {
+		names = append(names, name)
+	}
+	a.l.RUnlock()
+
+	for _, name := range names {
+		a.Deregister(name)
+	}
+}
+
+// closeBackend closes a backend that is no longer registered, logging the
+// error if it fails to close.
+func (a *AuditBroker) closeBackend(name string, b audit.Backend) {
+	if err := audit.Close(b); err != nil {
+		a.logger.Printf("[ERR] audit: failed to close backend '%s': %v", name, err)
+	}
+}
+
+// LastSuccess returns the time each backend last logged a request or a
+// response, by name. Backends that haven't logged one since they were
+// registered are omitted.
+func (a *AuditBroker) LastSuccess() map[string]time.Time {
+	a.healthLock.Lock()
+	defer a.healthLock.Unlock()
+	result := make(map[string]time.Time, len(a.lastSuccess))
+	for name, t := range a.lastSuccess {
+		result[name] = t
+	}
+	return result
+}
+
+// Degraded returns why each backend implementing audit.DegradedReporter
+// is degraded, by name. Backends that aren't degraded are omitted.
+func (a *AuditBroker) Degraded() map[string]string {
+	a.l.RLock()
+	defer a.l.RUnlock()
+	result := make(map[string]string)
+	for name, be := range a.backends {
+		dr, ok := be.backend.(audit.DegradedReporter)
+		if !ok {
+			continue
+		}
+		if reason := dr.Degraded(); reason != "" {
+			result[name] = reason
+		}
+	}
+	return result
+}
+
+// emitMetrics sets the gauge of the seconds since each backend last
+// logged a request or a response, so that alerts can fire when a backend
+// stops writing despite traffic. Backends that haven't logged one yet
+// count from the time they were registered. Backends implementing
+// audit.DegradedReporter also set a gauge of 1 while degraded, and 0
+// otherwise.
+func (a *AuditBroker) emitMetrics() {
+	a.l.RLock()
+	defer a.l.RUnlock()
+	a.healthLock.Lock()
+	defer a.healthLock.Unlock()
+
+	now := a.clock()
+	for name, be := range a.backends {
+		last, ok := a.lastSuccess[name]
+		if !ok {
+			last = be.registered
+		}
+		metrics.SetGauge([]string{"audit", name, "seconds_since_success"},
+			float32(now.Sub(last).Seconds()))
+
+		if dr, ok := be.backend.(audit.DegradedReporter); ok {
+			var degraded float32
+			if dr.Degraded() != "" {
+				degraded = 1
+			}
+			metrics.SetGauge([]string{"audit", name, "degraded"}, degraded)
+		}
+	}
+}
+
+// StartHeartbeat logs a heartbeat through the named backend at every
+// interval, if it implements audit.HeartbeatLogger, until the backend is
+// deregistered or the context is done.
+func (a *AuditBroker) StartHeartbeat(
+	ctx context.Context, name string, interval time.Duration) {
+	a.l.Lock()
+	defer a.l.Unlock()
+	be, ok := a.backends[name]
+	if !ok || be.stopHeartbeat != nil {
+		return
+	}
+	hl, ok := be.backend.(audit.HeartbeatLogger)
+	if !ok {
+		return
+	}
+
+	be.stopHeartbeat = make(chan struct{})
+	a.backends[name] = be
+	go a.heartbeat(ctx, name, hl, interval, be.stopHeartbeat)
+}
+
+// heartbeat logs the heartbeats of a backend until stopCh is closed or
+// the context is done. A failure is logged but doesn't change the health
+// of the backend, which is tracked from the requests it logs.
+func (a *AuditBroker) heartbeat(ctx context.Context, name string,
+	hl audit.HeartbeatLogger, interval time.Duration, stopCh chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+
+		if err := hl.LogHeartbeat(ctx, &audit.Heartbeat{Backend: name}); err != nil {
+			metrics.IncrCounter([]string{"audit", name, "heartbeat_failure"}, 1)
+			a.logger.Printf(
+				"[ERR] audit: backend '%s' failed to log heartbeat: %v", name, err)
+		}
+	}
+}
+
+// LogInventory logs the inventory through every backend that implements
+// audit.InventoryLogger. Like for heartbeats, a failure is logged but
+// doesn't change the health of the backend.
+func (a *AuditBroker) LogInventory(ctx context.Context, inv *audit.Inventory) {
+	a.l.RLock()
+	defer a.l.RUnlock()
+	for name, be := range a.backends {
+		il, ok := be.backend.(audit.InventoryLogger)
+		if !ok {
+			continue
+		}
+		if err := il.LogInventory(ctx, inv); err != nil {
+			metrics.IncrCounter([]string{"audit", name, "inventory_failure"}, 1)
+			a.logger.Printf(
+				"[ERR] audit: backend '%s' failed to log inventory: %v", name, err)
+		}
+	}
+}
+
+// LogAuditChange logs the change of an audit backend through every backend
+// that implements audit.AuditChangeLogger. Like for inventories, a failure
+// is logged but doesn't fail the change.
+func (a *AuditBroker) LogAuditChange(ctx context.Context, change *audit.AuditChange) {
+	a.l.RLock()
+	defer a.l.RUnlock()
+	for name, be := range a.backends {
+		cl, ok := be.backend.(audit.AuditChangeLogger)
+		if !ok {
+			continue
+		}
+		if err := cl.LogAuditChange(ctx, change); err != nil {
+			metrics.IncrCounter([]string{"audit", name, "audit_change_failure"}, 1)
+			a.logger.Printf(
+				"[ERR] audit: backend '%s' failed to log audit change: %v", name, err)
+		}
+	}
 }
 
 // IsRegistered is used to check if a given audit backend is registered
@@ -259,53 +1432,259 @@ func (a *AuditBroker) IsRegistered(name string) bool {
 	return ok
 }
 
+// Entries returns the entries kept in memory by the named backend, if it
+// implements audit.EntryReader. The rate limit of the backend is looked
+// through, since the entries are read from the backend it wraps.
+func (a *AuditBroker) Entries(name string) ([]map[string]interface{}, error) {
+	a.l.RLock()
+	be, ok := a.backends[name]
+	a.l.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no matching backend")
+	}
+
+	backend := be.backend
+	if rl, ok := backend.(*rateLimitedAuditBackend); ok {
+		backend = rl.backend
+	}
+	r, ok := backend.(audit.EntryReader)
+	if !ok {
+		return nil, fmt.Errorf("backend doesn't keep its entries")
+	}
+	return r.Entries()
+}
+
+// Count returns the number of registered audit backends that are not in
+// shadow mode. The failures of shadow backends are ignored, so they don't
+// count as auditing.
+func (a *AuditBroker) Count() int {
+	a.l.RLock()
+	defer a.l.RUnlock()
+	count := 0
+	for _, be := range a.backends {
+		if !be.shadow {
+			count++
+		}
+	}
+	return count
+}
+
 // LogRequest is used to ensure all the audit backends have an opportunity to
-// log the given request and that *at least one* succeeds.
-func (a *AuditBroker) LogRequest(auth *logical.Auth, req *logical.Request) error {
+// log the given request and that *at least one* succeeds. The context is
+// passed to the backends so that they can abort once it is done.
+func (a *AuditBroker) LogRequest(ctx context.Context,
+	auth *logical.Auth, req *logical.Request) error {
 	defer metrics.MeasureSince([]string{"audit", "log_request"}, time.Now())
 	a.l.RLock()
 	defer a.l.RUnlock()
+	req = removeAuditData(req)
 
-	// Ensure at least one backend logs
+	// Ensure at least one backend logs, not counting shadow backends
 	anyLogged := false
+	active := 0
+	failures := make(map[string]error)
 	for name, be := range a.backends {
 		start := time.Now()
-		err := be.backend.LogRequest(auth, req)
+		err := be.backend.LogRequest(ctx, auth, req)
 		metrics.MeasureSince([]string{"audit", name, "log_request"}, start)
-		if err != nil {
-			a.logger.Printf("[ERR] audit: backend '%s' failed to log request: %v", name, err)
+		if err == errAuditRateLimited {
+			metrics.IncrCounter([]string{"audit", name, "rate_limited"}, 1)
+		}
+		if be.shadow {
+			countShadow(name, err)
 		} else {
+			active++
+		}
+		if err != nil {
+			failures[name] = err
+		} else if !be.shadow {
 			anyLogged = true
 		}
 	}
-	if !anyLogged && len(a.backends) > 0 {
+	a.trackHealth(ctx, failures)
+	if !anyLogged && active > 0 {
+		metrics.IncrCounter([]string{"audit", "log_request_failure"}, 1)
+		a.reportFailure("request", req, failures)
 		return fmt.Errorf("no audit backend succeeded in logging the request")
 	}
+	for name, err := range failures {
+		if err == errAuditRateLimited {
+			continue
+		}
+		if a.backends[name].shadow {
+			a.logger.Printf("[WARN] audit: shadow backend '%s' failed to log request: %v", name, err)
+			continue
+		}
+		a.logger.Printf("[ERR] audit: backend '%s' failed to log request: %v", name, err)
+	}
 	return nil
 }
 
 // LogResponse is used to ensure all the audit backends have an opportunity to
-// log the given response and that *at least one* succeeds.
-func (a *AuditBroker) LogResponse(auth *logical.Auth, req *logical.Request,
-	resp *logical.Response, err error) error {
+// log the given response and that *at least one* succeeds. The context is
+// passed to the backends so that they can abort once it is done.
+func (a *AuditBroker) LogResponse(ctx context.Context, auth *logical.Auth,
+	req *logical.Request, resp *logical.Response, err error) error {
 	defer metrics.MeasureSince([]string{"audit", "log_response"}, time.Now())
 	a.l.RLock()
 	defer a.l.RUnlock()
+	req = removeAuditData(req)
 
-	// Ensure at least one backend logs
+	// Ensure at least one backend logs, not counting shadow backends
 	anyLogged := false
+	active := 0
+	failures := make(map[string]error)
 	for name, be := range a.backends {
 		start := time.Now()
-		err := be.backend.LogResponse(auth, req, resp, err)
+		err := be.backend.LogResponse(ctx, auth, req, resp, err)
 		metrics.MeasureSince([]string{"audit", name, "log_response"}, start)
-		if err != nil {
-			a.logger.Printf("[ERR] audit: backend '%s' failed to log response: %v", name, err)
+		if err == errAuditRateLimited {
+			metrics.IncrCounter([]string{"audit", name, "rate_limited"}, 1)
+		}
+		if be.shadow {
+			countShadow(name, err)
 		} else {
+			active++
+		}
+		if err != nil {
+			failures[name] = err
+		} else if !be.shadow {
 			anyLogged = true
 		}
 	}
-	if !anyLogged && len(a.backends) > 0 {
+	a.trackHealth(ctx, failures)
+	if !anyLogged && active > 0 {
+		metrics.IncrCounter([]string{"audit", "log_response_failure"}, 1)
+		a.reportFailure("response", req, failures)
 		return fmt.Errorf("no audit backend succeeded in logging the response")
 	}
+	for name, err := range failures {
+		if err == errAuditRateLimited {
+			continue
+		}
+		if a.backends[name].shadow {
+			a.logger.Printf("[WARN] audit: shadow backend '%s' failed to log response: %v", name, err)
+			continue
+		}
+		a.logger.Printf("[ERR] audit: backend '%s' failed to log response: %v", name, err)
+	}
 	return nil
 }
+
+// countShadow counts the entries a shadow backend logged, or failed to
+// log, other than the ones dropped by its rate limit.
+func countShadow(name string, err error) {
+	switch err {
+	case nil:
+		metrics.IncrCounter([]string{"audit", name, "shadow_success"}, 1)
+	case errAuditRateLimited:
+	default:
+		metrics.IncrCounter([]string{"audit", name, "shadow_failure"}, 1)
+	}
+}
+
+// removeAuditData returns the request with its data replaced by a marker
+// if the path is in auditRemoveDataPaths, and otherwise the request as is.
+// The request is copied rather than modified.
+func removeAuditData(req *logical.Request) *logical.Request {
+	if req == nil || req.Data == nil {
+		return req
+	}
+	for _, prefix := range auditRemoveDataPaths {
+		if strings.HasPrefix(req.Path, prefix) {
+			cp := *req
+			cp.Data = map[string]interface{}{auditRemovedDataKey: true}
+			return &cp
+		}
+	}
+	return req
+}
+
+// trackHealth records which backends failed to log an entry. When a
+// backend fails after having succeeded, or the other way around, the
+// change is logged through the backends that succeeded and implement
+// audit.StatusLogger, so that the audit log documents gaps in coverage.
+// The status is logged with the context of the entry, so that it is
+// aborted along with it. The read lock must be held.
+func (a *AuditBroker) trackHealth(ctx context.Context, failures map[string]error) {
+	var changes []*audit.Status
+	now := a.clock()
+	a.healthLock.Lock()
+	for name := range a.backends {
+		// Dropping an entry because of the rate limit isn't a failure
+		err, failed := failures[name]
+		if !failed {
+			a.lastSuccess[name] = now
+		}
+		if err == errAuditRateLimited || failed == a.failed[name] {
+			continue
+		}
+
+		status := &audit.Status{Backend: name, Healthy: !failed}
+		if failed {
+			status.Error = err.Error()
+			a.failed[name] = true
+		} else {
+			delete(a.failed, name)
+		}
+		changes = append(changes, status)
+	}
+	a.healthLock.Unlock()
+
+	for _, status := range changes {
+		if status.Healthy {
+			a.logger.Printf("[INFO] audit: backend '%s' recovered", status.Backend)
+		}
+		for name, be := range a.backends {
+			if _, failed := failures[name]; failed {
+				continue
+			}
+			sl, ok := be.backend.(audit.StatusLogger)
+			if !ok {
+				continue
+			}
+			if err := sl.LogStatus(ctx, status); err != nil {
+				a.logger.Printf(
+					"[ERR] audit: backend '%s' failed to log status of '%s': %v",
+					name, status.Backend, err)
+			}
+		}
+	}
+}
+
+// reportFailure logs a single line for an entry that no backend succeeded
+// in logging, naming every failing backend. During an outage every entry
+// fails, so the lines are rate limited to one per
+// auditFailureReportInterval and the failures in between are counted.
+func (a *AuditBroker) reportFailure(
+	kind string, req *logical.Request, failures map[string]error) {
+	a.failureLock.Lock()
+	defer a.failureLock.Unlock()
+
+	now := a.clock()
+	if now.Sub(a.lastFailureReport) < auditFailureReportInterval {
+		a.suppressed++
+		return
+	}
+	suppressed := a.suppressed
+	a.lastFailureReport = now
+	a.suppressed = 0
+
+	names := make([]string, 0, len(failures))
+	for name := range failures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	errs := make([]string, 0, len(names))
+	for _, name := range names {
+		errs = append(errs, fmt.Sprintf("%s: %v", name, failures[name]))
+	}
+
+	// The client token is left out, since only the backends have a salt
+	// to hash it with, and a hash without a salt could be matched to the
+	// token outside the audit log
+	a.logger.Printf(
+		"[ERR] audit: no backend succeeded in logging the %s: "+
+			"path=%q backends=[%s] suppressed=%d",
+		kind, req.Path, strings.Join(errs, "; "), suppressed)
+}
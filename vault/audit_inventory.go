@@ -0,0 +1,89 @@
+package vault
+
+import (
+	"sort"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+)
+
+// auditInventory returns the audit backends, mounts, and credential
+// backends enabled, sorted by path. The options of the audit backends are
+// the ones listed by listAudits, without the sensitive ones.
+func (c *Core) auditInventory() *audit.Inventory {
+	inv := new(audit.Inventory)
+	for _, entry := range c.listAudits() {
+		inv.Audits = append(inv.Audits, audit.InventoryEntry{
+			Path:        entry.Path,
+			Type:        entry.Type,
+			Description: entry.Description,
+			Options:     entry.Options,
+		})
+	}
+	inv.Mounts = inventoryEntries(c.mounts)
+	inv.Auths = inventoryEntries(c.auth)
+	return inv
+}
+
+// inventoryEntries returns the entries of a mount table sorted by path,
+// without their options.
+func inventoryEntries(table *MountTable) []audit.InventoryEntry {
+	if table == nil {
+		return nil
+	}
+
+	table.RLock()
+	entries := make([]*MountEntry, len(table.Entries))
+	copy(entries, table.Entries)
+	table.RUnlock()
+
+	sort.Sort(mountEntriesByPath(entries))
+	result := make([]audit.InventoryEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, audit.InventoryEntry{
+			Path:        entry.Path,
+			Type:        entry.Type,
+			Description: entry.Description,
+		})
+	}
+	return result
+}
+
+// logAuditInventory logs an "inventory" entry through the audit backends,
+// so that the audit log records the configuration the Vault was unsealed
+// with.
+func (c *Core) logAuditInventory() {
+	c.auditLock.RLock()
+	broker := c.auditBroker
+	c.auditLock.RUnlock()
+	if broker == nil {
+		return
+	}
+	broker.LogInventory(c.auditContext(), c.auditInventory())
+}
+
+// logAuditInventoryPeriodically logs the inventory at every interval until
+// stopCh is closed by preSeal. The state lock is held while logging so
+// that the mount tables aren't torn down meanwhile; a tick that races
+// with the seal finds stopCh closed once it gets the lock and returns.
+func (c *Core) logAuditInventoryPeriodically(stopCh chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return
+		}
+
+		c.stateLock.RLock()
+		select {
+		case <-stopCh:
+			c.stateLock.RUnlock()
+			return
+		default:
+		}
+		c.logAuditInventory()
+		c.stateLock.RUnlock()
+	}
+}
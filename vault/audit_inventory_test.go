@@ -0,0 +1,142 @@
+package vault
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/physical"
+	"golang.org/x/net/context"
+)
+
+// inventoryAudit is a NoopAudit that records the inventories it logs.
+type inventoryAudit struct {
+	NoopAudit
+	Inventories []*audit.Inventory
+	l           sync.Mutex
+}
+
+func (i *inventoryAudit) LogInventory(ctx context.Context, inv *audit.Inventory) error {
+	i.l.Lock()
+	defer i.l.Unlock()
+	i.Inventories = append(i.Inventories, inv)
+	return nil
+}
+
+func (i *inventoryAudit) count() int {
+	i.l.Lock()
+	defer i.l.Unlock()
+	return len(i.Inventories)
+}
+
+func TestCore_AuditInventory(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+	a := &inventoryAudit{}
+	c.auditBackends["inventory"] = func(map[string]string) (audit.Backend, error) {
+		return a, nil
+	}
+
+	me := &MountEntry{
+		Path:        "foo",
+		Type:        "inventory",
+		Description: "inventory",
+		Options:     map[string]string{"salt": "bar", "format": "json"},
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(a.Inventories) != 0 {
+		t.Fatalf("bad: %#v", a.Inventories)
+	}
+
+	// The inventory is logged when the Vault is unsealed
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c.Unseal(TestKeyCopy(key)); err != nil || !unseal {
+		t.Fatalf("err: %v", err)
+	}
+	if len(a.Inventories) != 1 {
+		t.Fatalf("bad: %#v", a.Inventories)
+	}
+
+	inv := a.Inventories[0]
+	expected := []audit.InventoryEntry{{
+		Path:        "foo/",
+		Type:        "inventory",
+		Description: "inventory",
+		Options:     map[string]string{"format": "json"},
+	}}
+	if !reflect.DeepEqual(inv.Audits, expected) {
+		t.Fatalf("bad: %#v", inv.Audits)
+	}
+
+	expected = []audit.InventoryEntry{
+		{Path: "secret/", Type: "generic", Description: "generic secret storage"},
+		{Path: "sys/", Type: "system", Description: "system endpoints used for control, policy and debugging"},
+	}
+	if !reflect.DeepEqual(inv.Mounts, expected) {
+		t.Fatalf("bad: %#v", inv.Mounts)
+	}
+	if len(inv.Auths) != 1 || inv.Auths[0].Path != "token/" || inv.Auths[0].Type != "token" {
+		t.Fatalf("bad: %#v", inv.Auths)
+	}
+}
+
+func TestCore_AuditInventoryInterval(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+	a := &inventoryAudit{}
+	c.auditBackends["inventory"] = func(map[string]string) (audit.Backend, error) {
+		return a, nil
+	}
+	me := &MountEntry{
+		Path: "foo",
+		Type: "inventory",
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The interval is below the minimum, which only NewCore enforces
+	c.auditInventoryInterval = 10 * time.Millisecond
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c.Unseal(TestKeyCopy(key)); err != nil || !unseal {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The inventory is logged again while the Vault is unsealed
+	deadline := time.Now().Add(2 * time.Second)
+	for a.count() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("bad: %d", a.count())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// and no longer once it is sealed
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	count := a.count()
+	time.Sleep(50 * time.Millisecond)
+	if a.count() != count {
+		t.Fatalf("bad: %d != %d", a.count(), count)
+	}
+}
+
+func TestNewCore_auditInventoryInterval(t *testing.T) {
+	for _, interval := range []time.Duration{-time.Minute, time.Second} {
+		_, err := NewCore(&CoreConfig{
+			Physical:               physical.NewInmem(),
+			DisableMlock:           true,
+			AuditInventoryInterval: interval,
+		})
+		if err == nil {
+			t.Fatalf("expected error: %s", interval)
+		}
+	}
+}
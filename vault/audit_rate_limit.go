@@ -0,0 +1,248 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+const (
+	// auditRateLimitOption is the option of an audit backend that limits
+	// the number of entries it logs per second. There is no limit by
+	// default.
+	auditRateLimitOption = "max_events_per_second"
+
+	// auditRateLimitOverflowOption is the option of an audit backend that
+	// sets what happens to the entries beyond its rate limit, one of the
+	// auditOverflow policies. It defaults to auditOverflowDrop.
+	auditRateLimitOverflowOption = "rate_limit_overflow"
+
+	// auditOverflowQueue makes the entries wait for the rate to allow
+	// them, up to auditRateLimitMaxWait, after which they are dropped.
+	auditOverflowQueue = "queue"
+
+	// auditOverflowDrop drops the entries, counting them in the
+	// audit.<path>.rate_limited metric.
+	auditOverflowDrop = "drop"
+
+	// auditOverflowSummarize drops the entries like auditOverflowDrop,
+	// and logs an audit.Summary of them before the next entry.
+	auditOverflowSummarize = "summarize"
+
+	// auditRateLimitMaxWait is the longest an entry waits for the rate
+	// limit with auditOverflowQueue, so that a sustained overload fails
+	// requests rather than queueing them without bound.
+	auditRateLimitMaxWait = 5 * time.Second
+)
+
+// errAuditRateLimited is returned by a rate limited audit backend for the
+// entries it dropped. The broker doesn't count them as logged, but they
+// don't change the health of the backend.
+var errAuditRateLimited = errors.New("audit entry dropped by the rate limit")
+
+// wrapAuditRateLimit returns the audit backend limited to the rate set by
+// its options, or the backend as is if the options set no limit.
+func wrapAuditRateLimit(
+	name string, b audit.Backend, options map[string]string) (audit.Backend, error) {
+	v, ok := options[auditRateLimitOption]
+	if !ok {
+		if _, ok := options[auditRateLimitOverflowOption]; ok {
+			return nil, fmt.Errorf("%s requires %s",
+				auditRateLimitOverflowOption, auditRateLimitOption)
+		}
+		return b, nil
+	}
+	rate, err := strconv.ParseFloat(v, 64)
+	if err != nil || rate <= 0 {
+		return nil, fmt.Errorf("invalid %s: %s", auditRateLimitOption, v)
+	}
+
+	overflow := auditOverflowDrop
+	if v, ok := options[auditRateLimitOverflowOption]; ok {
+		switch v {
+		case auditOverflowQueue, auditOverflowDrop, auditOverflowSummarize:
+			overflow = v
+		default:
+			return nil, fmt.Errorf("invalid %s: %s", auditRateLimitOverflowOption, v)
+		}
+	}
+
+	// A burst of one second of entries is allowed, and at least one
+	// entry, so that rates below one per second can be set
+	burst := rate
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimitedAuditBackend{
+		backend:  b,
+		name:     name,
+		overflow: overflow,
+		rate:     rate,
+		burst:    burst,
+		tokens:   burst,
+		last:     time.Now(),
+	}, nil
+}
+
+// rateLimitedAuditBackend limits the entries logged by an audit backend
+// with a token bucket. The status, heartbeat and summary entries are
+// passed through without being limited.
+type rateLimitedAuditBackend struct {
+	backend  audit.Backend
+	name     string
+	overflow string
+
+	// rate is the number of tokens added per second, up to burst
+	rate  float64
+	burst float64
+
+	// l protects the bucket and the entries dropped since the last
+	// summary. With auditOverflowQueue, tokens goes below zero as the
+	// waiting entries reserve the future tokens.
+	l       sync.Mutex
+	tokens  float64
+	last    time.Time
+	dropped int
+	since   time.Time
+	until   time.Time
+}
+
+func (b *rateLimitedAuditBackend) LogRequest(
+	ctx context.Context, auth *logical.Auth, req *logical.Request) error {
+	if err := b.wait(ctx); err != nil {
+		return err
+	}
+	return b.backend.LogRequest(ctx, auth, req)
+}
+
+func (b *rateLimitedAuditBackend) LogResponse(ctx context.Context, auth *logical.Auth,
+	req *logical.Request, resp *logical.Response, err error) error {
+	if err := b.wait(ctx); err != nil {
+		return err
+	}
+	return b.backend.LogResponse(ctx, auth, req, resp, err)
+}
+
+func (b *rateLimitedAuditBackend) LogStatus(ctx context.Context, status *audit.Status) error {
+	if sl, ok := b.backend.(audit.StatusLogger); ok {
+		return sl.LogStatus(ctx, status)
+	}
+	return nil
+}
+
+func (b *rateLimitedAuditBackend) LogHeartbeat(ctx context.Context, hb *audit.Heartbeat) error {
+	if hl, ok := b.backend.(audit.HeartbeatLogger); ok {
+		return hl.LogHeartbeat(ctx, hb)
+	}
+	return nil
+}
+
+func (b *rateLimitedAuditBackend) LogInventory(ctx context.Context, inv *audit.Inventory) error {
+	if il, ok := b.backend.(audit.InventoryLogger); ok {
+		return il.LogInventory(ctx, inv)
+	}
+	return nil
+}
+
+func (b *rateLimitedAuditBackend) LogAuditChange(ctx context.Context, change *audit.AuditChange) error {
+	if cl, ok := b.backend.(audit.AuditChangeLogger); ok {
+		return cl.LogAuditChange(ctx, change)
+	}
+	return nil
+}
+
+func (b *rateLimitedAuditBackend) Test(ctx context.Context, st *audit.SelfTest) error {
+	if tester, ok := b.backend.(audit.SelfTester); ok {
+		return tester.Test(ctx, st)
+	}
+	return nil
+}
+
+func (b *rateLimitedAuditBackend) SetClock(clock func() time.Time) {
+	audit.SetClock(b.backend, clock)
+}
+
+func (b *rateLimitedAuditBackend) Close() error {
+	return audit.Close(b.backend)
+}
+
+func (b *rateLimitedAuditBackend) Degraded() string {
+	if dr, ok := b.backend.(audit.DegradedReporter); ok {
+		return dr.Degraded()
+	}
+	return ""
+}
+
+// wait takes a token for an entry. It returns errAuditRateLimited if the
+// entry is dropped, or the error of the context if it is done while the
+// entry waits.
+func (b *rateLimitedAuditBackend) wait(ctx context.Context) error {
+	b.l.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	var delay time.Duration
+	if b.tokens < 1 {
+		delay = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		if b.overflow != auditOverflowQueue || delay > auditRateLimitMaxWait {
+			if b.dropped == 0 {
+				b.since = now
+			}
+			b.dropped++
+			b.until = now
+			b.l.Unlock()
+			return errAuditRateLimited
+		}
+	}
+	b.tokens--
+
+	var summary *audit.Summary
+	if b.dropped > 0 && b.overflow == auditOverflowSummarize {
+		summary = &audit.Summary{
+			Backend: b.name,
+			Dropped: b.dropped,
+			Since:   b.since,
+			Until:   b.until,
+		}
+	}
+	b.dropped = 0
+	b.l.Unlock()
+
+	if summary != nil {
+		if sl, ok := b.backend.(audit.SummaryLogger); ok {
+			if err := sl.LogSummary(ctx, summary); err != nil {
+				// Keep the count for the summary before the next entry
+				b.l.Lock()
+				if b.dropped == 0 {
+					b.until = summary.Until
+				}
+				b.dropped += summary.Dropped
+				b.since = summary.Since
+				b.l.Unlock()
+				return err
+			}
+		}
+	}
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,212 @@
+package vault
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/net/context"
+)
+
+type summaryAudit struct {
+	statusAudit
+	Summaries []*audit.Summary
+}
+
+func (s *summaryAudit) LogSummary(ctx context.Context, summary *audit.Summary) error {
+	s.Summaries = append(s.Summaries, summary)
+	return nil
+}
+
+func TestWrapAuditRateLimit(t *testing.T) {
+	cases := []struct {
+		Options map[string]string
+		Wrapped bool
+		Err     bool
+	}{
+		{map[string]string{}, false, false},
+		{map[string]string{"max_events_per_second": "100"}, true, false},
+		{map[string]string{"max_events_per_second": "0.5", "rate_limit_overflow": "queue"}, true, false},
+		{map[string]string{"max_events_per_second": "0"}, false, true},
+		{map[string]string{"max_events_per_second": "fast"}, false, true},
+		{map[string]string{"max_events_per_second": "100", "rate_limit_overflow": "block"}, false, true},
+		{map[string]string{"rate_limit_overflow": "drop"}, false, true},
+	}
+
+	for _, tc := range cases {
+		b, err := wrapAuditRateLimit("foo/", &NoopAudit{}, tc.Options)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%#v: err: %v", tc.Options, err)
+		}
+		if _, ok := b.(*rateLimitedAuditBackend); ok != tc.Wrapped {
+			t.Fatalf("%#v: bad: %#v", tc.Options, b)
+		}
+	}
+}
+
+func TestRateLimitedAuditBackend_drop(t *testing.T) {
+	noop := &NoopAudit{}
+	b, err := wrapAuditRateLimit("foo/", noop, map[string]string{
+		"max_events_per_second": "2",
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The burst is one second of entries
+	req := &logical.Request{Path: "sys/mounts"}
+	for i := 0; i < 2; i++ {
+		if err := b.LogRequest(context.Background(), nil, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if err := b.LogRequest(context.Background(), nil, req); err != errAuditRateLimited {
+		t.Fatalf("err: %v", err)
+	}
+	if len(noop.Req) != 2 {
+		t.Fatalf("bad: %#v", noop.Req)
+	}
+
+	// Tokens are added at the rate
+	time.Sleep(600 * time.Millisecond)
+	if err := b.LogResponse(context.Background(), nil, req, nil, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestRateLimitedAuditBackend_summarize(t *testing.T) {
+	a := &summaryAudit{}
+	b, err := wrapAuditRateLimit("foo/", a, map[string]string{
+		"max_events_per_second": "10",
+		"rate_limit_overflow":   "summarize",
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := &logical.Request{Path: "sys/mounts"}
+	start := time.Now()
+	for i := 0; i < 13; i++ {
+		err := b.LogRequest(context.Background(), nil, req)
+		if i < 10 && err != nil || i >= 10 && err != errAuditRateLimited {
+			t.Fatalf("%d: err: %v", i, err)
+		}
+	}
+	if len(a.Summaries) != 0 {
+		t.Fatalf("bad: %#v", a.Summaries)
+	}
+
+	// The summary is logged before the next entry
+	time.Sleep(150 * time.Millisecond)
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(a.Summaries) != 1 || len(a.Req) != 11 {
+		t.Fatalf("bad: %#v", a.Summaries)
+	}
+	s := a.Summaries[0]
+	if s.Backend != "foo/" || s.Dropped != 3 ||
+		s.Since.Before(start) || s.Until.Before(s.Since) {
+		t.Fatalf("bad: %#v", s)
+	}
+}
+
+func TestRateLimitedAuditBackend_queue(t *testing.T) {
+	noop := &NoopAudit{}
+	b, err := wrapAuditRateLimit("foo/", noop, map[string]string{
+		"max_events_per_second": "20",
+		"rate_limit_overflow":   "queue",
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := &logical.Request{Path: "sys/mounts"}
+	for i := 0; i < 20; i++ {
+		if err := b.LogRequest(context.Background(), nil, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// The next entries wait for their tokens
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := b.LogRequest(context.Background(), nil, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if d := time.Since(start); d < 80*time.Millisecond {
+		t.Fatalf("bad: %s", d)
+	}
+	if len(noop.Req) != 22 {
+		t.Fatalf("bad: %d", len(noop.Req))
+	}
+
+	// Waiting stops when the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.LogRequest(ctx, nil, req); err != context.Canceled {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestAuditBroker_RateLimited(t *testing.T) {
+	l := log.New(ioutil.Discard, "", 0)
+	broker := NewAuditBroker(l, nil)
+	limited := &statusAudit{}
+	other := &statusAudit{}
+	b, err := wrapAuditRateLimit("foo/", limited, map[string]string{
+		"max_events_per_second": "1",
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	broker.Register("foo/", b, nil)
+	broker.Register("bar/", other, nil)
+
+	// Dropped entries don't change the health of the backend
+	req := &logical.Request{Path: "sys/mounts"}
+	for i := 0; i < 3; i++ {
+		if err := broker.LogRequest(context.Background(), nil, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if len(limited.Req) != 1 || len(other.Req) != 3 || len(other.Statuses) != 0 {
+		t.Fatalf("bad: %#v %#v", limited, other)
+	}
+
+	// Dropped entries aren't logged
+	broker.Deregister("bar/")
+	if err := broker.LogRequest(context.Background(), nil, req); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
+func TestCore_EnableAudit_RateLimit(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.auditBackends["noop"] = func(map[string]string) (audit.Backend, error) {
+		return &NoopAudit{}, nil
+	}
+
+	me := &MountEntry{
+		Path:    "foo",
+		Type:    "noop",
+		Options: map[string]string{"max_events_per_second": "-1"},
+	}
+	if err := c.enableAudit(me, nil); err == nil {
+		t.Fatalf("should fail")
+	}
+
+	me.Options["max_events_per_second"] = "100"
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c.auditBroker.l.RLock()
+	defer c.auditBroker.l.RUnlock()
+	if _, ok := c.auditBroker.backends["foo/"].backend.(*rateLimitedAuditBackend); !ok {
+		t.Fatalf("bad: %#v", c.auditBroker.backends["foo/"])
+	}
+}
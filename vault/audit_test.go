@@ -1,15 +1,24 @@
 package vault
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"math/rand"
 	"os"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/audit/audittest"
 	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+	"golang.org/x/net/context"
 )
 
 type NoopAudit struct {
@@ -24,13 +33,13 @@ type NoopAudit struct {
 	RespErrs []error
 }
 
-func (n *NoopAudit) LogRequest(a *logical.Auth, r *logical.Request) error {
+func (n *NoopAudit) LogRequest(ctx context.Context, a *logical.Auth, r *logical.Request) error {
 	n.ReqAuth = append(n.ReqAuth, a)
 	n.Req = append(n.Req, r)
 	return n.ReqErr
 }
 
-func (n *NoopAudit) LogResponse(a *logical.Auth, r *logical.Request, re *logical.Response, err error) error {
+func (n *NoopAudit) LogResponse(ctx context.Context, a *logical.Auth, r *logical.Request, re *logical.Response, err error) error {
 	n.RespAuth = append(n.RespAuth, a)
 	n.RespReq = append(n.RespReq, r)
 	n.Resp = append(n.Resp, re)
@@ -38,6 +47,23 @@ func (n *NoopAudit) LogResponse(a *logical.Auth, r *logical.Request, re *logical
 	return n.RespErr
 }
 
+type ReplayAudit struct {
+	NoopAudit
+	Entries []string
+}
+
+func (r *ReplayAudit) Pending() (int, error) {
+	return len(r.Entries), nil
+}
+
+func (r *ReplayAudit) Replay(progress func()) error {
+	for len(r.Entries) > 0 {
+		r.Entries = r.Entries[1:]
+		progress()
+	}
+	return nil
+}
+
 func TestCore_EnableAudit(t *testing.T) {
 	c, key, _ := TestCoreUnsealed(t)
 	c.auditBackends["noop"] = func(map[string]string) (audit.Backend, error) {
@@ -48,7 +74,7 @@ func TestCore_EnableAudit(t *testing.T) {
 		Path: "foo",
 		Type: "noop",
 	}
-	err := c.enableAudit(me)
+	err := c.enableAudit(me, nil)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -88,13 +114,82 @@ func TestCore_EnableAudit(t *testing.T) {
 	}
 }
 
+func TestCore_EnableAudit_PersistFailure(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.auditBackends["noop"] = func(map[string]string) (audit.Backend, error) {
+		return &NoopAudit{}, nil
+	}
+
+	// Seal the barrier so the audit table cannot be persisted
+	if err := c.barrier.Seal(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	me := &MountEntry{
+		Path: "foo",
+		Type: "noop",
+	}
+	err := c.enableAudit(me, nil)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if !errwrap.Contains(err, ErrBarrierSealed.Error()) {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Nothing should have been registered or added to the table
+	if c.auditBroker.IsRegistered("foo/") {
+		t.Fatalf("audit backend present")
+	}
+	if len(c.audit.Entries) != 0 {
+		t.Fatalf("bad: %#v", c.audit.Entries)
+	}
+}
+
+func TestCore_DisableAudit_shadow(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.auditBackends["noop"] = func(map[string]string) (audit.Backend, error) {
+		return &NoopAudit{}, nil
+	}
+
+	for _, me := range []*MountEntry{
+		{Path: "foo", Type: "noop"},
+		{Path: "bar", Type: "noop", Options: map[string]string{"mode": "shadow"}},
+		{Path: "baz", Type: "noop", Options: map[string]string{"mode": "shadow"}},
+	} {
+		if err := c.enableAudit(me, nil); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// The shadow backends left don't audit, so force is still required
+	err := c.disableAudit("foo", false, nil)
+	if err == nil || err.Error() != "cannot disable the last audit backend without force" {
+		t.Fatalf("err: %v", err)
+	}
+
+	// while the shadow backends themselves are disabled without it
+	if err := c.disableAudit("bar", false, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.disableAudit("foo", true, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.disableAudit("baz", false, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if c.auditBroker.Count() != 0 || len(c.audit.Entries) != 0 {
+		t.Fatalf("bad: %#v", c.audit.Entries)
+	}
+}
+
 func TestCore_DisableAudit(t *testing.T) {
 	c, key, _ := TestCoreUnsealed(t)
 	c.auditBackends["noop"] = func(map[string]string) (audit.Backend, error) {
 		return &NoopAudit{}, nil
 	}
 
-	err := c.disableAudit("foo")
+	err := c.disableAudit("foo", false, nil)
 	if err.Error() != "no matching backend" {
 		t.Fatalf("err: %v", err)
 	}
@@ -103,12 +198,17 @@ func TestCore_DisableAudit(t *testing.T) {
 		Path: "foo",
 		Type: "noop",
 	}
-	err = c.enableAudit(me)
+	err = c.enableAudit(me, nil)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
-	err = c.disableAudit("foo")
+	err = c.disableAudit("foo", false, nil)
+	if err.Error() != "cannot disable the last audit backend without force" {
+		t.Fatalf("err: %v", err)
+	}
+
+	err = c.disableAudit("foo", true, nil)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -140,6 +240,344 @@ func TestCore_DisableAudit(t *testing.T) {
 	}
 }
 
+// auditChangeAudit is a NoopAudit that records the audit changes it logs.
+type auditChangeAudit struct {
+	NoopAudit
+	Changes []*audit.AuditChange
+}
+
+func (a *auditChangeAudit) LogAuditChange(ctx context.Context, change *audit.AuditChange) error {
+	a.Changes = append(a.Changes, change)
+	return nil
+}
+
+func TestCore_LogAuditChange(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	a := &auditChangeAudit{}
+	c.auditBackends["change"] = func(map[string]string) (audit.Backend, error) {
+		return a, nil
+	}
+	c.auditBackends["noop"] = func(map[string]string) (audit.Backend, error) {
+		return &NoopAudit{}, nil
+	}
+
+	actor := &audit.AuditActor{DisplayName: "root", RemoteAddr: "127.0.0.1", ClientType: "cli"}
+	me := &MountEntry{
+		Path:    "foo",
+		Type:    "change",
+		Options: map[string]string{"salt": "bar", "format": "json"},
+	}
+	if err := c.enableAudit(me, actor); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	me = &MountEntry{
+		Path:    "bar",
+		Type:    "noop",
+		Options: map[string]string{"path": "/tmp/audit.log", "token": "secret"},
+	}
+	if err := c.enableAudit(me, actor); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The new mode is logged, along with the sensitive options stored in
+	// the view, redacted
+	if err := c.tuneAuditMode("foo", "shadow", actor); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.rotateAuditSalt("foo", actor); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Nothing is logged for the changes that are refused
+	if err := c.tuneAuditMode("foo", "trial", actor); err == nil {
+		t.Fatalf("expected error")
+	}
+	if err := c.disableAudit("baz", true, actor); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// The backend being disabled logs its own removal
+	if err := c.disableAudit("foo", false, actor); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expected := []*audit.AuditChange{
+		{
+			Operation: "enable",
+			Path:      "bar/",
+			Type:      "noop",
+			Options:   map[string]string{"path": "/tmp/audit.log", "token": redactedAuditOption},
+			Actor:     *actor,
+		},
+		{
+			Operation: "tune",
+			Path:      "foo/",
+			Type:      "change",
+			Options:   map[string]string{"format": "json", "mode": "shadow", "salt": redactedAuditOption},
+			Actor:     *actor,
+		},
+		{
+			Operation: "rotate",
+			Path:      "foo/",
+			Type:      "change",
+			Options: map[string]string{
+				"format":               "json",
+				"mode":                 "shadow",
+				"salt":                 redactedAuditOption,
+				auditSaltVersionOption: redactedAuditOption,
+			},
+			Actor: *actor,
+		},
+		{
+			Operation: "disable",
+			Path:      "foo/",
+			Type:      "change",
+			Options: map[string]string{
+				"format":               "json",
+				"mode":                 "shadow",
+				"salt":                 redactedAuditOption,
+				auditSaltVersionOption: redactedAuditOption,
+			},
+			Actor: *actor,
+		},
+	}
+	if !reflect.DeepEqual(a.Changes, expected) {
+		for _, change := range a.Changes {
+			t.Logf("%#v", change)
+		}
+		t.Fatalf("bad: %#v", a.Changes)
+	}
+}
+
+// selfTestAudit is a NoopAudit that records its self-tests, which fail
+// with Err if set.
+type selfTestAudit struct {
+	NoopAudit
+	Err   error
+	Tests []*audit.SelfTest
+}
+
+func (a *selfTestAudit) Test(ctx context.Context, st *audit.SelfTest) error {
+	a.Tests = append(a.Tests, st)
+	return a.Err
+}
+
+func TestAuditBroker_Register_selfTest(t *testing.T) {
+	l := log.New(ioutil.Discard, "", 0)
+	b := NewAuditBroker(l, nil)
+	a := &selfTestAudit{}
+	if err := b.Register("foo/", a, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(a.Tests) != 1 || a.Tests[0].Backend != "foo/" || b.failed["foo/"] {
+		t.Fatalf("bad: %#v", a.Tests)
+	}
+
+	// A backend failing its test is registered, but flagged as failed
+	a = &selfTestAudit{Err: fmt.Errorf("no such file")}
+	if err := b.Register("bar/", a, nil); err == nil {
+		t.Fatalf("should fail")
+	}
+	if !b.IsRegistered("bar/") || !b.failed["bar/"] {
+		t.Fatalf("bad: %#v", b.failed)
+	}
+}
+
+func TestCore_EnableAudit_selfTest(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+	a := &selfTestAudit{}
+	c.auditBackends["test"] = func(map[string]string) (audit.Backend, error) {
+		return a, nil
+	}
+
+	me := &MountEntry{
+		Path: "foo",
+		Type: "test",
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(a.Tests) != 1 {
+		t.Fatalf("bad: %#v", a.Tests)
+	}
+
+	// The backend is tested again on unseal, and flagged if it fails
+	a.Err = fmt.Errorf("collector unreachable")
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c.Unseal(TestKeyCopy(key)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(a.Tests) != 2 || !c.auditBroker.failed["foo/"] {
+		t.Fatalf("bad: %#v", a.Tests)
+	}
+
+	// A backend failing its test can't be enabled
+	me = &MountEntry{
+		Path: "bar",
+		Type: "test",
+	}
+	if err := c.enableAudit(me, nil); err == nil {
+		t.Fatalf("should fail")
+	}
+	if c.audit.Find("bar/") != nil || c.auditBroker.IsRegistered("bar/") {
+		t.Fatalf("bad: %#v", c.audit)
+	}
+}
+
+// closeAudit is a selfTestAudit that counts the times it is closed.
+type closeAudit struct {
+	selfTestAudit
+	Closed int
+}
+
+func (a *closeAudit) Close() error {
+	a.Closed++
+	return nil
+}
+
+func TestAuditBroker_Close(t *testing.T) {
+	l := log.New(ioutil.Discard, "", 0)
+	b := NewAuditBroker(l, nil)
+	a1 := &closeAudit{}
+	if err := b.Register("foo/", a1, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Registering the same backend again doesn't close it, but replacing
+	// it with another does
+	if err := b.Register("foo/", a1, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if a1.Closed != 0 {
+		t.Fatalf("bad: %d", a1.Closed)
+	}
+	a2 := &closeAudit{}
+	if err := b.Register("foo/", a2, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if a1.Closed != 1 || a2.Closed != 0 {
+		t.Fatalf("bad: %d %d", a1.Closed, a2.Closed)
+	}
+
+	b.Deregister("foo/")
+	if a2.Closed != 1 {
+		t.Fatalf("bad: %d", a2.Closed)
+	}
+
+	a3 := &closeAudit{}
+	if err := b.Register("bar/", a3, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	b.Close()
+	if a3.Closed != 1 || b.IsRegistered("bar/") {
+		t.Fatalf("bad: %d", a3.Closed)
+	}
+}
+
+func TestCore_EnableAudit_close(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	a := &closeAudit{}
+	c.auditBackends["test"] = func(map[string]string) (audit.Backend, error) {
+		return a, nil
+	}
+
+	// A backend failing its test is closed
+	a.Err = fmt.Errorf("collector unreachable")
+	me := &MountEntry{
+		Path: "foo",
+		Type: "test",
+	}
+	if err := c.enableAudit(me, nil); err == nil {
+		t.Fatalf("should fail")
+	}
+	if a.Closed != 1 {
+		t.Fatalf("bad: %d", a.Closed)
+	}
+
+	// An enabled backend is closed when it is disabled, or when the Vault
+	// is sealed
+	a.Err, a.Closed = nil, 0
+	me = &MountEntry{
+		Path: "foo",
+		Type: "test",
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if a.Closed != 0 {
+		t.Fatalf("bad: %d", a.Closed)
+	}
+	if err := c.disableAudit("foo", true, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if a.Closed != 1 {
+		t.Fatalf("bad: %d", a.Closed)
+	}
+
+	a.Closed = 0
+	me = &MountEntry{
+		Path: "foo",
+		Type: "test",
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if a.Closed != 1 {
+		t.Fatalf("bad: %d", a.Closed)
+	}
+}
+
+func TestCore_ReplayAudit(t *testing.T) {
+	c, key, _ := TestCoreUnsealed(t)
+	replay := &ReplayAudit{Entries: []string{"a", "b", "c"}}
+	c.auditBackends["replay"] = func(map[string]string) (audit.Backend, error) {
+		return replay, nil
+	}
+
+	me := &MountEntry{
+		Path: "foo",
+		Type: "replay",
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Nothing is replayed until the backend is setup after an unseal
+	if status := c.AuditReplayStatus(); status.Pending != 0 {
+		t.Fatalf("bad: %#v", status)
+	}
+
+	conf := &CoreConfig{
+		Physical:      c.physical,
+		AuditBackends: c.auditBackends,
+		DisableMlock:  true,
+	}
+	c2, err := NewCore(conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c2.Unseal(key); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Pending entries should have been flushed
+	if len(replay.Entries) != 0 {
+		t.Fatalf("bad: %#v", replay.Entries)
+	}
+	expected := AuditReplayStatus{
+		Pending:  3,
+		Replayed: 3,
+	}
+	if status := c2.AuditReplayStatus(); status != expected {
+		t.Fatalf("bad: %#v", status)
+	}
+}
+
 func TestCore_DefaultAuditTable(t *testing.T) {
 	c, key, _ := TestCoreUnsealed(t)
 	verifyDefaultAuditTable(t, c.audit)
@@ -185,7 +623,7 @@ func verifyDefaultAuditTable(t *testing.T, table *MountTable) {
 
 func TestAuditBroker_LogRequest(t *testing.T) {
 	l := log.New(os.Stderr, "", log.LstdFlags)
-	b := NewAuditBroker(l)
+	b := NewAuditBroker(l, nil)
 	a1 := &NoopAudit{}
 	a2 := &NoopAudit{}
 	b.Register("foo", a1, nil)
@@ -204,7 +642,7 @@ func TestAuditBroker_LogRequest(t *testing.T) {
 		Path:      "sys/mounts",
 	}
 
-	err := b.LogRequest(auth, req)
+	err := b.LogRequest(context.Background(), auth, req)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -220,20 +658,20 @@ func TestAuditBroker_LogRequest(t *testing.T) {
 
 	// Should still work with one failing backend
 	a1.ReqErr = fmt.Errorf("failed")
-	if err := b.LogRequest(auth, req); err != nil {
+	if err := b.LogRequest(context.Background(), auth, req); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
 	// Should FAIL work with both failing backends
 	a2.ReqErr = fmt.Errorf("failed")
-	if err := b.LogRequest(auth, req); err.Error() != "no audit backend succeeded in logging the request" {
+	if err := b.LogRequest(context.Background(), auth, req); err.Error() != "no audit backend succeeded in logging the request" {
 		t.Fatalf("err: %v", err)
 	}
 }
 
 func TestAuditBroker_LogResponse(t *testing.T) {
 	l := log.New(os.Stderr, "", log.LstdFlags)
-	b := NewAuditBroker(l)
+	b := NewAuditBroker(l, nil)
 	a1 := &NoopAudit{}
 	a2 := &NoopAudit{}
 	b.Register("foo", a1, nil)
@@ -264,7 +702,7 @@ func TestAuditBroker_LogResponse(t *testing.T) {
 	}
 	respErr := fmt.Errorf("permission denied")
 
-	err := b.LogResponse(auth, req, resp, respErr)
+	err := b.LogResponse(context.Background(), auth, req, resp, respErr)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -286,15 +724,1120 @@ func TestAuditBroker_LogResponse(t *testing.T) {
 
 	// Should still work with one failing backend
 	a1.RespErr = fmt.Errorf("failed")
-	err = b.LogResponse(auth, req, resp, respErr)
+	err = b.LogResponse(context.Background(), auth, req, resp, respErr)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
 	// Should FAIL work with both failing backends
 	a2.RespErr = fmt.Errorf("failed")
-	err = b.LogResponse(auth, req, resp, respErr)
+	err = b.LogResponse(context.Background(), auth, req, resp, respErr)
 	if err.Error() != "no audit backend succeeded in logging the response" {
 		t.Fatalf("err: %v", err)
 	}
 }
+
+func TestCore_RequireAudit(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	c.requireAudit = true
+
+	// Secret paths are refused without an audit backend
+	req := &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "secret/foo",
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != ErrAuditRequired {
+		t.Fatalf("err: %v", err)
+	}
+
+	// System paths are still allowed
+	req = &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "sys/mounts",
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// and so are logins and the other credential paths, so that an
+	// operator can log in to enable an audit backend
+	c.credentialBackends["noop"] = func(map[string]string) (logical.Backend, error) {
+		return &NoopBackend{Login: []string{"login"}}, nil
+	}
+	req = logical.TestRequest(t, logical.WriteOperation, "sys/auth/foo")
+	req.Data["type"] = "noop"
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req = &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "auth/foo/login",
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req = &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "auth/token/lookup-self",
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A backend in shadow mode doesn't count
+	me := &MountEntry{
+		Path:    "bar",
+		Type:    "noop",
+		Options: map[string]string{"mode": "shadow"},
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req = &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "secret/foo",
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != ErrAuditRequired {
+		t.Fatalf("err: %v", err)
+	}
+
+	me = &MountEntry{
+		Path: "foo",
+		Type: "noop",
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Secret paths are allowed once audited
+	req = &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "secret/foo",
+		ClientToken: root,
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestCore_AuditTable(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.auditBackends["noop"] = func(map[string]string) (audit.Backend, error) {
+		return &NoopAudit{}, nil
+	}
+
+	me := &MountEntry{
+		Path: "foo",
+		Type: "noop",
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Modifying the returned table should not affect the core
+	table := c.auditTable()
+	if len(table.Entries) != 1 || table.Entries[0].Path != "foo/" {
+		t.Fatalf("bad: %#v", table.Entries)
+	}
+	table.Entries[0].Path = "bar/"
+	table.Entries = nil
+	if c.audit.Entries[0].Path != "foo/" {
+		t.Fatalf("bad: %#v", c.audit.Entries)
+	}
+
+	// Nothing is loaded after teardown
+	if err := c.teardownAudits(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if table := c.auditTable(); table != nil {
+		t.Fatalf("bad: %#v", table)
+	}
+}
+
+func TestCore_ListAudits(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.auditBackends["noop"] = func(map[string]string) (audit.Backend, error) {
+		return &NoopAudit{}, nil
+	}
+
+	for _, path := range []string{"foo", "bar"} {
+		me := &MountEntry{
+			Path: path,
+			Type: "noop",
+			Options: map[string]string{
+				"address": "127.0.0.1",
+			},
+		}
+		if err := c.enableAudit(me, nil); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Tables persisted by older versions may hold sensitive options
+	c.audit.Entries[0].Options["Password"] = "hunter2"
+
+	entries := c.listAudits()
+	if len(entries) != 2 {
+		t.Fatalf("bad: %#v", entries)
+	}
+	if entries[0].Path != "bar/" || entries[1].Path != "foo/" {
+		t.Fatalf("bad: %#v", entries)
+	}
+
+	expected := map[string]string{
+		"address":  "127.0.0.1",
+		"Password": redactedAuditOption,
+	}
+	if !reflect.DeepEqual(entries[1].Options, expected) {
+		t.Fatalf("bad: %#v", entries[1].Options)
+	}
+
+	// The stored options must not be modified
+	if c.audit.Entries[0].Options["Password"] != "hunter2" {
+		t.Fatalf("bad: %#v", c.audit.Entries[0].Options)
+	}
+}
+
+func TestCore_EnableAudit_SensitiveOptions(t *testing.T) {
+	c, key, _ := TestCoreUnsealed(t)
+	var conf map[string]string
+	c.auditBackends["noop"] = func(c map[string]string) (audit.Backend, error) {
+		conf = c
+		return &NoopAudit{}, nil
+	}
+
+	options := map[string]string{
+		"address":    "127.0.0.1",
+		"auth_token": "abcd",
+	}
+	me := &MountEntry{
+		Path:    "foo",
+		Type:    "noop",
+		Options: options,
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(conf, options) {
+		t.Fatalf("bad: %#v", conf)
+	}
+
+	// The token must only be stored in the barrier view
+	expected := map[string]string{
+		"address": "127.0.0.1",
+	}
+	if !reflect.DeepEqual(c.audit.Entries[0].Options, expected) {
+		t.Fatalf("bad: %#v", c.audit.Entries[0].Options)
+	}
+	view := NewBarrierView(c.barrier, auditBarrierPrefix+me.UUID+"/")
+	raw, err := view.Get(auditOptionsKey)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if raw == nil {
+		t.Fatalf("missing audit options")
+	}
+
+	// The backend should receive all the options after an unseal
+	conf = nil
+	c2, err := NewCore(&CoreConfig{
+		Physical:      c.physical,
+		AuditBackends: c.auditBackends,
+		DisableMlock:  true,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c2.Unseal(key); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(conf, options) {
+		t.Fatalf("bad: %#v", conf)
+	}
+
+	// Disabling clears the stored options
+	if err := c2.disableAudit("foo", true, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	raw, err = view.Get(auditOptionsKey)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if raw != nil {
+		t.Fatalf("bad: %#v", raw)
+	}
+}
+
+// orderAudit records the order in which entries are delivered to it. It
+// is safe for concurrent use.
+type orderAudit struct {
+	l       sync.Mutex
+	entries []string
+}
+
+func (o *orderAudit) LogRequest(ctx context.Context, a *logical.Auth, r *logical.Request) error {
+	// Give other requests a chance to interleave
+	time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+
+	o.l.Lock()
+	defer o.l.Unlock()
+	o.entries = append(o.entries, "request:"+r.Path)
+	return nil
+}
+
+func (o *orderAudit) LogResponse(ctx context.Context, a *logical.Auth, r *logical.Request, re *logical.Response, err error) error {
+	o.l.Lock()
+	defer o.l.Unlock()
+	o.entries = append(o.entries, "response:"+r.Path)
+	return nil
+}
+
+func TestAuditBroker_Ordering(t *testing.T) {
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	b := NewAuditBroker(l, nil)
+	a1 := &orderAudit{}
+	a2 := &orderAudit{}
+	b.Register("foo", a1, nil)
+	b.Register("bar", a2, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      fmt.Sprintf("secret/%d", i),
+			}
+			if err := b.LogRequest(context.Background(), nil, req); err != nil {
+				t.Errorf("err: %v", err)
+				return
+			}
+			if err := b.LogResponse(context.Background(), nil, req, nil, nil); err != nil {
+				t.Errorf("err: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, a := range []*orderAudit{a1, a2} {
+		if len(a.entries) != 100 {
+			t.Fatalf("bad: %#v", a.entries)
+		}
+
+		// Every response must be preceded by its request
+		seen := make(map[string]bool)
+		for _, e := range a.entries {
+			if strings.HasPrefix(e, "request:") {
+				seen[strings.TrimPrefix(e, "request:")] = true
+				continue
+			}
+			if path := strings.TrimPrefix(e, "response:"); !seen[path] {
+				t.Fatalf("response before request for %s: %#v", path, a.entries)
+			}
+		}
+	}
+}
+
+func TestAuditBroker_LastSuccess(t *testing.T) {
+	l := log.New(ioutil.Discard, "", 0)
+	now := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := NewTestAuditClock(now)
+	b := NewAuditBroker(l, &AuditBrokerConfig{Clock: clock.Now})
+	a1 := &NoopAudit{}
+	a2 := &NoopAudit{ReqErr: fmt.Errorf("disk full")}
+	b.Register("foo", a1, nil)
+	b.Register("bar", a2, nil)
+	if actual := b.LastSuccess(); len(actual) != 0 {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+	clock.Advance(time.Minute)
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := map[string]time.Time{"foo": now.Add(time.Minute)}
+	if actual := b.LastSuccess(); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// The failing backend is tracked once it recovers
+	a2.ReqErr = nil
+	clock.Advance(time.Minute)
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected = map[string]time.Time{
+		"foo": now.Add(2 * time.Minute),
+		"bar": now.Add(2 * time.Minute),
+	}
+	if actual := b.LastSuccess(); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	b.Deregister("bar")
+	if actual := b.LastSuccess(); len(actual) != 1 {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestAuditBroker_shadow(t *testing.T) {
+	l := log.New(ioutil.Discard, "", 0)
+	b := NewAuditBroker(l, nil)
+	a1 := &NoopAudit{ReqErr: fmt.Errorf("disk full")}
+	a2 := &NoopAudit{}
+	b.Register("foo", a1, nil)
+	b.RegisterShadow("bar", a2, nil)
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "secret/foo",
+	}
+
+	// The success of a shadow backend doesn't count
+	if err := b.LogRequest(context.Background(), nil, req); err == nil {
+		t.Fatalf("should fail")
+	}
+	if len(a2.Req) != 1 {
+		t.Fatalf("bad: %#v", a2.Req)
+	}
+
+	// and neither does its failure
+	a1.ReqErr = nil
+	a2.ReqErr = fmt.Errorf("connection refused")
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	b.Deregister("foo")
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestCore_EnableAudit_shadow(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.auditBackends["noop"] = func(map[string]string) (audit.Backend, error) {
+		return &NoopAudit{}, nil
+	}
+
+	me := &MountEntry{
+		Path:    "foo",
+		Type:    "noop",
+		Options: map[string]string{"mode": "shadow"},
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !c.auditBroker.backends["foo/"].shadow {
+		t.Fatalf("should be shadow")
+	}
+
+	me = &MountEntry{
+		Path:    "bar",
+		Type:    "noop",
+		Options: map[string]string{"mode": "trial"},
+	}
+	if err := c.enableAudit(me, nil); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
+// degradedAudit is a NoopAudit that reports a fixed degradation.
+type degradedAudit struct {
+	NoopAudit
+	reason string
+}
+
+func (d *degradedAudit) Degraded() string {
+	return d.reason
+}
+
+func TestAuditBroker_clock(t *testing.T) {
+	clock := NewTestAuditClock(time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC))
+	l := log.New(ioutil.Discard, "", 0)
+	b := NewAuditBroker(l, &AuditBrokerConfig{Clock: clock.Now})
+
+	// The backend behind the rate limit gets the clock when registered
+	a := &audittest.Backend{}
+	limited, err := wrapAuditRateLimit("foo", a,
+		map[string]string{"max_events_per_second": "10"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := b.Register("foo", limited, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := &logical.Request{Operation: logical.ReadOperation, Path: "secret/foo"}
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	clock.Advance(time.Minute)
+	if err := b.LogResponse(context.Background(), nil, req, nil, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if actual := a.Requests()[0].Time; !actual.Equal(time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("bad: %s", actual)
+	}
+	if actual := a.Responses()[0].Time; !actual.Equal(time.Date(2016, 1, 2, 3, 5, 5, 0, time.UTC)) {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestAuditBroker_Degraded(t *testing.T) {
+	l := log.New(ioutil.Discard, "", 0)
+	b := NewAuditBroker(l, nil)
+	b.Register("foo", &degradedAudit{reason: "disk full"}, nil)
+	b.Register("bar", &degradedAudit{}, nil)
+	b.Register("baz", &NoopAudit{}, nil)
+
+	// The rate limit is looked through
+	limited, err := wrapAuditRateLimit("qux", &degradedAudit{reason: "disk low"},
+		map[string]string{"max_events_per_second": "10"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	b.Register("qux", limited, nil)
+
+	expected := map[string]string{"foo": "disk full", "qux": "disk low"}
+	if actual := b.Degraded(); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestCore_TuneAuditMode(t *testing.T) {
+	c, key, root := TestCoreUnsealed(t)
+	a := &NoopAudit{}
+	c.auditBackends["noop"] = func(map[string]string) (audit.Backend, error) {
+		return a, nil
+	}
+
+	me := &MountEntry{
+		Path:    "foo",
+		Type:    "noop",
+		Options: map[string]string{"mode": "shadow"},
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The backend is promoted in place
+	if err := c.tuneAuditMode("foo", "enforced", nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	be := c.auditBroker.backends["foo/"]
+	if be.shadow || be.backend != audit.Backend(a) {
+		t.Fatalf("bad: %#v", be)
+	}
+
+	// and stays promoted once the Vault is unsealed again
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if unseal, err := c.Unseal(TestKeyCopy(key)); err != nil || !unseal {
+		t.Fatalf("err: %v", err)
+	}
+	if c.audit.Find("foo/").Options["mode"] != "enforced" {
+		t.Fatalf("bad: %#v", c.audit.Find("foo/").Options)
+	}
+	if c.auditBroker.backends["foo/"].shadow {
+		t.Fatalf("should not be shadow")
+	}
+
+	if err := c.tuneAuditMode("foo", "shadow", nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !c.auditBroker.backends["foo/"].shadow {
+		t.Fatalf("should be shadow")
+	}
+
+	for _, mode := range []string{"", "trial"} {
+		if err := c.tuneAuditMode("foo", mode, nil); err == nil {
+			t.Fatalf("expected error: %q", mode)
+		}
+	}
+	if err := c.tuneAuditMode("bar", "shadow", nil); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestAuditBroker_FailureReport(t *testing.T) {
+	var buf bytes.Buffer
+	l := log.New(&buf, "", 0)
+	clock := NewTestAuditClock(time.Now())
+	b := NewAuditBroker(l, &AuditBrokerConfig{Clock: clock.Now})
+	a1 := &NoopAudit{ReqErr: fmt.Errorf("disk full")}
+	a2 := &NoopAudit{ReqErr: fmt.Errorf("connection refused")}
+	b.Register("foo", a1, nil)
+	b.Register("bar", a2, nil)
+
+	req := &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "secret/foo",
+		ClientToken: "foo",
+	}
+
+	// Only the first failure is reported, the rest are counted
+	for i := 0; i < 3; i++ {
+		if err := b.LogRequest(context.Background(), nil, req); err == nil {
+			t.Fatal("should error")
+		}
+	}
+
+	expected := `[ERR] audit: no backend succeeded in logging the request: ` +
+		`path="secret/foo" ` +
+		`backends=[bar: connection refused; foo: disk full] suppressed=0` + "\n"
+	if actual := buf.String(); actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+	if strings.Contains(buf.String(), "token") {
+		t.Fatalf("token should be left out: %s", buf.String())
+	}
+
+	// Once the interval has passed, the suppressed failures are reported
+	buf.Reset()
+	clock.Advance(auditFailureReportInterval)
+	if err := b.LogRequest(context.Background(), nil, req); err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.HasSuffix(buf.String(), "suppressed=2\n") {
+		t.Fatalf("bad: %s", buf.String())
+	}
+}
+
+func TestNewCore_RegisteredAudits(t *testing.T) {
+	registered := func(map[string]string) (audit.Backend, error) {
+		return &NoopAudit{}, nil
+	}
+	audit.Register("test-registered", registered)
+	audit.Register("test-overridden", registered)
+
+	override := func(map[string]string) (audit.Backend, error) {
+		return nil, fmt.Errorf("overridden")
+	}
+	c, err := NewCore(&CoreConfig{
+		Physical:     physical.NewInmem(),
+		DisableMlock: true,
+		AuditBackends: map[string]audit.Factory{
+			"test-overridden": override,
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, ok := c.auditBackends["test-registered"]; !ok {
+		t.Fatalf("bad: %#v", c.auditBackends)
+	}
+	if _, err := c.auditBackends["test-overridden"](nil); err == nil {
+		t.Fatal("config should override the registered backend")
+	}
+}
+
+// statusAudit is a NoopAudit that records the status changes of the
+// other backends.
+type statusAudit struct {
+	NoopAudit
+	Statuses []*audit.Status
+}
+
+func (s *statusAudit) LogStatus(ctx context.Context, status *audit.Status) error {
+	s.Statuses = append(s.Statuses, status)
+	return nil
+}
+
+func TestAuditBroker_Status(t *testing.T) {
+	l := log.New(ioutil.Discard, "", 0)
+	b := NewAuditBroker(l, nil)
+	a1 := &statusAudit{}
+	a2 := &statusAudit{}
+	b.Register("foo/", a1, nil)
+	b.Register("bar/", a2, nil)
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "sys/mounts",
+	}
+
+	// No changes while both are healthy
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(a1.Statuses) != 0 || len(a2.Statuses) != 0 {
+		t.Fatalf("bad: %#v %#v", a1.Statuses, a2.Statuses)
+	}
+
+	// The failure is logged once through the healthy backend
+	a1.ReqErr = fmt.Errorf("disk full")
+	for i := 0; i < 2; i++ {
+		if err := b.LogRequest(context.Background(), nil, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	expected := []*audit.Status{
+		{Backend: "foo/", Healthy: false, Error: "disk full"},
+	}
+	if !reflect.DeepEqual(a2.Statuses, expected) {
+		t.Fatalf("bad: %#v", a2.Statuses)
+	}
+	if len(a1.Statuses) != 0 {
+		t.Fatalf("bad: %#v", a1.Statuses)
+	}
+
+	// The recovery is logged through both
+	a1.ReqErr = nil
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	recovered := &audit.Status{Backend: "foo/", Healthy: true}
+	if !reflect.DeepEqual(a2.Statuses[1:], []*audit.Status{recovered}) {
+		t.Fatalf("bad: %#v", a2.Statuses)
+	}
+	if !reflect.DeepEqual(a1.Statuses, []*audit.Status{recovered}) {
+		t.Fatalf("bad: %#v", a1.Statuses)
+	}
+}
+
+func TestCore_SealCancelsAudit(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	ctx := c.auditContext()
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := ctx.Err(); err != context.Canceled {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestAuditBroker_RemoveData(t *testing.T) {
+	l := log.New(ioutil.Discard, "", 0)
+	b := NewAuditBroker(l, nil)
+	a := &NoopAudit{}
+	b.Register("foo/", a, nil)
+
+	req := &logical.Request{
+		Operation: logical.WriteOperation,
+		Path:      "sys/unseal",
+		Data: map[string]interface{}{
+			"key": "abcd",
+		},
+	}
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := b.LogResponse(context.Background(), nil, req, nil, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expected := map[string]interface{}{"removed": true}
+	if !reflect.DeepEqual(a.Req[0].Data, expected) {
+		t.Fatalf("bad: %#v", a.Req[0].Data)
+	}
+	if !reflect.DeepEqual(a.RespReq[0].Data, expected) {
+		t.Fatalf("bad: %#v", a.RespReq[0].Data)
+	}
+
+	// The original request is not modified
+	if req.Data["key"] != "abcd" {
+		t.Fatalf("bad: %#v", req.Data)
+	}
+
+	// Other paths are logged as is
+	req.Path = "secret/foo"
+	if err := b.LogRequest(context.Background(), nil, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if a.Req[1] != req {
+		t.Fatalf("bad: %#v", a.Req[1])
+	}
+}
+
+// heartbeatAudit is a NoopAudit that sends its heartbeats on a channel.
+type heartbeatAudit struct {
+	NoopAudit
+	Heartbeats chan *audit.Heartbeat
+}
+
+func (h *heartbeatAudit) LogHeartbeat(ctx context.Context, hb *audit.Heartbeat) error {
+	h.Heartbeats <- hb
+	return nil
+}
+
+func TestAuditBroker_Heartbeat(t *testing.T) {
+	l := log.New(ioutil.Discard, "", 0)
+	b := NewAuditBroker(l, nil)
+	a := &heartbeatAudit{Heartbeats: make(chan *audit.Heartbeat)}
+	b.Register("foo/", a, nil)
+	b.StartHeartbeat(context.Background(), "foo/", 10*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case hb := <-a.Heartbeats:
+			if hb.Backend != "foo/" {
+				t.Fatalf("bad: %#v", hb)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no heartbeat")
+		}
+	}
+
+	// No heartbeats once deregistered. A heartbeat may already be in
+	// flight when the backend is deregistered.
+	b.Deregister("foo/")
+	select {
+	case <-a.Heartbeats:
+	case <-time.After(50 * time.Millisecond):
+	}
+	select {
+	case hb := <-a.Heartbeats:
+		t.Fatalf("bad: %#v", hb)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCore_EnableAudit_Heartbeat(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.auditBackends["noop"] = func(map[string]string) (audit.Backend, error) {
+		return &NoopAudit{}, nil
+	}
+
+	for _, v := range []string{"foo", "10ms"} {
+		me := &MountEntry{
+			Path:    "foo",
+			Type:    "noop",
+			Options: map[string]string{"heartbeat_interval": v},
+		}
+		if err := c.enableAudit(me, nil); err == nil {
+			t.Fatalf("%s: should fail", v)
+		}
+	}
+
+	me := &MountEntry{
+		Path:    "foo",
+		Type:    "noop",
+		Options: map[string]string{"heartbeat_interval": "1m"},
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !c.auditBroker.IsRegistered("foo/") {
+		t.Fatalf("missing audit backend")
+	}
+}
+
+func TestCore_AuditStorageErrors(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.auditBackends["noop"] = func(map[string]string) (audit.Backend, error) {
+		return &NoopAudit{}, nil
+	}
+	if errs := c.AuditStorageErrors(); len(errs) != 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+
+	// Seal the barrier so the sensitive options cannot be stored
+	if err := c.barrier.Seal(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	me := &MountEntry{
+		Path:    "foo",
+		Type:    "noop",
+		Options: map[string]string{"salt": "bar"},
+	}
+	if err := c.enableAudit(me, nil); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	expected := map[string]int{"foo/": 1}
+	if errs := c.AuditStorageErrors(); !reflect.DeepEqual(errs, expected) {
+		t.Fatalf("bad: %#v", errs)
+	}
+}
+
+func TestCore_RotateAuditSalt(t *testing.T) {
+	c, key, _ := TestCoreUnsealed(t)
+	var confs []map[string]string
+	factory := func(conf map[string]string) (audit.Backend, error) {
+		confs = append(confs, conf)
+		return &NoopAudit{}, nil
+	}
+	c.auditBackends["noop"] = factory
+
+	me := &MountEntry{
+		Path:    "foo",
+		Type:    "noop",
+		Options: map[string]string{"salt": "bar"},
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	version, err := c.rotateAuditSalt("foo", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if version != 1 {
+		t.Fatalf("bad: %d", version)
+	}
+	rotated := confs[len(confs)-1]
+	if rotated["salt"] == "" || rotated["salt"] == "bar" || rotated["salt_version"] != "1" {
+		t.Fatalf("bad: %#v", rotated)
+	}
+
+	// The rotated salt is used after an unseal
+	if _, err := c.rotateAuditSalt("foo", nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	rotated = confs[len(confs)-1]
+
+	conf := &CoreConfig{
+		Physical:      c.physical,
+		AuditBackends: map[string]audit.Factory{"noop": factory},
+		DisableMlock:  true,
+	}
+	c2, err := NewCore(conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := c2.Unseal(key); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if actual := confs[len(confs)-1]; !reflect.DeepEqual(actual, rotated) {
+		t.Fatalf("bad: %#v %#v", actual, rotated)
+	}
+
+	if _, err := c.rotateAuditSalt("bar", nil); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
+func TestCore_RotateAuditSalt_close(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	var backends []*closeAudit
+	var testErr error
+	var seal bool
+	c.auditBackends["test"] = func(map[string]string) (audit.Backend, error) {
+		a := &closeAudit{}
+		a.Err = testErr
+		backends = append(backends, a)
+		if seal {
+			// Seal the barrier so the rotated options cannot be
+			// persisted
+			if err := c.barrier.Seal(); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+
+	me := &MountEntry{
+		Path: "foo",
+		Type: "test",
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The backend with the previous salt is closed once the rotated one
+	// is registered
+	if _, err := c.rotateAuditSalt("foo", nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(backends) != 2 || backends[0].Closed != 1 || backends[1].Closed != 0 {
+		t.Fatalf("bad: %#v", backends)
+	}
+
+	// The rotated backend is closed if it fails its self-test
+	testErr = fmt.Errorf("collector unreachable")
+	if _, err := c.rotateAuditSalt("foo", nil); err == nil {
+		t.Fatalf("should fail")
+	}
+	if len(backends) != 3 || backends[1].Closed != 0 || backends[2].Closed != 1 {
+		t.Fatalf("bad: %#v", backends)
+	}
+
+	// or if its options can't be stored
+	testErr, seal = nil, true
+	if _, err := c.rotateAuditSalt("foo", nil); err == nil {
+		t.Fatalf("should fail")
+	}
+	if len(backends) != 4 || backends[1].Closed != 0 || backends[3].Closed != 1 {
+		t.Fatalf("bad: %#v", backends)
+	}
+}
+
+func TestCore_AuditHealthy_storage(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.auditBackends["noop"] = func(map[string]string) (audit.Backend, error) {
+		return &NoopAudit{}, nil
+	}
+	me := &MountEntry{
+		Path: "foo",
+		Type: "noop",
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	c.auditStorageError("foo/", "read", fmt.Errorf("barrier sealed"))
+	if c.AuditHealthy() {
+		t.Fatalf("should not be healthy")
+	}
+
+	// The backend is healthy again once its storage is read and written,
+	// but the failure stays counted
+	if _, err := c.rotateAuditSalt("foo", nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !c.AuditHealthy() {
+		t.Fatalf("should be healthy")
+	}
+	if errs := c.AuditStorageErrors(); !reflect.DeepEqual(errs, map[string]int{"foo/": 1}) {
+		t.Fatalf("bad: %#v", errs)
+	}
+}
+
+func TestAuditBroker_config(t *testing.T) {
+	b := NewAuditBroker(nil, nil)
+	if b.Now().IsZero() || b.NewID() == b.NewID() {
+		t.Fatalf("bad: %s", b.Now())
+	}
+
+	now := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := NewTestAuditClock(now)
+	b = NewAuditBroker(nil, &AuditBrokerConfig{
+		Clock: clock.Now,
+		NewID: TestAuditIDs("id-"),
+	})
+	clock.Advance(time.Minute)
+	if actual := b.Now(); !actual.Equal(now.Add(time.Minute)) {
+		t.Fatalf("bad: %s", actual)
+	}
+	if id1, id2 := b.NewID(), b.NewID(); id1 != "id-1" || id2 != "id-2" {
+		t.Fatalf("bad: %s %s", id1, id2)
+	}
+}
+
+func TestCore_AuditBrokerConfig(t *testing.T) {
+	now := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	c, err := NewCore(&CoreConfig{
+		Physical: physical.NewInmem(),
+		AuditBackends: map[string]audit.Factory{
+			"noop": func(map[string]string) (audit.Backend, error) {
+				return &NoopAudit{}, nil
+			},
+		},
+		DisableMlock: true,
+		AuditBroker: &AuditBrokerConfig{
+			Clock: NewTestAuditClock(now).Now,
+			NewID: TestAuditIDs("audit-"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	key, _ := TestCoreInit(t, c)
+	if _, err := c.Unseal(TestKeyCopy(key)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The views of the audit backends are named by the broker
+	me := &MountEntry{
+		Path:    "foo",
+		Type:    "noop",
+		Options: map[string]string{"salt": "bar", "rotation_window": "1h"},
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if me.UUID != "audit-1" {
+		t.Fatalf("bad: %s", me.UUID)
+	}
+
+	// and the rotation window ends on its clock
+	if _, err := c.rotateAuditSalt("foo", nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	view := NewBarrierView(c.barrier, auditBarrierPrefix+me.UUID+"/")
+	options, err := loadAuditOptions(view, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if options["previous_salt_until"] != "2016-01-02T04:04:05Z" {
+		t.Fatalf("bad: %#v", options)
+	}
+}
+
+func TestCore_RotateAuditSalt_window(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	var confs []map[string]string
+	c.auditBackends["noop"] = func(conf map[string]string) (audit.Backend, error) {
+		confs = append(confs, conf)
+		return &NoopAudit{}, nil
+	}
+
+	me := &MountEntry{
+		Path:    "foo",
+		Type:    "noop",
+		Options: map[string]string{"salt": "bar", "rotation_window": "1h"},
+	}
+	if err := c.enableAudit(me, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The previous salt is kept for the window
+	if _, err := c.rotateAuditSalt("foo", nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	rotated := confs[len(confs)-1]
+	if rotated["previous_salt"] != "bar" {
+		t.Fatalf("bad: %#v", rotated)
+	}
+	until, err := time.Parse(time.RFC3339, rotated["previous_salt_until"])
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if d := until.Sub(time.Now()); d < 59*time.Minute || d > time.Hour {
+		t.Fatalf("bad: %s", until)
+	}
+	hash, err := audit.ParseHashConfig(rotated)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if hash.PreviousSalt != "bar" || hash.Salt == "bar" {
+		t.Fatalf("bad: %#v", hash)
+	}
+
+	// and replaced on the next rotation
+	if _, err := c.rotateAuditSalt("foo", nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if actual := confs[len(confs)-1]; actual["previous_salt"] != rotated["salt"] {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// The window must be a valid duration
+	me = &MountEntry{
+		Path:    "bar",
+		Type:    "noop",
+		Options: map[string]string{"rotation_window": "forever"},
+	}
+	if err := c.enableAudit(me, nil); err == nil {
+		t.Fatalf("should fail")
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/physical"
 	"github.com/hashicorp/vault/shamir"
+	"golang.org/x/net/context"
 )
 
 const (
@@ -72,6 +73,10 @@ var (
 	// ErrHANotEnabled is returned if the operation only makes sense
 	// in an HA setting
 	ErrHANotEnabled = errors.New("Vault is not configured for highly-available mode")
+
+	// ErrAuditRequired is returned if an audit backend is required
+	// but none are enabled. Only system paths are allowed in this state.
+	ErrAuditRequired = errors.New("no audit backend is enabled")
 )
 
 // SealConfig is used to describe the seal configuration
@@ -186,13 +191,46 @@ type Core struct {
 	auth *MountTable
 
 	// audit is loaded after unseal since it is a protected
-	// configuration
-	audit *MountTable
+	// configuration. The auditLock must be held to access or
+	// swap the table, the embedded table lock is not used.
+	audit     *MountTable
+	auditLock sync.RWMutex
 
 	// auditBroker is used to ingest the audit events and fan
 	// out into the configured audit backends
 	auditBroker *AuditBroker
 
+	// auditCtx is given to the audit backends and is canceled when the
+	// Vault begins to seal. It has its own lock since it is canceled
+	// before the state lock is acquired.
+	auditCtx     context.Context
+	auditCancel  context.CancelFunc
+	auditCtxLock sync.Mutex
+
+	// auditReplay tracks the progress of replaying pending audit entries.
+	// It has its own lock since it is read while the state lock is held.
+	auditReplay     AuditReplayStatus
+	auditReplayLock sync.RWMutex
+
+	// auditStorageErrors counts the failed reads and writes of the barrier
+	// views of the audit backends, by path, and auditStorageFailing has
+	// the paths whose last read or write failed. They have their own lock
+	// since they are read by the health check.
+	auditStorageErrors  map[string]int
+	auditStorageFailing map[string]bool
+	auditStorageLock    sync.Mutex
+
+	// requireAudit refuses requests to secret paths if there are
+	// no audit backends enabled
+	requireAudit bool
+
+	// auditBrokerConfig is given to the audit brokers created on unseal
+	auditBrokerConfig *AuditBrokerConfig
+
+	// auditInventoryInterval is the interval at which the inventory is
+	// logged again while the Vault is unsealed, if not zero
+	auditInventoryInterval time.Duration
+
 	// systemView is the barrier view for the system backend
 	systemView *BarrierView
 
@@ -212,6 +250,9 @@ type Core struct {
 	// metricsCh is used to stop the metrics streaming
 	metricsCh chan struct{}
 
+	// auditInventoryCh is used to stop logging the inventory periodically
+	auditInventoryCh chan struct{}
+
 	logger *log.Logger
 }
 
@@ -226,6 +267,15 @@ type CoreConfig struct {
 	DisableMlock       bool   // Disables mlock syscall
 	CacheSize          int    // Custom cache size of zero for default
 	AdvertiseAddr      string // Set as the leader address for HA
+	RequireAudit       bool   // Refuse secret requests without an audit backend
+
+	// AuditInventoryInterval is the interval at which the audit inventory
+	// is logged again, so that archives keep a recent one. Zero disables it.
+	AuditInventoryInterval time.Duration
+
+	// AuditBroker sets the clock and the identifiers of the audit broker,
+	// for tests. Nil uses the real implementations.
+	AuditBroker *AuditBrokerConfig
 }
 
 // NewCore isk used to construct a new core
@@ -251,6 +301,13 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		}
 	}
 
+	// Validate the audit inventory interval
+	if conf.AuditInventoryInterval < 0 ||
+		(conf.AuditInventoryInterval > 0 && conf.AuditInventoryInterval < auditMinInventoryInterval) {
+		return nil, fmt.Errorf("audit inventory interval must be at least %s",
+			auditMinInventoryInterval)
+	}
+
 	// Wrap the backend in a cache unless disabled
 	if !conf.DisableCache {
 		_, isCache := conf.Physical.(*physical.Cache)
@@ -297,7 +354,11 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		router:        NewRouter(),
 		sealed:        true,
 		standby:       true,
+		requireAudit:  conf.RequireAudit,
 		logger:        conf.Logger,
+
+		auditInventoryInterval: conf.AuditInventoryInterval,
+		auditBrokerConfig:      conf.AuditBroker,
 	}
 
 	// Setup the backends
@@ -320,7 +381,9 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 	}
 	c.credentialBackends = credentialBackends
 
-	auditBackends := make(map[string]audit.Factory)
+	// Start with the registered audit backends, which the configuration
+	// can extend or override
+	auditBackends := audit.Factories()
 	for k, f := range conf.AuditBackends {
 		auditBackends[k] = f
 	}
@@ -333,6 +396,9 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 // problem. It is only used to gracefully quit in the case of HA so that failover
 // happens as quickly as possible.
 func (c *Core) Shutdown() error {
+	// Abort any audit entries blocking requests, which hold the state lock
+	c.cancelAudits()
+
 	c.stateLock.Lock()
 	defer c.stateLock.Unlock()
 	if c.sealed {
@@ -354,6 +420,10 @@ func (c *Core) HandleRequest(req *logical.Request) (resp *logical.Response, err
 		return nil, ErrStandby
 	}
 
+	// Attach the mount serving the request, might be used by audit backends
+	req.MountPoint, req.MountType = c.router.MatchingMountType(req.Path)
+	req.StartTime = c.auditBroker.Now()
+
 	if c.router.LoginPath(req.Path) {
 		resp, err = c.handleLoginRequest(req)
 	} else {
@@ -393,8 +463,13 @@ func (c *Core) handleRequest(req *logical.Request) (*logical.Response, error) {
 	// Attach the display name
 	req.DisplayName = auth.DisplayName
 
+	// Refuse the request if it cannot be audited
+	if err := c.checkAuditRequired(req.Path); err != nil {
+		return nil, err
+	}
+
 	// Create an audit trail of the request
-	if err := c.auditBroker.LogRequest(auth, req); err != nil {
+	if err := c.auditBroker.LogRequest(c.auditContext(), auth, req); err != nil {
 		c.logger.Printf("[ERR] core: failed to audit request (%#v): %v",
 			req, err)
 		return nil, ErrInternalError
@@ -457,7 +532,8 @@ func (c *Core) handleRequest(req *logical.Request) (*logical.Response, error) {
 	}
 
 	// Create an audit trail of the response
-	if err := c.auditBroker.LogResponse(auth, req, resp, err); err != nil {
+	req.EndTime = c.auditBroker.Now()
+	if err := c.auditBroker.LogResponse(c.auditContext(), auth, req, resp, err); err != nil {
 		c.logger.Printf("[ERR] core: failed to audit response (request: %#v, response: %#v): %v",
 			req, resp, err)
 		return nil, ErrInternalError
@@ -472,8 +548,13 @@ func (c *Core) handleRequest(req *logical.Request) (*logical.Response, error) {
 func (c *Core) handleLoginRequest(req *logical.Request) (*logical.Response, error) {
 	defer metrics.MeasureSince([]string{"core", "handle_login_request"}, time.Now())
 
+	// Refuse the request if it cannot be audited
+	if err := c.checkAuditRequired(req.Path); err != nil {
+		return nil, err
+	}
+
 	// Create an audit trail of the request, auth is not available on login requests
-	if err := c.auditBroker.LogRequest(nil, req); err != nil {
+	if err := c.auditBroker.LogRequest(c.auditContext(), nil, req); err != nil {
 		c.logger.Printf("[ERR] core: failed to audit request (%#v): %v",
 			req, err)
 		return nil, ErrInternalError
@@ -495,9 +576,8 @@ func (c *Core) handleLoginRequest(req *logical.Request) (*logical.Response, erro
 		auth = resp.Auth
 
 		// Determine the source of the login
-		source := c.router.MatchingMount(req.Path)
-		source = strings.TrimPrefix(source, credentialRoutePrefix)
-		source = strings.Replace(source, "/", "-", -1)
+		auth.Method = c.authMethod(req.Path)
+		source := strings.Replace(auth.Method, "/", "-", -1)
 
 		// Prepend the source to the display name
 		auth.DisplayName = strings.TrimSuffix(source+auth.DisplayName, "-")
@@ -539,7 +619,8 @@ func (c *Core) handleLoginRequest(req *logical.Request) (*logical.Response, erro
 	}
 
 	// Create an audit trail of the response
-	if err := c.auditBroker.LogResponse(auth, req, resp, err); err != nil {
+	req.EndTime = c.auditBroker.Now()
+	if err := c.auditBroker.LogResponse(c.auditContext(), auth, req, resp, err); err != nil {
 		c.logger.Printf("[ERR] core: failed to audit response (request: %#v, response: %#v): %v",
 			req, resp, err)
 		return nil, ErrInternalError
@@ -594,14 +675,24 @@ func (c *Core) checkToken(
 
 	// Create the auth response
 	auth := &logical.Auth{
-		ClientToken: token,
-		Policies:    te.Policies,
-		Metadata:    te.Meta,
-		DisplayName: te.DisplayName,
+		ClientToken:  token,
+		Policies:     te.Policies,
+		Metadata:     te.Meta,
+		DisplayName:  te.DisplayName,
+		Method:       c.authMethod(te.Path),
+		Capabilities: acl.Capabilities(path),
 	}
 	return auth, nil
 }
 
+// authMethod returns the mount path of the credential backend handling
+// the given login path, without the credential route prefix. It is empty
+// if the backend is no longer mounted.
+func (c *Core) authMethod(path string) string {
+	mount := c.router.MatchingMount(path)
+	return strings.TrimPrefix(mount, credentialRoutePrefix)
+}
+
 // Initialized checks if the Vault is already initialized
 func (c *Core) Initialized() (bool, error) {
 	// Check the barrier first
@@ -933,6 +1024,22 @@ func (c *Core) Unseal(key []byte) (bool, error) {
 // be unsealed again to perform any further operations.
 func (c *Core) Seal(token string) error {
 	defer metrics.MeasureSince([]string{"core", "seal"}, time.Now())
+
+	// Validate the token before acquiring the state lock, so that any
+	// audit entries blocking requests, which hold the state lock, can be
+	// aborted
+	c.stateLock.RLock()
+	if c.sealed {
+		c.stateLock.RUnlock()
+		return nil
+	}
+	_, err := c.checkToken(logical.WriteOperation, "sys/seal", token)
+	c.stateLock.RUnlock()
+	if err != nil {
+		return err
+	}
+	c.cancelAudits()
+
 	c.stateLock.Lock()
 	defer c.stateLock.Unlock()
 	if c.sealed {
@@ -940,8 +1047,7 @@ func (c *Core) Seal(token string) error {
 	}
 
 	// Validate the token is a root token
-	_, err := c.checkToken(logical.WriteOperation, "sys/seal", token)
-	if err != nil {
+	if _, err := c.checkToken(logical.WriteOperation, "sys/seal", token); err != nil {
 		return err
 	}
 
@@ -1246,6 +1352,11 @@ func (c *Core) postUnseal() error {
 	if err := c.setupAudits(); err != nil {
 		return err
 	}
+	c.logAuditInventory()
+	if c.auditInventoryInterval > 0 {
+		c.auditInventoryCh = make(chan struct{})
+		go c.logAuditInventoryPeriodically(c.auditInventoryCh, c.auditInventoryInterval)
+	}
 	c.metricsCh = make(chan struct{})
 	go c.emitMetrics(c.metricsCh)
 	c.logger.Printf("[INFO] core: post-unseal setup complete")
@@ -1266,6 +1377,10 @@ func (c *Core) preSeal() error {
 		close(c.metricsCh)
 		c.metricsCh = nil
 	}
+	if c.auditInventoryCh != nil {
+		close(c.auditInventoryCh)
+		c.auditInventoryCh = nil
+	}
 	if err := c.teardownAudits(); err != nil {
 		return err
 	}
@@ -1492,6 +1607,7 @@ func (c *Core) emitMetrics(stopCh chan struct{}) {
 		select {
 		case <-time.After(time.Second):
 			c.expiration.emitMetrics()
+			c.emitAuditMetrics()
 		case <-stopCh:
 			return
 		}
@@ -877,9 +877,18 @@ func TestCore_HandleRequest_AuditTrail(t *testing.T) {
 	if len(auth.Policies) != 1 || auth.Policies[0] != "root" {
 		t.Fatalf("bad: %#v", auth)
 	}
+	if auth.Method != "token/" {
+		t.Fatalf("bad: %#v", auth)
+	}
+	if !reflect.DeepEqual(auth.Capabilities, []string{"read", "write", "sudo"}) {
+		t.Fatalf("bad: %#v", auth)
+	}
 	if len(noop.Req) != 1 || !reflect.DeepEqual(noop.Req[0], req) {
 		t.Fatalf("Bad: %#v", noop.Req[0])
 	}
+	if noop.Req[0].MountPoint != "secret/" || noop.Req[0].MountType != "generic" {
+		t.Fatalf("bad: %#v", noop.Req[0])
+	}
 
 	if len(noop.RespAuth) != 2 {
 		t.Fatalf("bad: %#v", noop)
@@ -893,6 +902,11 @@ func TestCore_HandleRequest_AuditTrail(t *testing.T) {
 	if len(noop.Resp) != 2 || !reflect.DeepEqual(noop.Resp[1], resp) {
 		t.Fatalf("Bad: %#v", noop.Resp[1])
 	}
+
+	// The response is audited with the timing of the request
+	if r := noop.RespReq[1]; r.StartTime.IsZero() || r.EndTime.Before(r.StartTime) {
+		t.Fatalf("bad: %v %v", r.StartTime, r.EndTime)
+	}
 }
 
 // Ensure we get a client token
@@ -972,6 +986,9 @@ func TestCore_HandleLogin_AuditTrail(t *testing.T) {
 	if len(auth.Policies) != 2 || auth.Policies[0] != "foo" || auth.Policies[1] != "bar" {
 		t.Fatalf("bad: %#v", auth)
 	}
+	if auth.Method != "foo/" {
+		t.Fatalf("bad: %#v", auth)
+	}
 	if len(noop.RespReq) != 2 || !reflect.DeepEqual(noop.RespReq[1], lreq) {
 		t.Fatalf("Bad: %#v", noop.RespReq[1])
 	}
@@ -21,7 +21,7 @@ func TestExpiration_Restore(t *testing.T) {
 	noop := &NoopBackend{}
 	_, barrier, _ := mockBarrier(t)
 	view := NewBarrierView(barrier, "logical/")
-	exp.router.Mount(noop, "prod/aws/", generateUUID(), view)
+	exp.router.Mount(noop, "prod/aws/", "noop", generateUUID(), view)
 
 	paths := []string{
 		"prod/aws/foo",
@@ -174,7 +174,7 @@ func TestExpiration_Revoke(t *testing.T) {
 	noop := &NoopBackend{}
 	_, barrier, _ := mockBarrier(t)
 	view := NewBarrierView(barrier, "logical/")
-	exp.router.Mount(noop, "prod/aws/", generateUUID(), view)
+	exp.router.Mount(noop, "prod/aws/", "noop", generateUUID(), view)
 
 	req := &logical.Request{
 		Operation: logical.ReadOperation,
@@ -212,7 +212,7 @@ func TestExpiration_RevokeOnExpire(t *testing.T) {
 	noop := &NoopBackend{}
 	_, barrier, _ := mockBarrier(t)
 	view := NewBarrierView(barrier, "logical/")
-	exp.router.Mount(noop, "prod/aws/", generateUUID(), view)
+	exp.router.Mount(noop, "prod/aws/", "noop", generateUUID(), view)
 
 	req := &logical.Request{
 		Operation: logical.ReadOperation,
@@ -261,7 +261,7 @@ func TestExpiration_RevokePrefix(t *testing.T) {
 	noop := &NoopBackend{}
 	_, barrier, _ := mockBarrier(t)
 	view := NewBarrierView(barrier, "logical/")
-	exp.router.Mount(noop, "prod/aws/", generateUUID(), view)
+	exp.router.Mount(noop, "prod/aws/", "noop", generateUUID(), view)
 
 	paths := []string{
 		"prod/aws/foo",
@@ -321,7 +321,7 @@ func TestExpiration_RevokeByToken(t *testing.T) {
 	noop := &NoopBackend{}
 	_, barrier, _ := mockBarrier(t)
 	view := NewBarrierView(barrier, "logical/")
-	exp.router.Mount(noop, "prod/aws/", generateUUID(), view)
+	exp.router.Mount(noop, "prod/aws/", "noop", generateUUID(), view)
 
 	paths := []string{
 		"prod/aws/foo",
@@ -440,7 +440,7 @@ func TestExpiration_Renew(t *testing.T) {
 	noop := &NoopBackend{}
 	_, barrier, _ := mockBarrier(t)
 	view := NewBarrierView(barrier, "logical/")
-	exp.router.Mount(noop, "prod/aws/", generateUUID(), view)
+	exp.router.Mount(noop, "prod/aws/", "noop", generateUUID(), view)
 
 	req := &logical.Request{
 		Operation: logical.ReadOperation,
@@ -502,7 +502,7 @@ func TestExpiration_Renew_NotRenewable(t *testing.T) {
 	noop := &NoopBackend{}
 	_, barrier, _ := mockBarrier(t)
 	view := NewBarrierView(barrier, "logical/")
-	exp.router.Mount(noop, "prod/aws/", generateUUID(), view)
+	exp.router.Mount(noop, "prod/aws/", "noop", generateUUID(), view)
 
 	req := &logical.Request{
 		Operation: logical.ReadOperation,
@@ -544,7 +544,7 @@ func TestExpiration_Renew_RevokeOnExpire(t *testing.T) {
 	noop := &NoopBackend{}
 	_, barrier, _ := mockBarrier(t)
 	view := NewBarrierView(barrier, "logical/")
-	exp.router.Mount(noop, "prod/aws/", generateUUID(), view)
+	exp.router.Mount(noop, "prod/aws/", "noop", generateUUID(), view)
 
 	req := &logical.Request{
 		Operation: logical.ReadOperation,
@@ -612,7 +612,7 @@ func TestExpiration_revokeEntry(t *testing.T) {
 	noop := &NoopBackend{}
 	_, barrier, _ := mockBarrier(t)
 	view := NewBarrierView(barrier, "logical/")
-	exp.router.Mount(noop, "", generateUUID(), view)
+	exp.router.Mount(noop, "", "noop", generateUUID(), view)
 
 	le := &leaseEntry{
 		LeaseID: "foo/bar/1234",
@@ -701,7 +701,7 @@ func TestExpiration_renewEntry(t *testing.T) {
 	}
 	_, barrier, _ := mockBarrier(t)
 	view := NewBarrierView(barrier, "logical/")
-	exp.router.Mount(noop, "", generateUUID(), view)
+	exp.router.Mount(noop, "", "noop", generateUUID(), view)
 
 	le := &leaseEntry{
 		LeaseID: "foo/bar/1234",
@@ -763,7 +763,7 @@ func TestExpiration_renewAuthEntry(t *testing.T) {
 	}
 	_, barrier, _ := mockBarrier(t)
 	view := NewBarrierView(barrier, "auth/foo/")
-	exp.router.Mount(noop, "auth/foo/", generateUUID(), view)
+	exp.router.Mount(noop, "auth/foo/", "noop", generateUUID(), view)
 
 	le := &leaseEntry{
 		LeaseID: "auth/foo/1234",
@@ -1,10 +1,12 @@
 package vault
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -33,6 +35,11 @@ func NewSystemBackend(core *Core) logical.Backend {
 				"policy/*",
 				"audit",
 				"audit/*",
+				"audit-rotate/*",
+				"audit-tune/*",
+				"audit-preview/*",
+				"audit-entries/*",
+				"audit-health",
 				"seal", // Must be set for Core.Seal() logic
 				"raw/*",
 				"rotate",
@@ -263,6 +270,10 @@ func NewSystemBackend(core *Core) logical.Backend {
 						Type:        framework.TypeMap,
 						Description: strings.TrimSpace(sysHelp["audit_opts"][0]),
 					},
+					"force": &framework.FieldSchema{
+						Type:        framework.TypeBool,
+						Description: strings.TrimSpace(sysHelp["audit_force"][0]),
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -274,6 +285,109 @@ func NewSystemBackend(core *Core) logical.Backend {
 				HelpDescription: strings.TrimSpace(sysHelp["audit"][1]),
 			},
 
+			&framework.Path{
+				Pattern: "audit-rotate/(?P<path>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"path": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["audit_path"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.WriteOperation: b.handleAuditRotate,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["audit-rotate"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["audit-rotate"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "audit-tune/(?P<path>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"path": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["audit_path"][0]),
+					},
+					"mode": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["audit_mode"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.WriteOperation: b.handleAuditTune,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["audit-tune"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["audit-tune"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "audit-preview/(?P<path>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"path": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["audit_path"][0]),
+					},
+					"auth": &framework.FieldSchema{
+						Type:        framework.TypeMap,
+						Description: strings.TrimSpace(sysHelp["audit_preview_auth"][0]),
+					},
+					"request": &framework.FieldSchema{
+						Type:        framework.TypeMap,
+						Description: strings.TrimSpace(sysHelp["audit_preview_request"][0]),
+					},
+					"response": &framework.FieldSchema{
+						Type:        framework.TypeMap,
+						Description: strings.TrimSpace(sysHelp["audit_preview_response"][0]),
+					},
+					"error": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["audit_preview_error"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.WriteOperation: b.handleAuditPreview,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["audit-preview"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["audit-preview"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "audit-entries/(?P<path>.+)",
+
+				Fields: map[string]*framework.FieldSchema{
+					"path": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Description: strings.TrimSpace(sysHelp["audit_path"][0]),
+					},
+				},
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handleAuditEntries,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["audit-entries"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["audit-entries"][1]),
+			},
+
+			&framework.Path{
+				Pattern: "audit-health$",
+
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handleAuditHealth,
+				},
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["audit-health"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["audit-health"][1]),
+			},
+
 			&framework.Path{
 				Pattern: "raw/(?P<path>.+)",
 
@@ -593,13 +707,10 @@ func (b *SystemBackend) handlePolicyDelete(
 // handleAuditTable handles the "audit" endpoint to provide the audit table
 func (b *SystemBackend) handleAuditTable(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	b.Core.audit.Lock()
-	defer b.Core.audit.Unlock()
-
 	resp := &logical.Response{
 		Data: make(map[string]interface{}),
 	}
-	for _, entry := range b.Core.audit.Entries {
+	for _, entry := range b.Core.listAudits() {
 		info := map[string]interface{}{
 			"type":        entry.Type,
 			"description": entry.Description,
@@ -638,8 +749,9 @@ func (b *SystemBackend) handleEnableAudit(
 	}
 
 	// Attempt enabling
-	if err := b.Core.enableAudit(me); err != nil {
-		b.Backend.Logger().Printf("[ERR] sys: enable audit %#v failed: %v", me, err)
+	if err := b.Core.enableAudit(me, auditActor(req)); err != nil {
+		b.Backend.Logger().Printf("[ERR] sys: enable audit '%s' type: %s failed: %v",
+			path, backendType, err)
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
 	return nil, nil
@@ -649,15 +761,123 @@ func (b *SystemBackend) handleEnableAudit(
 func (b *SystemBackend) handleDisableAudit(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	path := data.Get("path").(string)
+	force := data.Get("force").(bool)
 
 	// Attempt disable
-	if err := b.Core.disableAudit(path); err != nil {
+	if err := b.Core.disableAudit(path, force, auditActor(req)); err != nil {
 		b.Backend.Logger().Printf("[ERR] sys: disable audit '%s' failed: %v", path, err)
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
 	return nil, nil
 }
 
+// handleAuditRotate is used to rotate the salt of an audit backend
+func (b *SystemBackend) handleAuditRotate(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path := data.Get("path").(string)
+
+	version, err := b.Core.rotateAuditSalt(path, auditActor(req))
+	if err != nil {
+		b.Backend.Logger().Printf("[ERR] sys: rotate audit salt '%s' failed: %v", path, err)
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"salt_version": version,
+		},
+	}, nil
+}
+
+// handleAuditTune is used to switch the mode of an audit backend
+func (b *SystemBackend) handleAuditTune(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path := data.Get("path").(string)
+	mode := data.Get("mode").(string)
+
+	if err := b.Core.tuneAuditMode(path, mode, auditActor(req)); err != nil {
+		b.Backend.Logger().Printf("[ERR] sys: tune audit backend '%s' failed: %v", path, err)
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	return nil, nil
+}
+
+// handleAuditPreview is used to preview the entries of an audit backend
+func (b *SystemBackend) handleAuditPreview(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path := data.Get("path").(string)
+
+	// The sample events have the structure of the JSON entries
+	var auth audit.JSONAuth
+	var sampleReq audit.JSONRequest
+	var sampleResp audit.JSONResponse
+	for field, v := range map[string]interface{}{
+		"auth":     &auth,
+		"request":  &sampleReq,
+		"response": &sampleResp,
+	} {
+		if err := decodeAuditPreview(data.Get(field).(map[string]interface{}), v); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid %s: %s", field, err)),
+				logical.ErrInvalidRequest
+		}
+	}
+
+	reqEntry, respEntry, err := b.Core.previewAudit(path, auth.Auth(),
+		sampleReq.Request(), sampleResp.Response(),
+		audit.ParseJSONError(data.Get("error").(string)))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"request":  reqEntry,
+			"response": respEntry,
+		},
+	}, nil
+}
+
+// decodeAuditPreview decodes a sample event into the structure of its
+// JSON entries.
+func decodeAuditPreview(raw map[string]interface{}, v interface{}) error {
+	if raw == nil {
+		return nil
+	}
+	buf, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, v)
+}
+
+// handleAuditEntries is used to read the entries kept by an audit backend
+func (b *SystemBackend) handleAuditEntries(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path := data.Get("path").(string)
+
+	entries, err := b.Core.auditEntries(path)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"entries": entries,
+		},
+	}, nil
+}
+
+// handleAuditHealth returns the health of each audit backend. It is kept
+// out of sys/health, which is unauthenticated, since it discloses the
+// paths of the backends.
+func (b *SystemBackend) handleAuditHealth(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"storage_errors": b.Core.AuditStorageErrors(),
+			"last_success":   b.Core.AuditLastSuccess(),
+			"degraded":       b.Core.AuditDegraded(),
+		},
+	}, nil
+}
+
 // handleRawRead is used to read directly from the barrier
 func (b *SystemBackend) handleRawRead(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
@@ -966,6 +1186,11 @@ a user friendly description of the audit backend, and it's configuration options
 		"",
 	},
 
+	"audit_force": {
+		`Required to disable the last remaining audit backend.`,
+		"",
+	},
+
 	"audit": {
 		`Enable or disable audit backends.`,
 		`
@@ -973,6 +1198,79 @@ Enable a new audit backend or disable an existing backend.
 		`,
 	},
 
+	"audit-preview": {
+		"Preview the entries of an audit backend.",
+		`
+Return the request and response entries the audit backend would write for
+the sample auth, request, and response, hashed with its salt and formatted
+with its options, without writing anything. The samples have the structure
+of the "auth", "request", and "response" of the JSON entries. This lets
+the options of a backend be checked before sensitive values are logged.
+		`,
+	},
+
+	"audit_preview_auth": {
+		`The sample auth, such as {"client_token": "..."}.`,
+		"",
+	},
+
+	"audit_preview_request": {
+		`The sample request, such as {"operation": "write", "path": "secret/foo", "data": {...}}.`,
+		"",
+	},
+
+	"audit_preview_response": {
+		`The sample response, such as {"data": {...}}.`,
+		"",
+	},
+
+	"audit_preview_error": {
+		"The sample error of the response.",
+		"",
+	},
+
+	"audit-tune": {
+		"Switch the mode of an audit backend.",
+		`
+Switch the audit backend between the "shadow" mode, in which it is on trial
+and its failures don't fail requests, and the "enforced" mode. The backend
+isn't recreated, so no entry is missed while it is promoted or demoted.
+		`,
+	},
+
+	"audit_mode": {
+		`The mode of the audit backend, "shadow" or "enforced".`,
+		"",
+	},
+
+	"audit-rotate": {
+		"Rotate the salt of an audit backend.",
+		`
+Replace the salt the audit backend hashes values with by a new random salt,
+so that values hashed before can't be correlated with values hashed after.
+The version of the salt is incremented, returned, and written with every
+entry of the backend.
+		`,
+	},
+
+	"audit-entries": {
+		"Read the entries kept by an audit backend.",
+		`
+Return the last entries kept in memory by an audit backend, oldest first.
+Only the "memory" backend keeps its entries.
+		`,
+	},
+
+	"audit-health": {
+		"Read the health of the audit backends.",
+		`
+Return, by path, the number of failed reads and writes of the storage of
+each audit backend since the Vault started, the time each backend last
+logged a request or a response since the Vault was unsealed, and why each
+degraded backend is degraded.
+		`,
+	},
+
 	"key-status": {
 		"Provides information about the backend encryption key.",
 		`
@@ -1,8 +1,11 @@
 package vault
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/logical"
@@ -18,6 +21,11 @@ func TestSystemBackend_RootPaths(t *testing.T) {
 		"policy/*",
 		"audit",
 		"audit/*",
+		"audit-rotate/*",
+		"audit-tune/*",
+		"audit-preview/*",
+		"audit-entries/*",
+		"audit-health",
 		"seal",
 		"raw/*",
 		"rotate",
@@ -576,9 +584,16 @@ func TestSystemBackend_disableAudit(t *testing.T) {
 	}
 	b.HandleRequest(req)
 
-	// Deregister it
+	// Deregister it, which must be forced for the last backend
 	req = logical.TestRequest(t, logical.DeleteOperation, "audit/foo")
 	resp, err := b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+
+	req = logical.TestRequest(t, logical.DeleteOperation, "audit/foo")
+	req.Data["force"] = true
+	resp, err = b.HandleRequest(req)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -600,6 +615,225 @@ func TestSystemBackend_disableAudit_invalid(t *testing.T) {
 	}
 }
 
+func TestSystemBackend_auditTune(t *testing.T) {
+	c, b, _ := testCoreSystemBackend(t)
+	c.auditBackends["noop"] = func(map[string]string) (audit.Backend, error) {
+		return &NoopAudit{}, nil
+	}
+
+	req := logical.TestRequest(t, logical.WriteOperation, "audit/foo")
+	req.Data["type"] = "noop"
+	req.Data["options"] = map[string]interface{}{
+		"mode": "shadow",
+	}
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req = logical.TestRequest(t, logical.WriteOperation, "audit-tune/foo")
+	req.Data["mode"] = "enforced"
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp != nil {
+		t.Fatalf("bad: %v", resp)
+	}
+	if c.auditBroker.backends["foo/"].shadow {
+		t.Fatalf("should not be shadow")
+	}
+
+	req = logical.TestRequest(t, logical.WriteOperation, "audit-tune/foo")
+	req.Data["mode"] = "trial"
+	if _, err := b.HandleRequest(req); err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+
+	req = logical.TestRequest(t, logical.WriteOperation, "audit-tune/bar")
+	req.Data["mode"] = "shadow"
+	resp, err = b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["error"] != "no matching backend" {
+		t.Fatalf("bad: %v", resp)
+	}
+}
+
+func TestSystemBackend_auditPreview(t *testing.T) {
+	c, b, _ := testCoreSystemBackend(t)
+	c.auditBackends["noop"] = func(map[string]string) (audit.Backend, error) {
+		return &NoopAudit{}, nil
+	}
+
+	req := logical.TestRequest(t, logical.WriteOperation, "audit/foo")
+	req.Data["type"] = "noop"
+	req.Data["options"] = map[string]interface{}{
+		"salt":                 "bar",
+		"preserve_format_keys": "card_*",
+	}
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req = logical.TestRequest(t, logical.WriteOperation, "audit-preview/foo")
+	req.Data["request"] = map[string]interface{}{
+		"operation": "write",
+		"path":      "secret/foo",
+		"data": map[string]interface{}{
+			"card_number": "4111-1111-1111-1111",
+			"value":       "baz",
+		},
+	}
+	req.Data["error"] = "permission denied"
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The values are hashed with the salt stored in the view
+	value, _ := audit.HashSHA1("bar")("baz")
+	card, _ := audit.HashPreserveFormat("bar")("4111-1111-1111-1111")
+	for _, field := range []string{"request", "response"} {
+		entry := resp.Data[field].(string)
+		if !strings.Contains(entry, value) || !strings.Contains(entry, card) {
+			t.Fatalf("bad: %s: %s", field, entry)
+		}
+		if strings.Contains(entry, "baz") || strings.Contains(entry, "4111-1111-1111-1111") {
+			t.Fatalf("bad: %s: %s", field, entry)
+		}
+	}
+	if !strings.Contains(resp.Data["response"].(string), "permission denied") {
+		t.Fatalf("bad: %s", resp.Data["response"])
+	}
+
+	// Key material is removed rather than hashed
+	req = logical.TestRequest(t, logical.WriteOperation, "audit-preview/foo")
+	req.Data["request"] = map[string]interface{}{
+		"operation": "write",
+		"path":      "sys/unseal",
+		"data":      map[string]interface{}{"key": "abcd"},
+	}
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	key, _ := audit.HashSHA1("bar")("abcd")
+	for _, field := range []string{"request", "response"} {
+		entry := resp.Data[field].(string)
+		if !strings.Contains(entry, `"data":{"removed":true}`) || strings.Contains(entry, key) {
+			t.Fatalf("bad: %s: %s", field, entry)
+		}
+	}
+
+	req = logical.TestRequest(t, logical.WriteOperation, "audit-preview/baz")
+	resp, err = b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["error"] != "no matching backend" {
+		t.Fatalf("bad: %v", resp)
+	}
+}
+
+// entriesAudit is a NoopAudit that keeps fixed entries
+type entriesAudit struct {
+	NoopAudit
+	entries []map[string]interface{}
+}
+
+func (n *entriesAudit) Entries() ([]map[string]interface{}, error) {
+	return n.entries, nil
+}
+
+func TestSystemBackend_auditEntries(t *testing.T) {
+	c, b, _ := testCoreSystemBackend(t)
+	entries := []map[string]interface{}{
+		map[string]interface{}{"type": "request"},
+		map[string]interface{}{"type": "response"},
+	}
+	c.auditBackends["entries"] = func(map[string]string) (audit.Backend, error) {
+		return &entriesAudit{entries: entries}, nil
+	}
+	c.auditBackends["noop"] = func(map[string]string) (audit.Backend, error) {
+		return &NoopAudit{}, nil
+	}
+
+	// The rate limit is looked through
+	req := logical.TestRequest(t, logical.WriteOperation, "audit/foo")
+	req.Data["type"] = "entries"
+	req.Data["options"] = map[string]interface{}{
+		"max_events_per_second": "10",
+	}
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req = logical.TestRequest(t, logical.WriteOperation, "audit/bar")
+	req.Data["type"] = "noop"
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "audit-entries/foo")
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(resp.Data["entries"], entries) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// A backend that doesn't keep its entries is refused
+	req = logical.TestRequest(t, logical.ReadOperation, "audit-entries/bar")
+	if _, err := b.HandleRequest(req); err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "audit-entries/baz")
+	resp, err = b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["error"] != "no matching backend" {
+		t.Fatalf("bad: %v", resp)
+	}
+}
+
+func TestSystemBackend_auditHealth(t *testing.T) {
+	c, b, _ := testCoreSystemBackend(t)
+	c.auditBackends["degraded"] = func(map[string]string) (audit.Backend, error) {
+		return &degradedAudit{reason: "disk full"}, nil
+	}
+	if !c.AuditHealthy() {
+		t.Fatalf("should be healthy")
+	}
+
+	req := logical.TestRequest(t, logical.WriteOperation, "audit/foo")
+	req.Data["type"] = "degraded"
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c.auditStorageError("foo/", "read", fmt.Errorf("barrier sealed"))
+	if c.AuditHealthy() {
+		t.Fatalf("should not be healthy")
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "audit-health")
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if errs := resp.Data["storage_errors"]; !reflect.DeepEqual(errs, map[string]int{"foo/": 1}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if degraded := resp.Data["degraded"]; !reflect.DeepEqual(degraded, map[string]string{"foo/": "disk full"}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if last := resp.Data["last_success"].(map[string]time.Time); len(last) != 0 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
+
 func TestSystemBackend_rawRead_Protected(t *testing.T) {
 	b := testSystemBackend(t)
 
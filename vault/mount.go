@@ -128,6 +128,13 @@ func (e *MountEntry) Clone() *MountEntry {
 	}
 }
 
+// mountEntriesByPath is used to sort mount entries by their path
+type mountEntriesByPath []*MountEntry
+
+func (s mountEntriesByPath) Len() int           { return len(s) }
+func (s mountEntriesByPath) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s mountEntriesByPath) Less(i, j int) bool { return s[i].Path < s[j].Path }
+
 // Mount is used to mount a new backend to the mount table.
 func (c *Core) mount(me *MountEntry) error {
 	c.mounts.Lock()
@@ -169,7 +176,7 @@ func (c *Core) mount(me *MountEntry) error {
 	c.mounts = newTable
 
 	// Mount the backend
-	if err := c.router.Mount(backend, me.Path, me.UUID, view); err != nil {
+	if err := c.router.Mount(backend, me.Path, me.Type, me.UUID, view); err != nil {
 		return err
 	}
 	c.logger.Printf("[INFO] core: mounted '%s' type: %s", me.Path, me.Type)
@@ -430,7 +437,7 @@ func (c *Core) setupMounts() error {
 		}
 
 		// Mount the backend
-		err = c.router.Mount(backend, entry.Path, entry.UUID, view)
+		err = c.router.Mount(backend, entry.Path, entry.Type, entry.UUID, view)
 		if err != nil {
 			c.logger.Printf("[ERR] core: failed to mount entry %#v: %v", entry, err)
 			return loadMountsFailed
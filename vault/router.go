@@ -31,6 +31,7 @@ func NewRouter() *Router {
 type mountEntry struct {
 	tainted    bool
 	salt       string
+	mountType  string
 	backend    logical.Backend
 	view       *BarrierView
 	rootPaths  *radix.Tree
@@ -44,9 +45,9 @@ func (me *mountEntry) SaltID(id string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// Mount is used to expose a logical backend at a given prefix, using a unique salt,
-// and the barrier view for that path.
-func (r *Router) Mount(backend logical.Backend, prefix, salt string, view *BarrierView) error {
+// Mount is used to expose a logical backend of the given type at a given prefix,
+// using a unique salt, and the barrier view for that path.
+func (r *Router) Mount(backend logical.Backend, prefix, mountType, salt string, view *BarrierView) error {
 	r.l.Lock()
 	defer r.l.Unlock()
 
@@ -64,6 +65,7 @@ func (r *Router) Mount(backend logical.Backend, prefix, salt string, view *Barri
 	// Create a mount entry
 	me := &mountEntry{
 		tainted:    false,
+		mountType:  mountType,
 		backend:    backend,
 		view:       view,
 		rootPaths:  pathsToRadix(paths.Root),
@@ -132,6 +134,18 @@ func (r *Router) MatchingMount(path string) string {
 	return mount
 }
 
+// MatchingMountType returns the mount prefix and the type of the backend
+// that would be used for a path
+func (r *Router) MatchingMountType(path string) (string, string) {
+	r.l.RLock()
+	mount, raw, ok := r.root.LongestPrefix(path)
+	r.l.RUnlock()
+	if !ok {
+		return "", ""
+	}
+	return mount, raw.(*mountEntry).mountType
+}
+
 // MatchingView returns the view used for a path
 func (r *Router) MatchingView(path string) *BarrierView {
 	r.l.RLock()
@@ -177,6 +191,7 @@ func (r *Router) Route(req *logical.Request) (*logical.Response, error) {
 
 	// Adjust the path to exclude the routing prefix
 	original := req.Path
+	originalMount := req.MountPoint
 	req.Path = strings.TrimPrefix(req.Path, mount)
 	req.MountPoint = mount
 	if req.Path == "/" {
@@ -201,7 +216,7 @@ func (r *Router) Route(req *logical.Request) (*logical.Response, error) {
 	// Reset the request before returning
 	defer func() {
 		req.Path = original
-		req.MountPoint = ""
+		req.MountPoint = originalMount
 		req.Connection = originalConn
 		req.Storage = nil
 		req.ClientToken = clientToken
@@ -52,12 +52,12 @@ func TestRouter_Mount(t *testing.T) {
 	view := NewBarrierView(barrier, "logical/")
 
 	n := &NoopBackend{}
-	err := r.Mount(n, "prod/aws/", generateUUID(), view)
+	err := r.Mount(n, "prod/aws/", "noop", generateUUID(), view)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
-	err = r.Mount(n, "prod/aws/", generateUUID(), view)
+	err = r.Mount(n, "prod/aws/", "noop", generateUUID(), view)
 	if !strings.Contains(err.Error(), "cannot mount under existing mount") {
 		t.Fatalf("err: %v", err)
 	}
@@ -70,6 +70,10 @@ func TestRouter_Mount(t *testing.T) {
 		t.Fatalf("bad: %s", v)
 	}
 
+	if path, mountType := r.MatchingMountType("prod/aws/foo"); path != "prod/aws/" || mountType != "noop" {
+		t.Fatalf("bad: %s %s", path, mountType)
+	}
+
 	if path := r.MatchingMount("stage/aws/foo"); path != "" {
 		t.Fatalf("bad: %s", path)
 	}
@@ -78,6 +82,10 @@ func TestRouter_Mount(t *testing.T) {
 		t.Fatalf("bad: %s", v)
 	}
 
+	if path, mountType := r.MatchingMountType("stage/aws/foo"); path != "" || mountType != "" {
+		t.Fatalf("bad: %s %s", path, mountType)
+	}
+
 	req := &logical.Request{
 		Path: "prod/aws/foo",
 	}
@@ -101,7 +109,7 @@ func TestRouter_Unmount(t *testing.T) {
 	view := NewBarrierView(barrier, "logical/")
 
 	n := &NoopBackend{}
-	err := r.Mount(n, "prod/aws/", generateUUID(), view)
+	err := r.Mount(n, "prod/aws/", "noop", generateUUID(), view)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -126,7 +134,7 @@ func TestRouter_Remount(t *testing.T) {
 	view := NewBarrierView(barrier, "logical/")
 
 	n := &NoopBackend{}
-	err := r.Mount(n, "prod/aws/", generateUUID(), view)
+	err := r.Mount(n, "prod/aws/", "noop", generateUUID(), view)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -174,7 +182,7 @@ func TestRouter_RootPath(t *testing.T) {
 			"policy/*",
 		},
 	}
-	err := r.Mount(n, "prod/aws/", generateUUID(), view)
+	err := r.Mount(n, "prod/aws/", "noop", generateUUID(), view)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -212,7 +220,7 @@ func TestRouter_LoginPath(t *testing.T) {
 			"oauth/*",
 		},
 	}
-	err := r.Mount(n, "auth/foo/", generateUUID(), view)
+	err := r.Mount(n, "auth/foo/", "noop", generateUUID(), view)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -243,7 +251,7 @@ func TestRouter_Taint(t *testing.T) {
 	view := NewBarrierView(barrier, "logical/")
 
 	n := &NoopBackend{}
-	err := r.Mount(n, "prod/aws/", generateUUID(), view)
+	err := r.Mount(n, "prod/aws/", "noop", generateUUID(), view)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -282,7 +290,7 @@ func TestRouter_Untaint(t *testing.T) {
 	view := NewBarrierView(barrier, "logical/")
 
 	n := &NoopBackend{}
-	err := r.Mount(n, "prod/aws/", generateUUID(), view)
+	err := r.Mount(n, "prod/aws/", "noop", generateUUID(), view)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
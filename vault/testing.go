@@ -1,13 +1,17 @@
 package vault
 
 import (
+	"fmt"
 	"log"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	"github.com/hashicorp/vault/physical"
+	"golang.org/x/net/context"
 )
 
 // This file contains a number of methods that are useful for unit
@@ -84,13 +88,53 @@ func TestKeyCopy(key []byte) []byte {
 	return result
 }
 
+// TestAuditClock is a clock for an AuditBroker that only moves when it is
+// advanced, so that tests of the times the broker sees are deterministic.
+// Its Now method is the Clock of an AuditBrokerConfig.
+type TestAuditClock struct {
+	l   sync.Mutex
+	now time.Time
+}
+
+// NewTestAuditClock returns a clock stopped at the given time.
+func NewTestAuditClock(now time.Time) *TestAuditClock {
+	return &TestAuditClock{now: now}
+}
+
+// Now returns the time of the clock.
+func (c *TestAuditClock) Now() time.Time {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by the given duration.
+func (c *TestAuditClock) Advance(d time.Duration) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// TestAuditIDs returns a source of identifiers for an AuditBroker that
+// returns the prefix followed by 1, 2, and so on.
+func TestAuditIDs(prefix string) func() string {
+	var l sync.Mutex
+	var n int
+	return func() string {
+		l.Lock()
+		defer l.Unlock()
+		n++
+		return fmt.Sprintf("%s%d", prefix, n)
+	}
+}
+
 type noopAudit struct{}
 
-func (n *noopAudit) LogRequest(a *logical.Auth, r *logical.Request) error {
+func (n *noopAudit) LogRequest(ctx context.Context, a *logical.Auth, r *logical.Request) error {
 	return nil
 }
 
-func (n *noopAudit) LogResponse(a *logical.Auth, r *logical.Request, re *logical.Response, err error) error {
+func (n *noopAudit) LogResponse(ctx context.Context, a *logical.Auth, r *logical.Request, re *logical.Response, err error) error {
 	return nil
 }
 
@@ -20,7 +20,7 @@ func mockTokenStore(t *testing.T) (*Core, *TokenStore, string) {
 	}
 
 	router := NewRouter()
-	router.Mount(ts, "auth/token/", "", ts.view)
+	router.Mount(ts, "auth/token/", "token", "", ts.view)
 
 	view := c.systemView.SubView(expirationSubPath)
 	exp := NewExpirationManager(router, view, ts, logger)
@@ -219,7 +219,7 @@ func TestTokenStore_Revoke_Leases(t *testing.T) {
 
 	// Mount a noop backend
 	noop := &NoopBackend{}
-	ts.expiration.router.Mount(noop, "", "", nil)
+	ts.expiration.router.Mount(noop, "", "noop", "", nil)
 
 	ent := &TokenEntry{Path: "test", Policies: []string{"dev", "ops"}}
 	if err := ts.Create(ent); err != nil {